@@ -1,54 +1,175 @@
 package app
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/HouzuoGuo/saptune/sap/note"
 	"github.com/HouzuoGuo/saptune/sap/solution"
+	"github.com/HouzuoGuo/saptune/system"
 	"github.com/HouzuoGuo/saptune/txtparser"
+	"io"
 	"io/ioutil"
+	"log"
 	"os"
+	"os/exec"
 	"path"
 	"reflect"
+	"regexp"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
-	SysconfigSaptuneDir = "/etc/sysconfig/saptune"
-	TuneForSolutionsKey = "TUNE_FOR_SOLUTIONS"
-	TuneForNotesKey     = "TUNE_FOR_NOTES"
+	SysconfigSaptuneDir   = "/etc/sysconfig/saptune"
+	TuneForSolutionsKey   = "TUNE_FOR_SOLUTIONS"
+	TuneForNotesKey       = "TUNE_FOR_NOTES"
+	SolutionSelectorKey   = "SOLUTION_SELECTOR"
+	AdHocNotesKey         = "AD_HOC_NOTES"
+	SolutionExclusionsKey = "SOLUTION_EXCLUSIONS"
+	StagedNotesKey        = "STAGED_NOTES"
+	// SystemctlRetriesKey and SystemctlRetryIntervalKey (in seconds) tune the retrying of transient systemctl failures.
+	SystemctlRetriesKey       = "SYSTEMCTL_RETRIES"
+	SystemctlRetryIntervalKey = "SYSTEMCTL_RETRY_INTERVAL"
+	// SAPProductVersionKey names the version of the installed SAP product, SAPProductVersionEnv overrides it.
+	SAPProductVersionKey = "SAP_PRODUCT_VERSION"
+	SAPProductVersionEnv = "SAPTUNE_SAP_PRODUCT_VERSION"
+	// ExternalCheckKeyPrefix is followed by a solution name, the value is a checker command run along with verifying the solution.
+	ExternalCheckKeyPrefix = "EXTERNAL_CHECK_"
+	// DefaultParallel is the number of notes that bulk operations inspect at a time unless told otherwise.
+	DefaultParallel = 4
 )
 
 // Application configuration and serialised state information.
 type App struct {
-	SysconfigPrefix  string
-	AllNotes         map[string]note.Note         // all notes
-	AllSolutions     map[string]solution.Solution // all solutions
-	TuneForSolutions []string                     // list of solution names to tune, must always be sorted in ascending order.
-	TuneForNotes     []string                     // list of additional notes to tune, must always be sorted in ascending order.
-	State            *State                       // examine and manage serialised notes.
+	SysconfigPrefix        string
+	AllNotes               map[string]note.Note         // all notes
+	AllSolutions           map[string]solution.Solution // all solutions
+	TuneForSolutions       []string                     // list of solution names to tune, must always be sorted in ascending order.
+	TuneForNotes           []string                     // list of additional notes to tune, must always be sorted in ascending order.
+	SolutionSelector       string                       // solution selector (e.g. amd64_PC) in effect when a solution was last applied.
+	AdHocNotes             map[string]string            // note ID VS path to note definition applied from outside of the tuning sheet directory.
+	SolutionExclusions     map[string][]string          // solution name VS IDs of its notes that are not to be applied.
+	SkippedNotes           map[string]string            // note ID VS reason, left out of the solutions tuned by this invocation only, not saved.
+	ExternalChecks         map[string]string            // solution name VS external checker command run along with verification.
+	StagedNotes            []string                     // additional notes desired to be tuned, sorted. nil if nothing has been staged.
+	SystemctlRetries       int                          // number of times a transient systemctl failure is retried.
+	SystemctlRetryInterval time.Duration                // pause before the first retry of a transient systemctl failure.
+	MaintenanceWindows     string                       // time ranges in which tuning is allowed, see MaintenanceWindowsKey. Empty for any time.
+	NoteStaging            bool                         // hold back updated note definitions until they are released, see NoteStagingKey.
+	SAPProductVersion      string                       // version of the installed SAP product, empty if unknown.
+	Progress               io.Writer                    // receives progress of long-running operations, nil for no progress.
+	Inspector              NoteInspector                // determines the current parameter values during verification, nil for the live system.
+	BackupDir              string                       // receives a backup of the parameter values before a note is applied, empty for none.
+	Parallel               int                          // number of notes that bulk operations inspect at a time, 1 for one after another.
+	ConfirmHighRisk        HighRiskConfirmer            // approves changing high-risk parameters, nil to approve without asking.
+	ReadbackReporter       ReadbackReporter             // receives the readback of each note applied, nil to ignore it.
+	IgnorePackages         bool                         // apply and verify notes even if software packages they require are not installed.
+	State                  *State                       // examine and manage serialised notes.
 }
 
 // Load application configuration. Panic on error.
 func InitialiseApp(sysconfigPrefix, stateDirPrefix string, allNotes map[string]note.Note, allSolutions map[string]solution.Solution) (app *App) {
 	app = &App{
-		SysconfigPrefix: sysconfigPrefix,
-		State:           &State{StateDirPrefix: stateDirPrefix},
-		AllNotes:        allNotes,
-		AllSolutions:    allSolutions,
+		SysconfigPrefix:        sysconfigPrefix,
+		State:                  &State{StateDirPrefix: stateDirPrefix},
+		AllNotes:               allNotes,
+		AllSolutions:           allSolutions,
+		SystemctlRetries:       system.SystemctlRetries,
+		SystemctlRetryInterval: system.SystemctlRetryInterval,
+		Parallel:               DefaultParallel,
 	}
-	sysconf, err := txtparser.ParseSysconfigFile(path.Join(app.SysconfigPrefix, SysconfigSaptuneDir), true)
+	sysconf, err := app.readSysconfig()
 	if err == nil {
 		app.TuneForSolutions = sysconf.GetStringArray(TuneForSolutionsKey, []string{})
 		app.TuneForNotes = sysconf.GetStringArray(TuneForNotesKey, []string{})
+		app.SolutionSelector = sysconf.GetString(SolutionSelectorKey, "")
+		app.MaintenanceWindows = sysconf.GetString(MaintenanceWindowsKey, "")
+		app.NoteStaging = sysconf.GetBool(NoteStagingKey, false)
+		app.SAPProductVersion = sysconf.GetString(SAPProductVersionKey, "")
+		app.AdHocNotes = make(map[string]string)
+		for _, idPath := range sysconf.GetStringArray(AdHocNotesKey, []string{}) {
+			if fields := strings.SplitN(idPath, ":", 2); len(fields) == 2 {
+				app.AdHocNotes[fields[0]] = fields[1]
+			}
+		}
+		app.SolutionExclusions = make(map[string][]string)
+		for _, solNote := range sysconf.GetStringArray(SolutionExclusionsKey, []string{}) {
+			if fields := strings.SplitN(solNote, ":", 2); len(fields) == 2 {
+				app.SolutionExclusions[fields[0]] = append(app.SolutionExclusions[fields[0]], fields[1])
+			}
+		}
+		if _, exists := sysconf.KeyValue[StagedNotesKey]; exists {
+			app.StagedNotes = sysconf.GetStringArray(StagedNotesKey, []string{})
+			sort.Strings(app.StagedNotes)
+		}
+		if retries := sysconf.GetInt(SystemctlRetriesKey, app.SystemctlRetries); retries >= 0 {
+			app.SystemctlRetries = retries
+		}
+		if interval := sysconf.GetInt(SystemctlRetryIntervalKey, -1); interval >= 0 {
+			app.SystemctlRetryInterval = time.Duration(interval) * time.Second
+		}
+		app.ExternalChecks = make(map[string]string)
+		for _, entry := range sysconf.AllValues {
+			if solName := strings.TrimPrefix(entry.Key, ExternalCheckKeyPrefix); solName != entry.Key && solName != "" && entry.Value != "" {
+				app.ExternalChecks[solName] = entry.Value
+			}
+		}
 	} else {
+		app.AdHocNotes = make(map[string]string)
+		app.SolutionExclusions = make(map[string][]string)
+		app.ExternalChecks = make(map[string]string)
 		app.TuneForSolutions = []string{}
 		app.TuneForNotes = []string{}
 	}
+	if version := os.Getenv(SAPProductVersionEnv); version != "" {
+		app.SAPProductVersion = version
+	}
 	sort.Strings(app.TuneForSolutions)
 	sort.Strings(app.TuneForNotes)
 	return
 }
 
+/*
+Parse /etc/sysconfig/saptune. If the file is empty or damaged, e.g. after an editing mistake, the previous content kept
+by SaveConfig is restored with a warning. The damaged content is kept next to the backup for inspection.
+*/
+func (app *App) readSysconfig() (*txtparser.Sysconfig, error) {
+	fileName := path.Join(app.SysconfigPrefix, SysconfigSaptuneDir)
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil || !isSysconfigDamaged(string(content)) {
+		return txtparser.ParseSysconfigFile(fileName, true)
+	}
+	backupPath := path.Join(app.State.StateDirPrefix, SysconfigBackupFile)
+	backup, err := ioutil.ReadFile(backupPath)
+	if err != nil || isSysconfigDamaged(string(backup)) {
+		log.Printf("Warning: %s is empty or damaged, and there is no usable backup in %s to restore it from", fileName, backupPath)
+		return txtparser.ParseSysconfig(string(content))
+	}
+	if err := writeFileAtomicBackup(fileName, backupPath+SysconfigDamagedSuffix, backup, 0644); err != nil {
+		log.Printf("Warning: %s is empty or damaged, its previous content in %s is used but cannot be restored - %v", fileName, backupPath, err)
+	} else {
+		log.Printf("Warning: %s was empty or damaged, its previous content has been restored from %s. The damaged content is kept in %s.", fileName, backupPath, backupPath+SysconfigDamagedSuffix)
+	}
+	return txtparser.ParseSysconfig(string(backup))
+}
+
+// Return true if the sysconfig text is blank or has a line that is neither a comment nor an assignment.
+func isSysconfigDamaged(content string) bool {
+	if strings.TrimSpace(content) == "" {
+		return true
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") && !strings.Contains(line, "=") {
+			return true
+		}
+	}
+	return false
+}
+
 // Save /etc/sysconfig/saptune.
 func (app *App) SaveConfig() error {
 	sysconf, err := txtparser.ParseSysconfigFile(path.Join(app.SysconfigPrefix, SysconfigSaptuneDir), true)
@@ -57,14 +178,48 @@ func (app *App) SaveConfig() error {
 	}
 	sysconf.SetStrArray(TuneForSolutionsKey, app.TuneForSolutions)
 	sysconf.SetStrArray(TuneForNotesKey, app.TuneForNotes)
-	return ioutil.WriteFile(path.Join(app.SysconfigPrefix, SysconfigSaptuneDir), []byte(sysconf.ToText()), 0644)
+	if app.SolutionSelector != "" {
+		sysconf.Set(SolutionSelectorKey, app.SolutionSelector)
+	}
+	if _, exists := sysconf.KeyValue[AdHocNotesKey]; exists || len(app.AdHocNotes) > 0 {
+		adHocNotes := make([]string, 0, len(app.AdHocNotes))
+		for noteID, filePath := range app.AdHocNotes {
+			adHocNotes = append(adHocNotes, noteID+":"+filePath)
+		}
+		sort.Strings(adHocNotes)
+		sysconf.SetStrArray(AdHocNotesKey, adHocNotes)
+	}
+	if _, exists := sysconf.KeyValue[SolutionExclusionsKey]; exists || len(app.SolutionExclusions) > 0 {
+		exclusions := make([]string, 0, 0)
+		for solName, noteIDs := range app.SolutionExclusions {
+			for _, noteID := range noteIDs {
+				exclusions = append(exclusions, solName+":"+noteID)
+			}
+		}
+		sort.Strings(exclusions)
+		sysconf.SetStrArray(SolutionExclusionsKey, exclusions)
+	}
+	if _, exists := sysconf.KeyValue[StagedNotesKey]; exists || app.StagedNotes != nil {
+		sysconf.SetStrArray(StagedNotesKey, app.StagedNotes)
+	}
+	// The backup is kept out of /etc/sysconfig, where other programs may pick up every file
+	return writeFileAtomicBackup(path.Join(app.SysconfigPrefix, SysconfigSaptuneDir), path.Join(app.State.StateDirPrefix, SysconfigBackupFile),
+		[]byte(sysconf.ToText()), 0644)
+}
+
+// Write the progress of a long-running operation, unless progress is not wanted.
+func (app *App) reportProgress(template string, stuff ...interface{}) {
+	if app.Progress != nil {
+		fmt.Fprintf(app.Progress, template, stuff...)
+	}
 }
 
 // Return the number of all solution-enabled SAP notes, sorted.
 func (app *App) GetSortedSolutionEnabledNotes() (allNoteIDs []string) {
 	allNoteIDs = make([]string, 0, 0)
 	for _, sol := range app.TuneForSolutions {
-		for _, noteID := range app.AllSolutions[sol] {
+		solNotes, _ := app.GetSolutionByName(sol)
+		for _, noteID := range solNotes {
 			if i := sort.SearchStrings(allNoteIDs, noteID); !(i < len(allNoteIDs) && allNoteIDs[i] == noteID) {
 				allNoteIDs = append(allNoteIDs, noteID)
 				sort.Strings(allNoteIDs)
@@ -74,12 +229,24 @@ func (app *App) GetSortedSolutionEnabledNotes() (allNoteIDs []string) {
 	return
 }
 
+// Return the number of all enabled SAP notes, both solution-enabled and additional ones, sorted and without duplicates.
+func (app *App) GetSortedAllEnabledNotes() (allNoteIDs []string) {
+	allNoteIDs = app.GetSortedSolutionEnabledNotes()
+	for _, noteID := range app.TuneForNotes {
+		if i := sort.SearchStrings(allNoteIDs, noteID); !(i < len(allNoteIDs) && allNoteIDs[i] == noteID) {
+			allNoteIDs = append(allNoteIDs, noteID)
+			sort.Strings(allNoteIDs)
+		}
+	}
+	return
+}
+
 // Return the note corresponding to the number, or an error if the note does not exist.
 func (app *App) GetNoteByID(id string) (note.Note, error) {
 	if n, exists := app.AllNotes[id]; exists {
 		return n, nil
 	}
-	return nil, fmt.Errorf(`Note ID "%s" is not recognised by saptune.
+	return nil, newError(ErrNoteNotFound, nil, `Note ID "%s" is not recognised by saptune.
 Run "saptune note list" for a complete list of supported notes.
 and then please double check your input and /etc/sysconfig/saptune.`, id)
 }
@@ -87,9 +254,20 @@ and then please double check your input and /etc/sysconfig/saptune.`, id)
 // Return the solution corresponding to the name, or an error if it does not exist.
 func (app *App) GetSolutionByName(name string) (solution.Solution, error) {
 	if n, exists := app.AllSolutions[name]; exists {
-		return n, nil
+		// Leave out the notes excluded from the solution
+		sol := make(solution.Solution, 0, len(n))
+		for _, noteID := range n {
+			excluded := false
+			for _, excludedID := range app.SolutionExclusions[name] {
+				excluded = excluded || excludedID == noteID
+			}
+			if !excluded {
+				sol = append(sol, noteID)
+			}
+		}
+		return sol, nil
 	}
-	return nil, fmt.Errorf(`Solution name "%s" is not recognised by saptune.
+	return nil, newError(ErrSolutionNotFound, nil, `Solution name "%s" is not recognised by saptune.
 Run "saptune solution list" for a complete list of supported solutions,
 and then please double check your input and /etc/sysconfig/saptune.`, name)
 }
@@ -100,9 +278,35 @@ If the note is not yet covered by one of the enabled solutions, the note number
 added into the list of additional notes.
 */
 func (app *App) TuneNote(noteID string) error {
+	_, err := app.TuneNoteReadback(noteID)
+	return err
+}
+
+/*
+Apply tuning for a note like TuneNote, and return the parameter values read back from the system right after they
+were written, comparison name VS comparison. The expected value of a comparison is the value that was written, the
+actual value is the value read back, a mismatch means the system did not accept the value. The readback is nil if the
+system already complied with the note, as nothing was written.
+*/
+func (app *App) TuneNoteReadback(noteID string) (readback map[string]note.NoteFieldComparison, err error) {
+	readback, _, err = app.tuneNote(noteID, false)
+	return
+}
+
+/*
+Apply tuning for a note like TuneNoteReadback, but only write the parameters whose current value differs from the
+desired one. Return the comparison names of the parameters that were left alone, they are not part of the readback.
+A note that cannot apply a subset of its parameters is written in full.
+*/
+func (app *App) TuneNoteIfChanged(noteID string) (readback map[string]note.NoteFieldComparison, skipped []string, err error) {
+	return app.tuneNote(noteID, true)
+}
+
+// Enable the note unless it is already enabled, and then apply it. Only deviating parameters are written if ifChanged.
+func (app *App) tuneNote(noteID string, ifChanged bool) (readback map[string]note.NoteFieldComparison, skipped []string, err error) {
 	aNote, err := app.GetNoteByID(noteID)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	solNotes := app.GetSortedSolutionEnabledNotes()
 	searchInSol := sort.SearchStrings(solNotes, noteID)
@@ -111,203 +315,1336 @@ func (app *App) TuneNote(noteID string) error {
 		// Note is not covered by any of the existing solution, hence adding it into the additions' list
 		app.TuneForNotes = append(app.TuneForNotes, noteID)
 		sort.Strings(app.TuneForNotes)
+		app.syncStagedNote(noteID, true)
 		if err := app.SaveConfig(); err != nil {
-			return err
+			return nil, nil, newError(ErrStateFailed, err, "%v", err)
+		}
+	}
+	return app.applyNote(noteID, aNote, true, ifChanged)
+}
+
+/*
+Apply the note and verify it right away. If the system does not conform to the note afterwards, e.g. because the
+kernel rejected some of the values, permanently revert the note and return the deviating parameters. Parameters that
+only take effect after a reboot are not considered deviating.
+*/
+func (app *App) TuneNoteVerified(noteID string) (deviations map[string]note.NoteFieldComparison, err error) {
+	deviations = make(map[string]note.NoteFieldComparison)
+	readback, err := app.TuneNoteReadback(noteID)
+	if err != nil {
+		return
+	}
+	aNote, _ := app.GetNoteByID(noteID)
+	for name, comparison := range GetRejected(readback) {
+		if !note.IsRebootRequired(aNote, comparison) {
+			deviations[name] = comparison
 		}
 	}
+	if len(deviations) > 0 {
+		err = app.RevertNote(noteID, true)
+	}
+	return
+}
+
+/*
+Apply tuning for a note without enabling it and without saving its state, the note can neither be reverted nor
+will it be applied again by the daemon.
+*/
+func (app *App) TuneNoteEphemeral(noteID string) error {
+	aNote, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return err
+	}
+	_, _, err = app.applyNote(noteID, aNote, false, false)
+	return err
+}
+
+/*
+Apply the optimised parameters of a note. Save the state beforehand and record the apply time if persistent. If
+ifChanged, only the deviating parameters are written and the comparison names of the others are returned as skipped.
+*/
+func (app *App) applyNote(noteID string, aNote note.Note, persistent, ifChanged bool) (readback map[string]note.NoteFieldComparison, skipped []string, err error) {
 	/*
 		Do not apply the note if system already complies with the requirements.
 		Otherwise, the state file (serialised parameters) will be overwritten, and it will no longer
 		be possible to revert the note to the state before it was tuned.
 	*/
-	if conforming, _, err := app.VerifyNote(noteID); err != nil {
-		return err
+	// Verification does not consider the parameters of a note on an unsupported kernel, hence check it beforehand
+	if err := note.CheckKernelVersion(aNote); err != nil {
+		return nil, nil, newError(ErrKernelUnsupported, err, "Refusing to apply note %s - %v", noteID, err)
+	}
+	if err := note.CheckRequiredPackages(aNote); err != nil && !app.IgnorePackages {
+		return nil, nil, newError(ErrPackageMissing, err, "Note %s: %v, skipping", noteID, err)
+	}
+	conforming, comparisons, err := app.VerifyNote(noteID)
+	if err != nil {
+		return nil, nil, err
 	} else if conforming {
-		return nil
+		return nil, nil, nil
+	}
+	if changes := GetHighRiskChanges(aNote, comparisons); len(changes) > 0 && app.ConfirmHighRisk != nil && !app.ConfirmHighRisk(noteID, changes) {
+		names := make([]string, 0, len(changes))
+		for _, change := range changes {
+			names = append(names, change.Param)
+		}
+		return nil, nil, newError(ErrNotConfirmed, nil, "Refusing to apply note %s, changing high-risk parameter(s) %s has not been confirmed", noteID, strings.Join(names, ", "))
 	}
 	// Save current state before applying optimisation
 	currentState, err := aNote.Initialise()
 	if err != nil {
-		return fmt.Errorf("Failed to examine system for the current status of note %s - %v", noteID, err)
-	} else if err = app.State.Store(noteID, currentState, false); err != nil {
-		return fmt.Errorf("Failed to save current state of note %s - %v", noteID, err)
+		return nil, nil, newError(ErrInspectionFailed, err, "Failed to examine system for the current status of note %s - %v", noteID, err)
+	}
+	if app.BackupDir != "" {
+		backupPath, err := app.writeNoteBackup(noteID, currentState)
+		if err != nil {
+			return nil, nil, newError(ErrStateFailed, err, "Failed to back up the current state of note %s - %v", noteID, err)
+		}
+		log.Printf("The parameter values before applying note %s have been backed up into %s", noteID, backupPath)
 	}
-	optimised, err := currentState.Optimise()
+	if persistent {
+		if err = app.State.Store(noteID, currentState, false); err != nil {
+			return nil, nil, newError(ErrStateFailed, err, "Failed to save current state of note %s - %v", noteID, err)
+		}
+	}
+	optimised, err := app.optimiseNote(noteID, currentState)
 	if err != nil {
-		return fmt.Errorf("Failed to calculate optimised parameters for note %s - %v", noteID, err)
+		return nil, nil, newError(ErrInspectionFailed, err, "Failed to calculate optimised parameters for note %s - %v", noteID, err)
 	}
-	if err := optimised.Apply(); err != nil {
-		return fmt.Errorf("Failed to apply note %s - %v", noteID, err)
+	if ifChanged {
+		skipped, err = note.ApplyDeviating(optimised, comparisons)
+	} else {
+		err = optimised.Apply()
 	}
-
-	return nil
+	// The environment may withhold the capabilities for some parameters, the rest of the note has been applied
+	limited, err := takeEnvironmentLimited(noteID, err)
+	if err != nil {
+		return nil, nil, newError(ErrApplyDenied, err, "Failed to apply note %s - %v", noteID, err)
+	}
+	// Read the values back, the kernel may silently clamp or reject some of them
+	appliedState, err := aNote.Initialise()
+	if err != nil {
+		return nil, nil, newError(ErrInspectionFailed, err, "Failed to read back the parameters of note %s - %v", noteID, err)
+	}
+	_, readback = note.CompareNoteFields(appliedState, optimised)
+	note.MarkNotApplicable(aNote, readback)
+	markEnvironmentLimited(readback, limited)
+	appliedValues := make(map[string]string)
+	for name, comparison := range note.FilterParameters(readback) {
+		appliedValues[name] = comparison.ActualValueJS
+	}
+	for _, name := range skipped {
+		delete(readback, name)
+	}
+	if app.ReadbackReporter != nil {
+		app.ReadbackReporter(noteID, readback)
+	}
+	if persistent {
+		if err := app.State.StoreApplyTime(noteID); err != nil {
+			return nil, nil, newError(ErrStateFailed, err, "Failed to record the apply time of note %s - %v", noteID, err)
+		}
+		if err := app.State.StoreAppliedValues(noteID, appliedValues); err != nil {
+			return nil, nil, newError(ErrStateFailed, err, "Failed to record the applied values of note %s - %v", noteID, err)
+		}
+		if err := app.State.StoreRejected(noteID, GetRejected(readback)); err != nil {
+			return nil, nil, newError(ErrStateFailed, err, "Failed to record the rejected parameters of note %s - %v", noteID, err)
+		}
+		if err := app.recordRelease(noteID, aNote); err != nil {
+			return nil, nil, err
+		}
+	}
+	return readback, skipped, nil
 }
 
 /*
-Apply tuning for a solution.
-If the solution is not yet enabled, the name will be added into the list of tuned solution names.
-If the solution covers any of the additional notes, those notes will be removed.
+Log the parameters that the environment did not permit to write if the error of applying or reverting the note tells
+so, and return them. The error is then cleared, as the rest of the note has taken effect.
 */
-func (app *App) TuneSolution(solName string) (removedExplicitNotes []string, err error) {
-	removedExplicitNotes = make([]string, 0, 0)
-	sol, err := app.GetSolutionByName(solName)
-	if err != nil {
-		return
+func takeEnvironmentLimited(noteID string, err error) ([]system.CapabilityError, error) {
+	var limitedErr note.EnvironmentLimitedError
+	if !errors.As(err, &limitedErr) {
+		return nil, err
 	}
-	if i := sort.SearchStrings(app.TuneForSolutions, solName); !(i < len(app.TuneForSolutions) && app.TuneForSolutions[i] == solName) {
-		app.TuneForSolutions = append(app.TuneForSolutions, solName)
-		sort.Strings(app.TuneForSolutions)
-		if err = app.SaveConfig(); err != nil {
-			return
-		}
+	for _, capErr := range limitedErr.Limited {
+		log.Printf("Note %s - %v", noteID, capErr)
 	}
-	for _, noteID := range sol {
-		// Remove solution's notes from additional notes list.
-		if i := sort.SearchStrings(app.TuneForNotes, noteID); i < len(app.TuneForNotes) && app.TuneForNotes[i] == noteID {
-			app.TuneForNotes = append(app.TuneForNotes[0:i], app.TuneForNotes[i+1:]...)
-			removedExplicitNotes = append(removedExplicitNotes, noteID)
-			if err = app.SaveConfig(); err != nil {
-				return
+	return limitedErr.Limited, nil
+}
+
+/*
+Mark the deviating comparisons of a readback whose parameters the environment did not permit to write, giving the
+missing capability as the reason why they are not applicable. Unlike other inapplicable parameters they still deviate.
+*/
+func markEnvironmentLimited(readback map[string]note.NoteFieldComparison, limited []system.CapabilityError) {
+	for _, capErr := range limited {
+		for name, comparison := range readback {
+			if !comparison.MatchExpectation && note.GetParamName(comparison) == capErr.Param {
+				comparison.NotApplicable = capErr.Error()
+				readback[name] = comparison
 			}
 		}
-		if err = app.TuneNote(noteID); err != nil {
-			return
-		}
 	}
-	return
 }
 
-// Tune for all currently enabled solutions and notes.
-func (app *App) TuneAll() error {
-	for _, solName := range app.TuneForSolutions {
-		sol, err := app.GetSolutionByName(solName)
-		if err != nil {
-			return err
-		}
-		for _, noteID := range sol {
-			if err = app.TuneNote(noteID); err != nil {
-				return err
-			}
+/*
+Return the comparisons of a readback whose values were not accepted by the system, comparison name VS comparison.
+Parameters that the environment did not permit to write are not among them, see GetEnvironmentLimited.
+*/
+func GetRejected(readback map[string]note.NoteFieldComparison) map[string]note.NoteFieldComparison {
+	rejected := make(map[string]note.NoteFieldComparison)
+	for name, comparison := range readback {
+		if !comparison.MatchExpectation && comparison.NotApplicable == "" {
+			rejected[name] = comparison
 		}
 	}
-	for _, noteID := range app.TuneForNotes {
-		if err := app.TuneNote(noteID); err != nil {
-			return err
+	return rejected
+}
+
+/*
+Return the comparisons of a readback whose parameters could not be written because the environment lacks the
+capabilities to do so, comparison name VS comparison. The reason is given by NotApplicable of each comparison.
+*/
+func GetEnvironmentLimited(readback map[string]note.NoteFieldComparison) map[string]note.NoteFieldComparison {
+	limited := make(map[string]note.NoteFieldComparison)
+	for name, comparison := range readback {
+		if !comparison.MatchExpectation && comparison.NotApplicable != "" {
+			limited[name] = comparison
 		}
 	}
-	return nil
+	return limited
 }
 
-// Revert parameters tuned by the note and clear its stored states.
-func (app *App) RevertNote(noteID string, permanent bool) error {
-	noteTemplate, err := app.GetNoteByID(noteID)
+/*
+Re-apply an enabled note after its definition has changed, e.g. after a tuning sheet was edited. The current
+definition is compared against the values recorded when the note was last applied, and only the fields whose value
+changed are re-applied, hence parameters that merely drifted on the system are left alone. Without such a record, e.g.
+for a note applied by an earlier version of saptune, the definition is compared against the system instead.
+Return the comparisons of the changed fields, their actual values are the values applied before. The state saved upon
+the first application of the note is kept, so that the note can still be reverted.
+*/
+func (app *App) RefreshNote(noteID string) (changes map[string]note.NoteFieldComparison, err error) {
+	changes = make(map[string]note.NoteFieldComparison)
+	enabledNotes := app.GetSortedAllEnabledNotes()
+	if i := sort.SearchStrings(enabledNotes, noteID); !(i < len(enabledNotes) && enabledNotes[i] == noteID) {
+		return nil, fmt.Errorf("Note %s is not enabled, hence it cannot be refreshed.", noteID)
+	}
+	aNote, err := app.GetNoteByID(noteID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Remove from configuration
-	if permanent {
-		i := sort.SearchStrings(app.TuneForNotes, noteID)
-		if i < len(app.TuneForNotes) && app.TuneForNotes[i] == noteID {
-			app.TuneForNotes = append(app.TuneForNotes[0:i], app.TuneForNotes[i+1:]...)
-			if err := app.SaveConfig(); err != nil {
-				return err
+	_, comparisons, err := app.VerifyNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := app.State.GetAppliedValues(noteID)
+	hasRecord := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return nil, newError(ErrStateFailed, err, "%v", err)
+	}
+	for name, comparison := range note.FilterParameters(comparisons) {
+		if !hasRecord {
+			if !comparison.MatchExpectation {
+				changes[name] = comparison
 			}
+		} else if appliedValue, recorded := applied[name]; !recorded {
+			// The parameter is new to the definition
+			comparison.MatchExpectation = false
+			changes[name] = comparison
+		} else if appliedValue != comparison.ExpectedValueJS {
+			comparison.ActualValue, comparison.ActualValueJS, comparison.MatchExpectation = appliedValue, appliedValue, false
+			changes[name] = comparison
 		}
 	}
+	if len(changes) == 0 {
+		return
+	}
+	return changes, app.applyChanges(noteID, aNote, changes, applied)
+}
 
-	// Revert parameters using the file record
-	// Workaround for Go JSON package's stubbornness, Go developers are not willing to fix their code in this occasion.
-	var noteReflectValue = reflect.New(reflect.TypeOf(noteTemplate))
-	var noteIface interface{} = noteReflectValue.Interface()
-	if err = app.State.Retrieve(noteID, &noteIface); err == nil {
-		var noteRecovered note.Note = noteIface.(note.Note)
-		if err := noteRecovered.Apply(); err != nil {
-			return err
-		} else if err := app.State.Remove(noteID); err != nil {
-			return err
+/*
+Apply the changed fields of the note, or the whole note if it cannot apply a subset of its parameters, and record their
+values read back together with the applied values of the other fields.
+*/
+func (app *App) applyChanges(noteID string, aNote note.Note, changes map[string]note.NoteFieldComparison, applied map[string]string) error {
+	initialised, err := aNote.Initialise()
+	if err != nil {
+		return newError(ErrInspectionFailed, err, "Failed to examine system for the current status of note %s - %v", noteID, err)
+	}
+	optimised, err := app.optimiseNote(noteID, initialised)
+	if err != nil {
+		return newError(ErrInspectionFailed, err, "Failed to calculate optimised parameters for note %s - %v", noteID, err)
+	}
+	_, err = note.ApplyDeviating(optimised, changes)
+	if _, err = takeEnvironmentLimited(noteID, err); err != nil {
+		return newError(ErrApplyDenied, err, "Failed to apply note %s - %v", noteID, err)
+	}
+	appliedState, err := aNote.Initialise()
+	if err != nil {
+		return newError(ErrInspectionFailed, err, "Failed to read back the parameters of note %s - %v", noteID, err)
+	}
+	_, readback := note.CompareNoteFields(appliedState, optimised)
+	values := make(map[string]string)
+	for name, comparison := range note.FilterParameters(readback) {
+		if _, changed := changes[name]; changed {
+			values[name] = comparison.ActualValueJS
+		} else if appliedValue, recorded := applied[name]; recorded {
+			values[name] = appliedValue
 		}
-	} else if !os.IsNotExist(err) {
-		return err
+	}
+	if err := app.State.StoreApplyTime(noteID); err != nil {
+		return newError(ErrStateFailed, err, "Failed to record the apply time of note %s - %v", noteID, err)
+	}
+	if err := app.State.StoreAppliedValues(noteID, values); err != nil {
+		return newError(ErrStateFailed, err, "Failed to record the applied values of note %s - %v", noteID, err)
 	}
 	return nil
 }
 
-// Permanently revert notes tuned by the solution and clear their stored states.
-func (app *App) RevertSolution(solName string) error {
-	sol, err := app.GetSolutionByName(solName)
-	if err != nil {
-		return err
-	}
-	// Remove from configuration
-	i := sort.SearchStrings(app.TuneForSolutions, solName)
-	if i < len(app.TuneForSolutions) && app.TuneForSolutions[i] == solName {
-		app.TuneForSolutions = append(app.TuneForSolutions[0:i], app.TuneForSolutions[i+1:]...)
-		if err := app.SaveConfig(); err != nil {
-			return err
+/*
+Apply tuning for a note that is defined outside of the tuning sheet directory, and remember where it is defined,
+so that it can be verified and reverted later on. The note must not conflict with an existing note.
+*/
+func (app *App) TuneAdHocNote(noteID, confFilePath string, aNote note.Note) error {
+	if _, exists := app.AllNotes[noteID]; exists {
+		if _, isAdHoc := app.AdHocNotes[noteID]; !isAdHoc {
+			return fmt.Errorf("Note ID \"%s\" is already defined by saptune, please choose a different ID.", noteID)
 		}
 	}
-	// The tricky part: figure out which notes are to be reverted, do not revert manually enabled notes.
-	notesDoNotRevert := make(map[string]struct{})
-	for _, note := range app.TuneForNotes {
-		notesDoNotRevert[note] = struct{}{}
+	app.AllNotes[noteID] = aNote
+	app.AdHocNotes[noteID] = confFilePath
+	if err := app.SaveConfig(); err != nil {
+		return err
 	}
-	// Do not revert notes that are referred to by other enabled solutions
-	for _, otherSolName := range app.TuneForSolutions {
-		if otherSolName != solName {
-			otherSolNotes, err := app.GetSolutionByName(otherSolName)
-			if err != nil {
-				return err
-			}
-			for _, noteID := range otherSolNotes {
-				notesDoNotRevert[noteID] = struct{}{}
-			}
+	return app.TuneNote(noteID)
+}
+
+/*
+Record the notes to leave out whenever the solution is applied and verified, replacing the previous exclusions of the
+solution. Return the IDs that do not belong to the solution, those are not recorded. The exclusions are saved together
+with the configuration, e.g. once TuneSolution succeeds.
+*/
+func (app *App) SetSolutionExclusions(solName string, noteIDs []string) (notInSolution []string, err error) {
+	notInSolution = make([]string, 0, 0)
+	sol, exists := app.AllSolutions[solName]
+	if !exists {
+		_, err = app.GetSolutionByName(solName)
+		return
+	}
+	exclusions := make([]string, 0, len(noteIDs))
+	for _, noteID := range noteIDs {
+		inSolution := false
+		for _, solNoteID := range sol {
+			inSolution = inSolution || solNoteID == noteID
+		}
+		if inSolution {
+			exclusions = append(exclusions, noteID)
+		} else {
+			notInSolution = append(notInSolution, noteID)
 		}
 	}
-	// Now revert the (sol notes - manually enabled - other sol notes)
-	noteErrs := make([]error, 0, 0)
-	for _, noteID := range sol {
-		if _, found := notesDoNotRevert[noteID]; found {
-			continue // skip this one
+	if len(exclusions) == 0 {
+		delete(app.SolutionExclusions, solName)
+	} else {
+		app.SolutionExclusions[solName] = exclusions
+	}
+	return
+}
+
+/*
+Permanently revert the notes excluded from the solution that are still applied from before they were excluded, unless
+they are enabled manually or by another solution.
+*/
+func (app *App) revertExcludedNotes(solName string) error {
+	enabledNotes := app.GetSortedAllEnabledNotes()
+	for _, noteID := range app.SolutionExclusions[solName] {
+		if i := sort.SearchStrings(enabledNotes, noteID); i < len(enabledNotes) && enabledNotes[i] == noteID {
+			continue
+		} else if !app.State.IsSaved(noteID) {
+			continue
 		}
+		app.reportProgress("reverting %s, which is excluded from the solution ...\n", noteID)
 		if err := app.RevertNote(noteID, true); err != nil {
-			if err != nil {
-				noteErrs = append(noteErrs, err)
-			}
+			return err
 		}
 	}
-	if len(noteErrs) == 0 {
-		return nil
-	}
-	return fmt.Errorf("Failed to revert one or more SAP notes that belong to the solution: %v", noteErrs)
+	return nil
+}
+
+/*
+Return the notes that do not suit the installed SAP product version, note ID VS the reason. Notes without version
+constraints always suit.
+*/
+func (app *App) FilterByProductVersion(noteIDs []string) (filtered map[string]string, err error) {
+	filtered = make(map[string]string)
+	for _, noteID := range noteIDs {
+		aNote, err := app.GetNoteByID(noteID)
+		if err != nil {
+			return nil, err
+		}
+		if versionErr := note.CheckProductVersion(aNote, app.SAPProductVersion); versionErr != nil {
+			filtered[noteID] = versionErr.Error()
+		}
+	}
+	return
+}
+
+/*
+Leave the notes of the solution that do not suit the installed SAP product version out when the solution is tuned by
+this invocation, see SkippedNotes. Unlike exclusions they are not saved. Return the notes that have been left out,
+note ID VS the reason.
+*/
+func (app *App) SkipByProductVersion(solName string) (filtered map[string]string, err error) {
+	sol, err := app.GetSolutionByName(solName)
+	if err != nil {
+		return
+	}
+	if filtered, err = app.FilterByProductVersion(sol); err != nil {
+		return
+	}
+	if app.SkippedNotes == nil {
+		app.SkippedNotes = make(map[string]string)
+	}
+	for noteID, reason := range filtered {
+		app.SkippedNotes[noteID] = reason
+	}
+	return
+}
+
+/*
+Add the note to (or remove it from) the staged set of additional notes without touching the system. The staged set
+starts as a copy of the additional notes that are currently tuned. The note is only tuned or reverted by ApplyStaged.
+*/
+func (app *App) StageNote(noteID string, enable bool) error {
+	if _, err := app.GetNoteByID(noteID); err != nil {
+		return err
+	}
+	if app.StagedNotes == nil {
+		app.StagedNotes = make([]string, len(app.TuneForNotes))
+		copy(app.StagedNotes, app.TuneForNotes)
+	}
+	app.syncStagedNote(noteID, enable)
+	return app.SaveConfig()
+}
+
+/*
+Add the note to (or remove it from) the staged set of additional notes, if anything has been staged, so that a note
+tuned or reverted directly is not reverted or tuned again by ApplyStaged. Return true if the staged set has changed.
+*/
+func (app *App) syncStagedNote(noteID string, enable bool) bool {
+	if app.StagedNotes == nil {
+		return false
+	}
+	i := sort.SearchStrings(app.StagedNotes, noteID)
+	staged := i < len(app.StagedNotes) && app.StagedNotes[i] == noteID
+	if enable && !staged {
+		app.StagedNotes = append(app.StagedNotes, noteID)
+		sort.Strings(app.StagedNotes)
+		return true
+	} else if !enable && staged {
+		app.StagedNotes = append(app.StagedNotes[0:i], app.StagedNotes[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// Return the staged notes that are not yet tuned, and the tuned additional notes that are no longer staged.
+func (app *App) GetStagedChanges() (toApply, toRevert []string) {
+	toApply = make([]string, 0, 0)
+	toRevert = make([]string, 0, 0)
+	if app.StagedNotes == nil {
+		return
+	}
+	for _, noteID := range app.StagedNotes {
+		if i := sort.SearchStrings(app.TuneForNotes, noteID); !(i < len(app.TuneForNotes) && app.TuneForNotes[i] == noteID) {
+			toApply = append(toApply, noteID)
+		}
+	}
+	for _, noteID := range app.TuneForNotes {
+		if i := sort.SearchStrings(app.StagedNotes, noteID); !(i < len(app.StagedNotes) && app.StagedNotes[i] == noteID) {
+			toRevert = append(toRevert, noteID)
+		}
+	}
+	return
+}
+
+/*
+Reconcile the system with the staged set of additional notes: revert the tuned notes that are no longer staged, then
+tune for the staged notes that are not yet tuned. Return the notes that have been applied and reverted.
+*/
+func (app *App) ApplyStaged() (applied, reverted []string, err error) {
+	applied = make([]string, 0, 0)
+	reverted = make([]string, 0, 0)
+	toApply, toRevert := app.GetStagedChanges()
+	for _, noteID := range toRevert {
+		if err = app.RevertNote(noteID, true); err != nil {
+			return
+		}
+		reverted = append(reverted, noteID)
+	}
+	for index, noteID := range toApply {
+		app.reportProgress("[%d/%d] applying %s ...\n", index+1, len(toApply), noteID)
+		if err = app.TuneNote(noteID); err != nil {
+			return
+		}
+		applied = append(applied, noteID)
+	}
+	return
+}
+
+/*
+Apply tuning for a solution.
+If the solution is not yet enabled, the name will be added into the list of tuned solution names.
+If the solution covers any of the additional notes, those notes will be removed.
+Notes excluded from the solution that are still applied from before they were excluded are reverted, and the
+exclusions of the solution are saved once the solution has been tuned.
+*/
+func (app *App) TuneSolution(solName string) (removedExplicitNotes []string, err error) {
+	removedExplicitNotes = make([]string, 0, 0)
+	sol, err := app.GetSolutionByName(solName)
+	if err != nil {
+		return
+	}
+	if i := sort.SearchStrings(app.TuneForSolutions, solName); !(i < len(app.TuneForSolutions) && app.TuneForSolutions[i] == solName) {
+		app.TuneForSolutions = append(app.TuneForSolutions, solName)
+		sort.Strings(app.TuneForSolutions)
+		if err = app.SaveConfig(); err != nil {
+			return
+		}
+	}
+	for index, noteID := range sol {
+		if _, skipped := app.SkippedNotes[noteID]; skipped {
+			app.reportProgress("[%d/%d] skipping %s ...\n", index+1, len(sol), noteID)
+			continue
+		}
+		app.reportProgress("[%d/%d] applying %s ...\n", index+1, len(sol), noteID)
+		// Remove solution's notes from additional notes list.
+		if i := sort.SearchStrings(app.TuneForNotes, noteID); i < len(app.TuneForNotes) && app.TuneForNotes[i] == noteID {
+			app.TuneForNotes = append(app.TuneForNotes[0:i], app.TuneForNotes[i+1:]...)
+			app.syncStagedNote(noteID, false)
+			removedExplicitNotes = append(removedExplicitNotes, noteID)
+			if err = app.SaveConfig(); err != nil {
+				return
+			}
+		}
+		if err = app.TuneNote(noteID); errors.Is(err, ErrPackageMissing) {
+			// The other notes of the solution still take effect on a host without the package
+			log.Printf("TuneSolution: %v", err)
+			err = nil
+		} else if err != nil {
+			return
+		}
+	}
+	if err = app.revertExcludedNotes(solName); err != nil {
+		return
+	}
+	// Save the exclusions of the solution now that they have taken effect
+	err = app.SaveConfig()
+	return
+}
+
+// Tune for all currently enabled solutions and notes.
+func (app *App) TuneAll() error {
+	return app.tuneAll(func(noteID string, err error) error {
+		// A note that does not support the running kernel or lacks its packages is skipped with a warning
+		if errors.Is(err, ErrKernelUnsupported) || errors.Is(err, ErrPackageMissing) {
+			log.Printf("TuneAll: %v", err)
+			return nil
+		}
+		return err
+	})
+}
+
+/*
+Tune for all currently enabled solutions and notes like TuneAll, but carry on with the other notes if a note fails,
+and report the outcome of each note: nil if it is applied, an error of kind ErrKernelUnsupported or ErrPackageMissing
+if it is skipped, or the failure.
+*/
+func (app *App) TuneAllReporting(report func(noteID string, err error)) error {
+	allErrs := make([]error, 0, 0)
+	err := app.tuneAll(func(noteID string, err error) error {
+		report(noteID, err)
+		if err != nil && !errors.Is(err, ErrKernelUnsupported) && !errors.Is(err, ErrPackageMissing) {
+			allErrs = append(allErrs, err)
+		}
+		return nil
+	})
+	if err != nil {
+		// An enabled solution is unknown, none of the notes is tuned
+		return err
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Failed to apply one or more SAP notes/solutions: %v", allErrs)
+}
+
+/*
+Tune each note of the enabled solutions followed by the additional notes, a note shared among them is tuned once. The
+outcome of each note is passed to handle, tuning stops at the first error that handle returns.
+*/
+func (app *App) tuneAll(handle func(noteID string, err error) error) error {
+	noteIDs, err := app.getTuneOrder()
+	if err != nil {
+		return err
+	}
+	for _, noteID := range noteIDs {
+		if err := handle(noteID, app.TuneNote(noteID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+Return the IDs of the enabled notes in the order TuneAll applies them: the notes of the enabled solutions followed by
+the additional notes, a note shared among them appears once. Hence a parameter defined by several notes takes the value
+of the last of them.
+*/
+func (app *App) getTuneOrder() ([]string, error) {
+	noteIDs := make([]string, 0, 0)
+	seen := make(map[string]struct{})
+	allNoteIDs := make([]string, 0, 0)
+	for _, solName := range app.TuneForSolutions {
+		sol, err := app.GetSolutionByName(solName)
+		if err != nil {
+			return nil, err
+		}
+		allNoteIDs = append(allNoteIDs, sol...)
+	}
+	for _, noteID := range append(allNoteIDs, app.TuneForNotes...) {
+		if _, exists := seen[noteID]; !exists {
+			seen[noteID] = struct{}{}
+			noteIDs = append(noteIDs, noteID)
+		}
+	}
+	return noteIDs, nil
+}
+
+// Revert parameters tuned by the note and clear its stored states.
+func (app *App) RevertNote(noteID string, permanent bool) error {
+	return app.revertNote(noteID, permanent, func(dest interface{}) error {
+		return app.State.Retrieve(noteID, dest)
+	})
+}
+
+/*
+Receives the parameter values read back right after the note has been applied, like TuneNoteReadback returns them,
+e.g. to report the parameters the system did not accept while applying a solution.
+*/
+type ReadbackReporter func(noteID string, readback map[string]note.NoteFieldComparison)
+
+// Decides whether the high-risk changes of the note may be carried out, e.g. by asking the user.
+type HighRiskConfirmer func(noteID string, changes []HighRiskChange) bool
+
+// A change of a high-risk parameter that applying a note would carry out.
+type HighRiskChange struct {
+	Param   string // name of the parameter
+	Current string // current value in JSON
+	Target  string // value of the note in JSON
+	Risk    string // why changing the parameter is high-risk
+}
+
+// Return the changes of high-risk parameters among the deviating parameters of the note, sorted by parameter.
+func GetHighRiskChanges(aNote note.Note, comparisons map[string]note.NoteFieldComparison) []HighRiskChange {
+	changes := make([]HighRiskChange, 0, 0)
+	for _, comparison := range comparisons {
+		if comparison.MatchExpectation || comparison.NotApplicable != "" {
+			continue
+		}
+		if risk := note.GetParamRisk(aNote, comparison); risk != "" {
+			changes = append(changes, HighRiskChange{Param: comparison.Label(), Current: comparison.ActualValueJS, Target: comparison.ExpectedValueJS, Risk: risk})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Param < changes[j].Param
+	})
+	return changes
+}
+
+/*
+Revert the note like RevertNote, but restore the parameter values recorded by retrieve, which deserialises them into
+the destination pointer. The error of retrieve satisfies os.IsNotExist if nothing is recorded.
+*/
+func (app *App) revertNote(noteID string, permanent bool, retrieve func(dest interface{}) error) error {
+	noteTemplate, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return err
+	}
+
+	// Remove from configuration
+	if permanent {
+		i := sort.SearchStrings(app.TuneForNotes, noteID)
+		enabled := i < len(app.TuneForNotes) && app.TuneForNotes[i] == noteID
+		if enabled {
+			app.TuneForNotes = append(app.TuneForNotes[0:i], app.TuneForNotes[i+1:]...)
+		}
+		if unstaged := app.syncStagedNote(noteID, false); enabled || unstaged {
+			if err := app.SaveConfig(); err != nil {
+				return newError(ErrStateFailed, err, "%v", err)
+			}
+		}
+		if err := os.Remove(app.GetSysctlDropInPath(noteID)); err != nil && !os.IsNotExist(err) {
+			return newError(ErrStateFailed, err, "%v", err)
+		}
+		// Nor is an automatic revert needed any longer
+		app.cancelPendingRevert(noteID)
+		// The next time the note is applied, its definition at that time is released
+		if err := app.State.RemoveRelease(noteID); err != nil {
+			return newError(ErrStateFailed, err, "%v", err)
+		}
+		// An ad-hoc note is forgotten once it is permanently reverted
+		if _, isAdHoc := app.AdHocNotes[noteID]; isAdHoc {
+			delete(app.AdHocNotes, noteID)
+			if err := app.SaveConfig(); err != nil {
+				return newError(ErrStateFailed, err, "%v", err)
+			}
+		}
+	}
+
+	// Revert parameters using the file record
+	// Workaround for Go JSON package's stubbornness, Go developers are not willing to fix their code in this occasion.
+	var noteReflectValue = reflect.New(reflect.TypeOf(noteTemplate))
+	var noteIface interface{} = noteReflectValue.Interface()
+	if err = retrieve(&noteIface); err == nil {
+		var noteRecovered note.Note = noteIface.(note.Note)
+		// Parameters the environment does not permit to write keep their values, the rest of the note is reverted
+		if _, err := takeEnvironmentLimited(noteID, note.RestoreSaved(noteRecovered)); err != nil {
+			return newError(ErrApplyDenied, err, "%v", err)
+		} else if err := app.State.Remove(noteID); err != nil {
+			return newError(ErrStateFailed, err, "%v", err)
+		} else if err := app.State.RemoveApplyTime(noteID); err != nil {
+			return newError(ErrStateFailed, err, "%v", err)
+		} else if err := app.State.RemoveAppliedValues(noteID); err != nil {
+			return newError(ErrStateFailed, err, "%v", err)
+		} else if err := app.State.RemoveRejected(noteID); err != nil {
+			return newError(ErrStateFailed, err, "%v", err)
+		}
+		if permanent {
+			return app.reapplyOverlappingNotes(noteID, noteReflectValue.Elem().Interface().(note.Note))
+		}
+	} else if !os.IsNotExist(err) {
+		return newError(ErrStateFailed, err, "%v", err)
+	}
+	return nil
+}
+
+/*
+Permanently revert the note and read its parameters back, to confirm that they have taken the values saved before the
+note was applied. Return the parameters that did not revert cleanly, the expected value is the saved one. Parameters
+shared with other enabled notes take the values of those notes instead, and parameters that only take effect after a
+reboot cannot be confirmed, neither of them is considered. Return an error without reverting if no state is saved.
+*/
+func (app *App) RevertNoteVerified(noteID string) (deviations map[string]note.NoteFieldComparison, err error) {
+	deviations = make(map[string]note.NoteFieldComparison)
+	noteTemplate, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return
+	}
+	var noteReflectValue = reflect.New(reflect.TypeOf(noteTemplate))
+	var noteIface interface{} = noteReflectValue.Interface()
+	if err = app.State.Retrieve(noteID, &noteIface); os.IsNotExist(err) {
+		return deviations, newError(ErrStateFailed, err, "Note %s has no saved state, hence reverting it cannot be verified.", noteID)
+	} else if err != nil {
+		return deviations, newError(ErrStateFailed, err, "%v", err)
+	}
+	original := noteReflectValue.Elem().Interface().(note.Note)
+	if err = app.RevertNote(noteID, true); err != nil {
+		return
+	}
+	reverted, err := noteTemplate.Initialise()
+	if err != nil {
+		return deviations, newError(ErrInspectionFailed, err, "Failed to read back the parameters of note %s - %v", noteID, err)
+	}
+	shared := make(map[string]struct{})
+	for _, enabledID := range app.GetSortedAllEnabledNotes() {
+		if enabledID == noteID {
+			continue
+		}
+		_, comparisons, err := app.VerifyNote(enabledID)
+		if err != nil {
+			return deviations, err
+		}
+		for name := range comparisons {
+			shared[name] = struct{}{}
+		}
+	}
+	_, comparisons := note.CompareNoteFields(reverted, original)
+	for name, comparison := range comparisons {
+		if _, isShared := shared[name]; !isShared && !comparison.MatchExpectation && !note.IsRebootRequired(noteTemplate, comparison) {
+			deviations[name] = comparison
+		}
+	}
+	return
+}
+
+/*
+Re-apply the enabled notes that share parameters with a reverted note, so that the shared parameters take the
+values recommended by the remaining notes instead of the values from before the reverted note was applied.
+Stored states of the re-applied notes are left untouched.
+*/
+func (app *App) reapplyOverlappingNotes(revertedNoteID string, revertedNote note.Note) error {
+	_, revertedFields := note.CompareNoteFields(revertedNote, revertedNote)
+	revertedParams := note.FilterParameters(revertedFields)
+	noteErrs := make([]error, 0, 0)
+	for _, noteID := range app.GetSortedAllEnabledNotes() {
+		if noteID == revertedNoteID {
+			continue
+		}
+		aNote, err := app.GetNoteByID(noteID)
+		if err != nil {
+			noteErrs = append(noteErrs, err)
+			continue
+		}
+		currentState, err := aNote.Initialise()
+		if err != nil {
+			noteErrs = append(noteErrs, err)
+			continue
+		}
+		overlapping := false
+		_, fields := note.CompareNoteFields(currentState, currentState)
+		for name := range note.FilterParameters(fields) {
+			if _, shared := revertedParams[name]; shared {
+				overlapping = true
+				break
+			}
+		}
+		if !overlapping {
+			continue
+		}
+		optimised, err := app.optimiseNote(noteID, currentState)
+		if err == nil {
+			err = optimised.Apply()
+		}
+		if err != nil {
+			noteErrs = append(noteErrs, fmt.Errorf("note %s - %v", noteID, err))
+		}
+	}
+	if len(noteErrs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Failed to re-apply notes that share parameters with reverted note %s: %v", revertedNoteID, noteErrs)
+}
+
+/*
+Permanently revert all manually enabled notes, leaving the notes enabled by solutions intact.
+Manually enabled notes that are also referred to by an enabled solution are skipped.
+*/
+func (app *App) RevertManualNotes() (revertedNotes, skippedNotes []string, err error) {
+	revertedNotes = make([]string, 0, 0)
+	skippedNotes = make([]string, 0, 0)
+	solNotes := app.GetSortedSolutionEnabledNotes()
+	manualNotes := make([]string, len(app.TuneForNotes))
+	copy(manualNotes, app.TuneForNotes)
+	noteErrs := make([]error, 0, 0)
+	for _, noteID := range manualNotes {
+		if i := sort.SearchStrings(solNotes, noteID); i < len(solNotes) && solNotes[i] == noteID {
+			skippedNotes = append(skippedNotes, noteID)
+			continue
+		}
+		if err := app.RevertNote(noteID, true); err != nil {
+			noteErrs = append(noteErrs, err)
+			continue
+		}
+		revertedNotes = append(revertedNotes, noteID)
+	}
+	if len(noteErrs) > 0 {
+		err = fmt.Errorf("Failed to revert one or more manually enabled SAP notes: %v", noteErrs)
+	}
+	return
+}
+
+// Return the IDs of enabled, staged, and saved notes that are no longer defined, e.g. because their tuning sheets were removed.
+func (app *App) GetDanglingNotes() []string {
+	dangling := make([]string, 0, 0)
+	savedNotes, _ := app.State.List()
+	for _, noteIDs := range [][]string{app.TuneForNotes, app.StagedNotes, savedNotes} {
+		for _, noteID := range noteIDs {
+			if _, exists := app.AllNotes[noteID]; exists {
+				continue
+			}
+			if i := sort.SearchStrings(dangling, noteID); !(i < len(dangling) && dangling[i] == noteID) {
+				dangling = append(dangling, noteID)
+				sort.Strings(dangling)
+			}
+		}
+	}
+	return dangling
+}
+
+/*
+Remove the notes that are no longer defined from the configuration, together with their stored states. Their
+parameters cannot be reverted without the definition, hence they are left as they are. Return the removed note IDs.
+*/
+func (app *App) PruneDanglingNotes() (prunedNotes []string, err error) {
+	prunedNotes = app.GetDanglingNotes()
+	for _, noteID := range prunedNotes {
+		if i := sort.SearchStrings(app.TuneForNotes, noteID); i < len(app.TuneForNotes) && app.TuneForNotes[i] == noteID {
+			app.TuneForNotes = append(app.TuneForNotes[0:i], app.TuneForNotes[i+1:]...)
+		}
+		if i := sort.SearchStrings(app.StagedNotes, noteID); i < len(app.StagedNotes) && app.StagedNotes[i] == noteID {
+			app.StagedNotes = append(app.StagedNotes[0:i], app.StagedNotes[i+1:]...)
+		}
+		delete(app.AdHocNotes, noteID)
+		// The note cannot be reverted any more, neither by hand nor automatically
+		app.cancelPendingRevert(noteID)
+		if err = app.State.Remove(noteID); err != nil {
+			return
+		} else if err = app.State.RemoveApplyTime(noteID); err != nil {
+			return
+		} else if err = app.State.RemoveAppliedValues(noteID); err != nil {
+			return
+		} else if err = app.State.RemoveRelease(noteID); err != nil {
+			return
+		} else if err = app.State.RemoveRejected(noteID); err != nil {
+			return
+		} else if err = app.State.StorePins(noteID, nil); err != nil {
+			return
+		}
+	}
+	if len(prunedNotes) > 0 {
+		err = app.SaveConfig()
+	}
+	return
+}
+
+// Permanently revert notes tuned by the solution and clear their stored states.
+func (app *App) RevertSolution(solName string) error {
+	if _, err := app.GetSolutionByName(solName); err != nil {
+		return err
+	}
+	// Notes excluded from the solution may still be applied from before they were excluded
+	sol := app.AllSolutions[solName]
+	// Remove from configuration, together with the notes excluded from the solution
+	i := sort.SearchStrings(app.TuneForSolutions, solName)
+	if i < len(app.TuneForSolutions) && app.TuneForSolutions[i] == solName {
+		app.TuneForSolutions = append(app.TuneForSolutions[0:i], app.TuneForSolutions[i+1:]...)
+		delete(app.SolutionExclusions, solName)
+		if err := app.SaveConfig(); err != nil {
+			return err
+		}
+	}
+	// The tricky part: figure out which notes are to be reverted, do not revert manually enabled notes.
+	notesDoNotRevert := make(map[string]struct{})
+	for _, note := range app.TuneForNotes {
+		notesDoNotRevert[note] = struct{}{}
+	}
+	// Do not revert notes that are referred to by other enabled solutions
+	for _, otherSolName := range app.TuneForSolutions {
+		if otherSolName != solName {
+			otherSolNotes, err := app.GetSolutionByName(otherSolName)
+			if err != nil {
+				return err
+			}
+			for _, noteID := range otherSolNotes {
+				notesDoNotRevert[noteID] = struct{}{}
+			}
+		}
+	}
+	// Now revert the (sol notes - manually enabled - other sol notes)
+	noteErrs := make([]error, 0, 0)
+	for _, noteID := range sol {
+		if _, found := notesDoNotRevert[noteID]; found {
+			continue // skip this one
+		}
+		if err := app.RevertNote(noteID, true); err != nil {
+			if err != nil {
+				noteErrs = append(noteErrs, err)
+			}
+		}
+	}
+	if len(noteErrs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Failed to revert one or more SAP notes that belong to the solution: %v", noteErrs)
 }
 
 // Revert all tuned parameters (both solutions and additional notes), and clear stored states.
 func (app *App) RevertAll(permanent bool) error {
 	allErrs := make([]error, 0, 0)
+	if permanent {
+		// None of the notes is left enabled to take over parameters shared with a reverted note
+		app.TuneForNotes = make([]string, 0, 0)
+		app.TuneForSolutions = make([]string, 0, 0)
+	}
 
 	// Simply revert all notes from serialised states
 	otherNotes, err := app.State.List()
 	if err == nil {
-		for _, otherNoteID := range otherNotes {
-			if err := app.RevertNote(otherNoteID, permanent); err != nil {
-				allErrs = append(allErrs, err)
-			}
-		}
-	} else {
-		allErrs = append(allErrs, err)
+		for _, otherNoteID := range otherNotes {
+			if err := app.RevertNote(otherNoteID, permanent); err != nil {
+				allErrs = append(allErrs, err)
+			}
+		}
+	} else {
+		allErrs = append(allErrs, err)
+	}
+	if permanent {
+		if err := app.SaveConfig(); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Failed to revert one or more SAP notes/solutions: %v", allErrs)
+}
+
+/*
+Calculate the optimised parameters of an initialised note, and then replace the values customised in the note's
+customisation file. Both tuning and verification go through here, so that they agree on the expected values.
+*/
+func (app *App) optimiseNote(noteID string, initialised note.Note) (note.Note, error) {
+	return app.optimiseReferencedNote(noteID, initialised, []string{})
+}
+
+/*
+Optimise the note as optimiseNote does, the chain holds the IDs of the notes whose customised values refer to the note,
+directly or indirectly, in order to detect circular references.
+*/
+func (app *App) optimiseReferencedNote(noteID string, initialised note.Note, chain []string) (note.Note, error) {
+	// Optimising fills the maps of the initialised note, hence its current values are taken beforehand
+	current := getParamValues(initialised)
+	optimised, err := initialised.Optimise()
+	if err != nil {
+		return nil, err
+	}
+	if optimised, err = app.holdBackDefinition(noteID, initialised, current, optimised); err != nil {
+		return nil, err
+	}
+	// The customisation file takes precedence over the override file
+	overrides := make(map[string]string)
+	for _, filePath := range []string{app.GetOverrideFilePath(noteID), app.GetCustomiseFilePath(noteID)} {
+		conf, err := txtparser.ParseSysconfigFile(filePath, false)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		fileOverrides, err := note.GetOverrides(optimised, conf)
+		if err != nil {
+			return nil, fmt.Errorf("note %s: %v", noteID, err)
+		}
+		for name, value := range fileOverrides {
+			overrides[name] = value
+		}
+	}
+	if err := app.resolveNoteReferences(noteID, overrides, append(chain, noteID)); err != nil {
+		return nil, fmt.Errorf("note %s: %v", noteID, err)
+	}
+	overridden, err := note.ApplyOverrides(optimised, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("note %s: %v", noteID, err)
+	}
+	return app.applyPins(noteID, overridden)
+}
+
+/*
+Replace the customised values that refer to a parameter of another note, e.g. "@note:1410736:vm.nr_hugepages", by the
+value that the other note applies, including its own customisation. The chain ends with the note itself, a reference
+to a note in the chain is circular and results in an error.
+*/
+func (app *App) resolveNoteReferences(noteID string, overrides map[string]string, chain []string) error {
+	for paramName, value := range overrides {
+		refNoteID, refParamName, isRef := note.ParseNoteReference(value)
+		if !isRef {
+			continue
+		}
+		for _, chainedID := range chain {
+			if chainedID == refNoteID {
+				return fmt.Errorf("circular reference %s -> %s", strings.Join(chain, " -> "), refNoteID)
+			}
+		}
+		refNote, err := app.GetNoteByID(refNoteID)
+		if err != nil {
+			return fmt.Errorf("parameter %s refers to an unknown note %s", paramName, refNoteID)
+		}
+		refInitialised, err := refNote.Initialise()
+		if err != nil {
+			return fmt.Errorf("parameter %s refers to note %s, which failed to inspect the system - %v", paramName, refNoteID, err)
+		}
+		refOptimised, err := app.optimiseReferencedNote(refNoteID, refInitialised, chain)
+		if err != nil {
+			return err
+		}
+		if overrides[paramName], err = note.GetParamValue(refOptimised, refParamName); err != nil {
+			return fmt.Errorf("parameter %s refers to note %s, but %v", paramName, refNoteID, err)
+		}
+	}
+	return nil
+}
+
+// Replace the values of the parameters pinned for the note, pins take precedence over the customisation file.
+func (app *App) applyPins(noteID string, optimised note.Note) (note.Note, error) {
+	pins, err := app.State.GetPins(noteID)
+	if err != nil {
+		return nil, fmt.Errorf("note %s: failed to read pinned values - %v", noteID, err)
+	}
+	pinned, err := note.ApplyOverrides(optimised, pins)
+	if err != nil {
+		return nil, fmt.Errorf("note %s: %v", noteID, err)
+	}
+	return pinned, nil
+}
+
+// Return the path to the customisation file of the note.
+func (app *App) GetCustomiseFilePath(noteID string) string {
+	return path.Join(app.SysconfigPrefix, fmt.Sprintf(note.CustomiseFileTemplate, noteID))
+}
+
+// Return the path to the override file of the note.
+func (app *App) GetOverrideFilePath(noteID string) string {
+	return path.Join(app.SysconfigPrefix, fmt.Sprintf(note.OverrideFileTemplate, noteID))
+}
+
+// Return true only if the override file of the note exists.
+func (app *App) IsNoteOverridden(noteID string) bool {
+	_, err := os.Stat(app.GetOverrideFilePath(noteID))
+	return err == nil
+}
+
+/*
+Write the values into the customisation file of the note without an editor, key VS value. A key must be a parameter
+of the note, a regular expression enclosed in slashes, or a switch already present in the file. If any key is not,
+nothing is written and the unsupported keys are returned in the error.
+*/
+func (app *App) CustomiseNote(noteID string, values map[string]string) error {
+	aNote, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return err
+	}
+	// Parameter names of map fields are only known after inspecting the system
+	initialised, err := aNote.Initialise()
+	if err != nil {
+		return err
+	}
+	fileName := app.GetCustomiseFilePath(noteID)
+	conf, err := txtparser.ParseSysconfigFile(fileName, true)
+	if err != nil {
+		return err
+	}
+	supported := make(map[string]struct{})
+	_, comparisons := note.CompareNoteFields(initialised, initialised)
+	for _, comparison := range note.FilterParameters(comparisons) {
+		supported[note.GetParamName(comparison)] = struct{}{}
+	}
+	keys := make([]string, 0, len(values))
+	unsupported := make([]string, 0, 0)
+	for key := range values {
+		keys = append(keys, key)
+		_, isParam := supported[key]
+		_, isSwitch := conf.KeyValue[key]
+		if note.IsRegexOverride(key) {
+			if _, err := regexp.Compile(key[1 : len(key)-1]); err != nil {
+				return fmt.Errorf("invalid regular expression %s - %v", key, err)
+			}
+		} else if !isParam && (!isSwitch || !note.IsParameterField(key)) {
+			unsupported = append(unsupported, key)
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return fmt.Errorf("note %s does not support customising %s", noteID, strings.Join(unsupported, ", "))
+	}
+	isNewFile := len(conf.AllValues) == 0
+	sort.Strings(keys)
+	for _, key := range keys {
+		conf.Set(key, values[key])
+	}
+	if isNewFile && len(conf.AllValues) > 0 {
+		conf.AllValues[0].LeadingComments = note.CustomiseFileHeader(noteID)
+	}
+	return ioutil.WriteFile(fileName, []byte(conf.ToText()), 0644)
+}
+
+/*
+Pin the parameter of the note to the value. A pinned value takes precedence over both the note definition and the
+customisation file, and it survives updates of the definition until the parameter is unpinned. The pin takes effect
+when the note is applied again.
+*/
+func (app *App) PinParameter(noteID, paramName, value string) error {
+	aNote, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return err
+	}
+	// Parameter names of map fields are only known after inspecting the system
+	initialised, err := aNote.Initialise()
+	if err != nil {
+		return newError(ErrInspectionFailed, err, "%v", err)
+	}
+	// Fields that describe the note, such as its ID, are not parameters and cannot be pinned
+	_, comparisons := note.CompareNoteFields(initialised, initialised)
+	isParam := false
+	for _, comparison := range note.FilterParameters(comparisons) {
+		isParam = isParam || note.GetParamName(comparison) == paramName
+	}
+	if !isParam {
+		return fmt.Errorf("note %s does not have parameter %s", noteID, paramName)
+	}
+	pins, err := app.State.GetPins(noteID)
+	if err != nil {
+		return newError(ErrStateFailed, err, "%v", err)
+	}
+	pins[paramName] = value
+	// Refuse values that do not suit the parameter, e.g. text for a number
+	if _, err := note.ApplyOverrides(initialised, pins); err != nil {
+		return err
+	}
+	if err := app.State.StorePins(noteID, pins); err != nil {
+		return newError(ErrStateFailed, err, "%v", err)
+	}
+	return nil
+}
+
+/*
+Remove the pin of the parameter of the note, the parameter then follows the note definition again when the note is
+applied again. Return an error if the parameter is not pinned. The pins of a note that is no longer defined may be
+removed as well.
+*/
+func (app *App) UnpinParameter(noteID, paramName string) error {
+	pins, err := app.State.GetPins(noteID)
+	if err != nil {
+		return newError(ErrStateFailed, err, "%v", err)
+	}
+	if _, err := app.GetNoteByID(noteID); err != nil && len(pins) == 0 {
+		return err
+	}
+	if _, pinned := pins[paramName]; !pinned {
+		return fmt.Errorf("parameter %s of note %s is not pinned", paramName, noteID)
+	}
+	delete(pins, paramName)
+	if err := app.State.StorePins(noteID, pins); err != nil {
+		return newError(ErrStateFailed, err, "%v", err)
+	}
+	return nil
+}
+
+// Return the pinned parameter values of all notes that have any, note ID VS parameter name VS value.
+func (app *App) GetAllPins() (map[string]map[string]string, error) {
+	allPins := make(map[string]map[string]string)
+	for noteID := range app.AllNotes {
+		pins, err := app.State.GetPins(noteID)
+		if err != nil {
+			return nil, newError(ErrStateFailed, err, "%v", err)
+		}
+		if len(pins) > 0 {
+			allPins[noteID] = pins
+		}
+	}
+	return allPins, nil
+}
+
+// The local modifications of a note, which an update of the note definition does not carry over by itself.
+type LocalModification struct {
+	Overridden bool // the override file of the note sets values
+	Customised bool // the customisation file of the note sets values
+	Pinned     bool // parameters of the note are pinned
+	// Parameters whose effective value differs from the definition, the actual value is the effective one and the
+	// expected value is the one of the definition.
+	Differences []note.NoteFieldComparison
+}
+
+// Return true only if the note is overridden, customised, or pinned.
+func (modification LocalModification) IsModified() bool {
+	return modification.Overridden || modification.Customised || modification.Pinned
+}
+
+/*
+Compare the values that the note applies including overrides, customisation, and pins against the values of the note definition,
+in order to review local modifications e.g. before an update replaces note definitions.
+*/
+func (app *App) GetLocalModification(noteID string) (modification LocalModification, err error) {
+	aNote, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return
+	}
+	conf, err := txtparser.ParseSysconfigFile(app.GetOverrideFilePath(noteID), false)
+	if err == nil {
+		modification.Overridden = len(conf.AllValues) > 0
+	} else if !os.IsNotExist(err) {
+		return
 	}
-	if permanent {
-		app.TuneForNotes = make([]string, 0, 0)
-		app.TuneForSolutions = make([]string, 0, 0)
-		if err := app.SaveConfig(); err != nil {
-			allErrs = append(allErrs, err)
+	conf, err = txtparser.ParseSysconfigFile(app.GetCustomiseFilePath(noteID), false)
+	if err == nil {
+		modification.Customised = len(conf.AllValues) > 0
+	} else if !os.IsNotExist(err) {
+		return
+	}
+	pins, err := app.State.GetPins(noteID)
+	if err != nil {
+		return modification, newError(ErrStateFailed, err, "%v", err)
+	}
+	modification.Pinned = len(pins) > 0
+	modification.Differences = make([]note.NoteFieldComparison, 0, 0)
+	if !modification.IsModified() {
+		return modification, nil
+	}
+	initialised, err := aNote.Initialise()
+	if err != nil {
+		return modification, newError(ErrInspectionFailed, err, "Failed to examine system for the current status of note %s - %v", noteID, err)
+	}
+	defaults, err := initialised.Optimise()
+	if err != nil {
+		return
+	}
+	effective, err := app.optimiseNote(noteID, initialised)
+	if err != nil {
+		return
+	}
+	_, comparisons := note.CompareNoteFields(effective, defaults)
+	for _, comparison := range comparisons {
+		if !comparison.MatchExpectation {
+			modification.Differences = append(modification.Differences, comparison)
 		}
 	}
-	if len(allErrs) == 0 {
-		return nil
+	sort.Slice(modification.Differences, func(i, j int) bool {
+		return modification.Differences[i].ParamID < modification.Differences[j].ParamID
+	})
+	return modification, nil
+}
+
+/*
+Remove the customisation file of the note, so that the note uses its built-in defaults when it is applied again.
+Return the values that have been cleared, key VS value. If the note is not customised, nothing is cleared.
+*/
+func (app *App) ResetCustomisation(noteID string) (cleared map[string]string, err error) {
+	if _, err = app.GetNoteByID(noteID); err != nil {
+		return
 	}
-	return fmt.Errorf("Failed to revert one or more SAP notes/solutions: %v", allErrs)
+	fileName := app.GetCustomiseFilePath(noteID)
+	cleared = make(map[string]string)
+	conf, err := txtparser.ParseSysconfigFile(fileName, false)
+	if os.IsNotExist(err) {
+		return cleared, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, entry := range conf.AllValues {
+		cleared[entry.Key] = entry.Value
+	}
+	if err = os.Remove(fileName); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Parse customisation values given by a JSON object, key VS value. Values may be strings, numbers, or booleans.
+func ParseCustomiseJSON(content []byte) (map[string]string, error) {
+	var obj map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	// Keep numbers as they are written, e.g. large integers are not turned into floating point notation
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for key, value := range obj {
+		switch value.(type) {
+		case string, json.Number, bool:
+			values[key] = fmt.Sprint(value)
+		default:
+			return nil, fmt.Errorf("value of %s must be a string, a number, or a boolean", key)
+		}
+	}
+	return values, nil
 }
 
 /*
@@ -319,40 +1656,83 @@ func (app *App) VerifyNote(noteID string) (conforming bool, comparisons map[stri
 	if err != nil {
 		return
 	}
+	for _, warning := range note.GetMissingModuleWarnings(theNote) {
+		log.Printf("Note %s: %s", noteID, warning)
+	}
 	// Run optimisation routine and compare it against current status
-	inspectedNote, err := theNote.Initialise()
+	inspectedNote, err := app.inspect(noteID, theNote)
 	if err != nil {
-		return false, nil, err
+		return false, nil, newError(ErrInspectionFailed, err, "%v", err)
 	}
 
 	// to get Apply work:
-	optimisedNote, err := theNote.Initialise()
+	optimisedNote, err := app.inspect(noteID, theNote)
 	if err != nil {
-		return false, nil, err
+		return false, nil, newError(ErrInspectionFailed, err, "%v", err)
 	}
 	// if used inspectedNote as before, inspectedNote and optimisedNote
 	// will have the same contents after 'Optimise()'
 	// so CompareNoteFields wont find a difference and NO Apply will done
 	//optimisedNote, err := inspectedNote.Optimise()
-	optimisedNote, err = optimisedNote.Optimise()
+	optimisedNote, err = app.optimiseNote(noteID, optimisedNote)
 	if err != nil {
-		return false, nil, err
+		return false, nil, newError(ErrInspectionFailed, err, "%v", err)
+	}
+	_, comparisons = note.CompareNoteFields(inspectedNote, optimisedNote)
+	note.MarkSeverity(theNote, comparisons)
+	// Parameters of file systems that are not mounted cannot be verified
+	conforming = note.MarkNotApplicable(theNote, comparisons)
+	// Nor do the parameters of a note take effect without the software packages it requires
+	if pkgErr := note.CheckRequiredPackages(theNote); pkgErr != nil && !app.IgnorePackages {
+		note.MarkAllNotApplicable(comparisons, pkgErr.Error())
+		conforming = true
 	}
-	conforming, comparisons = note.CompareNoteFields(inspectedNote, optimisedNote)
 	return
 }
 
+// Determine the current parameter values of the note using the inspector of the app, or the live system if there is none.
+func (app *App) inspect(noteID string, aNote note.Note) (note.Note, error) {
+	if app.Inspector == nil {
+		return LiveInspector{}.Inspect(noteID, aNote)
+	}
+	return app.Inspector.Inspect(noteID, aNote)
+}
+
 /*
 Inspect the system and verify that all parameters conform to all of the notes associated to the solution.
 The note comparison results will always contain all fields from all notes.
 */
 func (app *App) VerifySolution(solName string) (unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison, err error) {
+	return app.verifySolution(solName, false)
+}
+
+/*
+Inspect the system and verify the notes of the solution like VerifySolution, but stop at the first deviating note.
+Hence there is at most one unsatisfied note, and the comparisons only cover the notes inspected until then.
+*/
+func (app *App) VerifySolutionFailFast(solName string) (unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison, err error) {
+	return app.verifySolution(solName, true)
+}
+
+func (app *App) verifySolution(solName string, failFast bool) (unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison, err error) {
 	unsatisfiedNotes = make([]string, 0, 0)
 	comparisons = make(map[string]map[string]note.NoteFieldComparison)
 	sol, err := app.GetSolutionByName(solName)
 	if err != nil {
 		return nil, nil, err
 	}
+	if !failFast {
+		for i, result := range app.verifyEach(sol) {
+			if result.err != nil {
+				return nil, nil, result.err
+			} else if !result.conforming {
+				unsatisfiedNotes = append(unsatisfiedNotes, sol[i])
+			}
+			comparisons[sol[i]] = result.comparisons
+		}
+		return
+	}
+	// Stopping at the first deviating note requires to verify the notes one after another
 	for _, note := range sol {
 		conforming, noteComparisons, err := app.VerifyNote(note)
 		if err != nil {
@@ -361,38 +1741,525 @@ func (app *App) VerifySolution(solName string) (unsatisfiedNotes []string, compa
 			unsatisfiedNotes = append(unsatisfiedNotes, note)
 		}
 		comparisons[note] = noteComparisons
+		if !conforming {
+			break
+		}
+	}
+	return
+}
+
+// ComplianceScore summarises how many of the inspected note parameters conform to their expected values.
+type ComplianceScore struct {
+	TotalParameters      int     `json:"total"`      // number of parameters inspected across all notes
+	ConformingParameters int     `json:"conforming"` // number of inspected parameters that match the expectation
+	Percentage           float64 `json:"percentage"` // ConformingParameters in percent of TotalParameters, 100 if nothing was inspected
+}
+
+/*
+Calculate the compliance score from note comparison results, counting tunable parameters only.
+A system without any inspected parameter (e.g. nothing is enabled) is considered to be fully compliant (100%).
+*/
+func GetComplianceScore(comparisons map[string]map[string]note.NoteFieldComparison) (score ComplianceScore) {
+	for _, noteComparisons := range comparisons {
+		for _, comparison := range noteComparisons {
+			if !comparison.IsParameter() {
+				continue
+			}
+			score.TotalParameters++
+			if comparison.MatchExpectation {
+				score.ConformingParameters++
+			}
+		}
+	}
+	score.Percentage = 100
+	if score.TotalParameters > 0 {
+		score.Percentage = float64(score.ConformingParameters) * 100 / float64(score.TotalParameters)
 	}
 	return
 }
 
+// A note comparison together with the note it belongs to.
+type NoteComparison struct {
+	NoteID     string
+	Comparison note.NoteFieldComparison
+}
+
+/*
+Regroup note comparison results by the category of their parameters, category VS comparisons sorted by note ID and
+parameter ID. Parameters whose note does not declare a category fall into note.Uncategorised.
+*/
+func (app *App) GroupByCategory(comparisons map[string]map[string]note.NoteFieldComparison) map[string][]NoteComparison {
+	groups := make(map[string][]NoteComparison)
+	for noteID, noteComparisons := range comparisons {
+		aNote, _ := app.GetNoteByID(noteID)
+		categories := note.GetParamCategories(aNote)
+		for _, comparison := range noteComparisons {
+			category := note.GetParamCategory(categories, comparison)
+			groups[category] = append(groups[category], NoteComparison{NoteID: noteID, Comparison: comparison})
+		}
+	}
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].NoteID != group[j].NoteID {
+				return group[i].NoteID < group[j].NoteID
+			}
+			return group[i].Comparison.ParamID < group[j].Comparison.ParamID
+		})
+	}
+	return groups
+}
+
 /*
 Inspect the system and verify all parameters against all enabled notes/solutions.
-The note comparison results will always contain all fields from all notes.
+The note comparison results will always contain all fields from all notes that could be inspected.
+A note that fails to inspect the system does not stop the verification of other notes, its error is returned
+separately, note ID VS error.
+*/
+func (app *App) VerifyAll() (unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison, noteErrs map[string]error) {
+	return app.VerifyAllExcept([]string{})
+}
+
+// Inspect the system and verify all parameters against all enabled notes, except the notes to skip.
+func (app *App) VerifyAllExcept(skipNotes []string) (unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison, noteErrs map[string]error) {
+	skip := make(map[string]struct{})
+	for _, noteID := range skipNotes {
+		skip[noteID] = struct{}{}
+	}
+	noteIDs := make([]string, 0, 0)
+	for _, noteID := range app.GetSortedAllEnabledNotes() {
+		if _, found := skip[noteID]; !found {
+			noteIDs = append(noteIDs, noteID)
+		}
+	}
+	return app.VerifyNotes(noteIDs)
+}
+
+/*
+Inspect the system and verify all parameters against the notes, no matter they are enabled or not. A note that is
+unknown or fails to inspect the system does not stop the verification of other notes, its error is returned
+separately, note ID VS error.
 */
-func (app *App) VerifyAll() (unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison, err error) {
+func (app *App) VerifyNotes(noteIDs []string) (unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison, noteErrs map[string]error) {
 	unsatisfiedNotes = make([]string, 0, 0)
 	comparisons = make(map[string]map[string]note.NoteFieldComparison)
-	for _, solName := range app.TuneForSolutions {
-		// Collect field comparison results from solution notes
-		unsatisfiedSolNotes, noteComparisons, err := app.VerifySolution(solName)
+	noteErrs = make(map[string]error)
+	for i, result := range app.verifyEach(noteIDs) {
+		if result.err != nil {
+			noteErrs[noteIDs[i]] = result.err
+			continue
+		} else if !result.conforming {
+			unsatisfiedNotes = append(unsatisfiedNotes, noteIDs[i])
+		}
+		comparisons[noteIDs[i]] = result.comparisons
+	}
+	return
+}
+
+// The outcome of VerifyNote for a single note.
+type noteVerification struct {
+	conforming  bool
+	comparisons map[string]note.NoteFieldComparison
+	err         error
+}
+
+/*
+Verify the notes like VerifyNote, up to app.Parallel of them at a time, and return the outcomes in the order of the
+note IDs. Hence the outcome does not depend on the degree of parallelism, only the time it takes does.
+*/
+func (app *App) verifyEach(noteIDs []string) []noteVerification {
+	results := make([]noteVerification, len(noteIDs))
+	workers := app.Parallel
+	if workers < 1 {
+		workers = 1
+	}
+	if len(noteIDs) > 1 {
+		log.Printf("Verifying %d notes, up to %d at a time", len(noteIDs), workers)
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers && worker < len(noteIDs); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				result := &results[i]
+				result.conforming, result.comparisons, result.err = app.VerifyNote(noteIDs[i])
+			}
+		}()
+	}
+	for i := range noteIDs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+/*
+Read note IDs from a file, separated by spaces or line breaks. Text following # on a line is a comment.
+Duplicated IDs are only returned once, in the order of their first appearance.
+*/
+func ReadNoteListFile(filePath string) (noteIDs []string, err error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+	noteIDs = make([]string, 0, 0)
+	seen := make(map[string]struct{})
+	for _, line := range strings.Split(string(content), "\n") {
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		for _, noteID := range strings.Fields(line) {
+			if _, found := seen[noteID]; !found {
+				seen[noteID] = struct{}{}
+				noteIDs = append(noteIDs, noteID)
+			}
+		}
+	}
+	return
+}
+
+// Return the enabled notes that were applied within the time window before now, sorted by note ID.
+func (app *App) GetRecentlyAppliedNotes(window time.Duration) (noteIDs []string) {
+	noteIDs = make([]string, 0, 0)
+	for _, noteID := range app.GetSortedAllEnabledNotes() {
+		if applyTime, err := app.State.GetApplyTime(noteID); err == nil && time.Since(applyTime) < window {
+			noteIDs = append(noteIDs, noteID)
+		}
+	}
+	return
+}
+
+// A note that defines a parameter, together with the value it recommends and the current value.
+type ParameterSource struct {
+	NoteID     string
+	Enabled    bool                     // the note is enabled by a solution or manually
+	Comparison note.NoteFieldComparison // the expected value is the one recommended by the note
+}
+
+/*
+Return true only if the compared parameter is named so, case-insensitively, either by its comparison name, its sysctl
+name, or the structure field name of a built-in note.
+*/
+func isParameterNamed(name string, comparison note.NoteFieldComparison, paramName string) bool {
+	return strings.EqualFold(name, paramName) || strings.EqualFold(comparison.ReflectMapKey, paramName) ||
+		(comparison.ReflectMapKey == "" && strings.EqualFold(comparison.ReflectFieldName, paramName))
+}
+
+/*
+Return all notes that define the parameter, sorted by note ID. The parameter is matched case-insensitively against
+sysctl names of tuning sheets and against structure field names of built-in notes, e.g. "vm.swappiness" or
+"VMSwappiness". Notes that fail to inspect the system are logged and left out.
+*/
+func (app *App) InspectParameter(paramName string) (sources []ParameterSource) {
+	sources = make([]ParameterSource, 0, 0)
+	enabledNotes := app.GetSortedAllEnabledNotes()
+	noteIDs := make([]string, 0, len(app.AllNotes))
+	for noteID := range app.AllNotes {
+		noteIDs = append(noteIDs, noteID)
+	}
+	sort.Strings(noteIDs)
+	for _, noteID := range noteIDs {
+		_, comparisons, err := app.VerifyNote(noteID)
 		if err != nil {
-			return nil, nil, err
-		} else if len(unsatisfiedSolNotes) > 0 {
-			unsatisfiedNotes = append(unsatisfiedNotes, unsatisfiedSolNotes...)
+			log.Printf("InspectParameter: failed to inspect note %s - %v", noteID, err)
+			continue
 		}
-		for noteName, noteComparisonResult := range noteComparisons {
-			comparisons[noteName] = noteComparisonResult
+		for name, comparison := range comparisons {
+			if !isParameterNamed(name, comparison, paramName) {
+				continue
+			}
+			i := sort.SearchStrings(enabledNotes, noteID)
+			sources = append(sources, ParameterSource{
+				NoteID:     noteID,
+				Enabled:    i < len(enabledNotes) && enabledNotes[i] == noteID,
+				Comparison: comparison,
+			})
 		}
 	}
-	for _, noteID := range app.TuneForNotes {
-		// Collect field comparison results from additionally tuned notes
-		conforming, noteComparisons, err := app.VerifyNote(noteID)
+	return
+}
+
+/*
+Return true only if the note defines the parameter, named as for InspectParameter, judging by its definition without
+inspecting the system. Notes whose definition cannot be read are assumed to define it, so that their failure surfaces.
+*/
+func (app *App) definesParameter(noteID, paramName string) bool {
+	aNote, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return true
+	}
+	declared, err := note.GetDeclaredParameters(aNote)
+	if err != nil {
+		return true
+	}
+	for name, comparison := range declared {
+		if isParameterNamed(name, comparison, paramName) {
+			return true
+		}
+	}
+	return false
+}
+
+// The outcome of verifying a single parameter managed by the enabled notes.
+type ParameterVerification struct {
+	NoteID     string                   // the enabled note that provides the value, i.e. the last one to define the parameter
+	Comparison note.NoteFieldComparison // the expected value is the one provided by that note
+	Overridden []string                 // the other enabled notes that define the parameter, in the order they are applied
+}
+
+/*
+Verify the parameter, named as for InspectParameter, against the value enforced by the enabled notes. A parameter
+defined by several notes takes the value of the note applied last in the order of TuneAll, customised and pinned
+values are taken into account. Only the enabled notes that define the parameter inspect the system, notes that fail
+to do so are logged and left out. Return an error if none of them defines the parameter.
+*/
+func (app *App) VerifyParameter(paramName string) (result ParameterVerification, err error) {
+	noteIDs, err := app.getTuneOrder()
+	if err != nil {
+		return
+	}
+	found := false
+	for _, noteID := range noteIDs {
+		if !app.definesParameter(noteID, paramName) {
+			continue
+		}
+		_, comparisons, err := app.VerifyNote(noteID)
 		if err != nil {
-			return nil, nil, err
-		} else if !conforming {
-			unsatisfiedNotes = append(unsatisfiedNotes, noteID)
+			log.Printf("VerifyParameter: failed to inspect note %s - %v", noteID, err)
+			continue
+		}
+		for name, comparison := range comparisons {
+			if !isParameterNamed(name, comparison, paramName) {
+				continue
+			}
+			if found {
+				result.Overridden = append(result.Overridden, result.NoteID)
+			}
+			result.NoteID, result.Comparison, found = noteID, comparison, true
+			break
+		}
+	}
+	if !found {
+		err = fmt.Errorf("Parameter \"%s\" is not managed by any enabled note.", paramName)
+	}
+	return
+}
+
+// A parameter that two or more notes recommend different values for.
+type ParameterConflict struct {
+	Name    string            // comparison name of the parameter, e.g. SysctlParams[vm.swappiness]
+	Sources []ParameterSource // the notes defining the parameter, sorted by note ID
+}
+
+// Return the parameters that the notes recommend different values for, sorted by parameter name.
+func (app *App) GetParameterConflicts(noteIDs []string) (conflicts []ParameterConflict, err error) {
+	conflicts = make([]ParameterConflict, 0, 0)
+	enabledNotes := app.GetSortedAllEnabledNotes()
+	sortedIDs := make([]string, len(noteIDs))
+	copy(sortedIDs, noteIDs)
+	sort.Strings(sortedIDs)
+	sources := make(map[string][]ParameterSource)
+	for _, noteID := range sortedIDs {
+		_, comparisons, err := app.VerifyNote(noteID)
+		if err != nil {
+			return nil, err
+		}
+		i := sort.SearchStrings(enabledNotes, noteID)
+		for name, comparison := range note.FilterParameters(comparisons) {
+			sources[name] = append(sources[name], ParameterSource{
+				NoteID:     noteID,
+				Enabled:    i < len(enabledNotes) && enabledNotes[i] == noteID,
+				Comparison: comparison,
+			})
+		}
+	}
+	for name, paramSources := range sources {
+		for _, source := range paramSources[1:] {
+			if source.Comparison.ExpectedValueJS != paramSources[0].Comparison.ExpectedValueJS {
+				conflicts = append(conflicts, ParameterConflict{Name: name, Sources: paramSources})
+				break
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Name < conflicts[j].Name
+	})
+	return
+}
+
+// A parameter managed by a set of notes, together with the note that ultimately provides its value.
+type ManagedParameter struct {
+	Name   string `json:"name"`    // comparison name of the parameter, e.g. SysctlParams[vm.swappiness]
+	NoteID string `json:"note_id"` // the last of the notes that defines the parameter
+	Value  string `json:"value"`   // the value recommended by that note, in JSON
+}
+
+/*
+Return the union of parameters managed by the notes, sorted by parameter name. The notes are applied in the given
+order, hence a parameter takes the value recommended by the last note that defines it. Customised values are taken
+into account, the system is inspected but not changed.
+*/
+func (app *App) GetManagedParameters(noteIDs []string) (params []ManagedParameter, err error) {
+	comparisons := make(map[string]map[string]note.NoteFieldComparison)
+	for _, noteID := range noteIDs {
+		if _, comparisons[noteID], err = app.VerifyNote(noteID); err != nil {
+			return nil, err
+		}
+	}
+	return getManagedParameters(noteIDs, comparisons), nil
+}
+
+/*
+Return the union of parameters managed by the notes from their comparison results, like GetManagedParameters. Fields
+that describe the notes themselves are not managed parameters.
+*/
+func getManagedParameters(noteIDs []string, comparisons map[string]map[string]note.NoteFieldComparison) (params []ManagedParameter) {
+	managed := make(map[string]ManagedParameter)
+	for _, noteID := range noteIDs {
+		for name, comparison := range note.FilterParameters(comparisons[noteID]) {
+			managed[name] = ManagedParameter{Name: name, NoteID: noteID, Value: comparison.ExpectedValueJS}
+		}
+	}
+	params = make([]ManagedParameter, 0, len(managed))
+	for _, param := range managed {
+		params = append(params, param)
+	}
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+	return
+}
+
+/*
+Return the parameters managed system-wide by all notes enabled manually or by a solution. The notes are taken in the
+order TuneAll applies them, hence a parameter takes the value of the note that is applied last. Nothing is returned if
+no note is enabled.
+*/
+func (app *App) GetAllManagedParameters() ([]ManagedParameter, error) {
+	noteIDs, err := app.getTuneOrder()
+	if err != nil {
+		return nil, err
+	}
+	return app.GetManagedParameters(noteIDs)
+}
+
+// A definition that contributes to a note, either a file or the implementation built into saptune.
+type NoteSource struct {
+	Origin string // how the source contributes, e.g. "tuning sheet" or "customisation file"
+	Path   string // path to the file, empty for the built-in implementation
+}
+
+/*
+Return the sources of the note definition in order of precedence, the base definition first. Each source overrides
+the values of those before it. Only existing override, customisation, and pin files are returned, the system is not inspected.
+*/
+func (app *App) GetNoteSources(noteID string) (sources []NoteSource, err error) {
+	aNote, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return
+	}
+	sources = make([]NoteSource, 0, 4)
+	if sheet, isSheet := aNote.(note.INISettings); isSheet {
+		if sheet.BaseID != "" {
+			sources = append(sources, NoteSource{Origin: fmt.Sprintf("included built-in implementation of note %s", sheet.BaseID)})
+		}
+		for _, filePath := range sheet.IncludedFilePaths {
+			sources = append(sources, NoteSource{Origin: "included tuning sheet", Path: filePath})
+		}
+		origin := "tuning sheet"
+		if _, isAdHoc := app.AdHocNotes[noteID]; isAdHoc {
+			origin = "ad hoc tuning sheet"
+		}
+		sources = append(sources, NoteSource{Origin: origin, Path: sheet.ConfFilePath})
+	} else {
+		sources = append(sources, NoteSource{Origin: "built-in implementation"})
+	}
+	if app.IsNoteOverridden(noteID) {
+		sources = append(sources, NoteSource{Origin: "override file", Path: app.GetOverrideFilePath(noteID)})
+	}
+	customiseFile := app.GetCustomiseFilePath(noteID)
+	if _, err := os.Stat(customiseFile); err == nil {
+		sources = append(sources, NoteSource{Origin: "customisation file", Path: customiseFile})
+	}
+	pinFile := path.Join(app.State.StateDirPrefix, SaptunePinnedDir, noteID)
+	if _, err := os.Stat(pinFile); err == nil {
+		sources = append(sources, NoteSource{Origin: "pinned values", Path: pinFile})
+	}
+	return
+}
+
+/*
+Run the external checker command configured for the solution and return the non-empty lines of its output as
+findings. If no checker is configured, there are no findings and no error. A checker that cannot be started or
+exits abnormally results in an error, findings printed until then are still returned.
+*/
+func (app *App) RunExternalCheck(solName string) (findings []string, err error) {
+	findings = make([]string, 0, 0)
+	command := strings.Fields(app.ExternalChecks[solName])
+	if len(command) == 0 {
+		return
+	}
+	out, err := exec.Command(command[0], command[1:]...).Output()
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			findings = append(findings, line)
 		}
-		comparisons[noteID] = noteComparisons
+	}
+	if err != nil {
+		err = fmt.Errorf("external checker \"%s\" of solution %s failed - %v", app.ExternalChecks[solName], solName, err)
 	}
 	return
 }
+
+// Return the path to the sysctl drop-in file that persists the sysctl parameters of the note.
+func (app *App) GetSysctlDropInPath(noteID string) string {
+	return path.Join(app.SysconfigPrefix, system.SysctlDropInDir, "99-saptune-"+noteID+".conf")
+}
+
+/*
+Write the optimised sysctl parameters of the note into a sysctl drop-in file, so that they survive a reboot without
+the daemon. Return the names of the persisted sysctl parameters and the names of the note's parameters that cannot be
+persisted in this way, both sorted. If nothing is persisted, no drop-in file is written. The drop-in file is removed
+when the note is reverted.
+*/
+func (app *App) PersistNoteSysctl(noteID string) (persisted, notPersisted []string, err error) {
+	aNote, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return nil, nil, err
+	}
+	currentState, err := aNote.Initialise()
+	if err != nil {
+		return nil, nil, err
+	}
+	optimised, err := app.optimiseNote(noteID, currentState)
+	if err != nil {
+		return nil, nil, err
+	}
+	persisted = make([]string, 0, 0)
+	sysctlNote, ok := optimised.(note.SysctlPersistable)
+	if !ok {
+		// None of the parameters is known to be a sysctl parameter
+		_, comparisons := note.CompareNoteFields(optimised, optimised)
+		notPersisted = make([]string, 0, len(comparisons))
+		for name, comparison := range comparisons {
+			if comparison.IsParameter() {
+				notPersisted = append(notPersisted, name)
+			}
+		}
+		sort.Strings(notPersisted)
+		return
+	}
+	sysctlValues, notPersisted := sysctlNote.SysctlValues()
+	sort.Strings(notPersisted)
+	if len(sysctlValues) == 0 {
+		return
+	}
+	for name := range sysctlValues {
+		persisted = append(persisted, name)
+	}
+	sort.Strings(persisted)
+	comment := fmt.Sprintf("Written by saptune for note %s, removed upon `saptune note revert %s`.", noteID, noteID)
+	return persisted, notPersisted, system.WriteSysctlDropIn(app.GetSysctlDropInPath(noteID), comment, sysctlValues)
+}
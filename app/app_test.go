@@ -1,15 +1,20 @@
 package app
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/HouzuoGuo/saptune/sap/note"
 	"github.com/HouzuoGuo/saptune/sap/param"
 	"github.com/HouzuoGuo/saptune/sap/solution"
+	"github.com/HouzuoGuo/saptune/system"
 	"io/ioutil"
 	"os"
 	"path"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 var OSPackageInGOPATH = path.Join(os.Getenv("GOPATH"), "/src/github.com/HouzuoGuo/saptune/ospackage/")
@@ -416,7 +421,1331 @@ func TestVerifyNoteAndSolutions(t *testing.T) {
 	if notes, comparisons, err := tuneApp.VerifySolution("sol12"); err != nil || len(notes) != 1 || len(comparisons) != 2 || notes[0] != "1001" {
 		t.Fatal(notes, comparisons, err)
 	}
-	if notes, comparisons, err := tuneApp.VerifyAll(); err != nil || len(notes) != 1 || len(comparisons) != 2 || notes[0] != "1001" {
+	if notes, comparisons, noteErrs := tuneApp.VerifyAll(); len(noteErrs) != 0 || len(notes) != 1 || len(comparisons) != 2 || notes[0] != "1001" {
+		t.Fatal(notes, comparisons, noteErrs)
+	}
+}
+
+func TestGetComplianceScore(t *testing.T) {
+	if score := GetComplianceScore(map[string]map[string]note.NoteFieldComparison{}); score.TotalParameters != 0 || score.Percentage != 100 {
+		t.Fatal(score)
+	}
+	comparisons := map[string]map[string]note.NoteFieldComparison{
+		"1001": {"A": {MatchExpectation: true}, "B": {MatchExpectation: false}},
+		"1002": {"C": {MatchExpectation: true}, "D": {MatchExpectation: true}, "ID": {ReflectFieldName: "ID", MatchExpectation: true}},
+	}
+	// Fields describing the note are not parameters
+	if score := GetComplianceScore(comparisons); score.TotalParameters != 4 || score.ConformingParameters != 3 || score.Percentage != 75 {
+		t.Fatal(score)
+	}
+}
+
+func TestGetSortedAllEnabledNotes(t *testing.T) {
+	tuneApp := InitialiseApp(OSPackageInGOPATH, "", AllTestNotes, AllTestSolutions)
+	tuneApp.TuneForSolutions = []string{"sol1"}
+	tuneApp.TuneForNotes = []string{"1001", "1002"}
+	if notes := tuneApp.GetSortedAllEnabledNotes(); !reflect.DeepEqual(notes, []string{"1001", "1002"}) {
+		t.Fatal(notes)
+	}
+	tuneApp.TuneForSolutions = []string{}
+	tuneApp.TuneForNotes = []string{}
+	if notes := tuneApp.GetSortedAllEnabledNotes(); len(notes) != 0 {
+		t.Fatal(notes)
+	}
+}
+
+func TestRevertManualNotes(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if _, err := tuneApp.TuneSolution("sol1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.TuneNote("1002"); err != nil {
+		t.Fatal(err)
+	}
+	// Pretend that note 1001 has been enabled manually before the solution was
+	tuneApp.TuneForNotes = []string{"1001", "1002"}
+	reverted, skipped, err := tuneApp.RevertManualNotes()
+	if err != nil || !reflect.DeepEqual(reverted, []string{"1002"}) || !reflect.DeepEqual(skipped, []string{"1001"}) {
+		t.Fatal(reverted, skipped, err)
+	}
+	VerifyConfig(t, tuneApp, []string{"1001"}, []string{"sol1"})
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+}
+
+func TestRefreshNote(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if _, err := tuneApp.RefreshNote("1001"); err == nil {
+		t.Fatal("did not error")
+	}
+	if err := tuneApp.TuneNote("1001"); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := tuneApp.RefreshNote("1001"); err != nil || len(changes) != 0 {
+		t.Fatal(changes, err)
+	}
+	// A value that drifted on the system is not a change of the definition
+	WriteFileOrPanic(SampleParamFile, "changed")
+	if changes, err := tuneApp.RefreshNote("1001"); err != nil || len(changes) != 0 {
+		t.Fatal(changes, err)
+	}
+	VerifyFileContent(t, SampleParamFile, "changed")
+	// Simulate a changed definition
+	tuneApp.AllNotes["1001"] = SampleNote2{}
+	defer func() {
+		tuneApp.AllNotes["1001"] = SampleNote1{}
+	}()
+	changes, err := tuneApp.RefreshNote("1001")
+	if err != nil || len(changes) != 1 || changes["Param"].ActualValueJS != `{"Data":"optimised1"}` || changes["Param"].ExpectedValueJS != `{"Data":"optimised2"}` {
+		t.Fatal(changes, err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised2")
+	if changes, err := tuneApp.RefreshNote("1001"); err != nil || len(changes) != 0 {
+		t.Fatal(changes, err)
+	}
+	// The original state is still revertible
+	if err := tuneApp.RevertNote("1001", true); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "")
+}
+
+// A note of two parameters kept in files, either of which can be applied alone.
+type PairNote struct {
+	First, Second string
+}
+
+// The value PairNote calculates for its second parameter, tests change it to change the definition of the note.
+var pairSecondValue = "2"
+
+func (n PairNote) Name() string {
+	return "pair note"
+}
+func (n PairNote) Initialise() (note.Note, error) {
+	first, _ := ioutil.ReadFile(path.Join(SampleNoteDataDir, "first"))
+	second, _ := ioutil.ReadFile(path.Join(SampleNoteDataDir, "second"))
+	n.First, n.Second = string(first), string(second)
+	return n, nil
+}
+func (n PairNote) Optimise() (note.Note, error) {
+	n.First, n.Second = "1", pairSecondValue
+	return n, nil
+}
+func (n PairNote) Apply() error {
+	return n.ApplyOnly([]string{"First", "Second"})
+}
+func (n PairNote) ApplyOnly(params []string) error {
+	for _, param := range params {
+		value := n.First
+		if param == "Second" {
+			value = n.Second
+		}
+		if err := ioutil.WriteFile(path.Join(SampleNoteDataDir, strings.ToLower(param)), []byte(value), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRefreshNoteChangedFields(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	defer func() {
+		pairSecondValue = "2"
+	}()
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), map[string]note.Note{"pair": PairNote{}}, AllTestSolutions)
+	if err := tuneApp.TuneNote("pair"); err != nil {
+		t.Fatal(err)
+	}
+	// The first parameter drifts, the definition of the second one changes
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "first"), "drifted")
+	pairSecondValue = "3"
+	changes, err := tuneApp.RefreshNote("pair")
+	if err != nil || len(changes) != 1 || changes["Second"].ActualValueJS != "2" || changes["Second"].ExpectedValueJS != "3" {
+		t.Fatal(changes, err)
+	}
+	// Only the changed field is applied
+	VerifyFileContent(t, path.Join(SampleNoteDataDir, "first"), "drifted")
+	VerifyFileContent(t, path.Join(SampleNoteDataDir, "second"), "3")
+	if applied, err := tuneApp.State.GetAppliedValues("pair"); err != nil || applied["First"] != "1" || applied["Second"] != "3" {
+		t.Fatal(applied, err)
+	}
+}
+
+func TestSaveSolutionSelector(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if tuneApp.SolutionSelector != "" {
+		t.Fatal(tuneApp.SolutionSelector)
+	}
+	tuneApp.SolutionSelector = "amd64_PC"
+	if err := tuneApp.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions); reloaded.SolutionSelector != "amd64_PC" {
+		t.Fatal(reloaded.SolutionSelector)
+	}
+	// The previous configuration is kept in the state directory rather than next to the configuration
+	if err := tuneApp.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if files, err := ioutil.ReadDir(path.Dir(path.Join(SampleNoteDataDir, "conf", SysconfigSaptuneDir))); err != nil || len(files) != 1 {
+		t.Fatal(files, err)
+	}
+	if _, err := os.Stat(path.Join(SampleNoteDataDir, "data", SysconfigBackupFile)); err != nil {
+		t.Fatal(err)
+	}
+	// An emptied configuration is restored from the backup
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "conf", SysconfigSaptuneDir), "")
+	if reloaded := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions); reloaded.SolutionSelector != "amd64_PC" {
+		t.Fatal(reloaded.SolutionSelector)
+	}
+	if content, err := ioutil.ReadFile(path.Join(SampleNoteDataDir, "conf", SysconfigSaptuneDir)); err != nil || !strings.Contains(string(content), "amd64_PC") {
+		t.Fatal(string(content), err)
+	}
+	// So is a damaged one
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "conf", SysconfigSaptuneDir), "TUNE_FOR_NOTES\x00\x00")
+	if reloaded := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions); reloaded.SolutionSelector != "amd64_PC" {
+		t.Fatal(reloaded.SolutionSelector)
+	}
+	if _, err := os.Stat(path.Join(SampleNoteDataDir, "data", SysconfigBackupFile+SysconfigDamagedSuffix)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTuneAdHocNote(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	allNotes := map[string]note.Note{"1001": SampleNote1{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if err := tuneApp.TuneAdHocNote("1001", "/tmp/whatever", SampleNote2{}); err == nil {
+		t.Fatal("did not error")
+	}
+	if err := tuneApp.TuneAdHocNote("adhoc", "/tmp/adhoc.conf", SampleNote2{}); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised2")
+	reloaded := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if !reflect.DeepEqual(reloaded.AdHocNotes, map[string]string{"adhoc": "/tmp/adhoc.conf"}) || !reflect.DeepEqual(reloaded.TuneForNotes, []string{"adhoc"}) {
+		t.Fatal(reloaded.AdHocNotes, reloaded.TuneForNotes)
+	}
+	if err := tuneApp.RevertNote("adhoc", true); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "")
+	reloaded = InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if len(reloaded.AdHocNotes) != 0 {
+		t.Fatal(reloaded.AdHocNotes)
+	}
+}
+
+func TestInspectParameter(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	tuneApp.TuneForNotes = []string{"1002"}
+	sources := tuneApp.InspectParameter("param")
+	if len(sources) != 2 || sources[0].NoteID != "1001" || sources[0].Enabled || sources[1].NoteID != "1002" || !sources[1].Enabled {
+		t.Fatal(sources)
+	}
+	if sources[0].Comparison.ExpectedValueJS != `{"Data":"optimised1"}` || sources[1].Comparison.ExpectedValueJS != `{"Data":"optimised2"}` {
+		t.Fatal(sources)
+	}
+	if sources := tuneApp.InspectParameter("does.not.exist"); len(sources) != 0 {
+		t.Fatal(sources)
+	}
+}
+
+func TestVerifyParameter(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if _, err := tuneApp.VerifyParameter("param"); err == nil {
+		t.Fatal("did not error")
+	}
+	tuneApp.TuneForNotes = []string{"1001", "1002"}
+	WriteFileOrPanic(SampleParamFile, "optimised2")
+	result, err := tuneApp.VerifyParameter("Param")
+	if err != nil || result.NoteID != "1002" || !reflect.DeepEqual(result.Overridden, []string{"1001"}) || !result.Comparison.MatchExpectation {
+		t.Fatal(result, err)
+	}
+	WriteFileOrPanic(SampleParamFile, "optimised1")
+	if result, err := tuneApp.VerifyParameter("param"); err != nil || result.Comparison.MatchExpectation || result.Comparison.ExpectedValueJS != `{"Data":"optimised2"}` {
+		t.Fatal(result, err)
+	}
+	// The notes of solutions are applied before the additional notes, and a note that fails does not stop the others
+	tuneApp.AllNotes = map[string]note.Note{"1001": SampleNote1{}, "1002": SampleNote2{}, "broken": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "missing.conf"), ID: "broken"}}
+	tuneApp.TuneForSolutions = []string{"sol2"}
+	tuneApp.TuneForNotes = []string{"1001", "broken"}
+	result, err = tuneApp.VerifyParameter("Param")
+	if err != nil || result.NoteID != "1001" || !reflect.DeepEqual(result.Overridden, []string{"1002"}) || !result.Comparison.MatchExpectation {
+		t.Fatal(result, err)
+	}
+}
+
+func TestRevertOverlappingNote(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	// Both sample notes manage the same parameter
+	if err := tuneApp.TuneNote("1001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.TuneNote("1002"); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised2")
+	// The parameter falls back to the value of the note that remains enabled
+	if err := tuneApp.RevertNote("1001", true); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised2")
+	VerifyConfig(t, tuneApp, []string{"1002"}, []string{})
+	// Without other notes the value from before note 1002 was applied is restored, which is the value of note 1001
+	// rather than the value from before any of the notes was applied
+	if err := tuneApp.RevertNote("1002", true); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+}
+
+func TestGetParameterConflicts(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if conflicts, err := tuneApp.GetParameterConflicts([]string{"1001"}); err != nil || len(conflicts) != 0 {
+		t.Fatal(conflicts, err)
+	}
+	conflicts, err := tuneApp.GetParameterConflicts([]string{"1002", "1001"})
+	if err != nil || len(conflicts) != 1 || conflicts[0].Name != "Param" || len(conflicts[0].Sources) != 2 || conflicts[0].Sources[0].NoteID != "1001" {
+		t.Fatal(conflicts, err)
+	}
+	if _, err := tuneApp.GetParameterConflicts([]string{"1001", "does-not-exist"}); err == nil {
+		t.Fatal("did not error")
+	}
+	// Tuning sheets always differ in their ID and file, which are not parameters
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "a.conf"), "[sysctl]\nvm.swappiness=10\n")
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "b.conf"), "[sysctl]\nvm.swappiness=10\n")
+	tuneApp = InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), map[string]note.Note{
+		"a": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "a.conf"), ID: "a"},
+		"b": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "b.conf"), ID: "b"},
+	}, AllTestSolutions)
+	if conflicts, err := tuneApp.GetParameterConflicts([]string{"a", "b"}); err != nil || len(conflicts) != 0 {
+		t.Fatal(conflicts, err)
+	}
+}
+
+func TestVerifyRecentlyAppliedNotes(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if err := tuneApp.TuneNote("1001"); err != nil {
+		t.Fatal(err)
+	}
+	if notes := tuneApp.GetRecentlyAppliedNotes(time.Hour); !reflect.DeepEqual(notes, []string{"1001"}) {
+		t.Fatal(notes)
+	}
+	if notes := tuneApp.GetRecentlyAppliedNotes(0); len(notes) != 0 {
+		t.Fatal(notes)
+	}
+	if _, comparisons, noteErrs := tuneApp.VerifyAllExcept([]string{"1001"}); len(noteErrs) != 0 || len(comparisons) != 0 {
+		t.Fatal(comparisons, noteErrs)
+	}
+	if err := tuneApp.RevertNote("1001", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tuneApp.State.GetApplyTime("1001"); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestRunExternalCheck(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(path.Join(SampleNoteDataDir, "conf", path.Dir(SysconfigSaptuneDir)), 0755)
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "conf", SysconfigSaptuneDir), `EXTERNAL_CHECK_sol1="echo finding"
+EXTERNAL_CHECK_sol2="/does/not/exist"
+`)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if findings, err := tuneApp.RunExternalCheck("sol1"); err != nil || !reflect.DeepEqual(findings, []string{"finding"}) {
+		t.Fatal(findings, err)
+	}
+	if findings, err := tuneApp.RunExternalCheck("sol2"); err == nil || len(findings) != 0 {
+		t.Fatal(findings, err)
+	}
+	if findings, err := tuneApp.RunExternalCheck("sol12"); err != nil || len(findings) != 0 {
+		t.Fatal(findings, err)
+	}
+}
+
+func TestTuneNoteEphemeral(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if err := tuneApp.TuneNoteEphemeral("1001"); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+	VerifyConfig(t, tuneApp, []string{}, []string{})
+	if states, err := tuneApp.State.List(); err != nil || len(states) != 0 {
+		t.Fatal(states, err)
+	}
+	if err := tuneApp.TuneNoteEphemeral("does-not-exist"); err == nil {
+		t.Fatal("did not error")
+	}
+}
+
+func TestPersistNoteSysctl(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	// The sample notes do not have sysctl parameters
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if persisted, notPersisted, err := tuneApp.PersistNoteSysctl("1001"); err != nil || len(persisted) != 0 || !reflect.DeepEqual(notPersisted, []string{"Param"}) {
+		t.Fatal(persisted, notPersisted, err)
+	}
+	if _, err := os.Stat(tuneApp.GetSysctlDropInPath("1001")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	// Tuning sheets do
+	allNotes := map[string]note.Note{"ini": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini"}}
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=10\n[vm]\nTHP=never\n")
+	tuneApp = InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if persisted, notPersisted, err := tuneApp.PersistNoteSysctl("ini"); err != nil || !reflect.DeepEqual(persisted, []string{"vm.swappiness"}) || !reflect.DeepEqual(notPersisted, []string{"THP"}) {
+		t.Fatal(persisted, notPersisted, err)
+	}
+	VerifyFileContent(t, tuneApp.GetSysctlDropInPath("ini"), "# Written by saptune for note ini, removed upon `saptune note revert ini`.\nvm.swappiness = 10\n")
+	if err := tuneApp.RevertNote("ini", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tuneApp.GetSysctlDropInPath("ini")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestCustomiseOverrides(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(path.Join(SampleNoteDataDir, "conf/etc/sysconfig"), 0755)
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nnet.ipv4.conf.all.rp_filter=2\nnet.ipv4.conf.default.rp_filter=2\nvm.swappiness=10\n")
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "conf", fmt.Sprintf(note.CustomiseFileTemplate, "ini")), `/rp_filter$/="1"
+net.ipv4.conf.default.rp_filter="0"
+`)
+	allNotes := map[string]note.Note{"ini": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini"}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	_, comparisons, err := tuneApp.VerifyNote("ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"SysctlParams[net.ipv4.conf.all.rp_filter]":     "1",
+		"SysctlParams[net.ipv4.conf.default.rp_filter]": "0",
+		"SysctlParams[vm.swappiness]":                   "10",
+	} {
+		if comparisons[name].ExpectedValueJS != expected {
+			t.Fatal(name, comparisons[name])
+		}
+	}
+}
+
+func TestOverrideFile(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(path.Join(SampleNoteDataDir, "conf/etc/saptune/override"), 0755)
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=10\nvm.dirty_ratio=10\nvm.dirty_background_ratio=5\n")
+	allNotes := map[string]note.Note{"ini": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini"}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if tuneApp.IsNoteOverridden("ini") {
+		t.Fatal("overridden without override file")
+	}
+	WriteFileOrPanic(tuneApp.GetOverrideFilePath("ini"), "vm.swappiness=\"20\"\nvm.dirty_ratio=\"20\"\n")
+	// The customisation file takes precedence over the override file
+	if err := tuneApp.CustomiseNote("ini", map[string]string{"vm.dirty_ratio": "30"}); err != nil {
+		t.Fatal(err)
+	}
+	_, comparisons, err := tuneApp.VerifyNote("ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"SysctlParams[vm.swappiness]":             "20",
+		"SysctlParams[vm.dirty_ratio]":            "30",
+		"SysctlParams[vm.dirty_background_ratio]": "5",
+	} {
+		if comparisons[name].ExpectedValueJS != expected {
+			t.Fatal(name, comparisons[name])
+		}
+	}
+	if !tuneApp.IsNoteOverridden("ini") {
+		t.Fatal("not overridden")
+	}
+	modification, err := tuneApp.GetLocalModification("ini")
+	if err != nil || !modification.Overridden || !modification.Customised || len(modification.Differences) != 2 {
+		t.Fatal(modification, err)
+	}
+	sources, err := tuneApp.GetNoteSources("ini")
+	if err != nil || len(sources) != 3 || sources[1].Origin != "override file" || sources[1].Path != tuneApp.GetOverrideFilePath("ini") {
+		t.Fatal(sources, err)
+	}
+}
+
+func TestCustomiseNoteReferences(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(path.Join(SampleNoteDataDir, "conf/etc/sysconfig"), 0755)
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "a.conf"), "[sysctl]\nvm.swappiness=10\n")
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "b.conf"), "[sysctl]\nvm.swappiness=60\nvm.dirty_ratio=20\n")
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "conf", fmt.Sprintf(note.CustomiseFileTemplate, "a")), `vm.swappiness="25"`+"\n")
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "conf", fmt.Sprintf(note.CustomiseFileTemplate, "b")), `vm.swappiness="@note:a:vm.swappiness"`+"\n")
+	allNotes := map[string]note.Note{
+		"a": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "a.conf"), ID: "a"},
+		"b": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "b.conf"), ID: "b"},
+	}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	// The referenced value includes the customisation of the other note
+	_, comparisons, err := tuneApp.VerifyNote("b")
+	if err != nil || comparisons["SysctlParams[vm.swappiness]"].ExpectedValueJS != "25" || comparisons["SysctlParams[vm.dirty_ratio]"].ExpectedValueJS != "20" {
+		t.Fatal(comparisons, err)
+	}
+	// Circular references are detected
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "conf", fmt.Sprintf(note.CustomiseFileTemplate, "a")), `vm.swappiness="@note:b:vm.dirty_ratio"`+"\n")
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "conf", fmt.Sprintf(note.CustomiseFileTemplate, "b")), `vm.dirty_ratio="@note:a:vm.swappiness"`+"\n")
+	if _, _, err := tuneApp.VerifyNote("a"); err == nil || !strings.Contains(err.Error(), "circular reference a -> b -> a") {
+		t.Fatal(err)
+	}
+	if err := tuneApp.TuneNote("b"); err == nil || !strings.Contains(err.Error(), "circular reference b -> a -> b") {
+		t.Fatal(err)
+	}
+	// References to unknown notes and parameters are reported
+	for _, ref := range []string{"@note:c:vm.swappiness", "@note:b:vm.does_not_exist"} {
+		WriteFileOrPanic(path.Join(SampleNoteDataDir, "conf", fmt.Sprintf(note.CustomiseFileTemplate, "a")), `vm.swappiness="`+ref+`"`+"\n")
+		if _, _, err := tuneApp.VerifyNote("a"); err == nil {
+			t.Fatal(ref)
+		}
+	}
+}
+
+func TestTuneSolutionProgress(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	var progress bytes.Buffer
+	tuneApp.Progress = &progress
+	if _, err := tuneApp.TuneSolution("sol12"); err != nil {
+		t.Fatal(err)
+	}
+	if progress.String() != "[1/2] applying 1001 ...\n[2/2] applying 1002 ...\n" {
+		t.Fatal(progress.String())
+	}
+}
+
+func TestSolutionExclusions(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if _, err := tuneApp.TuneSolution("sol12"); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised2")
+	if notInSolution, err := tuneApp.SetSolutionExclusions("sol12", []string{"1002", "9999"}); err != nil || !reflect.DeepEqual(notInSolution, []string{"9999"}) {
+		t.Fatal(notInSolution, err)
+	}
+	// The exclusions are saved only once the solution is tuned
+	if reloaded := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions); len(reloaded.SolutionExclusions) != 0 {
+		t.Fatal(reloaded.SolutionExclusions)
+	}
+	// The newly excluded note is no longer applied
+	if _, err := tuneApp.TuneSolution("sol12"); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+	if tuneApp.State.IsSaved("1002") || !tuneApp.State.IsSaved("1001") {
+		t.Fatal("the state of the excluded note remains")
+	}
+	// The exclusions survive a reload and apply to verification
+	reloaded := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if _, comparisons, err := reloaded.VerifySolution("sol12"); err != nil || len(comparisons) != 1 || comparisons["1001"] == nil {
+		t.Fatal(comparisons, err)
+	}
+	if notes := reloaded.GetSortedSolutionEnabledNotes(); !reflect.DeepEqual(notes, []string{"1001"}) {
+		t.Fatal(notes)
+	}
+	// Reverting the solution forgets the exclusions
+	if err := reloaded.RevertSolution("sol12"); err != nil {
+		t.Fatal(err)
+	}
+	if sol, err := reloaded.GetSolutionByName("sol12"); err != nil || len(sol) != 2 {
+		t.Fatal(sol, err)
+	}
+	if _, err := reloaded.SetSolutionExclusions("does-not-exist", []string{"1001"}); err == nil {
+		t.Fatal("did not error")
+	}
+}
+
+type FailingNote struct {
+	SampleNote1
+}
+
+func (n FailingNote) Initialise() (note.Note, error) {
+	return n, fmt.Errorf("failing note")
+}
+
+func TestVerifyAllWithFailingNote(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	allNotes := map[string]note.Note{"1001": SampleNote1{}, "failing": FailingNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	tuneApp.TuneForNotes = []string{"1001", "failing"}
+	unsatisfiedNotes, comparisons, noteErrs := tuneApp.VerifyAll()
+	if !reflect.DeepEqual(unsatisfiedNotes, []string{"1001"}) || len(comparisons) != 1 || len(noteErrs) != 1 || noteErrs["failing"] == nil {
+		t.Fatal(unsatisfiedNotes, comparisons, noteErrs)
+	}
+}
+
+func TestTuneAllReporting(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	allNotes := map[string]note.Note{"1001": SampleNote1{}, "1002": SampleNote2{}, "failing": FailingNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	tuneApp.TuneForSolutions = []string{"sol1"}
+	tuneApp.TuneForNotes = []string{"1001", "failing", "1002"}
+	reported := make([]string, 0, 0)
+	err := tuneApp.TuneAllReporting(func(noteID string, err error) {
+		reported = append(reported, fmt.Sprintf("%s:%v", noteID, err == nil))
+	})
+	// The failing note does not stop the others, the note shared by the solution is only applied once
+	if err == nil || !reflect.DeepEqual(reported, []string{"1001:true", "failing:false", "1002:true"}) {
+		t.Fatal(reported, err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised2")
+	// TuneAll stops at the failing note
+	tuneApp.TuneForNotes = []string{"failing", "1001"}
+	if err := tuneApp.TuneAll(); err == nil {
+		t.Fatal("did not error")
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+}
+
+func TestVerifyNotesInParallel(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	allNotes := map[string]note.Note{"failing": FailingNote{}}
+	noteIDs := make([]string, 0, 0)
+	for i := 0; i < 10; i++ {
+		noteID := fmt.Sprintf("%d", 1001+i)
+		allNotes[noteID] = SampleNote1{}
+		noteIDs = append(noteIDs, noteID, "failing")
+	}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	tuneApp.Parallel = 1
+	sequential, sequentialComparisons, sequentialErrs := tuneApp.VerifyNotes(noteIDs)
+	if len(sequential) != 10 || len(sequentialComparisons) != 10 || len(sequentialErrs) != 1 {
+		t.Fatal(sequential, sequentialComparisons, sequentialErrs)
+	}
+	for _, parallel := range []int{0, 3, 100} {
+		tuneApp.Parallel = parallel
+		unsatisfiedNotes, comparisons, noteErrs := tuneApp.VerifyNotes(noteIDs)
+		if !reflect.DeepEqual(unsatisfiedNotes, sequential) || !reflect.DeepEqual(comparisons, sequentialComparisons) || len(noteErrs) != 1 {
+			t.Fatal(parallel, unsatisfiedNotes, comparisons, noteErrs)
+		}
+	}
+}
+
+func TestStagedNotes(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if err := tuneApp.TuneNote("1002"); err != nil {
+		t.Fatal(err)
+	}
+	// Staging does not touch the system
+	if err := tuneApp.StageNote("1001", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.StageNote("1002", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.StageNote("8932147", true); err == nil {
+		t.Fatal("did not error")
+	}
+	VerifyConfig(t, tuneApp, []string{"1002"}, []string{})
+	VerifyFileContent(t, SampleParamFile, "optimised2")
+	reloaded := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if toApply, toRevert := reloaded.GetStagedChanges(); !reflect.DeepEqual(toApply, []string{"1001"}) || !reflect.DeepEqual(toRevert, []string{"1002"}) {
+		t.Fatal(toApply, toRevert)
+	}
+	// Applying the staged notes reconciles the system
+	if applied, reverted, err := reloaded.ApplyStaged(); err != nil || !reflect.DeepEqual(applied, []string{"1001"}) || !reflect.DeepEqual(reverted, []string{"1002"}) {
+		t.Fatal(applied, reverted, err)
+	}
+	VerifyConfig(t, reloaded, []string{"1001"}, []string{})
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+	if toApply, toRevert := reloaded.GetStagedChanges(); len(toApply) != 0 || len(toRevert) != 0 {
+		t.Fatal(toApply, toRevert)
+	}
+	// Notes tuned and reverted directly are not undone by the next ApplyStaged
+	if err := reloaded.TuneNote("1002"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.RevertNote("1001", true); err != nil {
+		t.Fatal(err)
+	}
+	if toApply, toRevert := reloaded.GetStagedChanges(); len(toApply) != 0 || len(toRevert) != 0 || !reflect.DeepEqual(reloaded.StagedNotes, []string{"1002"}) {
+		t.Fatal(toApply, toRevert, reloaded.StagedNotes)
+	}
+}
+
+func TestVerifyListedNotes(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	listFile := path.Join(SampleNoteDataDir, "hana.list")
+	WriteFileOrPanic(listFile, "# notes for HANA hosts\n1001 9999\n\n1001 # duplicated\n")
+	noteIDs, err := ReadNoteListFile(listFile)
+	if err != nil || !reflect.DeepEqual(noteIDs, []string{"1001", "9999"}) {
+		t.Fatal(noteIDs, err)
+	}
+	if _, err := ReadNoteListFile(path.Join(SampleNoteDataDir, "does-not-exist")); err == nil {
+		t.Fatal("did not error")
+	}
+	// Listed notes are verified although they are not enabled, unknown notes are errors
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	unsatisfiedNotes, comparisons, noteErrs := tuneApp.VerifyNotes(noteIDs)
+	if !reflect.DeepEqual(unsatisfiedNotes, []string{"1001"}) || len(comparisons) != 1 || len(noteErrs) != 1 || noteErrs["9999"] == nil {
+		t.Fatal(unsatisfiedNotes, comparisons, noteErrs)
+	}
+}
+
+func TestCustomiseNote(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=10\nvm.dirty_ratio=10\n")
+	allNotes := map[string]note.Note{"ini": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini"}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	values, err := ParseCustomiseJSON([]byte(`{"vm.swappiness": 20, "vm.dirty_ratio": "30"}`))
+	if err != nil || !reflect.DeepEqual(values, map[string]string{"vm.swappiness": "20", "vm.dirty_ratio": "30"}) {
+		t.Fatal(values, err)
+	}
+	if _, err := ParseCustomiseJSON([]byte(`{"vm.swappiness": [20]}`)); err == nil {
+		t.Fatal("did not error")
+	}
+	// Unsupported keys are refused and nothing is written, neither are fields that describe the note itself
+	if err := tuneApp.CustomiseNote("ini", map[string]string{"vm.swappiness": "20", "does.not.exist": "1"}); err == nil {
+		t.Fatal("did not error")
+	}
+	if err := tuneApp.CustomiseNote("ini", map[string]string{"ConfFilePath": "/etc/passwd"}); err == nil {
+		t.Fatal("did not error")
+	}
+	if err := tuneApp.CustomiseNote("ini", values); err != nil {
+		t.Fatal(err)
+	}
+	// Writing again keeps a single entry per key, and adds the keys that are new
+	if err := tuneApp.CustomiseNote("ini", map[string]string{"vm.swappiness": "20", "/^vm\\.dirty_/": "40"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join(note.CustomiseFileHeader("ini"), "\n") + "\nvm.dirty_ratio=\"30\"\nvm.swappiness=\"20\"\n/^vm\\.dirty_/=\"40\"\n"
+	VerifyFileContent(t, tuneApp.GetCustomiseFilePath("ini"), expected)
+	if _, comparisons, err := tuneApp.VerifyNote("ini"); err != nil || comparisons["SysctlParams[vm.dirty_ratio]"].ExpectedValueJS != "30" {
+		t.Fatal(comparisons, err)
+	}
+	// Resetting removes the file and reports the cleared values
+	cleared, err := tuneApp.ResetCustomisation("ini")
+	if err != nil || !reflect.DeepEqual(cleared, map[string]string{"vm.swappiness": "20", "vm.dirty_ratio": "30", "/^vm\\.dirty_/": "40"}) {
+		t.Fatal(cleared, err)
+	}
+	if _, err := os.Stat(tuneApp.GetCustomiseFilePath("ini")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if _, comparisons, err := tuneApp.VerifyNote("ini"); err != nil || comparisons["SysctlParams[vm.dirty_ratio]"].ExpectedValueJS != "10" {
+		t.Fatal(comparisons, err)
+	}
+	if cleared, err := tuneApp.ResetCustomisation("ini"); err != nil || len(cleared) != 0 {
+		t.Fatal(cleared, err)
+	}
+}
+
+func TestPruneDanglingNotes(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if err := tuneApp.TuneNote("1001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.TuneNote("1002"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.State.StorePins("1002", map[string]string{"Param": "pinned"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.State.StoreRejected("1002", map[string]note.NoteFieldComparison{"Param": {ReflectFieldName: "Param"}}); err != nil {
+		t.Fatal(err)
+	}
+	// The definition of note 1002 disappears
+	remainingNotes := map[string]note.Note{"1001": SampleNote1{}}
+	reloaded := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), remainingNotes, AllTestSolutions)
+	if dangling := reloaded.GetDanglingNotes(); !reflect.DeepEqual(dangling, []string{"1002"}) {
+		t.Fatal(dangling)
+	}
+	if pruned, err := reloaded.PruneDanglingNotes(); err != nil || !reflect.DeepEqual(pruned, []string{"1002"}) {
+		t.Fatal(pruned, err)
+	}
+	if dangling := reloaded.GetDanglingNotes(); len(dangling) != 0 {
+		t.Fatal(dangling)
+	}
+	VerifyConfig(t, reloaded, []string{"1001"}, []string{})
+	if savedNotes, err := reloaded.State.List(); err != nil || !reflect.DeepEqual(savedNotes, []string{"1001"}) {
+		t.Fatal(savedNotes, err)
+	}
+	if pins, err := reloaded.State.GetPins("1002"); err != nil || len(pins) != 0 {
+		t.Fatal(pins, err)
+	}
+	if rejected, err := reloaded.State.GetRejected("1002"); err != nil || len(rejected) != 0 {
+		t.Fatal(rejected, err)
+	}
+}
+
+func TestGetManagedParameters(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	// The later note provides the value of the shared parameter
+	params, err := tuneApp.GetManagedParameters(AllTestSolutions["sol12"])
+	if err != nil || len(params) != 1 || params[0].Name != "Param" || params[0].NoteID != "1002" || params[0].Value != `{"Data":"optimised2"}` {
+		t.Fatal(params, err)
+	}
+	if _, err := tuneApp.GetManagedParameters([]string{"9999"}); err == nil {
+		t.Fatal("did not error")
+	}
+	// All notes enabled manually or by a solution make up the system-wide set
+	if params, err := tuneApp.GetAllManagedParameters(); err != nil || len(params) != 0 {
+		t.Fatal(params, err)
+	}
+	// The notes of solutions are applied before the additional notes
+	tuneApp.TuneForNotes = []string{"1001"}
+	tuneApp.TuneForSolutions = []string{"sol2"}
+	params, err = tuneApp.GetAllManagedParameters()
+	if err != nil || len(params) != 1 || params[0].NoteID != "1001" || params[0].Value != `{"Data":"optimised1"}` {
+		t.Fatal(params, err)
+	}
+	// The ID and the file of a tuning sheet are not managed parameters
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=10\n")
+	allNotes := map[string]note.Note{"ini": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini"}}
+	tuneApp = InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if params, err := tuneApp.GetManagedParameters([]string{"ini"}); err != nil || len(params) != 1 || params[0].Name != "SysctlParams[vm.swappiness]" {
+		t.Fatal(params, err)
+	}
+	tuneApp.TuneForNotes = []string{"ini"}
+	if params, err := tuneApp.GetAllManagedParameters(); err != nil || len(params) != 1 || params[0].Name != "SysctlParams[vm.swappiness]" {
+		t.Fatal(params, err)
+	}
+}
+
+// A note whose values never take effect.
+type StubbornNote struct {
+	SampleNote1
+}
+
+func (n StubbornNote) Initialise() (note.Note, error) {
+	initialised, err := n.SampleNote1.Initialise()
+	return StubbornNote{initialised.(SampleNote1)}, err
+}
+func (n StubbornNote) Optimise() (note.Note, error) {
+	optimised, err := n.SampleNote1.Optimise()
+	return StubbornNote{optimised.(SampleNote1)}, err
+}
+func (n StubbornNote) Apply() error {
+	return nil
+}
+
+func TestTuneNoteVerified(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	allNotes := map[string]note.Note{"1001": SampleNote1{}, "stubborn": StubbornNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if deviations, err := tuneApp.TuneNoteVerified("1001"); err != nil || len(deviations) != 0 {
+		t.Fatal(deviations, err)
+	}
+	if !reflect.DeepEqual(tuneApp.TuneForNotes, []string{"1001"}) {
+		t.Fatal(tuneApp.TuneForNotes)
+	}
+	// The note that does not take effect is reverted and disabled
+	WriteFileOrPanic(SampleParamFile, "drifted")
+	if deviations, err := tuneApp.TuneNoteVerified("stubborn"); err != nil || len(deviations) != 1 || deviations["SampleNote1"].ParamID != "SampleNote1" {
+		t.Fatal(deviations, err)
+	}
+	if !reflect.DeepEqual(tuneApp.TuneForNotes, []string{"1001"}) {
+		t.Fatal(tuneApp.TuneForNotes)
+	}
+	if savedNotes, err := tuneApp.State.List(); err != nil || !reflect.DeepEqual(savedNotes, []string{"1001"}) {
+		t.Fatal(savedNotes, err)
+	}
+}
+
+func TestTuneNoteReadback(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	allNotes := map[string]note.Note{"1001": SampleNote1{}, "stubborn": StubbornNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	readback, err := tuneApp.TuneNoteReadback("stubborn")
+	if err != nil || len(GetRejected(readback)) != 1 {
+		t.Fatal(readback, err)
+	}
+	if comparison := readback["SampleNote1"]; comparison.ExpectedValueJS != `{"Param":{"Data":"optimised1"}}` || comparison.ActualValueJS != `{"Param":{"Data":""}}` {
+		t.Fatal(comparison)
+	}
+	if rejected, err := tuneApp.State.GetRejected("stubborn"); err != nil || len(rejected) != 1 || rejected["SampleNote1"].ParamID != "SampleNote1" {
+		t.Fatal(rejected, err)
+	}
+	if err := tuneApp.RevertNote("stubborn", true); err != nil {
+		t.Fatal(err)
+	}
+	if rejected, err := tuneApp.State.GetRejected("stubborn"); err != nil || len(rejected) != 0 {
+		t.Fatal(rejected, err)
+	}
+	// Accepted values are read back as they were written
+	readback, err = tuneApp.TuneNoteReadback("1001")
+	if err != nil || len(readback) != 1 || len(GetRejected(readback)) != 0 {
+		t.Fatal(readback, err)
+	}
+	if rejected, err := tuneApp.State.GetRejected("1001"); err != nil || len(rejected) != 0 {
+		t.Fatal(rejected, err)
+	}
+	// Notes that cannot apply a subset of their parameters are written in full
+	allNotes["1002"] = SampleNote2{}
+	readback, skipped, err := tuneApp.TuneNoteIfChanged("1002")
+	if err != nil || len(skipped) != 0 || len(readback) != 1 || len(GetRejected(readback)) != 0 {
+		t.Fatal(readback, skipped, err)
+	}
+}
+
+func TestRevertNoteVerified(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	allNotes := map[string]note.Note{"1001": SampleNote1{}, "stubborn": StubbornNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if _, err := tuneApp.RevertNoteVerified("1001"); err == nil {
+		t.Fatal("did not error")
+	}
+	WriteFileOrPanic(SampleParamFile, "original")
+	if err := tuneApp.TuneNote("1001"); err != nil {
+		t.Fatal(err)
+	}
+	if deviations, err := tuneApp.RevertNoteVerified("1001"); err != nil || len(deviations) != 0 {
+		t.Fatal(deviations, err)
+	}
+	VerifyFileContent(t, SampleParamFile, "original")
+	// The stubborn note does not restore the value changed behind its back
+	if err := tuneApp.TuneNote("stubborn"); err != nil {
+		t.Fatal(err)
+	}
+	WriteFileOrPanic(SampleParamFile, "drifted")
+	deviations, err := tuneApp.RevertNoteVerified("stubborn")
+	if err != nil || len(deviations) != 1 || deviations["SampleNote1"].ExpectedValueJS != `{"Param":{"Data":"original"}}` || deviations["SampleNote1"].ActualValueJS != `{"Param":{"Data":"drifted"}}` {
+		t.Fatal(deviations, err)
+	}
+}
+
+// A note whose parameter the environment does not permit to write.
+type LimitedNote struct {
+	StubbornNote
+}
+
+func (n LimitedNote) Initialise() (note.Note, error) {
+	initialised, err := n.StubbornNote.Initialise()
+	return LimitedNote{initialised.(StubbornNote)}, err
+}
+func (n LimitedNote) Optimise() (note.Note, error) {
+	optimised, err := n.StubbornNote.Optimise()
+	return LimitedNote{optimised.(StubbornNote)}, err
+}
+func (n LimitedNote) Apply() error {
+	return note.EnvironmentLimitedError{Limited: []system.CapabilityError{{Param: "StubbornNote", Capability: "CAP_SYS_ADMIN"}}}
+}
+
+func TestTuneEnvironmentLimitedNote(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	allNotes := map[string]note.Note{"limited": LimitedNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	// The note is applied nonetheless, the parameter is reported apart from the rejected ones
+	readback, err := tuneApp.TuneNoteReadback("limited")
+	if err != nil || len(GetRejected(readback)) != 0 {
+		t.Fatal(readback, err)
+	}
+	limited := GetEnvironmentLimited(readback)
+	if len(limited) != 1 || !strings.Contains(limited["StubbornNote"].NotApplicable, "requires capability CAP_SYS_ADMIN") {
+		t.Fatal(readback)
+	}
+	if !reflect.DeepEqual(tuneApp.TuneForNotes, []string{"limited"}) {
+		t.Fatal(tuneApp.TuneForNotes)
+	}
+	// Reverting leaves the parameter alone likewise, and clears the saved state
+	if err := tuneApp.RevertNote("limited", true); err != nil || tuneApp.State.IsSaved("limited") {
+		t.Fatal(err)
+	}
+	// The notes of a solution are reported along the way
+	tuneApp.AllSolutions = map[string]solution.Solution{"limitedsol": {"limited"}}
+	reported := make([]string, 0, 0)
+	tuneApp.ReadbackReporter = func(noteID string, readback map[string]note.NoteFieldComparison) {
+		if len(GetEnvironmentLimited(readback)) == 1 {
+			reported = append(reported, noteID)
+		}
+	}
+	if _, err := tuneApp.TuneSolution("limitedsol"); err != nil || !reflect.DeepEqual(reported, []string{"limited"}) {
+		t.Fatal(reported, err)
+	}
+}
+
+func TestVerifySolutionFailFast(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	// Neither note conforms, only the first one is inspected
+	if notes, comparisons, err := tuneApp.VerifySolutionFailFast("sol12"); err != nil || !reflect.DeepEqual(notes, []string{"1001"}) || len(comparisons) != 1 {
 		t.Fatal(notes, comparisons, err)
 	}
+	if notes, comparisons, err := tuneApp.VerifySolution("sol12"); err != nil || len(notes) != 2 || len(comparisons) != 2 {
+		t.Fatal(notes, comparisons, err)
+	}
+}
+
+func TestGetNoteSources(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "base.conf"), "[sysctl]\nvm.swappiness=10\n")
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=20\n")
+	allNotes := map[string]note.Note{
+		"1001": SampleNote1{},
+		"ini": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini",
+			IncludedFilePaths: []string{path.Join(SampleNoteDataDir, "base.conf")}},
+	}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if sources, err := tuneApp.GetNoteSources("1001"); err != nil || !reflect.DeepEqual(sources, []NoteSource{{Origin: "built-in implementation"}}) {
+		t.Fatal(sources, err)
+	}
+	if err := tuneApp.CustomiseNote("ini", map[string]string{"vm.swappiness": "20"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []NoteSource{
+		{Origin: "included tuning sheet", Path: path.Join(SampleNoteDataDir, "base.conf")},
+		{Origin: "tuning sheet", Path: path.Join(SampleNoteDataDir, "ini.conf")},
+		{Origin: "customisation file", Path: tuneApp.GetCustomiseFilePath("ini")},
+	}
+	if sources, err := tuneApp.GetNoteSources("ini"); err != nil || !reflect.DeepEqual(sources, expected) {
+		t.Fatal(sources, err)
+	}
+	if _, err := tuneApp.GetNoteSources("does not exist"); err == nil {
+		t.Fatal("did not error")
+	}
+}
+
+func TestPinParameter(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=10\nvm.dirty_ratio=10\n")
+	allNotes := map[string]note.Note{"ini": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini"}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if err := tuneApp.PinParameter("ini", "does.not.exist", "1"); err == nil {
+		t.Fatal("did not error")
+	}
+	for _, field := range []string{"ID", "ConfFilePath", "SysconfigPrefix"} {
+		if err := tuneApp.PinParameter("ini", field, "/abc"); err == nil {
+			t.Fatal("did not error", field)
+		}
+	}
+	// A pin takes precedence over the customisation file
+	if err := tuneApp.CustomiseNote("ini", map[string]string{"vm.swappiness": "20"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.PinParameter("ini", "vm.swappiness", "30"); err != nil {
+		t.Fatal(err)
+	}
+	if _, comparisons, err := tuneApp.VerifyNote("ini"); err != nil || comparisons["SysctlParams[vm.swappiness]"].ExpectedValueJS != "30" {
+		t.Fatal(comparisons, err)
+	}
+	// The pin survives an update of the definition
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=40\nvm.dirty_ratio=10\n")
+	if _, comparisons, err := tuneApp.VerifyNote("ini"); err != nil || comparisons["SysctlParams[vm.swappiness]"].ExpectedValueJS != "30" {
+		t.Fatal(comparisons, err)
+	}
+	if allPins, err := tuneApp.GetAllPins(); err != nil || !reflect.DeepEqual(allPins, map[string]map[string]string{"ini": {"vm.swappiness": "30"}}) {
+		t.Fatal(allPins, err)
+	}
+	if err := tuneApp.UnpinParameter("ini", "vm.swappiness"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.UnpinParameter("ini", "vm.swappiness"); err == nil {
+		t.Fatal("did not error")
+	}
+	if _, comparisons, err := tuneApp.VerifyNote("ini"); err != nil || comparisons["SysctlParams[vm.swappiness]"].ExpectedValueJS != "20" {
+		t.Fatal(comparisons, err)
+	}
+	if allPins, err := tuneApp.GetAllPins(); err != nil || len(allPins) != 0 {
+		t.Fatal(allPins, err)
+	}
+	// The pins of a note that is no longer defined can still be removed
+	if err := tuneApp.UnpinParameter("gone", "vm.swappiness"); err == nil {
+		t.Fatal("did not error")
+	}
+	if err := tuneApp.State.StorePins("gone", map[string]string{"vm.swappiness": "30"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.UnpinParameter("gone", "vm.swappiness"); err != nil {
+		t.Fatal(err)
+	}
+	if pins, err := tuneApp.State.GetPins("gone"); err != nil || len(pins) != 0 {
+		t.Fatal(pins, err)
+	}
+}
+
+func TestGetLocalModification(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=10\nvm.dirty_ratio=10\n")
+	allNotes := map[string]note.Note{"ini": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini"}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if modification, err := tuneApp.GetLocalModification("ini"); err != nil || modification.IsModified() || len(modification.Differences) != 0 {
+		t.Fatal(modification, err)
+	}
+	if _, err := tuneApp.GetLocalModification("does not exist"); err == nil {
+		t.Fatal("did not error")
+	}
+	// A customised value that equals the definition is no difference
+	if err := tuneApp.CustomiseNote("ini", map[string]string{"vm.swappiness": "20", "vm.dirty_ratio": "10"}); err != nil {
+		t.Fatal(err)
+	}
+	modification, err := tuneApp.GetLocalModification("ini")
+	if err != nil || !modification.Customised || modification.Pinned || len(modification.Differences) != 1 {
+		t.Fatal(modification, err)
+	}
+	if diff := modification.Differences[0]; diff.Label() != "vm.swappiness" || diff.ActualValueJS != "20" || diff.ExpectedValueJS != "10" {
+		t.Fatal(diff)
+	}
+	if err := tuneApp.PinParameter("ini", "vm.dirty_ratio", "5"); err != nil {
+		t.Fatal(err)
+	}
+	modification, err = tuneApp.GetLocalModification("ini")
+	if err != nil || !modification.Customised || !modification.Pinned || len(modification.Differences) != 2 {
+		t.Fatal(modification, err)
+	}
+	if diff := modification.Differences[0]; diff.Label() != "vm.dirty_ratio" || diff.ActualValueJS != "5" || diff.ExpectedValueJS != "10" {
+		t.Fatal(diff)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if findings := tuneApp.Diagnose(); len(findings) != 0 {
+		t.Fatal(findings)
+	}
+	if err := tuneApp.TuneNote("1001"); err != nil {
+		t.Fatal(err)
+	}
+	if findings := tuneApp.Diagnose(); len(findings) != 0 {
+		t.Fatal(findings)
+	}
+	// An updated definition is not a change of the applied values
+	tuneApp.AllNotes = map[string]note.Note{"1001": SampleNote2{}}
+	if findings := tuneApp.Diagnose(); len(findings) != 0 {
+		t.Fatal(findings)
+	}
+	// An external change of an applied value, a note that was never applied, and a leftover saved state
+	WriteFileOrPanic(SampleParamFile, "changed")
+	tuneApp.TuneForNotes = append(tuneApp.TuneForNotes, "1002")
+	if err := tuneApp.State.Store("1003", SampleNote1{}, true); err != nil {
+		t.Fatal(err)
+	}
+	tuneApp.AllNotes = map[string]note.Note{"1001": SampleNote1{}, "1002": SampleNote2{}, "1003": SampleNote1{}}
+	findings := tuneApp.Diagnose()
+	if len(findings) != 3 {
+		t.Fatal(findings)
+	}
+	if findings[0].NoteID != "1001" || findings[0].Remedy != "saptune note refresh 1001" || !strings.Contains(findings[0].Problem, "Param") {
+		t.Fatal(findings[0])
+	}
+	if findings[1].NoteID != "1002" || findings[1].Remedy != "saptune note apply 1002" {
+		t.Fatal(findings[1])
+	}
+	if findings[2].NoteID != "1003" || findings[2].Remedy != "saptune note revert 1003" {
+		t.Fatal(findings[2])
+	}
+	// A note that is no longer defined
+	delete(tuneApp.AllNotes, "1002")
+	if findings := tuneApp.Diagnose(); findings[0].NoteID != "1002" || findings[0].Remedy != "saptune note prune" {
+		t.Fatal(findings)
+	}
+}
+
+func TestGroupByCategory(t *testing.T) {
+	allNotes := map[string]note.Note{"1001": SampleNote1{}, "1275776": note.PrepareForSAPEnvironments{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	defer os.RemoveAll(SampleNoteDataDir)
+	comparisons := map[string]map[string]note.NoteFieldComparison{
+		"1001": {"Param": {ParamID: "Param", ReflectFieldName: "Param"}},
+		"1275776": {
+			"KernelShmMni":  {ParamID: "KernelShmMni", ReflectFieldName: "KernelShmMni"},
+			"KernelShmAll":  {ParamID: "KernelShmAll", ReflectFieldName: "KernelShmAll"},
+			"VMMaxMapCount": {ParamID: "VMMaxMapCount", ReflectFieldName: "VMMaxMapCount"},
+		},
+	}
+	groups := tuneApp.GroupByCategory(comparisons)
+	if len(groups) != 3 || len(groups["kernel"]) != 2 || groups["kernel"][0].Comparison.ParamID != "KernelShmAll" {
+		t.Fatal(groups)
+	}
+	if len(groups["memory"]) != 1 || groups["memory"][0].NoteID != "1275776" {
+		t.Fatal(groups)
+	}
+	if len(groups[note.Uncategorised]) != 1 || groups[note.Uncategorised][0].NoteID != "1001" {
+		t.Fatal(groups)
+	}
+}
+
+type VersionedNote struct {
+	SampleNote2
+}
+
+func (n VersionedNote) ProductVersions() []string {
+	return []string{"2.0*"}
+}
+
+func TestExcludeByProductVersion(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	allNotes := map[string]note.Note{"1001": SampleNote1{}, "1002": VersionedNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	tuneApp.SAPProductVersion = "2.00.059"
+	if filtered, err := tuneApp.FilterByProductVersion([]string{"1001", "1002"}); err != nil || len(filtered) != 0 {
+		t.Fatal(filtered, err)
+	}
+	tuneApp.SAPProductVersion = "1.00.122"
+	filtered, err := tuneApp.SkipByProductVersion("sol12")
+	if err != nil || len(filtered) != 1 || !strings.Contains(filtered["1002"], "1.00.122") {
+		t.Fatal(filtered, err)
+	}
+	// The note is left out of this apply only, it is not recorded as an exclusion
+	if _, err := tuneApp.TuneSolution("sol12"); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+	if sol, err := tuneApp.GetSolutionByName("sol12"); err != nil || !reflect.DeepEqual(sol, solution.Solution{"1001", "1002"}) {
+		t.Fatal(sol, err)
+	}
+	if reloaded := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions); len(reloaded.SolutionExclusions) != 0 {
+		t.Fatal(reloaded.SolutionExclusions)
+	}
+	if _, err := tuneApp.FilterByProductVersion([]string{"9999"}); err == nil {
+		t.Fatal("did not error")
+	}
+}
+
+// A note whose parameter is high-risk to change.
+type RiskyNote struct {
+	SampleNote1
+}
+
+func (n RiskyNote) HighRiskParams() map[string]string {
+	return map[string]string{"Param": "may upset the sample"}
+}
+
+func TestConfirmHighRisk(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	WriteFileOrPanic(SampleParamFile, "current")
+	allNotes := map[string]note.Note{"risky": RiskyNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	var asked []HighRiskChange
+	tuneApp.ConfirmHighRisk = func(noteID string, changes []HighRiskChange) bool {
+		asked = changes
+		return false
+	}
+	if err := tuneApp.TuneNote("risky"); !errors.Is(err, ErrNotConfirmed) {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(asked, []HighRiskChange{{Param: "Param", Current: `{"Data":"current"}`, Target: `{"Data":"optimised1"}`, Risk: "may upset the sample"}}) {
+		t.Fatal(asked)
+	}
+	VerifyFileContent(t, SampleParamFile, "current")
+	// Once confirmed the note is applied
+	tuneApp.ConfirmHighRisk = func(noteID string, changes []HighRiskChange) bool {
+		return true
+	}
+	if err := tuneApp.TuneNote("risky"); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+	// Nothing is asked when the parameter already conforms
+	asked = nil
+	tuneApp.ConfirmHighRisk = func(noteID string, changes []HighRiskChange) bool {
+		asked = changes
+		return false
+	}
+	if err := tuneApp.TuneNote("risky"); err != nil || asked != nil {
+		t.Fatal(err, asked)
+	}
+}
+
+// A note that requires a package which is never installed.
+type PackageNote struct {
+	SampleNote1
+}
+
+func (n PackageNote) RequiredPackages() []string {
+	return []string{"saptune-test-package-never-installed"}
+}
+
+func TestRequiredPackages(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	WriteFileOrPanic(SampleParamFile, "current")
+	allNotes := map[string]note.Note{"1002": SampleNote2{}, "package": PackageNote{}}
+	allSolutions := map[string]solution.Solution{"sol": {"package", "1002"}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, allSolutions)
+	// The note is not applicable without its package
+	conforming, comparisons, err := tuneApp.VerifyNote("package")
+	if err != nil || !conforming || !strings.Contains(comparisons["Param"].NotApplicable, "not installed") {
+		t.Fatal(conforming, comparisons, err)
+	}
+	if err := tuneApp.TuneNote("package"); !errors.Is(err, ErrPackageMissing) || !strings.HasSuffix(err.Error(), "skipping") {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "current")
+	// The other notes of a solution are still applied
+	if _, err := tuneApp.TuneSolution("sol"); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised2")
+	// Unless the requirement is ignored
+	tuneApp.IgnorePackages = true
+	if conforming, _, err := tuneApp.VerifyNote("package"); err != nil || conforming {
+		t.Fatal(conforming, err)
+	}
+	if err := tuneApp.TuneNote("package"); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised1")
 }
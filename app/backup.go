@@ -0,0 +1,88 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+/*
+BackupTimeFormat is the time stamp in the names of backup files, e.g. "1410736-20240106T010000.123456789.json". It
+goes down to nanoseconds, so that applying a note twice in a row does not overwrite the earlier backup.
+*/
+const BackupTimeFormat = "20060102T150405.000000000"
+
+/*
+A human-readable record of the parameter values of a note before it was applied, kept independent of the state
+files of saptune, e.g. for compliance audits.
+*/
+type NoteBackup struct {
+	NoteID     string            `json:"note_id"`
+	NoteName   string            `json:"note_name"`
+	Time       time.Time         `json:"time"`
+	Parameters map[string]string `json:"parameters"` // parameter name VS value before the note was applied, in JSON, without the fields describing the note
+	State      json.RawMessage   `json:"state"`      // the serialised note, it restores the values upon revert
+}
+
+/*
+Write the parameter values of the initialised note into a new backup file in the backup directory, and return the
+path to the file. The directory is created if necessary.
+*/
+func (app *App) writeNoteBackup(noteID string, initialised note.Note) (string, error) {
+	state, err := json.Marshal(initialised)
+	if err != nil {
+		return "", err
+	}
+	backup := NoteBackup{
+		NoteID:     noteID,
+		NoteName:   initialised.Name(),
+		Time:       time.Now(),
+		Parameters: make(map[string]string),
+		State:      state,
+	}
+	_, comparisons := note.CompareNoteFields(initialised, initialised)
+	for _, comparison := range note.FilterParameters(comparisons) {
+		backup.Parameters[comparison.Label()] = comparison.ActualValueJS
+	}
+	content, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(app.BackupDir, 0700); err != nil {
+		return "", err
+	}
+	filePath := path.Join(app.BackupDir, fmt.Sprintf("%s-%s.json", noteID, backup.Time.Format(BackupTimeFormat)))
+	return filePath, writeFileAtomic(filePath, append(content, '\n'), 0600)
+}
+
+// Read a backup file written when a note was applied.
+func ReadNoteBackup(filePath string) (backup NoteBackup, err error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(content, &backup); err == nil && (backup.NoteID == "" || len(backup.State) == 0) {
+		err = fmt.Errorf("%s is not a backup of a note", filePath)
+	}
+	return
+}
+
+/*
+Revert the note like RevertNote, but restore the parameter values recorded in the backup file rather than those saved
+by saptune, e.g. after the state of saptune was reset. The backup must belong to the note.
+*/
+func (app *App) RevertNoteFromBackup(noteID, filePath string, permanent bool) error {
+	backup, err := ReadNoteBackup(filePath)
+	if err != nil {
+		return newError(ErrStateFailed, err, "Failed to read backup file %s - %v", filePath, err)
+	} else if backup.NoteID != noteID {
+		return fmt.Errorf("Backup file %s belongs to note %s rather than note %s.", filePath, backup.NoteID, noteID)
+	}
+	return app.revertNote(noteID, permanent, func(dest interface{}) error {
+		return json.Unmarshal(backup.State, dest)
+	})
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestNoteBackup(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	WriteFileOrPanic(SampleParamFile, "original")
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	tuneApp.BackupDir = path.Join(SampleNoteDataDir, "backup")
+	if err := tuneApp.TuneNote("1001"); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+	files, err := ioutil.ReadDir(tuneApp.BackupDir)
+	if err != nil || len(files) != 1 {
+		t.Fatal(files, err)
+	}
+	backupPath := path.Join(tuneApp.BackupDir, files[0].Name())
+	backup, err := ReadNoteBackup(backupPath)
+	if err != nil || backup.NoteID != "1001" || backup.NoteName != "sample note 1" || !reflect.DeepEqual(backup.Parameters, map[string]string{"Param": `{"Data":"original"}`}) {
+		t.Fatal(backup, err)
+	}
+	// The backup survives a reset of the state of saptune
+	os.RemoveAll(path.Join(SampleNoteDataDir, "data"))
+	if err := tuneApp.RevertNoteFromBackup("1002", backupPath, true); err == nil {
+		t.Fatal("did not error")
+	}
+	if err := tuneApp.RevertNoteFromBackup("1001", path.Join(tuneApp.BackupDir, "does-not-exist"), true); err == nil {
+		t.Fatal("did not error")
+	}
+	if err := tuneApp.RevertNoteFromBackup("1001", backupPath, true); err != nil {
+		t.Fatal(err)
+	}
+	VerifyFileContent(t, SampleParamFile, "original")
+	if len(tuneApp.TuneForNotes) != 0 {
+		t.Fatal(tuneApp.TuneForNotes)
+	}
+	// Only parameters are listed, and backups written in a row do not overwrite each other
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=10\n")
+	sheet := note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini", SysctlParams: map[string]string{"vm.swappiness": "60"}}
+	for i := 0; i < 2; i++ {
+		backupPath, err = tuneApp.writeNoteBackup("ini", sheet)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if backup, err := ReadNoteBackup(backupPath); err != nil || !reflect.DeepEqual(backup.Parameters, map[string]string{"vm.swappiness": "60"}) {
+		t.Fatal(backup, err)
+	}
+	if files, err := ioutil.ReadDir(tuneApp.BackupDir); err != nil || len(files) != 3 {
+		t.Fatal(files, err)
+	}
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+// The outcome of verifying a parameter at the time the baseline was captured.
+type BaselineParameter struct {
+	Match    bool   `json:"match"`
+	Actual   string `json:"actual"`
+	Expected string `json:"expected"`
+}
+
+/*
+Baseline records the outcome of a verification at a point in time, so that a later verification may tell which
+parameters changed their conformance since then.
+*/
+type Baseline struct {
+	Time  time.Time                               `json:"time"`
+	Notes map[string]map[string]BaselineParameter `json:"notes"` // note ID VS comparison name VS outcome
+}
+
+// A parameter whose conformance changed since the baseline was captured.
+type ComplianceChange struct {
+	NoteID     string
+	Before     BaselineParameter
+	Comparison note.NoteFieldComparison // the outcome of the fresh verification
+}
+
+// Return true only if the parameter deviated in the baseline and conforms now.
+func (change ComplianceChange) IsFixed() bool {
+	return change.Comparison.MatchExpectation
+}
+
+// Capture the verification result, note ID VS comparison name VS comparison, as a baseline.
+func NewBaseline(comparisons map[string]map[string]note.NoteFieldComparison) Baseline {
+	baseline := Baseline{Time: time.Now(), Notes: make(map[string]map[string]BaselineParameter)}
+	for noteID, noteComparisons := range comparisons {
+		baseline.Notes[noteID] = make(map[string]BaselineParameter)
+		for name, comparison := range noteComparisons {
+			baseline.Notes[noteID][name] = BaselineParameter{Match: comparison.MatchExpectation, Actual: comparison.ActualValueJS, Expected: comparison.ExpectedValueJS}
+		}
+	}
+	return baseline
+}
+
+// Write the baseline into a file in JSON.
+func (baseline Baseline) Save(filePath string) error {
+	content, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filePath, append(content, '\n'), 0644)
+}
+
+// Read a baseline file written by Baseline.Save.
+func ReadBaseline(filePath string) (baseline Baseline, err error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(content, &baseline); err == nil && baseline.Notes == nil {
+		err = fmt.Errorf("%s is not a baseline of a verification", filePath)
+	}
+	return
+}
+
+/*
+Compare a fresh verification result against the baseline and return the parameters that became deviating or
+conforming since, sorted by note ID and comparison name. Only parameters present in both are compared, so that a
+changed definition of a note does not count as a change of conformance. Also return the notes of the baseline that
+were not verified this time, e.g. because they are no longer enabled, and the verified notes unknown to the baseline,
+both sorted.
+*/
+func (baseline Baseline) Compare(comparisons map[string]map[string]note.NoteFieldComparison) (changes []ComplianceChange, missingNotes, newNotes []string) {
+	changes = make([]ComplianceChange, 0, 0)
+	missingNotes = make([]string, 0, 0)
+	newNotes = make([]string, 0, 0)
+	for noteID := range baseline.Notes {
+		if _, verified := comparisons[noteID]; !verified {
+			missingNotes = append(missingNotes, noteID)
+		}
+	}
+	noteIDs := make([]string, 0, len(comparisons))
+	for noteID := range comparisons {
+		noteIDs = append(noteIDs, noteID)
+	}
+	sort.Strings(noteIDs)
+	for _, noteID := range noteIDs {
+		before, known := baseline.Notes[noteID]
+		if !known {
+			newNotes = append(newNotes, noteID)
+			continue
+		}
+		names := make([]string, 0, len(comparisons[noteID]))
+		for name := range comparisons[noteID] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			comparison := comparisons[noteID][name]
+			if param, exists := before[name]; exists && param.Match != comparison.MatchExpectation {
+				changes = append(changes, ComplianceChange{NoteID: noteID, Before: param, Comparison: comparison})
+			}
+		}
+	}
+	sort.Strings(missingNotes)
+	return
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if err := tuneApp.TuneNote("1001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.TuneNote("1002"); err != nil {
+		t.Fatal(err)
+	}
+	// Note 1002 wrote its value last, hence 1001 deviates
+	_, comparisons, _ := tuneApp.VerifyAll()
+	baselineFile := path.Join(SampleNoteDataDir, "baseline.json")
+	if err := NewBaseline(comparisons).Save(baselineFile); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := ReadBaseline(baselineFile)
+	if err != nil || !baseline.Notes["1002"]["Param"].Match || baseline.Notes["1001"]["Param"].Match {
+		t.Fatal(baseline, err)
+	}
+	// Nothing changed
+	if changes, missing, added := baseline.Compare(comparisons); len(changes) != 0 || len(missing) != 0 || len(added) != 0 {
+		t.Fatal(changes, missing, added)
+	}
+	// Note 1001 conforms now, and note 1002 is no longer verified
+	WriteFileOrPanic(SampleParamFile, "optimised1")
+	_, comparisons, _ = tuneApp.VerifyAll()
+	delete(comparisons, "1002")
+	changes, missing, added := baseline.Compare(comparisons)
+	if len(changes) != 1 || changes[0].NoteID != "1001" || !changes[0].IsFixed() || changes[0].Before.Actual != `{"Data":"optimised2"}` {
+		t.Fatal(changes)
+	}
+	if !reflect.DeepEqual(missing, []string{"1002"}) || len(added) != 0 {
+		t.Fatal(missing, added)
+	}
+	// A note unknown to the baseline is not compared
+	delete(baseline.Notes, "1001")
+	if changes, _, added := baseline.Compare(comparisons); len(changes) != 0 || !reflect.DeepEqual(added, []string{"1001"}) {
+		t.Fatal(changes, added)
+	}
+	// A file that is not a baseline is refused
+	WriteFileOrPanic(baselineFile, "{}")
+	if _, err := ReadBaseline(baselineFile); err == nil {
+		t.Fatal("did not error")
+	}
+}
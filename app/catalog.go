@@ -0,0 +1,105 @@
+package app
+
+import (
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"sort"
+)
+
+// A parameter of a note in the catalog, with the value that the note applies by default.
+type CatalogParameter struct {
+	Name           string `json:"name"`
+	Default        string `json:"default"`
+	Category       string `json:"category"`
+	Severity       string `json:"severity"`
+	RebootRequired bool   `json:"reboot_required,omitempty"`
+}
+
+// A note in the catalog.
+type CatalogNote struct {
+	ID              string             `json:"id"`
+	Name            string             `json:"name"`
+	Sources         []string           `json:"sources,omitempty"` // paths to the tuning sheets that define the note, empty for built-in notes
+	DeprecatedBy    string             `json:"deprecated_by,omitempty"`
+	KernelMin       string             `json:"kernel_min,omitempty"`
+	KernelMax       string             `json:"kernel_max,omitempty"`
+	ProductVersions []string           `json:"sap_versions,omitempty"`
+	Parameters      []CatalogParameter `json:"parameters"`
+	Error           string             `json:"error,omitempty"` // why the default values could not be determined
+}
+
+// The definitions of all notes and solutions known to saptune, e.g. for rendering them in another tool.
+type Catalog struct {
+	Notes     []CatalogNote       `json:"notes"`
+	Solutions map[string][]string `json:"solutions"` // solution name VS note IDs
+}
+
+/*
+Return the catalog of all notes and solutions, except the notes to exclude, sorted by note ID. The default values are
+those the notes calculate for this system, without customisation and pins. A note that fails to calculate them is
+still listed, with the error and without parameters. Neither the system nor the configuration is changed.
+*/
+func (app *App) GetCatalog(excludeNotes []string) Catalog {
+	exclude := make(map[string]struct{})
+	for _, noteID := range excludeNotes {
+		exclude[noteID] = struct{}{}
+	}
+	catalog := Catalog{Notes: make([]CatalogNote, 0, len(app.AllNotes)), Solutions: make(map[string][]string)}
+	noteIDs := make([]string, 0, len(app.AllNotes))
+	for noteID := range app.AllNotes {
+		if _, excluded := exclude[noteID]; !excluded {
+			noteIDs = append(noteIDs, noteID)
+		}
+	}
+	sort.Strings(noteIDs)
+	for _, noteID := range noteIDs {
+		catalog.Notes = append(catalog.Notes, app.getCatalogNote(noteID, app.AllNotes[noteID]))
+	}
+	for solName, sol := range app.AllSolutions {
+		catalog.Solutions[solName] = append([]string{}, sol...)
+	}
+	return catalog
+}
+
+// Describe the note and calculate the default values of its parameters.
+func (app *App) getCatalogNote(noteID string, aNote note.Note) CatalogNote {
+	entry := CatalogNote{ID: noteID, Name: aNote.Name(), DeprecatedBy: note.GetReplacement(aNote), Parameters: []CatalogParameter{}}
+	if sheet, isSheet := aNote.(note.INISettings); isSheet {
+		entry.Sources = append(append(entry.Sources, sheet.IncludedFilePaths...), sheet.ConfFilePath)
+	}
+	if kernelNote, ok := aNote.(note.KernelRequired); ok {
+		entry.KernelMin, entry.KernelMax = kernelNote.KernelVersionRange()
+	}
+	if versionedNote, ok := aNote.(note.ProductVersionRequired); ok {
+		entry.ProductVersions = versionedNote.ProductVersions()
+	}
+	initialised, err := aNote.Initialise()
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	optimised, err := initialised.Optimise()
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	_, comparisons := note.CompareNoteFields(optimised, optimised)
+	comparisons = note.FilterParameters(comparisons)
+	note.MarkSeverity(aNote, comparisons)
+	paramIDs := make([]string, 0, len(comparisons))
+	for paramID := range comparisons {
+		paramIDs = append(paramIDs, paramID)
+	}
+	sort.Strings(paramIDs)
+	categories := note.GetParamCategories(aNote)
+	for _, paramID := range paramIDs {
+		comparison := comparisons[paramID]
+		entry.Parameters = append(entry.Parameters, CatalogParameter{
+			Name:           comparison.Label(),
+			Default:        comparison.ExpectedValueJS,
+			Category:       note.GetParamCategory(categories, comparison),
+			Severity:       comparison.Severity,
+			RebootRequired: note.IsRebootRequired(aNote, comparison),
+		})
+	}
+	return entry
+}
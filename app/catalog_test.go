@@ -0,0 +1,51 @@
+package app
+
+import (
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestGetCatalog(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	sheetPath := path.Join(SampleNoteDataDir, "ini.conf")
+	WriteFileOrPanic(sheetPath, "[main]\nrecommended = vm.swappiness\nkernel_min = 4.12\n[sysctl]\nvm.swappiness = 10\n")
+	allNotes := map[string]note.Note{
+		"1001":    SampleNote1{},
+		"ini":     note.INISettings{ConfFilePath: sheetPath, ID: "ini", DescriptiveName: "sheet"},
+		"failing": FailingNote{},
+	}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	catalog := tuneApp.GetCatalog([]string{"failing"})
+	if len(catalog.Notes) != 2 || catalog.Notes[0].ID != "1001" || catalog.Notes[1].ID != "ini" {
+		t.Fatal(catalog.Notes)
+	}
+	if sample := catalog.Notes[0]; sample.Name != "sample note 1" || len(sample.Sources) != 0 || !reflect.DeepEqual(sample.Parameters, []CatalogParameter{
+		{Name: "Param", Default: `{"Data":"optimised1"}`, Category: note.Uncategorised, Severity: note.SeverityMandatory},
+	}) {
+		t.Fatal(sample)
+	}
+	sheet := catalog.Notes[1]
+	if !reflect.DeepEqual(sheet.Sources, []string{sheetPath}) || sheet.KernelMin != "4.12" || sheet.KernelMax != "" {
+		t.Fatal(sheet)
+	}
+	// The fields describing the sheet, such as its ID and file path, are not parameters
+	if len(sheet.Parameters) != 1 {
+		t.Fatal(sheet.Parameters)
+	}
+	if param := sheet.Parameters[0]; param.Name != "vm.swappiness" || param.Default != "10" || param.Category != "memory" || param.Severity != note.SeverityRecommended {
+		t.Fatal(param)
+	}
+	if !reflect.DeepEqual(catalog.Solutions["sol12"], []string{"1001", "1002"}) || len(catalog.Solutions) != len(AllTestSolutions) {
+		t.Fatal(catalog.Solutions)
+	}
+	// A note that fails to calculate its values is listed with the error
+	catalog = tuneApp.GetCatalog([]string{})
+	if failing := catalog.Notes[1]; failing.ID != "failing" || failing.Error == "" || len(failing.Parameters) != 0 {
+		t.Fatal(failing)
+	}
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// An inconsistency between the records of saptune and the system, together with the command that resolves it.
+type Finding struct {
+	NoteID  string
+	Problem string // completes a sentence about the note, e.g. "is enabled, but it has never been applied"
+	Remedy  string // command that resolves the inconsistency, empty if there is none
+}
+
+/*
+Cross-check the records of saptune against the system and against each other, rather than against the
+recommendations of the notes: notes that are recorded but no longer defined, enabled notes whose applied values have
+since changed or that have never been applied, and saved states of notes that are no longer enabled. The current
+values are compared against the values recorded right after the note was applied, hence an updated note definition
+is not mistaken for a change of the system, and parameters that the system did not accept are not reported. Notes
+applied before values were recorded are compared against their definition instead. The system is not changed.
+*/
+func (app *App) Diagnose() (findings []Finding) {
+	findings = make([]Finding, 0, 0)
+	for _, noteID := range app.GetDanglingNotes() {
+		findings = append(findings, Finding{NoteID: noteID, Problem: "is recorded as enabled, staged, or applied, but it is no longer defined", Remedy: "saptune note prune"})
+	}
+	savedNotes, _ := app.State.List()
+	sort.Strings(savedNotes)
+	enabledNotes := app.GetSortedAllEnabledNotes()
+	for _, noteID := range enabledNotes {
+		if _, exists := app.AllNotes[noteID]; !exists {
+			continue
+		}
+		conforming, comparisons, err := app.VerifyNote(noteID)
+		if err != nil {
+			findings = append(findings, Finding{NoteID: noteID, Problem: fmt.Sprintf("cannot be inspected - %v", err)})
+			continue
+		}
+		appliedValues, appliedErr := app.State.GetAppliedValues(noteID)
+		if appliedErr != nil && conforming {
+			continue
+		}
+		applyTime, err := app.State.GetApplyTime(noteID)
+		if i := sort.SearchStrings(savedNotes, noteID); err != nil && !(i < len(savedNotes) && savedNotes[i] == noteID) {
+			if !conforming {
+				findings = append(findings, Finding{NoteID: noteID, Problem: "is enabled, but it has never been applied", Remedy: "saptune note apply " + noteID})
+			}
+			continue
+		}
+		changed := make([]string, 0, 0)
+		if appliedErr == nil {
+			for paramID, comparison := range comparisons {
+				if value, recorded := appliedValues[paramID]; recorded && comparison.ActualValueJS != value {
+					changed = append(changed, comparison.Label())
+				}
+			}
+		} else {
+			rejected, _ := app.State.GetRejected(noteID)
+			for paramID, comparison := range comparisons {
+				if _, wasRejected := rejected[paramID]; !comparison.MatchExpectation && !wasRejected {
+					changed = append(changed, comparison.Label())
+				}
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		sort.Strings(changed)
+		problem := fmt.Sprintf("has been applied, but these parameters no longer have the applied values: %s", strings.Join(changed, ", "))
+		if err == nil {
+			problem = fmt.Sprintf("has been applied at %s, but these parameters no longer have the applied values: %s", applyTime.Format("2006-01-02 15:04:05"), strings.Join(changed, ", "))
+		}
+		findings = append(findings, Finding{NoteID: noteID, Problem: problem, Remedy: "saptune note refresh " + noteID})
+	}
+	for _, noteID := range savedNotes {
+		_, exists := app.AllNotes[noteID]
+		if i := sort.SearchStrings(enabledNotes, noteID); exists && !(i < len(enabledNotes) && enabledNotes[i] == noteID) {
+			findings = append(findings, Finding{NoteID: noteID, Problem: "has a saved state for reverting it, but it is not enabled", Remedy: "saptune note revert " + noteID})
+		}
+	}
+	return
+}
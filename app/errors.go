@@ -0,0 +1,52 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of failure reported by the functions of App, callers may tell them apart using errors.Is.
+var (
+	ErrNoteNotFound     = errors.New("note is not recognised by saptune")
+	ErrSolutionNotFound = errors.New("solution is not recognised by saptune")
+	ErrInspectionFailed = errors.New("failed to inspect the system")
+	ErrApplyDenied      = errors.New("the system did not accept the parameter values")
+	ErrStateFailed      = errors.New("failed to read or write the configuration or state of saptune")
+	// ErrKernelUnsupported is returned when a note is applied on a kernel outside of the versions it supports.
+	ErrKernelUnsupported = errors.New("the running kernel is not supported by the note")
+	// ErrOutsideMaintenanceWindow is returned while tuning is not allowed by the configured maintenance windows.
+	ErrOutsideMaintenanceWindow = errors.New("outside of the maintenance windows")
+	// ErrPackageMissing is returned when a note is applied while a software package it requires is not installed.
+	ErrPackageMissing = errors.New("a package required by the note is not installed")
+	// ErrNotConfirmed is returned when applying a note would change high-risk parameters that were not confirmed.
+	ErrNotConfirmed = errors.New("high-risk parameters were not confirmed")
+)
+
+/*
+Error is returned by the functions of App for a failure of a known kind. The message is meant for the user and is
+unaffected by the kind, errors.Is matches both the kind and the underlying cause.
+*/
+type Error struct {
+	Kind error // one of the Err* kinds of failure
+	Err  error // underlying cause, nil if there is none
+	msg  string
+}
+
+// Return an error of the kind, carrying the cause and a message formatted from the template.
+func newError(kind, cause error, template string, stuff ...interface{}) *Error {
+	return &Error{Kind: kind, Err: cause, msg: fmt.Sprintf(template, stuff...)}
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Return true only if the target is the kind of the error.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Return the underlying cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"errors"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	allNotes := map[string]note.Note{"1001": SampleNote1{}, "failing": FailingNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	// The message remains meant for the user
+	if err := tuneApp.TuneNote("9999"); !errors.Is(err, ErrNoteNotFound) || !strings.HasPrefix(err.Error(), `Note ID "9999" is not recognised`) {
+		t.Fatal(err)
+	}
+	if _, err := tuneApp.GetSolutionByName("nosol"); !errors.Is(err, ErrSolutionNotFound) || errors.Is(err, ErrNoteNotFound) {
+		t.Fatal(err)
+	}
+	// The underlying cause is kept
+	if _, _, err := tuneApp.VerifyNote("failing"); !errors.Is(err, ErrInspectionFailed) || errors.Unwrap(err).Error() != "failing note" {
+		t.Fatal(err)
+	}
+	if err := tuneApp.TuneNote("failing"); !errors.Is(err, ErrInspectionFailed) {
+		t.Fatal(err)
+	}
+	// The kind may also be examined directly
+	var appErr *Error
+	if err := tuneApp.RevertNote("9999", true); !errors.As(err, &appErr) || appErr.Kind != ErrNoteNotFound {
+		t.Fatal(err)
+	}
+}
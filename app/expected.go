@@ -0,0 +1,66 @@
+package app
+
+import (
+	"fmt"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"github.com/HouzuoGuo/saptune/system"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+/*
+Read a file of expected parameter values maintained outside of saptune, one "parameter = value" pair per line, e.g.
+"vm.swappiness = 10". Blank lines and lines starting with # are ignored. Return parameter name VS expected value.
+*/
+func ReadExpectedValues(filePath string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	expected := make(map[string]string)
+	for lineNum, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" {
+			return nil, fmt.Errorf("%s:%d: \"%s\" is not a pair of parameter = value", filePath, lineNum+1, line)
+		}
+		expected[strings.TrimSpace(fields[0])] = strings.TrimSpace(fields[1])
+	}
+	return expected, nil
+}
+
+/*
+Read the live values of the sysctl parameters and compare them against the expected values, parameter name VS value,
+without regard to any note. The comparisons are keyed like those of tuning sheets, e.g. "SysctlParams[vm.swappiness]".
+Values consisting of several fields match regardless of the white space between them. Parameters that do not exist
+on the running kernel are not compared, they are returned separately and sorted.
+*/
+func VerifyExpectedValues(expected map[string]string) (comparisons map[string]note.NoteFieldComparison, missing []string) {
+	comparisons = make(map[string]note.NoteFieldComparison)
+	missing = make([]string, 0, 0)
+	for param, expectedValue := range expected {
+		if !system.IsSysctlAvailable(param) {
+			missing = append(missing, param)
+			continue
+		}
+		actualValue, _ := system.GetSysctlString(param)
+		actualValue = strings.Join(strings.Fields(actualValue), " ")
+		expectedValue = strings.Join(strings.Fields(expectedValue), " ")
+		comparison := note.NoteFieldComparison{
+			ReflectFieldName: "SysctlParams",
+			ReflectMapKey:    param,
+			ParamID:          fmt.Sprintf("SysctlParams[%s]", param),
+			ActualValue:      actualValue,
+			ExpectedValue:    expectedValue,
+			Severity:         note.SeverityMandatory,
+		}
+		comparison.ActualValueJS, comparison.ExpectedValueJS, comparison.MatchExpectation = note.CompareJSValue(actualValue, expectedValue)
+		comparisons[comparison.ParamID] = comparison
+	}
+	sort.Strings(missing)
+	return
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyExpectedValues(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	filePath := path.Join(SampleNoteDataDir, "expected.conf")
+	WriteFileOrPanic(filePath, "# policy\nkernel.ostype = Linux\n\nkernel.ostype.saptune = 1\nkernel.osrelease=0.0.0\n")
+	expected, err := ReadExpectedValues(filePath)
+	if err != nil || !reflect.DeepEqual(expected, map[string]string{"kernel.ostype": "Linux", "kernel.ostype.saptune": "1", "kernel.osrelease": "0.0.0"}) {
+		t.Fatal(expected, err)
+	}
+	comparisons, missing := VerifyExpectedValues(expected)
+	if !reflect.DeepEqual(missing, []string{"kernel.ostype.saptune"}) || len(comparisons) != 2 {
+		t.Fatal(comparisons, missing)
+	}
+	if comparison := comparisons["SysctlParams[kernel.ostype]"]; !comparison.MatchExpectation || comparison.Label() != "kernel.ostype" {
+		t.Fatal(comparison)
+	}
+	if comparison := comparisons["SysctlParams[kernel.osrelease]"]; comparison.MatchExpectation || comparison.ExpectedValueJS != "0.0.0" {
+		t.Fatal(comparison)
+	}
+	// A line that is not a pair is refused
+	WriteFileOrPanic(filePath, "kernel.ostype\n")
+	if _, err := ReadExpectedValues(filePath); err == nil {
+		t.Fatal("did not error")
+	}
+}
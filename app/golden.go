@@ -0,0 +1,109 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"io/ioutil"
+)
+
+/*
+GoldenState records the actual parameter values of a reference host, note ID VS parameter name VS value in JSON.
+The parameter names are those of the note comparisons, e.g. "SysctlParams[vm.swappiness]".
+*/
+type GoldenState map[string]map[string]json.RawMessage
+
+// Inspect the system and record the actual parameter values of all enabled notes.
+func (app *App) ExportGoldenState() (GoldenState, error) {
+	golden := make(GoldenState)
+	for _, noteID := range app.GetSortedAllEnabledNotes() {
+		aNote, err := app.GetNoteByID(noteID)
+		if err != nil {
+			return nil, err
+		}
+		initialised, err := aNote.Initialise()
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect the system for note %s - %v", noteID, err)
+		}
+		_, comparisons := note.CompareNoteFields(initialised, initialised)
+		golden[noteID] = make(map[string]json.RawMessage)
+		for name, comparison := range comparisons {
+			// The JS representation of a string value is unquoted, hence it is not necessarily valid JSON
+			value, err := json.Marshal(comparison.ActualValue)
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialise note %s, parameter %s - %v", noteID, name, err)
+			}
+			golden[noteID][name] = json.RawMessage(value)
+		}
+	}
+	return golden, nil
+}
+
+// Write the golden state into a file in JSON.
+func (golden GoldenState) Save(filePath string) error {
+	content, err := json.MarshalIndent(golden, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filePath, content, 0644)
+}
+
+// Read a golden state file written by GoldenState.Save.
+func ReadGoldenState(filePath string) (golden GoldenState, err error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(content, &golden)
+	return
+}
+
+/*
+Inspect the system and compare the actual parameter values against those recorded in the golden state, rather than
+against the recommendations of the notes. Only the notes and parameters recorded in the golden state are compared.
+The expected value of each comparison is the golden value. A parameter unknown to the note on this host has no
+actual value and deviates.
+*/
+func (app *App) VerifyAgainstGolden(golden GoldenState) (unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison, err error) {
+	unsatisfiedNotes = make([]string, 0, 0)
+	comparisons = make(map[string]map[string]note.NoteFieldComparison)
+	for _, noteID := range golden.GetSortedNoteIDs() {
+		aNote, err := app.GetNoteByID(noteID)
+		if err != nil {
+			return nil, nil, err
+		}
+		initialised, err := aNote.Initialise()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to inspect the system for note %s - %v", noteID, err)
+		}
+		_, actual := note.CompareNoteFields(initialised, initialised)
+		conforming := true
+		comparisons[noteID] = make(map[string]note.NoteFieldComparison)
+		for name, goldenJS := range golden[noteID] {
+			var goldenValue interface{}
+			// Keep numbers as they are written, large integers would otherwise lose precision
+			decoder := json.NewDecoder(bytes.NewReader(goldenJS))
+			decoder.UseNumber()
+			if err := decoder.Decode(&goldenValue); err != nil {
+				return nil, nil, fmt.Errorf("invalid golden value of note %s, parameter %s - %v", noteID, name, err)
+			}
+			comparison, exists := actual[name]
+			comparison.ParamID = name
+			comparison.ExpectedValue = goldenValue
+			comparison.ActualValueJS, comparison.ExpectedValueJS, comparison.MatchExpectation = note.CompareJSValue(comparison.ActualValue, goldenValue)
+			if !exists {
+				comparison.ActualValueJS = "(not defined)"
+				comparison.MatchExpectation = false
+			}
+			if !comparison.MatchExpectation {
+				conforming = false
+			}
+			comparisons[noteID][name] = comparison
+		}
+		if !conforming {
+			unsatisfiedNotes = append(unsatisfiedNotes, noteID)
+		}
+	}
+	return
+}
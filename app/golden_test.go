@@ -0,0 +1,67 @@
+package app
+
+import (
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestGoldenState(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if err := tuneApp.TuneNote("1001"); err != nil {
+		t.Fatal(err)
+	}
+	golden, err := tuneApp.ExportGoldenState()
+	if err != nil || len(golden) != 1 || string(golden["1001"]["Param"]) != `{"Data":"optimised1"}` {
+		t.Fatal(golden, err)
+	}
+	goldenFile := path.Join(SampleNoteDataDir, "golden.json")
+	if err := golden.Save(goldenFile); err != nil {
+		t.Fatal(err)
+	}
+	golden, err = ReadGoldenState(goldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The host matches its own golden state
+	if unsatisfiedNotes, comparisons, err := tuneApp.VerifyAgainstGolden(golden); err != nil || len(unsatisfiedNotes) != 0 || len(comparisons["1001"]) != 1 {
+		t.Fatal(unsatisfiedNotes, comparisons, err)
+	}
+	// Drift is reported against the golden value rather than the recommendation
+	WriteFileOrPanic(SampleParamFile, "drifted")
+	unsatisfiedNotes, comparisons, err := tuneApp.VerifyAgainstGolden(golden)
+	if err != nil || !reflect.DeepEqual(unsatisfiedNotes, []string{"1001"}) {
+		t.Fatal(unsatisfiedNotes, err)
+	}
+	if comparison := comparisons["1001"]["Param"]; comparison.ExpectedValueJS != `{"Data":"optimised1"}` || comparison.ActualValueJS != `{"Data":"drifted"}` {
+		t.Fatal(comparison)
+	}
+	// String values are exported as JSON strings
+	allNotes := map[string]note.Note{"ini": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini"}}
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nkernel.sem = 250 32000 100 128\n")
+	iniApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	iniApp.TuneForNotes = []string{"ini"}
+	iniGolden, err := iniApp.ExportGoldenState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := iniGolden.Save(goldenFile); err != nil {
+		t.Fatal(err)
+	}
+	if unsatisfiedNotes, _, err := iniApp.VerifyAgainstGolden(iniGolden); err != nil || len(unsatisfiedNotes) != 0 {
+		t.Fatal(unsatisfiedNotes, err)
+	}
+	// Parameters and notes unknown to this host
+	golden["1001"]["Missing"] = golden["1001"]["Param"]
+	if unsatisfiedNotes, comparisons, err := tuneApp.VerifyAgainstGolden(golden); err != nil || len(unsatisfiedNotes) != 1 || comparisons["1001"]["Missing"].MatchExpectation {
+		t.Fatal(unsatisfiedNotes, comparisons, err)
+	}
+	golden["9999"] = golden["1001"]
+	if _, _, err := tuneApp.VerifyAgainstGolden(golden); err == nil {
+		t.Fatal("did not error")
+	}
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"fmt"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"os"
+	"sort"
+)
+
+/*
+NoteStagingKey enables the staging of note definitions if it is "yes". The parameter values as declared by a note
+definition are then recorded when the note is first applied, and an updated definition, e.g. shipped by a new saptune
+package, is held back until it is released explicitly: parameters are optimised by their released declarations, and
+parameters new to the definition keep their current values.
+*/
+const NoteStagingKey = "NOTE_STAGING"
+
+// Parameter change kinds of DefinitionChange.
+const (
+	DefinitionChanged = "changed"
+	DefinitionAdded   = "added"
+	DefinitionRemoved = "removed"
+)
+
+// A parameter whose value in the current note definition differs from the released definition.
+type DefinitionChange struct {
+	Param    string // parameter name, e.g. vm.swappiness
+	Kind     string // DefinitionChanged, DefinitionAdded, or DefinitionRemoved
+	Released string // the value of the released definition, empty if the parameter is added
+	Staged   string // the value of the current definition, empty if the parameter is removed
+}
+
+// Return the values of the parameters of the note, parameter name VS value, in the text form of customisation files.
+func getParamValues(aNote note.Note) map[string]string {
+	values := make(map[string]string)
+	_, comparisons := note.CompareNoteFields(aNote, aNote)
+	for _, comparison := range note.FilterParameters(comparisons) {
+		values[note.GetParamName(comparison)] = fmt.Sprint(comparison.ExpectedValue)
+	}
+	return values
+}
+
+/*
+Return the parameter values as declared by the current definition of the note, e.g. ">10" or "75%", without
+customisation and pins. The system is not inspected, hence the definition does not depend on the current values.
+Built-in notes declare nothing, their definition consists of the values they calculate without inspecting the system,
+i.e. their defaults.
+*/
+func getDefinition(noteID string, aNote note.Note) (map[string]string, error) {
+	if _, isDeclarative := aNote.(note.Declarative); !isDeclarative {
+		if defaults, err := aNote.Optimise(); err == nil {
+			aNote = defaults
+		}
+	}
+	declared, err := note.GetDeclaredParameters(aNote)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the definition of note %s - %v", noteID, err)
+	}
+	definition := make(map[string]string)
+	for _, comparison := range declared {
+		definition[note.GetParamName(comparison)] = fmt.Sprint(comparison.ExpectedValue)
+	}
+	return definition, nil
+}
+
+// Return the differences between the released and the current parameter values of a definition, sorted by name.
+func compareDefinitions(released, staged map[string]string) []DefinitionChange {
+	changes := make([]DefinitionChange, 0, 0)
+	for param, value := range staged {
+		if releasedValue, exists := released[param]; !exists {
+			changes = append(changes, DefinitionChange{Param: param, Kind: DefinitionAdded, Staged: value})
+		} else if releasedValue != value {
+			changes = append(changes, DefinitionChange{Param: param, Kind: DefinitionChanged, Released: releasedValue, Staged: value})
+		}
+	}
+	for param, value := range released {
+		if _, exists := staged[param]; !exists {
+			changes = append(changes, DefinitionChange{Param: param, Kind: DefinitionRemoved, Released: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Param < changes[j].Param
+	})
+	return changes
+}
+
+// Record the current definition of the note as released, unless a definition of the note has been released before.
+func (app *App) recordRelease(noteID string, aNote note.Note) error {
+	if !app.NoteStaging {
+		return nil
+	} else if _, err := app.State.GetRelease(noteID); !os.IsNotExist(err) {
+		return nil
+	}
+	definition, err := getDefinition(noteID, aNote)
+	if err != nil {
+		return err
+	}
+	if err := app.State.StoreRelease(noteID, definition); err != nil {
+		return newError(ErrStateFailed, err, "%v", err)
+	}
+	return nil
+}
+
+/*
+Hold back the changes of the note definition that have not been released: changed parameters of Redefinable notes are
+optimised by their released declarations against their current values, changed parameters of other notes, such as the
+built-in notes, take their released values, and parameters new to the definition take their current values, parameter
+name VS value, so that applying the note does not change them. Parameters whose values cannot be customised follow the
+current definition. The note is returned unchanged if staging is disabled or no definition of the note has been released.
+*/
+func (app *App) holdBackDefinition(noteID string, initialised note.Note, current map[string]string, optimised note.Note) (note.Note, error) {
+	if !app.NoteStaging {
+		return optimised, nil
+	}
+	released, err := app.State.GetRelease(noteID)
+	if os.IsNotExist(err) {
+		return optimised, nil
+	} else if err != nil {
+		return nil, newError(ErrStateFailed, err, "%v", err)
+	}
+	// The definition of a built-in note is calculated from the note as defined rather than as initialised
+	definedNote, err := app.GetNoteByID(noteID)
+	if err != nil {
+		definedNote = initialised
+	}
+	staged, err := getDefinition(noteID, definedNote)
+	if err != nil {
+		return nil, err
+	}
+	redefinable, isRedefinable := initialised.(note.Redefinable)
+	heldBack := make(map[string]string)
+	for _, change := range compareDefinitions(released, staged) {
+		var value string
+		switch change.Kind {
+		case DefinitionRemoved:
+			continue
+		case DefinitionAdded:
+			value = current[change.Param]
+		case DefinitionChanged:
+			value = change.Released
+			if isRedefinable {
+				if value, err = redefinable.OptimiseParam(change.Param, change.Released, current[change.Param]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if _, err := note.ApplyOverrides(optimised, map[string]string{change.Param: value}); err == nil {
+			heldBack[change.Param] = value
+		}
+	}
+	return note.ApplyOverrides(optimised, heldBack)
+}
+
+/*
+Return the changes of the note definition since it was released, sorted by parameter name. Customisation and pins are
+not taken into account. Return an error if no definition of the note has been released.
+*/
+func (app *App) GetDefinitionChanges(noteID string) ([]DefinitionChange, error) {
+	aNote, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return nil, err
+	}
+	released, err := app.State.GetRelease(noteID)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("No definition of note %s has been released.", noteID)
+	} else if err != nil {
+		return nil, newError(ErrStateFailed, err, "%v", err)
+	}
+	definition, err := getDefinition(noteID, aNote)
+	if err != nil {
+		return nil, err
+	}
+	return compareDefinitions(released, definition), nil
+}
+
+/*
+Return the notes whose current definition differs from the released one, note ID VS changes. Released notes that are
+no longer defined are left out.
+*/
+func (app *App) GetAllDefinitionChanges() (map[string][]DefinitionChange, error) {
+	allChanges := make(map[string][]DefinitionChange)
+	noteIDs, err := app.State.GetReleasedNotes()
+	if err != nil {
+		return nil, newError(ErrStateFailed, err, "%v", err)
+	}
+	for _, noteID := range noteIDs {
+		if _, err := app.GetNoteByID(noteID); err != nil {
+			continue
+		}
+		changes, err := app.GetDefinitionChanges(noteID)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 {
+			allChanges[noteID] = changes
+		}
+	}
+	return allChanges, nil
+}
+
+/*
+Release the current definition of the note into use. If the note is enabled, it is refreshed right away, and the
+parameters that changed on the system are returned like RefreshNote does.
+*/
+func (app *App) ReleaseNote(noteID string) (refreshed map[string]note.NoteFieldComparison, err error) {
+	refreshed = make(map[string]note.NoteFieldComparison)
+	aNote, err := app.GetNoteByID(noteID)
+	if err != nil {
+		return
+	}
+	definition, err := getDefinition(noteID, aNote)
+	if err != nil {
+		return nil, err
+	}
+	if err = app.State.StoreRelease(noteID, definition); err != nil {
+		return nil, newError(ErrStateFailed, err, "%v", err)
+	}
+	enabledNotes := app.GetSortedAllEnabledNotes()
+	if i := sort.SearchStrings(enabledNotes, noteID); i < len(enabledNotes) && enabledNotes[i] == noteID {
+		return app.RefreshNote(noteID)
+	}
+	return
+}
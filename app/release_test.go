@@ -0,0 +1,158 @@
+package app
+
+import (
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"github.com/HouzuoGuo/saptune/system"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// The value that BuiltinNote calculates, a new saptune package may change it.
+var builtinDefault = "10"
+
+// A note built into saptune, whose definition is its calculation rather than a tuning sheet.
+type BuiltinNote struct {
+	Value string
+}
+
+func (n BuiltinNote) Name() string {
+	return "built-in note"
+}
+func (n BuiltinNote) Initialise() (note.Note, error) {
+	content, _ := ioutil.ReadFile(SampleParamFile)
+	n.Value = string(content)
+	return n, nil
+}
+func (n BuiltinNote) Optimise() (note.Note, error) {
+	n.Value = builtinDefault
+	return n, nil
+}
+func (n BuiltinNote) Apply() error {
+	return ioutil.WriteFile(SampleParamFile, []byte(n.Value), 0644)
+}
+
+func TestBuiltinNoteStaging(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	defer func() {
+		builtinDefault = "10"
+	}()
+	allNotes := map[string]note.Note{"builtin": BuiltinNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	tuneApp.NoteStaging = true
+	if err := tuneApp.recordRelease("builtin", allNotes["builtin"]); err != nil {
+		t.Fatal(err)
+	}
+	// The released definition consists of the calculated default, regardless of the current value
+	WriteFileOrPanic(SampleParamFile, "5")
+	if released, err := tuneApp.State.GetRelease("builtin"); err != nil || !reflect.DeepEqual(released, map[string]string{"Value": "10"}) {
+		t.Fatal(released, err)
+	}
+	// A new saptune package changes the default, applying the note keeps the released value
+	builtinDefault = "20"
+	if changes, err := tuneApp.GetDefinitionChanges("builtin"); err != nil || !reflect.DeepEqual(changes, []DefinitionChange{
+		{Param: "Value", Kind: DefinitionChanged, Released: "10", Staged: "20"},
+	}) {
+		t.Fatal(changes, err)
+	}
+	if err := tuneApp.TuneNote("builtin"); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(SampleParamFile); string(content) != "10" {
+		t.Fatal(string(content))
+	}
+	// Releasing the definition applies the new default
+	if refreshed, err := tuneApp.ReleaseNote("builtin"); err != nil || refreshed["Value"].ExpectedValueJS != "20" {
+		t.Fatal(refreshed, err)
+	}
+	if content, _ := ioutil.ReadFile(SampleParamFile); string(content) != "20" {
+		t.Fatal(string(content))
+	}
+}
+
+func TestNoteStaging(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	os.MkdirAll(SampleNoteDataDir, 0755)
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=10\nvm.dirty_ratio=10\n")
+	allNotes := map[string]note.Note{"ini": note.INISettings{ConfFilePath: path.Join(SampleNoteDataDir, "ini.conf"), ID: "ini"}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	tuneApp.NoteStaging = true
+	if _, err := tuneApp.GetDefinitionChanges("ini"); err == nil {
+		t.Fatal("no definition has been released")
+	}
+	if err := tuneApp.recordRelease("ini", allNotes["ini"]); err != nil {
+		t.Fatal(err)
+	}
+	// Only the parameters are recorded, as declared by the definition
+	if released, err := tuneApp.State.GetRelease("ini"); err != nil || !reflect.DeepEqual(released, map[string]string{"vm.swappiness": "10", "vm.dirty_ratio": "10"}) {
+		t.Fatal(released, err)
+	}
+	// An update changes a value, adds a parameter, and removes another
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=20\nvm.dirty_background_ratio=5\n")
+	changes, err := tuneApp.GetDefinitionChanges("ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changes, []DefinitionChange{
+		{Param: "vm.dirty_background_ratio", Kind: DefinitionAdded, Staged: "5"},
+		{Param: "vm.dirty_ratio", Kind: DefinitionRemoved, Released: "10"},
+		{Param: "vm.swappiness", Kind: DefinitionChanged, Released: "10", Staged: "20"},
+	}) {
+		t.Fatal(changes)
+	}
+	// Until the update is released, the note keeps its released value and does not touch the added parameter
+	current, err := system.GetSysctlString("vm.dirty_background_ratio")
+	if err != nil {
+		t.Skip(err)
+	}
+	_, comparisons, err := tuneApp.VerifyNote("ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comparisons["SysctlParams[vm.swappiness]"].ExpectedValueJS != "10" || comparisons["SysctlParams[vm.dirty_background_ratio]"].ExpectedValueJS != current {
+		t.Fatal(comparisons)
+	}
+	if allChanges, err := tuneApp.GetAllDefinitionChanges(); err != nil || len(allChanges["ini"]) != 3 {
+		t.Fatal(allChanges, err)
+	}
+	// The note is not enabled, hence releasing does not apply it
+	if refreshed, err := tuneApp.ReleaseNote("ini"); err != nil || len(refreshed) != 0 {
+		t.Fatal(refreshed, err)
+	}
+	if changes, err := tuneApp.GetDefinitionChanges("ini"); err != nil || len(changes) != 0 {
+		t.Fatal(changes, err)
+	}
+	_, comparisons, err = tuneApp.VerifyNote("ini")
+	if err != nil || comparisons["SysctlParams[vm.swappiness]"].ExpectedValueJS != "20" || comparisons["SysctlParams[vm.dirty_background_ratio]"].ExpectedValueJS != "5" {
+		t.Fatal(comparisons, err)
+	}
+	// A released declaration is optimised against the current value rather than pinned
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness>1\nvm.dirty_background_ratio=5\n")
+	tuneApp.State.StoreRelease("ini", map[string]string{"vm.swappiness": ">200", "vm.dirty_background_ratio": "5"})
+	if changes, err := tuneApp.GetDefinitionChanges("ini"); err != nil || !reflect.DeepEqual(changes, []DefinitionChange{
+		{Param: "vm.swappiness", Kind: DefinitionChanged, Released: ">200", Staged: ">1"},
+	}) {
+		t.Fatal(changes, err)
+	}
+	swappiness, err := system.GetSysctlString("vm.swappiness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := note.CalculateOptimumValue(">", swappiness, "200")
+	_, comparisons, err = tuneApp.VerifyNote("ini")
+	if err != nil || comparisons["SysctlParams[vm.swappiness]"].ExpectedValueJS != expected {
+		t.Fatal(comparisons, err)
+	}
+	// Without staging the current definition is in use
+	WriteFileOrPanic(path.Join(SampleNoteDataDir, "ini.conf"), "[sysctl]\nvm.swappiness=30\n")
+	tuneApp.NoteStaging = false
+	_, comparisons, err = tuneApp.VerifyNote("ini")
+	if err != nil || comparisons["SysctlParams[vm.swappiness]"].ExpectedValueJS != "30" {
+		t.Fatal(comparisons, err)
+	}
+}
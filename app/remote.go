@@ -0,0 +1,159 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/HouzuoGuo/saptune/txtparser"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// NoteSourceURLKey names an HTTP(S) URL of a tar archive (optionally gzip-compressed) of tuning sheets.
+	NoteSourceURLKey = "NOTE_SOURCE_URL"
+	// NoteSourceChecksumKey is the SHA-256 checksum of the archive, the archive is not trusted without it.
+	NoteSourceChecksumKey  = "NOTE_SOURCE_SHA256"
+	SaptuneRemoteSheetsDir = "/var/lib/saptune/remote_sheets" // the tuning sheets last fetched from the note source
+	// RemoteSheetsMaxSize limits the size of the archive of tuning sheets in bytes.
+	RemoteSheetsMaxSize = 64 * 1024 * 1024
+)
+
+// The timeout of fetching the archive of tuning sheets, it is a variable so that tests may shorten it.
+var remoteSheetsTimeout = 30 * time.Second
+
+// A central source of tuning sheets, fetched into a local cache.
+type RemoteSheetSource struct {
+	URL      string // location of the archive of tuning sheets
+	Checksum string // SHA-256 checksum of the archive in hexadecimal
+	CacheDir string // the directory of the tuning sheets last fetched
+}
+
+/*
+Return the note source configured in /etc/sysconfig/saptune, and true only if a note source is configured. The state
+directory prefix locates the cache, both prefixes are normally empty.
+*/
+func GetRemoteSheetSource(sysconfigPrefix, stateDirPrefix string) (source RemoteSheetSource, configured bool) {
+	sysconf, err := txtparser.ParseSysconfigFile(path.Join(sysconfigPrefix, SysconfigSaptuneDir), false)
+	if err != nil {
+		return
+	}
+	source = RemoteSheetSource{
+		URL:      sysconf.GetString(NoteSourceURLKey, ""),
+		Checksum: strings.ToLower(sysconf.GetString(NoteSourceChecksumKey, "")),
+		CacheDir: path.Join(stateDirPrefix, SaptuneRemoteSheetsDir),
+	}
+	return source, source.URL != ""
+}
+
+/*
+Download the archive of tuning sheets, verify its checksum, and replace the cache with the sheets in the archive. The
+cache is left untouched if the source is unreachable or the archive cannot be trusted, so that the tuning sheets last
+fetched remain in use. Directories in the archive are disregarded, only the names of the sheets are kept.
+*/
+func (source RemoteSheetSource) Fetch() error {
+	if source.Checksum == "" {
+		return fmt.Errorf("%s is not set, hence the tuning sheets of %s cannot be trusted", NoteSourceChecksumKey, source.URL)
+	}
+	client := http.Client{Timeout: remoteSheetsTimeout}
+	resp, err := client.Get(source.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %s", source.URL, resp.Status)
+	}
+	archive, err := ioutil.ReadAll(io.LimitReader(resp.Body, RemoteSheetsMaxSize+1))
+	if err != nil {
+		return err
+	} else if len(archive) > RemoteSheetsMaxSize {
+		return fmt.Errorf("the archive at %s exceeds %d bytes", source.URL, RemoteSheetsMaxSize)
+	}
+	checksum := sha256.Sum256(archive)
+	if actual := hex.EncodeToString(checksum[:]); actual != source.Checksum {
+		return fmt.Errorf("the checksum of the archive at %s is %s, but %s is expected by %s", source.URL, actual, source.Checksum, NoteSourceChecksumKey)
+	}
+	// Unpack into a fresh directory first, so that a broken archive does not spoil the cache
+	newDir := fmt.Sprintf("%s.%d", source.CacheDir, time.Now().UnixNano())
+	if err := unpackSheets(archive, newDir); err != nil {
+		os.RemoveAll(newDir)
+		return fmt.Errorf("failed to unpack the archive at %s - %v", source.URL, err)
+	}
+	if err := swapCacheDir(source.CacheDir, newDir); err != nil {
+		os.RemoveAll(newDir)
+		return err
+	}
+	return nil
+}
+
+/*
+Make the cache a symbolic link to the directory of the sheets just fetched. The link is replaced by renaming another
+link over it, hence readers find either the previous or the new sheets, but never a missing cache. The directory of the
+previous sheets is removed afterwards. A cache written by an earlier version of saptune is a plain directory, which is
+moved aside first.
+*/
+func swapCacheDir(cacheDir, newDir string) error {
+	previous, err := os.Readlink(cacheDir)
+	if err == nil {
+		previous = path.Join(path.Dir(cacheDir), previous)
+	} else if info, statErr := os.Lstat(cacheDir); statErr == nil && info.IsDir() {
+		previous = cacheDir + ".old"
+		if err := os.RemoveAll(previous); err != nil {
+			return err
+		} else if err := os.Rename(cacheDir, previous); err != nil {
+			return err
+		}
+	}
+	newLink := newDir + ".link"
+	if err := os.Symlink(path.Base(newDir), newLink); err != nil {
+		return err
+	}
+	if err := os.Rename(newLink, cacheDir); err != nil {
+		os.Remove(newLink)
+		return err
+	}
+	if previous != "" {
+		return os.RemoveAll(previous)
+	}
+	return nil
+}
+
+// Write the regular files of the tar archive, which may be gzip-compressed, into the directory by their base names.
+func unpackSheets(archive []byte, dirPath string) error {
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return err
+	}
+	var reader io.Reader = bytes.NewReader(archive)
+	if gzipReader, err := gzip.NewReader(bytes.NewReader(archive)); err == nil {
+		reader = gzipReader
+	}
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fileName := path.Base(header.Name)
+		if header.Typeflag != tar.TypeReg || strings.HasPrefix(fileName, ".") {
+			continue
+		}
+		content, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path.Join(dirPath, fileName), content, 0644); err != nil {
+			return err
+		}
+	}
+}
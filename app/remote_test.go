@@ -0,0 +1,110 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+)
+
+// Return a gzip-compressed tar archive of the files, file name VS content.
+func makeSheetArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tarWriter.Write([]byte(content))
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+	return buf.Bytes()
+}
+
+func TestRemoteSheetSource(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	archive := makeSheetArchive(t, map[string]string{
+		"sheets-main/REMOTE-remote.conf": "[sysctl]\nvm.swappiness = 10\n",
+		"sheets-main/SHARED-remote.conf": "[sysctl]\nvm.swappiness = 20\n",
+	})
+	served := archive
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(served)
+	}))
+	defer server.Close()
+	checksum := sha256.Sum256(archive)
+	confDir := path.Join(SampleNoteDataDir, "conf")
+	os.MkdirAll(path.Join(confDir, path.Dir(SysconfigSaptuneDir)), 0755)
+	WriteFileOrPanic(path.Join(confDir, SysconfigSaptuneDir), NoteSourceURLKey+"=\""+server.URL+"\"\n"+NoteSourceChecksumKey+"=\""+hex.EncodeToString(checksum[:])+"\"\n")
+
+	source, configured := GetRemoteSheetSource(confDir, path.Join(SampleNoteDataDir, "data"))
+	if !configured || source.URL != server.URL {
+		t.Fatal(source)
+	}
+	if err := source.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	// Local sheets override fetched ones
+	localDir := path.Join(SampleNoteDataDir, "extra")
+	os.MkdirAll(localDir, 0755)
+	WriteFileOrPanic(path.Join(localDir, "SHARED-local.conf"), "[sysctl]\nvm.swappiness = 30\n")
+	opts := note.GetTuningOptionsFrom("", source.CacheDir, localDir)
+	if sheet, exists := opts["REMOTE"]; !exists || sheet.(note.INISettings).ConfFilePath != path.Join(source.CacheDir, "REMOTE-remote.conf") {
+		t.Fatal(opts)
+	}
+	if sheet := opts["SHARED"]; sheet.(note.INISettings).ConfFilePath != path.Join(localDir, "SHARED-local.conf") {
+		t.Fatal(sheet)
+	}
+	// An archive that does not match the checksum is refused, the cache remains in use
+	served = makeSheetArchive(t, map[string]string{"EVIL-evil.conf": "[sysctl]\nvm.swappiness = 100\n"})
+	if err := source.Fetch(); err == nil {
+		t.Fatal("did not error")
+	}
+	if _, err := ioutil.ReadFile(path.Join(source.CacheDir, "REMOTE-remote.conf")); err != nil {
+		t.Fatal(err)
+	}
+	// So does an unreachable source
+	server.Close()
+	if err := source.Fetch(); err == nil {
+		t.Fatal("did not error")
+	}
+	if _, err := ioutil.ReadFile(path.Join(source.CacheDir, "EVIL-evil.conf")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	// A newer archive replaces the sheets fetched before
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(served)
+	}))
+	defer server.Close()
+	served = makeSheetArchive(t, map[string]string{"NEWER-remote.conf": "[sysctl]\nvm.swappiness = 40\n"})
+	checksum = sha256.Sum256(served)
+	source.URL, source.Checksum = server.URL, hex.EncodeToString(checksum[:])
+	if err := source.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadFile(path.Join(source.CacheDir, "NEWER-remote.conf")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadFile(path.Join(source.CacheDir, "REMOTE-remote.conf")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if files, err := ioutil.ReadDir(path.Dir(source.CacheDir)); err != nil || len(files) != 2 {
+		t.Fatal(files, err)
+	}
+	// The archive is not trusted without checksum
+	source.Checksum = ""
+	if err := source.Fetch(); err == nil {
+		t.Fatal("did not error")
+	}
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"os"
+	"sort"
+	"time"
+)
+
+// The state of the tuning daemon at the time of a report.
+type DaemonStatus struct {
+	Running bool `json:"running"` // saptune.service is active
+}
+
+/*
+Report combines the configuration of saptune, the outcome of verifying all enabled notes and the resulting set of
+managed parameters into one document, e.g. to be archived as evidence of compliance.
+*/
+type Report struct {
+	Time              time.Time                                      `json:"time"`
+	Hostname          string                                         `json:"hostname"`
+	Daemon            *DaemonStatus                                  `json:"daemon,omitempty"` // nil if the daemon was not inspected
+	EnabledSolutions  []string                                       `json:"enabled_solutions"`
+	EnabledNotes      []string                                       `json:"enabled_notes"` // enabled manually or by a solution
+	UnsatisfiedNotes  []string                                       `json:"unsatisfied_notes"`
+	Errors            map[string]string                              `json:"errors"`      // note ID VS failure to inspect the system
+	Comparisons       map[string]map[string]note.NoteFieldComparison `json:"comparisons"` // note ID VS comparison name VS comparison
+	Score             ComplianceScore                                `json:"compliance_score"`
+	ManagedParameters []ManagedParameter                             `json:"managed_parameters"`
+}
+
+/*
+Verify all enabled notes and assemble the report, the system is inspected but not changed. The daemon status is given
+by the caller, it may be nil. Parameters of notes that fail to inspect the system are not among the managed ones.
+*/
+func (app *App) GetReport(daemon *DaemonStatus) (report Report, err error) {
+	report = Report{Time: time.Now(), Daemon: daemon, Errors: make(map[string]string)}
+	if report.Hostname, err = os.Hostname(); err != nil {
+		return
+	}
+	report.EnabledSolutions = make([]string, len(app.TuneForSolutions))
+	copy(report.EnabledSolutions, app.TuneForSolutions)
+	sort.Strings(report.EnabledSolutions)
+	report.EnabledNotes = app.GetSortedAllEnabledNotes()
+	var noteErrs map[string]error
+	report.UnsatisfiedNotes, report.Comparisons, noteErrs = app.VerifyAll()
+	sort.Strings(report.UnsatisfiedNotes)
+	for noteID, noteErr := range noteErrs {
+		report.Errors[noteID] = noteErr.Error()
+	}
+	report.Score = GetComplianceScore(report.Comparisons)
+	// A parameter takes the value of the note that TuneAll applies last
+	tuneOrder, err := app.getTuneOrder()
+	if err != nil {
+		return
+	}
+	report.ManagedParameters = getManagedParameters(tuneOrder, report.Comparisons)
+	return
+}
+
+// Write the report into a file in JSON.
+func (report Report) Save(filePath string) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filePath, append(content, '\n'), 0644)
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	allNotes := map[string]note.Note{"1001": SampleNote1{}, "1002": SampleNote2{}, "failing": FailingNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	if _, err := tuneApp.TuneSolution("sol1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tuneApp.TuneNote("1002"); err != nil {
+		t.Fatal(err)
+	}
+	tuneApp.TuneForNotes = append(tuneApp.TuneForNotes, "failing")
+	daemon := &DaemonStatus{Running: true}
+	report, err := tuneApp.GetReport(daemon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Hostname == "" || report.Daemon != daemon || !reflect.DeepEqual(report.EnabledSolutions, []string{"sol1"}) {
+		t.Fatal(report)
+	}
+	if !reflect.DeepEqual(report.EnabledNotes, []string{"1001", "1002", "failing"}) || !reflect.DeepEqual(report.UnsatisfiedNotes, []string{"1001"}) {
+		t.Fatal(report.EnabledNotes, report.UnsatisfiedNotes)
+	}
+	if _, failed := report.Errors["failing"]; !failed || len(report.Comparisons) != 2 {
+		t.Fatal(report.Errors, report.Comparisons)
+	}
+	if report.Score.TotalParameters != 2 || report.Score.ConformingParameters != 1 {
+		t.Fatal(report.Score)
+	}
+	// Note 1002 is applied after 1001, hence it provides the value
+	if len(report.ManagedParameters) != 1 || report.ManagedParameters[0].NoteID != "1002" || report.ManagedParameters[0].Value != `{"Data":"optimised2"}` {
+		t.Fatal(report.ManagedParameters)
+	}
+	// The notes of solutions are applied before the additional notes regardless of their IDs
+	tuneApp.TuneForSolutions, tuneApp.TuneForNotes = []string{"sol2"}, []string{"1001"}
+	if report, err := tuneApp.GetReport(nil); err != nil || len(report.ManagedParameters) != 1 || report.ManagedParameters[0].NoteID != "1001" {
+		t.Fatal(report.ManagedParameters, err)
+	}
+	// The report is saved in JSON
+	reportFile := path.Join(SampleNoteDataDir, "report.json")
+	if err := report.Save(reportFile); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]interface{}
+	if err := json.Unmarshal(content, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved["hostname"] != report.Hostname || saved["daemon"].(map[string]interface{})["running"] != true {
+		t.Fatal(saved)
+	}
+	if saved["compliance_score"].(map[string]interface{})["percentage"] != 50.0 {
+		t.Fatal(saved["compliance_score"])
+	}
+}
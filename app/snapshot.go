@@ -0,0 +1,91 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+NoteInspector determines the current parameter values of a note, by default by inspecting the live system. The values
+are returned as a copy of the note, just like Note.Initialise does.
+*/
+type NoteInspector interface {
+	Inspect(noteID string, aNote note.Note) (note.Note, error)
+}
+
+// LiveInspector inspects the running system.
+type LiveInspector struct{}
+
+func (LiveInspector) Inspect(noteID string, aNote note.Note) (note.Note, error) {
+	return aNote.Initialise()
+}
+
+/*
+SnapshotInspector takes the current parameter values from a snapshot captured on another host, such as a golden state
+file written by `saptune verify --export`, rather than from the running system. A parameter that is not captured
+keeps the value of the note definition, a note that is not captured cannot be inspected.
+*/
+type SnapshotInspector struct {
+	Snapshot GoldenState
+}
+
+func (inspector SnapshotInspector) Inspect(noteID string, aNote note.Note) (note.Note, error) {
+	captured, exists := inspector.Snapshot[noteID]
+	if !exists {
+		return nil, fmt.Errorf("note %s is not captured in the snapshot", noteID)
+	}
+	// Note structures serialise their fields by name, the captured values are substituted field by field
+	content, err := json.Marshal(aNote)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+	for paramID, value := range captured {
+		fieldName, mapKey := paramID, ""
+		if i := strings.Index(paramID, "["); i > 0 && strings.HasSuffix(paramID, "]") {
+			fieldName, mapKey = paramID[:i], paramID[i+1:len(paramID)-1]
+		}
+		if _, isField := fields[fieldName]; !isField {
+			return nil, fmt.Errorf("note %s does not have parameter %s captured in the snapshot", noteID, paramID)
+		}
+		if mapKey == "" {
+			fields[fieldName] = value
+			continue
+		}
+		entries := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(fields[fieldName], &entries); err != nil {
+			return nil, fmt.Errorf("note %s, parameter %s - %v", noteID, paramID, err)
+		} else if entries == nil {
+			entries = make(map[string]json.RawMessage)
+		}
+		entries[mapKey] = value
+		if fields[fieldName], err = json.Marshal(entries); err != nil {
+			return nil, err
+		}
+	}
+	if content, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
+	inspected := reflect.New(reflect.TypeOf(aNote))
+	if err := json.Unmarshal(content, inspected.Interface()); err != nil {
+		return nil, fmt.Errorf("note %s does not accept the values captured in the snapshot - %v", noteID, err)
+	}
+	return inspected.Elem().Interface().(note.Note), nil
+}
+
+// Return the IDs of the notes captured in the snapshot, sorted.
+func (golden GoldenState) GetSortedNoteIDs() []string {
+	noteIDs := make([]string, 0, len(golden))
+	for noteID := range golden {
+		noteIDs = append(noteIDs, noteID)
+	}
+	sort.Strings(noteIDs)
+	return noteIDs
+}
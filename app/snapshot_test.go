@@ -0,0 +1,46 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSnapshotInspector(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	// The live system is not consulted, hence the parameter file is missing
+	tuneApp.Inspector = SnapshotInspector{Snapshot: GoldenState{"1001": {"Param": json.RawMessage(`{"Data":"captured"}`)}}}
+	conforming, comparisons, err := tuneApp.VerifyNote("1001")
+	if err != nil || conforming {
+		t.Fatal(conforming, err)
+	}
+	if comparison := comparisons["Param"]; comparison.ActualValueJS != `{"Data":"captured"}` || comparison.ExpectedValueJS != `{"Data":"optimised1"}` {
+		t.Fatal(comparison)
+	}
+	tuneApp.Inspector = SnapshotInspector{Snapshot: GoldenState{"1001": {"Param": json.RawMessage(`{"Data":"optimised1"}`)}}}
+	if conforming, comparisons, err := tuneApp.VerifyNote("1001"); err != nil || !conforming {
+		t.Fatal(comparisons, err)
+	}
+	// Notes not captured cannot be verified
+	if _, _, err := tuneApp.VerifyNote("1002"); err == nil {
+		t.Fatal("did not error")
+	}
+	// Map entries are substituted by their key
+	iniNote := note.INISettings{ID: "ini", SysctlParams: map[string]string{"vm.swappiness": "10", "kernel.sem": "1"}}
+	inspector := SnapshotInspector{Snapshot: GoldenState{"ini": {"SysctlParams[vm.swappiness]": json.RawMessage(`"60"`)}}}
+	inspected, err := inspector.Inspect("ini", iniNote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params := inspected.(note.INISettings).SysctlParams; params["vm.swappiness"] != "60" || params["kernel.sem"] != "1" {
+		t.Fatal(params)
+	}
+	inspector.Snapshot["ini"]["NoSuchField"] = json.RawMessage(`1`)
+	if _, err := inspector.Inspect("ini", iniNote); err == nil {
+		t.Fatal("did not error")
+	}
+}
@@ -4,11 +4,109 @@ import (
 	"encoding/json"
 	"github.com/HouzuoGuo/saptune/sap/note"
 	"io/ioutil"
+	"log"
 	"os"
 	"path"
+	"strings"
+	"time"
 )
 
-const SaptuneStateDir = "/var/lib/saptune/saved_state"
+const (
+	SaptuneStateDir         = "/var/lib/saptune/saved_state"
+	SaptuneAppliedDir       = "/var/lib/saptune/applied_time"   // the time each note was last applied
+	SaptuneRejectedDir      = "/var/lib/saptune/rejected"       // parameters the system did not accept when each note was last applied
+	SaptuneAppliedValuesDir = "/var/lib/saptune/applied_values" // the parameter values on the system right after each note was last applied
+	SaptunePinnedDir        = "/var/lib/saptune/pinned"         // parameter values pinned by the operator for each note
+	SaptuneExpiryDir        = "/var/lib/saptune/expiry"         // the time each note applied with a TTL is to be reverted
+	SaptuneReleasedDir      = "/var/lib/saptune/released"       // the parameter values of each note definition released into use
+	SysconfigBackupFile     = "/var/lib/saptune/sysconfig.bak"  // the previous content of /etc/sysconfig/saptune
+	SysconfigDamagedSuffix  = ".damaged"                        // appended to SysconfigBackupFile for the content replaced by the backup
+	// StateBackupSuffix is appended to the name of a state file to keep its previous content.
+	StateBackupSuffix = ".bak"
+)
+
+/*
+Replace the content of the file atomically, so that an interrupted write never leaves a truncated file behind. The
+content is written into a temporary file in the same directory, which is then renamed over the file. The previous
+content is kept in a backup file, to recover from a file that turns out to be corrupt nonetheless.
+*/
+func writeFileAtomic(filePath string, content []byte, perm os.FileMode) error {
+	return writeFileAtomicBackup(filePath, filePath+StateBackupSuffix, content, perm)
+}
+
+/*
+Replace the content of the file atomically like writeFileAtomic does, but keep the previous content in the backup file
+at the path given, e.g. outside of a directory whose files are all read by other programs. The temporary file is
+hidden and removed before returning.
+*/
+func writeFileAtomicBackup(filePath, backupPath string, content []byte, perm os.FileMode) error {
+	tmpFile, err := ioutil.TempFile(path.Dir(filePath), "."+path.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpFile.Name(), perm); err != nil {
+		return err
+	}
+	if previous, err := ioutil.ReadFile(filePath); err == nil {
+		if err := os.MkdirAll(path.Dir(backupPath), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(backupPath, previous, perm); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filePath)
+}
+
+/*
+Deserialise the JSON state file into the destination pointer. If the file is corrupt, the backup of its previous
+content is used instead, with a warning. The error satisfies os.IsNotExist if the file does not exist.
+*/
+func readStateFile(filePath string, dest interface{}) error {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(content, dest)
+	if err == nil {
+		return nil
+	}
+	backup, backupErr := ioutil.ReadFile(filePath + StateBackupSuffix)
+	if backupErr != nil || json.Unmarshal(backup, dest) != nil {
+		return err
+	}
+	log.Printf("State file %s is corrupt (%v), its previous content is used instead", filePath, err)
+	return nil
+}
+
+// Return true only if the file in a state directory is a backup or a temporary file rather than state.
+func isStateHelperFile(fileName string) bool {
+	return strings.HasPrefix(fileName, ".") || strings.HasSuffix(fileName, StateBackupSuffix)
+}
+
+// Remove the state file together with the backup of its previous content. A file that does not exist is ignored.
+func removeStateFile(filePath string) error {
+	for _, fileName := range []string{filePath, filePath + StateBackupSuffix} {
+		if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
 
 // Store and manage serialised note states.
 type State struct {
@@ -30,11 +128,17 @@ func (state *State) Store(noteID string, obj note.Note, overwriteExisting bool)
 		return err
 	}
 	if _, err := os.Stat(state.GetPathToNote(noteID)); os.IsNotExist(err) || overwriteExisting {
-		return ioutil.WriteFile(state.GetPathToNote(noteID), content, 0644)
+		return writeFileAtomic(state.GetPathToNote(noteID), content, 0644)
 	}
 	return nil
 }
 
+// Return true only if the state of the system before the note was applied is saved, i.e. the note has been applied.
+func (state *State) IsSaved(noteID string) bool {
+	_, err := os.Stat(state.GetPathToNote(noteID))
+	return err == nil
+}
+
 // List all stored note states. Return note numbers.
 func (state *State) List() (ret []string, err error) {
 	if err = os.MkdirAll(path.Join(state.StateDirPrefix, SaptuneStateDir), 0755); err != nil {
@@ -49,28 +153,236 @@ func (state *State) List() (ret []string, err error) {
 	}
 	ret = make([]string, 0, len(dirContent))
 	for _, info := range dirContent {
-		ret = append(ret, info.Name())
+		if !isStateHelperFile(info.Name()) {
+			ret = append(ret, info.Name())
+		}
 	}
 	return
 }
 
-// Deserialise an SAP note into the destination pointer. The destination must be a pointer.
+/*
+Deserialise an SAP note into the destination pointer. The destination must be a pointer. A corrupt state file is
+recovered from its backup.
+*/
 func (state *State) Retrieve(noteID string, dest interface{}) error {
-	content, err := ioutil.ReadFile(state.GetPathToNote(noteID))
+	return readStateFile(state.GetPathToNote(noteID), dest)
+}
+
+// Remove a serialised state file.
+func (state *State) Remove(noteID string) error {
+	return removeStateFile(state.GetPathToNote(noteID))
+}
+
+// Record the current time as the time the note was last applied.
+func (state *State) StoreApplyTime(noteID string) error {
+	if err := os.MkdirAll(path.Join(state.StateDirPrefix, SaptuneAppliedDir), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path.Join(state.StateDirPrefix, SaptuneAppliedDir, noteID), []byte(time.Now().Format(time.RFC3339)), 0644)
+}
+
+// Return the time the note was last applied. The error satisfies os.IsNotExist if no time was recorded.
+func (state *State) GetApplyTime(noteID string) (time.Time, error) {
+	filePath := path.Join(state.StateDirPrefix, SaptuneAppliedDir, noteID)
+	content, err := ioutil.ReadFile(filePath)
 	if err != nil {
+		return time.Time{}, err
+	}
+	appliedTime, err := time.Parse(time.RFC3339, strings.TrimSpace(string(content)))
+	if err != nil {
+		// Recover a corrupt file from its backup
+		if backup, backupErr := ioutil.ReadFile(filePath + StateBackupSuffix); backupErr == nil {
+			if backupTime, backupErr := time.Parse(time.RFC3339, strings.TrimSpace(string(backup))); backupErr == nil {
+				log.Printf("State file %s is corrupt (%v), its previous content is used instead", filePath, err)
+				return backupTime, nil
+			}
+		}
+	}
+	return appliedTime, err
+}
+
+// Remove the recorded apply time of the note.
+func (state *State) RemoveApplyTime(noteID string) error {
+	return removeStateFile(path.Join(state.StateDirPrefix, SaptuneAppliedDir, noteID))
+}
+
+/*
+Record the parameters of the note that the system did not accept when the note was last applied, comparison name VS
+comparison. Nothing is recorded if all parameters were accepted.
+*/
+func (state *State) StoreRejected(noteID string, rejected map[string]note.NoteFieldComparison) error {
+	if len(rejected) == 0 {
+		return state.RemoveRejected(noteID)
+	}
+	content, err := json.Marshal(rejected)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Join(state.StateDirPrefix, SaptuneRejectedDir), 0755); err != nil {
 		return err
 	}
-	return json.Unmarshal(content, dest)
+	return writeFileAtomic(path.Join(state.StateDirPrefix, SaptuneRejectedDir, noteID), content, 0644)
 }
 
-// Remove a serialised state file.
-func (state *State) Remove(noteID string) error {
-	_, err := os.Stat(state.GetPathToNote(noteID))
+/*
+Return the parameters of the note that the system did not accept when the note was last applied. Only the stable
+identifiers, labels, and JS values of the comparisons are recorded. The map is empty if nothing was recorded.
+*/
+func (state *State) GetRejected(noteID string) (rejected map[string]note.NoteFieldComparison, err error) {
+	rejected = make(map[string]note.NoteFieldComparison)
+	if err = readStateFile(path.Join(state.StateDirPrefix, SaptuneRejectedDir, noteID), &rejected); os.IsNotExist(err) {
+		return rejected, nil
+	}
+	return
+}
+
+// Remove the recorded rejected parameters of the note.
+func (state *State) RemoveRejected(noteID string) error {
+	return removeStateFile(path.Join(state.StateDirPrefix, SaptuneRejectedDir, noteID))
+}
+
+/*
+Record the values the parameters of the note had on the system right after the note was last applied, comparison
+name VS value in JSON.
+*/
+func (state *State) StoreAppliedValues(noteID string, values map[string]string) error {
+	content, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Join(state.StateDirPrefix, SaptuneAppliedValuesDir), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path.Join(state.StateDirPrefix, SaptuneAppliedValuesDir, noteID), content, 0644)
+}
+
+/*
+Return the values the parameters of the note had on the system right after the note was last applied, comparison
+name VS value in JSON. The error satisfies os.IsNotExist if none are recorded, e.g. the note was applied by an older
+saptune.
+*/
+func (state *State) GetAppliedValues(noteID string) (values map[string]string, err error) {
+	values = make(map[string]string)
+	err = readStateFile(path.Join(state.StateDirPrefix, SaptuneAppliedValuesDir, noteID), &values)
+	return
+}
+
+// Remove the recorded applied values of the note.
+func (state *State) RemoveAppliedValues(noteID string) error {
+	return removeStateFile(path.Join(state.StateDirPrefix, SaptuneAppliedValuesDir, noteID))
+}
+
+/*
+Record the values pinned for the parameters of the note, parameter name VS value. The pins of the note are removed if
+there are none.
+*/
+func (state *State) StorePins(noteID string, pins map[string]string) error {
+	if len(pins) == 0 {
+		return removeStateFile(path.Join(state.StateDirPrefix, SaptunePinnedDir, noteID))
+	}
+	content, err := json.Marshal(pins)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Join(state.StateDirPrefix, SaptunePinnedDir), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path.Join(state.StateDirPrefix, SaptunePinnedDir, noteID), content, 0644)
+}
+
+// Return the values pinned for the parameters of the note, parameter name VS value. The map is empty if none is pinned.
+func (state *State) GetPins(noteID string) (pins map[string]string, err error) {
+	pins = make(map[string]string)
+	if err = readStateFile(path.Join(state.StateDirPrefix, SaptunePinnedDir, noteID), &pins); os.IsNotExist(err) {
+		return pins, nil
+	}
+	return
+}
+
+// Record the time the note is to be reverted automatically.
+func (state *State) StoreExpiry(noteID string, expiry time.Time) error {
+	if err := os.MkdirAll(path.Join(state.StateDirPrefix, SaptuneExpiryDir), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path.Join(state.StateDirPrefix, SaptuneExpiryDir, noteID), []byte(expiry.Format(time.RFC3339)), 0644)
+}
+
+// Return the time the note is to be reverted automatically. The error satisfies os.IsNotExist if none is recorded.
+func (state *State) GetExpiry(noteID string) (time.Time, error) {
+	content, err := ioutil.ReadFile(path.Join(state.StateDirPrefix, SaptuneExpiryDir, noteID))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(content)))
+}
+
+// Return the times all notes are to be reverted automatically, note ID VS time.
+func (state *State) GetAllExpiries() (map[string]time.Time, error) {
+	expiries := make(map[string]time.Time)
+	dirContent, err := ioutil.ReadDir(path.Join(state.StateDirPrefix, SaptuneExpiryDir))
 	if os.IsNotExist(err) {
-		return nil
-	} else if err == nil {
-		return os.Remove(state.GetPathToNote(noteID))
-	} else {
+		return expiries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, info := range dirContent {
+		if isStateHelperFile(info.Name()) {
+			continue
+		}
+		expiry, err := state.GetExpiry(info.Name())
+		if err != nil {
+			return nil, err
+		}
+		expiries[info.Name()] = expiry
+	}
+	return expiries, nil
+}
+
+// Remove the recorded time the note is to be reverted automatically.
+func (state *State) RemoveExpiry(noteID string) error {
+	return removeStateFile(path.Join(state.StateDirPrefix, SaptuneExpiryDir, noteID))
+}
+
+// Record the parameter values of the note definition released into use, parameter name VS value.
+func (state *State) StoreRelease(noteID string, values map[string]string) error {
+	content, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Join(state.StateDirPrefix, SaptuneReleasedDir), 0755); err != nil {
 		return err
 	}
+	return writeFileAtomic(path.Join(state.StateDirPrefix, SaptuneReleasedDir, noteID), content, 0644)
+}
+
+/*
+Return the parameter values of the note definition released into use, parameter name VS value. The error satisfies
+os.IsNotExist if no definition of the note has been released.
+*/
+func (state *State) GetRelease(noteID string) (values map[string]string, err error) {
+	values = make(map[string]string)
+	err = readStateFile(path.Join(state.StateDirPrefix, SaptuneReleasedDir, noteID), &values)
+	return
+}
+
+// Return the IDs of the notes whose definition has been released into use, sorted.
+func (state *State) GetReleasedNotes() ([]string, error) {
+	noteIDs := make([]string, 0, 0)
+	dirContent, err := ioutil.ReadDir(path.Join(state.StateDirPrefix, SaptuneReleasedDir))
+	if os.IsNotExist(err) {
+		return noteIDs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, info := range dirContent {
+		if !isStateHelperFile(info.Name()) {
+			noteIDs = append(noteIDs, info.Name())
+		}
+	}
+	return noteIDs, nil
+}
+
+// Remove the released definition of the note.
+func (state *State) RemoveRelease(noteID string) error {
+	return removeStateFile(path.Join(state.StateDirPrefix, SaptuneReleasedDir, noteID))
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 )
 
 // sample note implementation 1
@@ -94,6 +95,9 @@ func TestState(t *testing.T) {
 	if num, err := state.List(); err != nil || len(num) != 1 || num[0] != "2" {
 		t.Fatal(num, err)
 	}
+	if state.IsSaved("1") || !state.IsSaved("2") {
+		t.Fatal("wrong saved state")
+	}
 	if err := state.Remove("2"); err != nil {
 		t.Fatal(err)
 	}
@@ -101,3 +105,64 @@ func TestState(t *testing.T) {
 		t.Fatal(num, err)
 	}
 }
+
+func TestApplyTime(t *testing.T) {
+	tmpDir := path.Join(os.TempDir(), "saptune-test-applytime")
+	defer os.RemoveAll(tmpDir)
+	state := State{StateDirPrefix: tmpDir}
+	if _, err := state.GetApplyTime("1"); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if err := state.StoreApplyTime("1"); err != nil {
+		t.Fatal(err)
+	}
+	if applyTime, err := state.GetApplyTime("1"); err != nil || time.Since(applyTime) > time.Minute {
+		t.Fatal(applyTime, err)
+	}
+	if err := state.RemoveApplyTime("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.RemoveApplyTime("1"); err != nil { // remove again should not raise error
+		t.Fatal(err)
+	}
+	if _, err := state.GetApplyTime("1"); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestStateRecovery(t *testing.T) {
+	tmpDir := path.Join(os.TempDir(), "saptune-test-recovery")
+	defer os.RemoveAll(tmpDir)
+	state := State{StateDirPrefix: tmpDir}
+	if err := state.Store("1", Note1{Str: "first"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Store("1", Note1{Str: "second"}, true); err != nil {
+		t.Fatal(err)
+	}
+	// The backup and temporary files are not mistaken for notes
+	if num, err := state.List(); err != nil || len(num) != 1 || num[0] != "1" {
+		t.Fatal(num, err)
+	}
+	readNote1 := Note1{}
+	if err := state.Retrieve("1", &readNote1); err != nil || readNote1.Str != "second" {
+		t.Fatal(err, readNote1)
+	}
+	// A truncated state file is recovered from the previous content
+	WriteFileOrPanic(state.GetPathToNote("1"), `{"Str":"sec`)
+	readNote1 = Note1{}
+	if err := state.Retrieve("1", &readNote1); err != nil || readNote1.Str != "first" {
+		t.Fatal(err, readNote1)
+	}
+	// Without a usable backup the corruption is reported
+	WriteFileOrPanic(state.GetPathToNote("1")+StateBackupSuffix, `{"Str":"fir`)
+	if err := state.Retrieve("1", &readNote1); err == nil {
+		t.Fatal("did not error")
+	}
+	if err := state.Remove("1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(state.GetPathToNote("1") + StateBackupSuffix); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"sort"
+)
+
+// An overview of the tuning of the system, see GetStatus.
+type Status struct {
+	Daemon              *DaemonStatus // nil if the daemon was not inspected
+	EnabledSolutions    []string
+	EnabledNotes        []string // enabled manually or by a solution
+	TotalParameters     int      // number of tunable parameters inspected across all enabled notes
+	DeviatingParameters int      // number of deviating parameters, apart from those pending a reboot
+	PendingReboot       int      // number of deviating parameters that only take effect after a reboot
+	FailedNotes         []string // enabled notes that failed to inspect the system
+}
+
+/*
+Verify all enabled notes and summarise the tuning of the system, the system is inspected but not changed. The daemon
+status is given by the caller, it may be nil. Parameters of notes that fail to inspect the system are not counted.
+*/
+func (app *App) GetStatus(daemon *DaemonStatus) (status Status) {
+	status = Status{Daemon: daemon, FailedNotes: make([]string, 0, 0)}
+	status.EnabledSolutions = make([]string, len(app.TuneForSolutions))
+	copy(status.EnabledSolutions, app.TuneForSolutions)
+	sort.Strings(status.EnabledSolutions)
+	status.EnabledNotes = app.GetSortedAllEnabledNotes()
+	_, comparisons, noteErrs := app.VerifyAll()
+	for noteID := range noteErrs {
+		status.FailedNotes = append(status.FailedNotes, noteID)
+	}
+	sort.Strings(status.FailedNotes)
+	for noteID, noteComparisons := range comparisons {
+		aNote, _ := app.GetNoteByID(noteID)
+		for _, comparison := range note.FilterParameters(noteComparisons) {
+			status.TotalParameters++
+			if comparison.MatchExpectation {
+				continue
+			} else if note.IsRebootRequired(aNote, comparison) {
+				status.PendingReboot++
+			} else {
+				status.DeviatingParameters++
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// A note whose parameter only takes effect after a reboot.
+type RebootNote struct {
+	SampleNote2
+}
+
+func (n RebootNote) RebootRequiredParams() []string {
+	return []string{"Param"}
+}
+
+func TestGetStatus(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	allNotes := map[string]note.Note{"1001": SampleNote1{}, "1002": SampleNote2{}, "reboot": RebootNote{}, "failing": FailingNote{}}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), allNotes, AllTestSolutions)
+	// Nothing is enabled
+	if status := tuneApp.GetStatus(nil); status.Daemon != nil || len(status.EnabledNotes) != 0 || status.TotalParameters != 0 {
+		t.Fatal(status)
+	}
+	if _, err := tuneApp.TuneSolution("sol1"); err != nil {
+		t.Fatal(err)
+	}
+	// Note 1001 conforms, the other notes deviate in the same parameter
+	tuneApp.TuneForNotes = append(tuneApp.TuneForNotes, "1002", "failing", "reboot")
+	daemon := &DaemonStatus{Running: true}
+	status := tuneApp.GetStatus(daemon)
+	if status.Daemon != daemon || !reflect.DeepEqual(status.EnabledSolutions, []string{"sol1"}) {
+		t.Fatal(status)
+	}
+	if !reflect.DeepEqual(status.EnabledNotes, []string{"1001", "1002", "failing", "reboot"}) || !reflect.DeepEqual(status.FailedNotes, []string{"failing"}) {
+		t.Fatal(status.EnabledNotes, status.FailedNotes)
+	}
+	if status.TotalParameters != 3 || status.DeviatingParameters != 1 || status.PendingReboot != 1 {
+		t.Fatal(status)
+	}
+}
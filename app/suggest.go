@@ -0,0 +1,63 @@
+package app
+
+import (
+	"sort"
+	"strings"
+)
+
+// Return the Levenshtein edit distance between two strings, case is ignored.
+func EditDistance(a, b string) int {
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if del := prev[j] + 1; del < curr[j] {
+				curr[j] = del
+			}
+			if ins := curr[j-1] + 1; ins < curr[j] {
+				curr[j] = ins
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+/*
+Return up to three candidates that are closest to the input by edit distance, closest first.
+Candidates that differ in more than a third of the input's characters (at least two) are not considered similar.
+*/
+func GetSimilar(input string, candidates []string) []string {
+	maxDistance := len(input) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	distances := make(map[string]int)
+	similar := make([]string, 0, 0)
+	for _, candidate := range candidates {
+		if distance := EditDistance(input, candidate); distance <= maxDistance {
+			distances[candidate] = distance
+			similar = append(similar, candidate)
+		}
+	}
+	sort.SliceStable(similar, func(i, j int) bool {
+		if distances[similar[i]] != distances[similar[j]] {
+			return distances[similar[i]] < distances[similar[j]]
+		}
+		return similar[i] < similar[j]
+	})
+	if len(similar) > 3 {
+		similar = similar[:3]
+	}
+	return similar
+}
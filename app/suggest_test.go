@@ -0,0 +1,37 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"1275776", "1275776", 0},
+		{"1275767", "1275776", 2},
+		{"hana", "HANA", 0},
+		{"NETWEAVR", "NETWEAVER", 1},
+	} {
+		if d := EditDistance(c.a, c.b); d != c.distance {
+			t.Fatal(c, d)
+		}
+	}
+}
+
+func TestGetSimilar(t *testing.T) {
+	candidates := []string{"1275776", "1984787", "2205917", "SUSE-GUIDE-01", "SUSE-GUIDE-02"}
+	if similar := GetSimilar("1275766", candidates); !reflect.DeepEqual(similar, []string{"1275776"}) {
+		t.Fatal(similar)
+	}
+	if similar := GetSimilar("SUSE-GUIDE-03", candidates); !reflect.DeepEqual(similar, []string{"SUSE-GUIDE-01", "SUSE-GUIDE-02"}) {
+		t.Fatal(similar)
+	}
+	if similar := GetSimilar("abcdefg", candidates); len(similar) != 0 {
+		t.Fatal(similar)
+	}
+}
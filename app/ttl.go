@@ -0,0 +1,114 @@
+package app
+
+import (
+	"fmt"
+	"github.com/HouzuoGuo/saptune/system"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// TTLUnitPrefix is followed by the note ID in the names of the transient systemd units that revert notes automatically.
+const TTLUnitPrefix = "saptune-ttl-"
+
+// Schedule and cancel the automatic revert of a note, they are variables so that tests may replace them.
+var (
+	scheduleRevert = func(noteID string, delay time.Duration) error {
+		executable, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		// The TTL promises the revert, hence it is not held up until the next maintenance window
+		return system.ScheduleCommand(TTLUnitPrefix+noteID, delay, executable, "note", "revert", "--force", noteID)
+	}
+	cancelRevert = func(noteID string) error {
+		return system.CancelScheduledCommand(TTLUnitPrefix + noteID)
+	}
+)
+
+/*
+Apply the note like TuneNote, and revert it permanently once the TTL has elapsed by running `saptune note revert` in a
+transient systemd timer. The revert is scheduled beforehand, so that the note is never applied for good by mistake:
+if it cannot be scheduled, e.g. without systemd, nothing is applied. A revert that is already pending for the note is
+replaced. Return the time of the revert.
+*/
+func (app *App) TuneNoteWithTTL(noteID string, ttl time.Duration) (expiry time.Time, err error) {
+	if _, err = app.GetNoteByID(noteID); err != nil {
+		return
+	} else if ttl < time.Second {
+		return expiry, fmt.Errorf("The TTL of note %s must be at least one second.", noteID)
+	}
+	app.cancelPendingRevert(noteID)
+	expiry = time.Now().Add(ttl)
+	if err = scheduleRevert(noteID, ttl); err != nil {
+		return expiry, fmt.Errorf("Refusing to apply note %s, its automatic revert cannot be scheduled - %v", noteID, err)
+	}
+	if err = app.State.StoreExpiry(noteID, expiry); err != nil {
+		app.cancelPendingRevert(noteID)
+		return expiry, newError(ErrStateFailed, err, "%v", err)
+	}
+	if err = app.TuneNote(noteID); err != nil {
+		app.cancelPendingRevert(noteID)
+		app.State.RemoveExpiry(noteID)
+	}
+	return
+}
+
+// Cancel the automatic revert of the note and forget its expiry, if a revert is pending.
+func (app *App) cancelPendingRevert(noteID string) {
+	if _, err := app.State.GetExpiry(noteID); os.IsNotExist(err) {
+		return
+	}
+	// The revert may have already run, or the system may have rebooted since
+	if err := cancelRevert(noteID); err != nil {
+		log.Printf("cancelPendingRevert: %v", err)
+	}
+	if err := app.State.RemoveExpiry(noteID); err != nil {
+		log.Printf("cancelPendingRevert: %v", err)
+	}
+}
+
+// Return the notes that will be reverted automatically, note ID VS time of the revert.
+func (app *App) GetPendingReverts() (map[string]time.Time, error) {
+	expiries, err := app.State.GetAllExpiries()
+	if err != nil {
+		return nil, newError(ErrStateFailed, err, "%v", err)
+	}
+	return expiries, nil
+}
+
+/*
+Catch up on the automatic reverts that a reboot prevented, as transient timers do not survive it: revert the notes
+whose TTL has elapsed, and schedule the revert of the others again for the remaining time. A note that fails to revert
+is logged and left for the next time. Return the reverted notes, sorted.
+*/
+func (app *App) ReconcilePendingReverts(now time.Time) (reverted []string, err error) {
+	reverted = make([]string, 0, 0)
+	expiries, err := app.GetPendingReverts()
+	if err != nil {
+		return
+	}
+	noteIDs := make([]string, 0, len(expiries))
+	for noteID := range expiries {
+		noteIDs = append(noteIDs, noteID)
+	}
+	sort.Strings(noteIDs)
+	for _, noteID := range noteIDs {
+		if remaining := expiries[noteID].Sub(now); remaining > 0 {
+			if err := cancelRevert(noteID); err != nil {
+				log.Printf("ReconcilePendingReverts: %v", err)
+			}
+			if err := scheduleRevert(noteID, remaining); err != nil {
+				log.Printf("ReconcilePendingReverts: failed to schedule the revert of note %s again - %v", noteID, err)
+			}
+			continue
+		}
+		if err := app.RevertNote(noteID, true); err != nil {
+			log.Printf("ReconcilePendingReverts: failed to revert note %s - %v", noteID, err)
+			continue
+		}
+		reverted = append(reverted, noteID)
+	}
+	return
+}
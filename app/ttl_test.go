@@ -0,0 +1,111 @@
+package app
+
+import (
+	"errors"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTuneNoteWithTTL(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	oldSchedule, oldCancel := scheduleRevert, cancelRevert
+	defer func() {
+		scheduleRevert, cancelRevert = oldSchedule, oldCancel
+	}()
+	scheduled := make(map[string]time.Duration)
+	scheduleRevert = func(noteID string, delay time.Duration) error {
+		scheduled[noteID] = delay
+		return nil
+	}
+	cancelRevert = func(noteID string) error {
+		delete(scheduled, noteID)
+		return nil
+	}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	expiry, err := tuneApp.TuneNoteWithTTL("1001", 2*time.Hour)
+	if err != nil || scheduled["1001"] != 2*time.Hour {
+		t.Fatal(scheduled, err)
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+	if pending, err := tuneApp.GetPendingReverts(); err != nil || !pending["1001"].Equal(expiry.Truncate(time.Second)) {
+		t.Fatal(pending, expiry, err)
+	}
+	// Nothing is applied if the revert cannot be scheduled
+	scheduleRevert = func(noteID string, delay time.Duration) error {
+		return errors.New("no systemd")
+	}
+	if _, err := tuneApp.TuneNoteWithTTL("1002", time.Hour); err == nil {
+		t.Fatal("did not error")
+	}
+	VerifyFileContent(t, SampleParamFile, "optimised1")
+	if pending, _ := tuneApp.GetPendingReverts(); len(pending) != 1 {
+		t.Fatal(pending)
+	}
+	// Reverting the note by hand cancels the automatic revert
+	if err := tuneApp.RevertNote("1001", true); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := tuneApp.GetPendingReverts(); len(pending) != 0 || len(scheduled) != 0 {
+		t.Fatal(pending, scheduled)
+	}
+	// After a reboot, elapsed notes are reverted and the others are scheduled again
+	scheduleRevert = func(noteID string, delay time.Duration) error {
+		scheduled[noteID] = delay
+		return nil
+	}
+	if _, err := tuneApp.TuneNoteWithTTL("1001", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tuneApp.TuneNoteWithTTL("1002", 3*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	scheduled = make(map[string]time.Duration)
+	reverted, err := tuneApp.ReconcilePendingReverts(time.Now().Add(2 * time.Hour))
+	if err != nil || !reflect.DeepEqual(reverted, []string{"1001"}) {
+		t.Fatal(reverted, err)
+	}
+	if delay := scheduled["1002"]; len(scheduled) != 1 || delay > time.Hour || delay < 59*time.Minute {
+		t.Fatal(scheduled)
+	}
+	if !reflect.DeepEqual(tuneApp.TuneForNotes, []string{"1002"}) {
+		t.Fatal(tuneApp.TuneForNotes)
+	}
+	if _, err := tuneApp.TuneNoteWithTTL("1002", time.Millisecond); err == nil {
+		t.Fatal("did not error")
+	}
+}
+
+func TestPruneCancelsPendingRevert(t *testing.T) {
+	os.RemoveAll(SampleNoteDataDir)
+	defer os.RemoveAll(SampleNoteDataDir)
+	oldSchedule, oldCancel := scheduleRevert, cancelRevert
+	defer func() {
+		scheduleRevert, cancelRevert = oldSchedule, oldCancel
+	}()
+	scheduled := make(map[string]time.Duration)
+	scheduleRevert = func(noteID string, delay time.Duration) error {
+		scheduled[noteID] = delay
+		return nil
+	}
+	cancelRevert = func(noteID string) error {
+		delete(scheduled, noteID)
+		return nil
+	}
+	tuneApp := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), AllTestNotes, AllTestSolutions)
+	if _, err := tuneApp.TuneNoteWithTTL("1002", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// The definition of note 1002 disappears, its revert could never succeed
+	reloaded := InitialiseApp(path.Join(SampleNoteDataDir, "conf"), path.Join(SampleNoteDataDir, "data"), map[string]note.Note{"1001": SampleNote1{}}, AllTestSolutions)
+	if pruned, err := reloaded.PruneDanglingNotes(); err != nil || !reflect.DeepEqual(pruned, []string{"1002"}) {
+		t.Fatal(pruned, err)
+	}
+	if pending, _ := reloaded.GetPendingReverts(); len(pending) != 0 || len(scheduled) != 0 {
+		t.Fatal(pending, scheduled)
+	}
+}
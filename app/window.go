@@ -0,0 +1,148 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+MaintenanceWindowsKey configures the time ranges in which saptune may tune the system, separated by spaces. Each range
+is written as DAYS@HH:MM-HH:MM, e.g. "Sat,Sun@00:00-24:00 Mon-Fri@22:00-05:00", where DAYS are days of the week or "*"
+for every day. A range that ends before it starts continues past midnight into the following day. If the key is empty,
+tuning is allowed at any time.
+*/
+const MaintenanceWindowsKey = "MAINTENANCE_WINDOWS"
+
+var weekdayAbbreviations = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// A recurring time range in which saptune may tune the system.
+type MaintenanceWindow struct {
+	Days       [7]bool       // the days on which the window opens, indexed by time.Weekday
+	Start, End time.Duration // time of day at which the window opens and closes
+}
+
+// Return the index of the abbreviated weekday name (e.g. "Mon"), or an error if it is not a weekday.
+func parseWeekday(name string) (int, error) {
+	for i, abbreviation := range weekdayAbbreviations {
+		if strings.ToLower(name) == abbreviation {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("\"%s\" is not a day of the week such as Mon", name)
+}
+
+// Parse time of day written as HH:MM, 24:00 is the end of the day.
+func parseTimeOfDay(value string) (time.Duration, error) {
+	var hour, minute int
+	if n, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil || n != 2 || hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("\"%s\" is not a time of day such as 22:00", value)
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// Parse the maintenance windows written as described by MaintenanceWindowsKey.
+func ParseMaintenanceWindows(spec string) (windows []MaintenanceWindow, err error) {
+	windows = make([]MaintenanceWindow, 0, 0)
+	for _, field := range strings.Fields(spec) {
+		daysTimes := strings.SplitN(field, "@", 2)
+		if len(daysTimes) != 2 {
+			return nil, fmt.Errorf("maintenance window \"%s\" must be written as DAYS@HH:MM-HH:MM", field)
+		}
+		var window MaintenanceWindow
+		for _, days := range strings.Split(daysTimes[0], ",") {
+			if days == "*" {
+				window.Days = [7]bool{true, true, true, true, true, true, true}
+				continue
+			}
+			fromTo := strings.SplitN(days, "-", 2)
+			from, err := parseWeekday(fromTo[0])
+			if err != nil {
+				return nil, fmt.Errorf("maintenance window \"%s\": %v", field, err)
+			}
+			to := from
+			if len(fromTo) == 2 {
+				if to, err = parseWeekday(fromTo[1]); err != nil {
+					return nil, fmt.Errorf("maintenance window \"%s\": %v", field, err)
+				}
+			}
+			// A range of days may wrap around the end of the week, e.g. Sat-Mon
+			for day := from; ; day = (day + 1) % 7 {
+				window.Days[day] = true
+				if day == to {
+					break
+				}
+			}
+		}
+		startEnd := strings.SplitN(daysTimes[1], "-", 2)
+		if len(startEnd) != 2 {
+			return nil, fmt.Errorf("maintenance window \"%s\" must be written as DAYS@HH:MM-HH:MM", field)
+		}
+		if window.Start, err = parseTimeOfDay(startEnd[0]); err != nil {
+			return nil, fmt.Errorf("maintenance window \"%s\": %v", field, err)
+		}
+		if window.End, err = parseTimeOfDay(startEnd[1]); err != nil {
+			return nil, fmt.Errorf("maintenance window \"%s\": %v", field, err)
+		}
+		windows = append(windows, window)
+	}
+	return
+}
+
+// Return the opening and closing time of the window if it opens on the same day as the time t.
+func (window MaintenanceWindow) onDayOf(t time.Time, dayOffset int) (opens, closes time.Time, ok bool) {
+	midnight := time.Date(t.Year(), t.Month(), t.Day()+dayOffset, 0, 0, 0, 0, t.Location())
+	if !window.Days[midnight.Weekday()] {
+		return
+	}
+	opens = midnight.Add(window.Start)
+	closes = midnight.Add(window.End)
+	if window.End <= window.Start {
+		closes = closes.Add(24 * time.Hour)
+	}
+	return opens, closes, true
+}
+
+// Return true only if the time t lies within any of the windows.
+func InMaintenanceWindow(windows []MaintenanceWindow, t time.Time) bool {
+	for _, window := range windows {
+		// The window that opened yesterday may still be open
+		for _, dayOffset := range []int{-1, 0} {
+			if opens, closes, ok := window.onDayOf(t, dayOffset); ok && !t.Before(opens) && t.Before(closes) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Return the time at which the next of the windows opens after the time t, or false if none of them ever opens.
+func NextMaintenanceWindow(windows []MaintenanceWindow, t time.Time) (next time.Time, found bool) {
+	for _, window := range windows {
+		for dayOffset := 0; dayOffset <= 7; dayOffset++ {
+			if opens, _, ok := window.onDayOf(t, dayOffset); ok && opens.After(t) && (!found || opens.Before(next)) {
+				next, found = opens, true
+			}
+		}
+	}
+	return
+}
+
+/*
+Return an error of kind ErrOutsideMaintenanceWindow if maintenance windows are configured and the time t lies outside
+all of them. The error names the time at which the next window opens.
+*/
+func (app *App) CheckMaintenanceWindow(t time.Time) error {
+	windows, err := ParseMaintenanceWindows(app.MaintenanceWindows)
+	if err != nil {
+		return fmt.Errorf("invalid %s in %s: %v", MaintenanceWindowsKey, SysconfigSaptuneDir, err)
+	}
+	if len(windows) == 0 || InMaintenanceWindow(windows, t) {
+		return nil
+	}
+	if next, found := NextMaintenanceWindow(windows, t); found {
+		return newError(ErrOutsideMaintenanceWindow, nil, "Tuning is only allowed within the maintenance windows \"%s\", the next one opens at %s.",
+			app.MaintenanceWindows, next.Format("Mon 2006-01-02 15:04"))
+	}
+	return newError(ErrOutsideMaintenanceWindow, nil, "Tuning is only allowed within the maintenance windows \"%s\", none of them ever opens.", app.MaintenanceWindows)
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	windows, err := ParseMaintenanceWindows("Sat,Sun@00:00-24:00 Mon-Fri@22:00-05:00")
+	if err != nil || len(windows) != 2 || !windows[0].Days[time.Sunday] || windows[0].Days[time.Monday] || !windows[1].Days[time.Friday] || windows[1].Days[time.Saturday] {
+		t.Fatal(windows, err)
+	}
+	for _, spec := range []string{"Sat", "Sat@22:00", "Xyz@00:00-01:00", "Mon@25:00-26:00", "Mon@22:00-05:60"} {
+		if _, err := ParseMaintenanceWindows(spec); err == nil {
+			t.Fatal(spec)
+		}
+	}
+	// 2024-01-03 is a Wednesday
+	for _, c := range []struct {
+		at     string
+		inside bool
+	}{
+		{"2024-01-03 12:00", false},
+		{"2024-01-03 22:00", true},
+		{"2024-01-04 04:59", true}, // the window opened on the previous day
+		{"2024-01-04 05:00", false},
+		{"2024-01-06 12:00", true},
+		{"2024-01-08 03:00", false}, // Sunday's window does not wrap past midnight
+	} {
+		at, _ := time.Parse("2006-01-02 15:04", c.at)
+		if InMaintenanceWindow(windows, at) != c.inside {
+			t.Fatal(c)
+		}
+	}
+	at, _ := time.Parse("2006-01-02 15:04", "2024-01-03 12:00")
+	if next, found := NextMaintenanceWindow(windows, at); !found || next.Format("2006-01-02 15:04") != "2024-01-03 22:00" {
+		t.Fatal(next, found)
+	}
+	// The app refuses outside of the windows and names the next one
+	tuneApp := &App{MaintenanceWindows: "Sat@01:00-02:00"}
+	if err := tuneApp.CheckMaintenanceWindow(at); !errors.Is(err, ErrOutsideMaintenanceWindow) || !strings.Contains(err.Error(), "Sat 2024-01-06 01:00") {
+		t.Fatal(err)
+	}
+	tuneApp.MaintenanceWindows = ""
+	if err := tuneApp.CheckMaintenanceWindow(at); err != nil {
+		t.Fatal(err)
+	}
+	tuneApp.MaintenanceWindows = "invalid"
+	if err := tuneApp.CheckMaintenanceWindow(at); err == nil {
+		t.Fatal("did not error")
+	}
+}
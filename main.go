@@ -1,78 +1,369 @@
 package main
 
 import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/HouzuoGuo/saptune/app"
 	"github.com/HouzuoGuo/saptune/sap/note"
 	"github.com/HouzuoGuo/saptune/sap/solution"
 	"github.com/HouzuoGuo/saptune/system"
+	"github.com/HouzuoGuo/saptune/txtparser"
 	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"path"
+	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 )
 
 const (
-	SapconfService        = "sapconf.service"
-	TunedService          = "tuned.service"
-	TunedProfileName      = "saptune"
-	ExitTunedStopped      = 1
-	ExitTunedWrongProfile = 2
-	ExitNotTuned          = 3
+	SapconfService    = "sapconf.service"
+	SaptuneService    = "saptune.service" // applies the enabled notes at boot and reverts them on stop
+	TunedService      = "tuned.service"
+	ExitSuccess       = 0
+	ExitFailure       = 1 // the action failed, or the system deviates from the notes
+	ExitDaemonStopped = ExitFailure // kept equal to ExitFailure for compatibility, hence listed with it by `exit-codes`
+	ExitNotTuned      = 3
+	ExitRebootPending = 4 // all deviating parameters of enabled notes will conform after a reboot
+	ExitVerifyFailed  = 5 // some enabled notes failed to inspect the system, hence their conformance is unknown
+	ExitDrifted       = 6 // the daemon is healthy, but the system has drifted from the enabled notes
+	// LegacyTunedProfileName is the tuned profile that ran saptune before saptune.service, `daemon start` retires it.
+	LegacyTunedProfileName = "saptune"
 	// ExtraTuningSheets is a directory located on file system for external parties to place their tuning option files.
 	ExtraTuningSheets = "/etc/saptune/extra/"
+	// CompositeSolutionsFile defines composite solutions that are made of several solutions.
+	CompositeSolutionsFile = "/etc/saptune/composite_solutions"
+	// SaptuneServiceUnit is the systemd unit file of saptune.service installed by the package.
+	SaptuneServiceUnit = "/usr/lib/systemd/system/saptune.service"
+	// SaptuneLogFile is the log file of saptune, including the output of saptune.service.
+	SaptuneLogFile = "/var/log/saptune/saptune.log"
+	// DefaultExporterAddress is the address `saptune exporter` listens on unless --listen is given, only local by default.
+	DefaultExporterAddress = "127.0.0.1:9758"
+	// DefaultStatusWaitSec is the number of seconds `daemon status --wait` waits for saptune.service to become active.
+	DefaultStatusWaitSec = 30
 )
 
+// An exit status of saptune and its meaning.
+type ExitCode struct {
+	Code        int    `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ExitCodes describes all exit statuses of saptune, `saptune exit-codes` prints it for external tooling.
+var ExitCodes = []ExitCode{
+	{ExitSuccess, "success", "the action succeeded, the verified system conforms"},
+	{ExitFailure, "failure", "the action failed, or the verified system deviates from the notes, or daemon status: saptune.service is stopped"},
+	{ExitNotTuned, "not_tuned", "daemon status: no solution or note is enabled"},
+	{ExitRebootPending, "reboot_pending", "note verify --pending-reboot: all deviating parameters will conform after a reboot"},
+	{ExitVerifyFailed, "verify_failed", "verify: some notes failed to inspect the system, hence their conformance is unknown"},
+	{ExitDrifted, "drifted", "daemon status --check-drift: the daemon is healthy, but the system has drifted from the enabled notes"},
+}
+
+// Print the exit statuses of saptune and their meanings, in JSON with --format=json.
+func PrintExitCodes() {
+	switch format := cliFlagValue("format"); format {
+	case "json":
+		content, err := json.MarshalIndent(ExitCodes, "", "  ")
+		if err != nil {
+			errorExit("Failed to serialise the exit codes: %v", err)
+		}
+		fmt.Println(string(content))
+	case "", "text":
+		for _, exitCode := range ExitCodes {
+			fmt.Printf("%d\t%s\t%s\n", exitCode.Code, exitCode.Name, exitCode.Description)
+		}
+	default:
+		errorExit("The value of --format must be \"text\" or \"json\" for the exit codes, \"%s\" is not supported.", format)
+	}
+}
+
 func PrintHelpAndExit(exitStatus int) {
 	fmt.Println(`saptune: Comprehensive system optimisation management for SAP solutions.
+Global options:
+  --root=PATH           locate saptune configuration, state, and log files relative to PATH instead of /,
+                        actions that tune the system are refused
+  --quiet               do not report the progress of long-running operations
+  --force               tune the system even outside of the configured maintenance windows,
+                        and apply notes even without the software packages they require
+  --ignore-packages     apply and verify notes even without the software packages they require
+  --parallel=N          inspect up to N notes at a time when verifying several notes (default: 4)
+  --trace-loading       trace how the tuning sheets are discovered, parsed, and resolved on stderr
+  --format=FORMAT       print the results of verify and simulate as "text" (default), "csv", or "json",
+                        and note list as "text" or "json"
+  --param-filter=REGEX  print only the parameters of verify and simulate whose name matches REGEX
+  --filter-affects-exit together with --param-filter, only the printed parameters decide the exit status
 Daemon control:
   saptune daemon [ start | status | stop ]
+  saptune daemon start --apply-now
+  saptune daemon start --dry-run [--apply-now]
+  saptune daemon status --wait[=SECONDS]
+  saptune daemon status --check-drift
 Tune system according to SAP and SUSE notes:
   saptune note [ list | verify ]
+  saptune note list [ --enabled-only | --disabled-only | --diff-from-defaults ] [--format=json]
   saptune note [ apply | simulate | verify | customise | revert ] NoteID
+  saptune note apply --from-file=PATH
+  saptune note apply --no-save [ NoteID | --from-file=PATH ]
+  saptune note apply --persist=sysctl [ NoteID | --from-file=PATH ]
+  saptune note apply --reverse-on-verify-fail NoteID
+  saptune note apply --if-changed NoteID
+  saptune note apply --matching-version [ NoteID | --from-file=PATH ]
+  saptune note apply --then-start-daemon [ NoteID | --from-file=PATH ]
+  saptune note apply --backup-dir=DIR [ NoteID | --from-file=PATH ]
+  saptune note apply --yes [ NoteID | --from-file=PATH ]
+  saptune note apply --ttl=DURATION NoteID
+  saptune note simulate --all [--diff-only]
+  saptune note refresh [ NoteID | all ]
+  saptune note revert --all-manual
+  saptune note revert --from-backup=FILE NoteID
+  saptune note revert --verify NoteID
+  saptune note prune
+  saptune note fetch
+  saptune note verify --pending-reboot [NoteID]
+  saptune note verify --since=DURATION
+  saptune note verify --tolerate-recommended [NoteID]
+  saptune note verify --exclude-note=NoteID ...
+  saptune note verify --group-by=[ note | category ]
+  saptune note verify --list-file=PATH
+  saptune note verify --snapshot=FILE [NoteID]
+  saptune note verify [ --baseline-save=FILE | --baseline-compare=FILE ]
+  saptune note verify --fix [--yes]
+  saptune note [ enable | disable ] NoteID
+  saptune note customise [ --set[=]KEY=VALUE ... | --from-json=PATH ] NoteID
+  saptune note customise --reset [--yes] NoteID
+  saptune note owner NoteID
+  saptune note validate [NoteID]
+  saptune note pin --param=NAME --value=VALUE NoteID
+  saptune note unpin --param=NAME NoteID
 Tune system for all notes applicable to your SAP solution:
   saptune solution [ list | verify ]
   saptune solution [ apply | simulate | verify | revert ] SolutionName
-`)
+  saptune solution verify --explain SolutionName
+  saptune solution verify --fail-fast SolutionName
+  saptune solution apply --exclude=NoteID[,NoteID...] SolutionName
+  saptune solution apply --matching-version SolutionName
+  saptune solution apply --then-start-daemon SolutionName
+  saptune solution apply --yes SolutionName
+  saptune solution simulate --diff-only SolutionName
+  saptune solution params SolutionName
+Apply the notes staged by note enable/disable:
+  saptune apply staged
+Apply all enabled solutions and notes again:
+  saptune apply all
+Compare the system against the parameter values exported from a reference host:
+  saptune verify --export=FILE
+  saptune verify --against=FILE
+Verify a single parameter against the value enforced by the enabled notes:
+  saptune verify --param=NAME
+Compare the system against a file of expected parameter values maintained outside of saptune:
+  saptune verify --expected=FILE
+List all parameters managed by the enabled notes and solutions:
+  saptune managed
+Cross-check the records of saptune against the system:
+  saptune doctor
+Print the compliance of the system as metrics for the textfile collector of node_exporter:
+  saptune metrics
+Serve the compliance metrics over HTTP for Prometheus to scrape, on 127.0.0.1:9758 unless --listen is given:
+  saptune exporter [--listen=[HOST]:PORT]
+Print the definitions of all notes and solutions for external tooling:
+  saptune catalog [--format=json] [--exclude-note=NoteID ...]
+Show an overview of the daemon, the enabled solutions and notes, the deviations, and pending reboots:
+  saptune status
+Assemble the daemon status, the verification, and the managed parameters into one compliance report:
+  saptune report [--format=json] [--output-file=FILE]
+List, compare, or release the note definitions held back by NOTE_STAGING since an update:
+  saptune staging list
+  saptune staging diff [NoteID]
+  saptune staging release NoteID|all
+Select solutions and notes on an interactive menu:
+  saptune interactive
+Show which notes define a parameter and the values they recommend:
+  saptune inspect PARAM
+Check the installation and environment of saptune:
+  saptune self-check
+Print the exit codes of saptune and their meanings:
+  saptune exit-codes [--format=json]`)
 	os.Exit(exitStatus)
 }
 
 // Print the message to stderr and exit 1.
 func errorExit(template string, stuff ...interface{}) {
 	fmt.Fprintf(os.Stderr, template+"\n", stuff...)
-	os.Exit(1)
+	os.Exit(ExitFailure)
 }
 
-// Return the i-th command line parameter, or empty string if it is not specified.
+// Return the i-th command line parameter, or empty string if it is not specified. Flags (--name) are not counted.
 func cliArg(i int) string {
-	if len(os.Args) >= i+1 {
-		return os.Args[i]
+	args := make([]string, 0, len(os.Args))
+	for j, arg := range os.Args {
+		if j > 0 && isSeparateValueFlag(os.Args[j-1]) {
+			// The value of a flag given as --name VALUE
+			continue
+		}
+		if !strings.HasPrefix(arg, "--") {
+			args = append(args, arg)
+		}
+	}
+	if len(args) >= i+1 {
+		return args[i]
+	}
+	return ""
+}
+
+// Return true only if the command line carries the flag, e.g. cliFlag("apply-now") looks for "--apply-now".
+func cliFlag(name string) bool {
+	for _, arg := range os.Args[1:] {
+		if arg == "--"+name || strings.HasPrefix(arg, "--"+name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the value of a flag given as --name=VALUE, or empty string if it is not specified.
+func cliFlagValue(name string) string {
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "--"+name+"=") {
+			return strings.TrimPrefix(arg, "--"+name+"=")
+		}
 	}
 	return ""
 }
 
-var tuneApp *app.App                 // application configuration and tuning states
-var tuningOptions note.TuningOptions // Collection of tuning options from SAP notes and 3rd party vendors.
+// Flags whose value may also be given as the next argument, i.e. --name VALUE rather than --name=VALUE.
+var separateValueFlags = map[string]struct{}{"set": {}}
+
+// Return true only if the argument is a flag of separateValueFlags given without =VALUE.
+func isSeparateValueFlag(arg string) bool {
+	_, exists := separateValueFlags[strings.TrimPrefix(arg, "--")]
+	return exists && strings.HasPrefix(arg, "--")
+}
+
+/*
+Return the values of a flag that may be given multiple times as --name=VALUE, in the order they are given. A flag of
+separateValueFlags may also be given as --name VALUE.
+*/
+func cliFlagValues(name string) []string {
+	values := make([]string, 0, 0)
+	for i, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "--"+name+"=") {
+			values = append(values, strings.TrimPrefix(arg, "--"+name+"="))
+		} else if arg == "--"+name && isSeparateValueFlag(arg) && i+2 < len(os.Args) {
+			values = append(values, os.Args[i+2])
+		}
+	}
+	return values
+}
+
+var tuneApp *app.App                                 // application configuration and tuning states
+var tuningOptions note.TuningOptions                 // Collection of tuning options from SAP notes and 3rd party vendors.
+var compositeSolutions map[string]solution.Composite // Composite solution name VS member solution names
 var solutionSelector = runtime.GOARCH
+var rootPrefix = ""            // alternative root directory given by --root, saptune files are located relative to it.
+var paramFilter *regexp.Regexp // parameters to print, given by --param-filter, nil to print all of them.
+
+// Return true only if saptune operates on the live system rather than an alternative root directory.
+func isLiveRoot() bool {
+	return rootPrefix == "" || path.Clean(rootPrefix) == "/"
+}
+
+/*
+Load the tuning sheets, the configuration and states of saptune, and the notes applied from files. The exporter loads
+them again upon each scrape, so that it takes in the changes made since it started.
+*/
+func loadTuning(archSolutions map[string]solution.Solution) {
+	if source, configured := app.GetRemoteSheetSource(rootPrefix, rootPrefix); configured {
+		// The tuning sheets last fetched are used, `saptune note fetch` updates them
+		tuningOptions = note.GetTuningOptionsFrom(rootPrefix, source.CacheDir, path.Join(rootPrefix, ExtraTuningSheets))
+	} else {
+		tuningOptions = note.GetTuningOptions(rootPrefix, path.Join(rootPrefix, ExtraTuningSheets))
+	}
+	tuneApp = app.InitialiseApp(rootPrefix, rootPrefix, tuningOptions, archSolutions)
+	system.SystemctlRetries = tuneApp.SystemctlRetries
+	system.SystemctlRetryInterval = tuneApp.SystemctlRetryInterval
+	compositeSolutions = solution.GetCompositeSolutions(path.Join(rootPrefix, CompositeSolutionsFile), archSolutions)
+	// Notes applied by `note apply --from-file` remain available for verification and revert
+	for noteID, filePath := range tuneApp.AdHocNotes {
+		if _, exists := tuningOptions[noteID]; exists {
+			continue
+		}
+		if adHocNote, err := note.LoadINISettingsFile(filePath, tuningOptions); err == nil {
+			tuningOptions[noteID] = adHocNote
+		} else {
+			log.Printf("Failed to load note %s applied from file %s - %v", noteID, filePath, err)
+		}
+	}
+}
+
+// Set up the application loaded by loadTuning according to the command line flags.
+func configureTuning() {
+	if value := cliFlagValue("parallel"); value != "" {
+		parallel, err := strconv.Atoi(value)
+		if err != nil || parallel < 1 {
+			errorExit("The value of --parallel must be a positive number, \"%s\" is not supported.", value)
+		}
+		tuneApp.Parallel = parallel
+	}
+	// Notes are applied and verified even without the software packages they require, --force overrides the skip too
+	tuneApp.IgnorePackages = cliFlag("ignore-packages") || cliFlag("force")
+	// Note and solution apply ask before changing high-risk parameters, unlike the daemon
+	if (cliArg(1) == "note" || cliArg(1) == "solution") && cliArg(2) == "apply" {
+		tuneApp.ConfirmHighRisk = confirmHighRisk
+	}
+	if !cliFlag("quiet") {
+		// Progress goes to stderr, so that it does not mix with the output of saptune
+		tuneApp.Progress = os.Stderr
+	}
+}
 
 func main() {
 	if arg1 := cliArg(1); arg1 == "" || arg1 == "help" || arg1 == "--help" {
-		PrintHelpAndExit(0)
+		PrintHelpAndExit(ExitSuccess)
+	}
+	if cliArg(1) == "exit-codes" {
+		// Neither the system nor the configuration is needed
+		PrintExitCodes()
+		return
 	}
-	// All other actions require super user privilege
-	if os.Geteuid() != 0 {
+	// Read-only actions may run as ordinary user, all other actions require super user privilege
+	readOnly := isReadOnlyAction(cliArg(1), cliArg(2))
+	if !readOnly && os.Geteuid() != 0 {
 		errorExit("Please run saptune with root privilege.")
 		return
 	}
-	var saptune_log io.Writer
-	saptune_log, err := os.OpenFile("/var/log/tuned/tuned.log", os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
-	if err != nil {
-		panic(err.Error())
+	rootPrefix = cliFlagValue("root")
+	if cliArg(1) == "self-check" {
+		// Diagnose the problems that would otherwise fail the other actions half-way
+		SelfCheck()
+		return
+	}
+	if readOnly && os.Geteuid() != 0 {
+		// The log file is not writable for ordinary users
+		log.SetOutput(os.Stderr)
+	} else {
+		if err := os.MkdirAll(path.Join(rootPrefix, path.Dir(SaptuneLogFile)), 0755); err != nil {
+			errorExit("Failed to prepare the log directory %s: %v", path.Join(rootPrefix, path.Dir(SaptuneLogFile)), err)
+		}
+		var saptune_log io.Writer
+		saptune_log, err := os.OpenFile(path.Join(rootPrefix, SaptuneLogFile), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+		if err != nil {
+			panic(err.Error())
+		}
+		saptune_writer := io.MultiWriter(os.Stderr, saptune_log)
+		log.SetOutput(saptune_writer)
 	}
-	saptune_writer := io.MultiWriter(os.Stderr, saptune_log)
-	log.SetOutput(saptune_writer)
 	if system.IsPagecacheAvailable() {
 		solutionSelector = solutionSelector + "_PC"
 	}
@@ -81,194 +372,2417 @@ func main() {
 		errorExit("The system architecture (%s) is not supported.", runtime.GOARCH)
 		return
 	}
-	// Initialise application configuration and tuning procedures
-	tuningOptions = note.GetTuningOptions(ExtraTuningSheets)
-	tuneApp = app.InitialiseApp("", "", tuningOptions, archSolutions)
-	switch cliArg(1) {
-	case "daemon":
-		DaemonAction(cliArg(2))
-	case "note":
-		NoteAction(cliArg(2), cliArg(3))
-	case "solution":
-		SolutionAction(cliArg(2), cliArg(3))
-	default:
-		PrintHelpAndExit(1)
+	if cliFlag("trace-loading") {
+		// Help the authors of tuning sheets to find out why a sheet does not take effect
+		note.LoadingTrace = os.Stderr
+	}
+	// Initialise application configuration and tuning procedures
+	loadTuning(archSolutions)
+	configureTuning()
+	if cliArg(1) == "catalog" || cliArg(1) == "report" {
+		if format := cliFlagValue("format"); format != "" && format != "json" {
+			errorExit("The value of --format must be \"json\" for the %s, \"%s\" is not supported.", cliArg(1), format)
+		}
+	} else if format := cliFlagValue("format"); format != "" && format != "text" && format != "csv" && format != "json" {
+		errorExit("The value of --format must be \"text\", \"csv\", or \"json\", \"%s\" is not supported.", format)
+	}
+	if groupBy := cliFlagValue("group-by"); groupBy != "" && groupBy != "note" && groupBy != "category" {
+		errorExit("The value of --group-by must be \"note\" or \"category\", \"%s\" is not supported.", groupBy)
+	}
+	if expr := cliFlagValue("param-filter"); expr != "" {
+		filter, err := regexp.Compile(expr)
+		if err != nil {
+			errorExit("The value of --param-filter is not a valid regular expression: %v", err)
+		}
+		paramFilter = filter
+	} else if cliFlag("filter-affects-exit") {
+		errorExit("--filter-affects-exit requires --param-filter.")
+	}
+	// Tuning would change the running system rather than the alternative root directory
+	if isTuningAction(cliArg(1), cliArg(2)) && !isLiveRoot() {
+		errorExit("Tuning changes the live system, it is not available together with --root.")
+	}
+	// Tuning is refused outside of the maintenance windows, verification and status are never refused
+	if isTuningAction(cliArg(1), cliArg(2)) && !cliFlag("force") && !cliFlag("dry-run") {
+		if err := tuneApp.CheckMaintenanceWindow(time.Now()); err != nil {
+			errorExit("%v\nUse --force to tune the system nevertheless.", err)
+		}
+	}
+	if dangling := tuneApp.GetDanglingNotes(); len(dangling) > 0 && !(cliArg(1) == "note" && cliArg(2) == "prune") {
+		fmt.Fprintf(os.Stderr, "Warning: the following notes are enabled or saved, but they are no longer defined: %s\n"+
+			"Run `saptune note prune` to remove them.\n", strings.Join(dangling, ", "))
+	}
+	switch cliArg(1) {
+	case "daemon":
+		DaemonAction(cliArg(2))
+	case "note":
+		NoteAction(cliArg(2), cliArg(3))
+	case "solution":
+		SolutionAction(cliArg(2), cliArg(3))
+	case "inspect":
+		InspectParameter(cliArg(2))
+	case "apply":
+		switch cliArg(2) {
+		case "staged":
+			ApplyStagedNotes()
+		case "all":
+			ApplyAllEnabled()
+		default:
+			PrintHelpAndExit(ExitFailure)
+		}
+	case "verify":
+		VerifyGoldenState()
+	case "managed":
+		PrintAllManagedParameters()
+	case "interactive":
+		InteractiveMenu()
+	case "doctor":
+		Diagnose()
+	case "metrics":
+		PrintAllMetrics()
+	case "exporter":
+		ServeMetrics(archSolutions)
+	case "catalog":
+		PrintCatalog()
+	case "report":
+		PrintReport()
+	case "status":
+		PrintStatus()
+	case "staging":
+		StagingAction(cliArg(2), cliArg(3))
+	default:
+		PrintHelpAndExit(ExitFailure)
+	}
+}
+
+/*
+Return true only if the action neither changes the system nor the configuration of saptune, and is safe to run without
+super user privilege. Actions that verify the system are not among them, as some of the live values are only readable
+by root.
+*/
+func isReadOnlyAction(category, actionName string) bool {
+	switch category {
+	case "inspect", "catalog":
+		return true
+	case "note":
+		return actionName == "list" || actionName == "owner" || actionName == "validate"
+	case "solution":
+		return actionName == "list"
+	}
+	return false
+}
+
+/*
+Return true only if the action may change the system, be it by applying or by reverting notes. It is then subject to
+the maintenance windows, and refused together with --root. The actions run by saptune.service upon boot and shutdown
+are not among them.
+*/
+func isTuningAction(category, actionName string) bool {
+	switch category {
+	case "daemon":
+		return actionName == "start" || actionName == "stop"
+	case "note":
+		switch actionName {
+		case "apply", "refresh", "revert":
+			return true
+		case "verify":
+			return cliFlag("fix")
+		case "customise":
+			// Resetting the customisation offers to re-apply the note
+			return cliFlag("reset")
+		}
+	case "solution":
+		return actionName == "apply" || actionName == "revert"
+	case "apply":
+		return actionName == "staged" || actionName == "all"
+	case "staging":
+		return actionName == "release"
+	case "interactive":
+		return true
+	}
+	return false
+}
+
+/*
+Verify a single parameter against the value enforced by the enabled notes, and print the note that provides the value.
+Exit 1 if the parameter deviates.
+*/
+func VerifySingleParameter(paramName string) {
+	result, err := tuneApp.VerifyParameter(paramName)
+	if err != nil {
+		errorExit("Failed to verify parameter %s: %v", paramName, err)
+	}
+	comparisons := map[string]note.NoteFieldComparison{result.Comparison.ParamID: result.Comparison}
+	if isStructuredOutput() {
+		PrintStructuredComparisons(map[string]map[string]note.NoteFieldComparison{result.NoteID: comparisons})
+	} else {
+		PrintNoteFields(result.NoteID, comparisons, true)
+		if len(result.Overridden) > 0 {
+			fmt.Printf("The value overrides the one of note(s) %s.\n", strings.Join(result.Overridden, ", "))
+		}
+	}
+	if !result.Comparison.MatchExpectation {
+		os.Exit(ExitFailure)
+	}
+}
+
+// Print all notes that define the parameter, the value each of them recommends, and whether they are enabled.
+func InspectParameter(paramName string) {
+	if paramName == "" {
+		PrintHelpAndExit(ExitFailure)
+	}
+	sources := tuneApp.InspectParameter(paramName)
+	if len(sources) == 0 {
+		errorExit("No note defines parameter \"%s\".", paramName)
+	}
+	fmt.Printf("Notes defining %s (+ denotes enabled notes):\n", paramName)
+	for _, source := range sources {
+		format := "\t%s\t%s\n"
+		if source.Enabled {
+			format = "+" + format
+		}
+		fmt.Printf(format, source.NoteID, tuningOptions[source.NoteID].Name())
+		fmt.Printf("\t\tRecommended: %s\n", source.Comparison.ExpectedValueJS)
+		fmt.Printf("\t\tCurrent    : %s\n", source.Comparison.ActualValueJS)
+	}
+}
+
+// Return true only if tuned still runs saptune by its legacy profile, which saptune.service replaces.
+func isLegacyTunedProfileActive() bool {
+	return system.SystemctlIsRunning(TunedService) && system.GetTunedProfile() == LegacyTunedProfileName
+}
+
+/*
+Stop sapconf, retire tuned if it still runs saptune by the legacy profile, then enable and start saptune.service. tuned
+reverts the notes as it stops, saptune.service then applies them again.
+*/
+func startDaemon() error {
+	system.SystemctlDisableStop(SapconfService) // failing to stop sapconf is not fatal
+	if isLegacyTunedProfileActive() {
+		if err := system.SystemctlDisableStop(TunedService); err != nil {
+			return fmt.Errorf("Failed to retire tuned, which runs saptune by the profile %s - %v", LegacyTunedProfileName, err)
+		}
+	}
+	return system.SystemctlEnableStart(SaptuneService)
+}
+
+/*
+Conclude a successful apply. With --then-start-daemon the daemon is started so that the tuning persists across reboot,
+otherwise a reminder to start it is printed if necessary. Exit 1 if the daemon fails to start.
+*/
+func startDaemonAfterApply() {
+	if !cliFlag("then-start-daemon") {
+		printDaemonReminder()
+		return
+	}
+	fmt.Println("Starting daemon (saptune.service), this may take several seconds...")
+	if err := startDaemon(); err != nil {
+		errorExit("The tuning has been applied, but the daemon failed to start, hence the tuning will not persist across reboot: %v", err)
+	}
+	fmt.Println("Daemon (saptune.service) has been enabled and started, the tuning persists across reboot.")
+}
+
+// Exit if --then-start-daemon is given where the daemon cannot be started.
+func checkThenStartDaemon() {
+	if !cliFlag("then-start-daemon") {
+		return
+	} else if !isLiveRoot() {
+		errorExit("--then-start-daemon requires the live system, it is not available together with --root.")
+	} else if cliFlag("no-save") {
+		errorExit("--then-start-daemon and --no-save cannot be used together.")
+	}
+}
+
+func DaemonAction(actionName string) {
+	if !isLiveRoot() {
+		errorExit("Daemon control requires the live system, it is not available together with --root.")
+	}
+	switch actionName {
+	case "start":
+		if cliFlag("dry-run") {
+			PrintDaemonStartPlan()
+			return
+		}
+		fmt.Println("Starting daemon (saptune.service), this may take several seconds...")
+		if err := startDaemon(); err != nil {
+			errorExit("%v", err)
+		}
+		// saptune.service then calls `saptune daemon apply`
+		fmt.Println("Daemon (saptune.service) has been enabled and started.")
+		if len(tuneApp.TuneForSolutions) == 0 && len(tuneApp.TuneForNotes) == 0 {
+			fmt.Println("Your system has not yet been tuned. Please visit `saptune note` and `saptune solution` to start tuning.")
+		} else if cliFlag("apply-now") {
+			ReportDaemonTuning()
+		}
+	case "apply":
+		// This action name is only used by saptune.service, hence it is not advertised to end user.
+		// Notes whose TTL elapsed while the system was down are reverted rather than applied again
+		if reverted, err := tuneApp.ReconcilePendingReverts(time.Now()); err != nil {
+			log.Printf("Failed to catch up on the automatic reverts - %v", err)
+		} else if len(reverted) > 0 {
+			log.Printf("The TTL of notes %s has elapsed, they have been reverted", strings.Join(reverted, ", "))
+		}
+		tuneApp.ReadbackReporter = PrintReadbackProblems
+		if err := tuneApp.TuneAll(); err != nil {
+			panic(err)
+		}
+	case "status":
+		warnSolutionSelectorMismatch()
+		if cliFlag("wait") {
+			waitForDaemon()
+		}
+		// Check daemon
+		if system.SystemctlIsRunning(SaptuneService) {
+			fmt.Println("Daemon (saptune.service) is running.")
+		} else {
+			fmt.Fprintln(os.Stderr, "Daemon (saptune.service) is stopped. If you wish to start the daemon, run `saptune daemon start`.")
+			os.Exit(ExitDaemonStopped)
+		}
+		if isLegacyTunedProfileActive() {
+			fmt.Fprintf(os.Stderr, "Warning: tuned still runs saptune by the profile %s. Run `saptune daemon start` to retire it.\n", LegacyTunedProfileName)
+		}
+		// Staged changes are reported even if nothing is tuned yet, e.g. a note staged for enabling on a fresh system
+		printStagedChanges()
+		// Check for any enabled note/solution
+		if len(tuneApp.TuneForSolutions) > 0 || len(tuneApp.TuneForNotes) > 0 {
+			fmt.Println("The system has been tuned for the following solutions and notes:")
+			for _, sol := range tuneApp.TuneForSolutions {
+				fmt.Println("\t" + sol)
+			}
+			for _, noteID := range tuneApp.TuneForNotes {
+				fmt.Println("\t" + noteID)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, "Your system has not yet been tuned. Please visit `saptune note` and `saptune solution` to start tuning.")
+			os.Exit(ExitNotTuned)
+		}
+		printPinnedParameters(os.Stdout)
+		printPendingReverts()
+		if cliFlag("check-drift") {
+			checkDrift()
+		}
+	case "stop":
+		fmt.Println("Stopping daemon (saptune.service), this may take several seconds...")
+		if err := system.SystemctlDisableStop(SaptuneService); err != nil {
+			errorExit("%v", err)
+		}
+		// saptune.service then calls `saptune daemon revert`
+		fmt.Println("Daemon (saptune.service) has been disabled and stopped.")
+		fmt.Println("All tuned parameters have been reverted to default.")
+	case "revert":
+		// This action name is only used by saptune.service, hence it is not advertised to end user.
+		if err := tuneApp.RevertAll(false); err != nil {
+			panic(err)
+		}
+	default:
+		PrintHelpAndExit(ExitFailure)
+	}
+}
+
+/*
+Verify the system against all enabled notes and report in short whether it has drifted from them. Exit with
+ExitDrifted on deviation, or with ExitVerifyFailed if some of the notes could not be verified.
+*/
+func checkDrift() {
+	unsatisfiedNotes, _, noteErrs := tuneApp.VerifyAll()
+	if len(noteErrs) > 0 {
+		erroredNotes := make([]string, 0, len(noteErrs))
+		for noteID := range noteErrs {
+			erroredNotes = append(erroredNotes, noteID)
+		}
+		sort.Strings(erroredNotes)
+		for _, noteID := range erroredNotes {
+			fmt.Fprintf(os.Stderr, "Failed to verify note %s: %v\n", noteID, noteErrs[noteID])
+		}
+		os.Exit(ExitVerifyFailed)
+	}
+	if len(unsatisfiedNotes) > 0 {
+		fmt.Fprintf(os.Stderr, "The system has drifted from the following enabled notes: %s. Run `saptune note verify` for details.\n", strings.Join(unsatisfiedNotes, " "))
+		os.Exit(ExitDrifted)
+	}
+	fmt.Println("The system conforms to all enabled notes.")
+}
+
+/*
+With --export=FILE, record the actual parameter values of all enabled notes in the golden state file. With
+--against=FILE, compare the actual parameter values against those of the golden state file and exit 1 on deviation.
+*/
+func VerifyGoldenState() {
+	if paramName := cliFlagValue("param"); paramName != "" {
+		VerifySingleParameter(paramName)
+		return
+	}
+	if filePath := cliFlagValue("expected"); filePath != "" {
+		VerifyExpectedFile(filePath)
+		return
+	}
+	if filePath := cliFlagValue("export"); filePath != "" {
+		golden, err := tuneApp.ExportGoldenState()
+		if err != nil {
+			errorExit("Failed to inspect the current system: %v", err)
+		}
+		if err := golden.Save(filePath); err != nil {
+			errorExit("Failed to write golden state file %s: %v", filePath, err)
+		}
+		fmt.Printf("The parameter values of %d enabled notes have been exported to %s.\n", len(golden), filePath)
+		return
+	}
+	filePath := cliFlagValue("against")
+	if filePath == "" {
+		PrintHelpAndExit(ExitFailure)
+	}
+	golden, err := app.ReadGoldenState(filePath)
+	if err != nil {
+		errorExit("Failed to read golden state file %s: %v", filePath, err)
+	}
+	unsatisfiedNotes, comparisons, err := tuneApp.VerifyAgainstGolden(golden)
+	if err != nil {
+		errorExit("Failed to test the current system against the golden state: %v", err)
+	}
+	if isStructuredOutput() {
+		PrintStructuredComparisons(comparisons)
+		if len(unsatisfiedNotes) > 0 {
+			os.Exit(ExitFailure)
+		}
+		return
+	}
+	if len(unsatisfiedNotes) == 0 {
+		fmt.Println("The system fully conforms to the golden state.")
+		return
+	}
+	for _, unsatisfiedNoteID := range unsatisfiedNotes {
+		PrintNoteFields(unsatisfiedNoteID, comparisons[unsatisfiedNoteID], true)
+	}
+	errorExit("The parameters listed above have deviated from the golden state.")
+}
+
+/*
+Compare the live values of the parameters listed in the expected values file against the values in the file, without
+regard to the notes, and exit 1 if any parameter deviates or does not exist on this system.
+*/
+func VerifyExpectedFile(filePath string) {
+	expected, err := app.ReadExpectedValues(filePath)
+	if err != nil {
+		errorExit("Failed to read expected values file %s: %v", filePath, err)
+	}
+	comparisons, missing := app.VerifyExpectedValues(expected)
+	if isStructuredOutput() {
+		PrintStructuredComparisons(map[string]map[string]note.NoteFieldComparison{filePath: comparisons})
+		for _, param := range missing {
+			fmt.Fprintf(os.Stderr, "Parameter %s does not exist on this system.\n", param)
+		}
+	} else {
+		paramIDs := make([]string, 0, len(comparisons))
+		for paramID, comparison := range comparisons {
+			if !comparison.MatchExpectation {
+				paramIDs = append(paramIDs, paramID)
+			}
+		}
+		sort.Strings(paramIDs)
+		if len(paramIDs) == 0 && len(missing) == 0 {
+			fmt.Printf("The system conforms to all %d parameters of %s.\n", len(comparisons), filePath)
+			return
+		}
+		fmt.Printf("%s -\n", filePath)
+		for _, paramID := range paramIDs {
+			comparison := comparisons[paramID]
+			fmt.Printf("\t%s Expected: %s\n", comparison.Label(), comparison.ExpectedValueJS)
+			fmt.Printf("\t%s Actual  : %s\n", comparison.Label(), comparison.ActualValueJS)
+		}
+		for _, param := range missing {
+			fmt.Printf("\t%s : does not exist on this system\n", param)
+		}
+	}
+	for _, comparison := range comparisons {
+		if !comparison.MatchExpectation {
+			os.Exit(ExitFailure)
+		}
+	}
+	if len(missing) > 0 {
+		os.Exit(ExitFailure)
+	}
+}
+
+// Print the notes staged by `note enable` and `note disable` that have not yet been applied, if there are any.
+func printStagedChanges() {
+	toApply, toRevert := tuneApp.GetStagedChanges()
+	if len(toApply) == 0 && len(toRevert) == 0 {
+		return
+	}
+	fmt.Println("The following staged changes have not yet been applied, run `saptune apply staged` to apply them:")
+	for _, noteID := range toApply {
+		fmt.Printf("\t+ %s\n", noteID)
+	}
+	for _, noteID := range toRevert {
+		fmt.Printf("\t- %s\n", noteID)
+	}
+}
+
+// Print the parameter values pinned by `note pin`, so that they are not forgotten, if there are any.
+func printPinnedParameters(out io.Writer) {
+	allPins, err := tuneApp.GetAllPins()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read the pinned parameters: %v\n", err)
+		return
+	} else if len(allPins) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "The following parameters are pinned regardless of the note definitions, run `saptune note unpin` to unpin them:")
+	noteIDs := make([]string, 0, len(allPins))
+	for noteID := range allPins {
+		noteIDs = append(noteIDs, noteID)
+	}
+	sort.Strings(noteIDs)
+	for _, noteID := range noteIDs {
+		paramNames := make([]string, 0, len(allPins[noteID]))
+		for paramName := range allPins[noteID] {
+			paramNames = append(paramNames, paramName)
+		}
+		sort.Strings(paramNames)
+		for _, paramName := range paramNames {
+			fmt.Fprintf(out, "\t%s\t%s = %s\n", noteID, paramName, allPins[noteID][paramName])
+		}
+	}
+}
+
+// Tune and revert notes to reconcile the system with the notes staged by `note enable` and `note disable`.
+func ApplyStagedNotes() {
+	applied, reverted, err := tuneApp.ApplyStaged()
+	for _, noteID := range reverted {
+		fmt.Printf("Note %s has been reverted.\n", noteID)
+	}
+	for _, noteID := range applied {
+		fmt.Printf("Note %s has been applied.\n", noteID)
+	}
+	if err != nil {
+		errorExit("Failed to apply the staged notes: %v", err)
+	}
+	if len(applied) == 0 && len(reverted) == 0 {
+		fmt.Println("The system already matches the staged notes, there is nothing to apply.")
+		return
+	}
+	printDaemonReminder()
+}
+
+/*
+Apply all enabled solutions and notes again in the foreground, e.g. after an update of the operating system reset
+some of the parameters, and report the outcome of each note. A failing note does not stop the others.
+*/
+func ApplyAllEnabled() {
+	if len(tuneApp.TuneForSolutions) == 0 && len(tuneApp.TuneForNotes) == 0 {
+		fmt.Println("Your system has not yet been tuned. Please visit `saptune note` and `saptune solution` to start tuning.")
+		return
+	}
+	fmt.Println("Applying tuning for all enabled solutions and notes:")
+	tuneApp.ReadbackReporter = PrintReadbackProblems
+	applied, skipped, failed := 0, 0, 0
+	err := tuneApp.TuneAllReporting(func(noteID string, err error) {
+		if err == nil {
+			fmt.Printf("\t%s\tapplied\n", noteID)
+			applied++
+		} else if errors.Is(err, app.ErrKernelUnsupported) || errors.Is(err, app.ErrPackageMissing) {
+			fmt.Printf("\t%s\tskipped - %v\n", noteID, err)
+			skipped++
+		} else {
+			fmt.Printf("\t%s\tfailed - %v\n", noteID, err)
+			failed++
+		}
+	})
+	fmt.Printf("%d notes applied, %d skipped, %d failed.\n", applied, skipped, failed)
+	if err != nil {
+		errorExit("%v", err)
+	}
+	printDaemonReminder()
+}
+
+/*
+Wait until saptune.service is active, i.e. it has applied the enabled notes, or until the number of seconds given by
+--wait=SECONDS (DefaultStatusWaitSec by default) has elapsed. The caller reports the final state.
+*/
+func waitForDaemon() {
+	waitSec := DefaultStatusWaitSec
+	if value := cliFlagValue("wait"); value != "" {
+		var err error
+		if waitSec, err = strconv.Atoi(value); err != nil || waitSec < 0 {
+			errorExit("The value of --wait must be a number of seconds, \"%s\" is not.", value)
+		}
+	}
+	deadline := time.Now().Add(time.Duration(waitSec) * time.Second)
+	for {
+		if system.SystemctlIsRunning(SaptuneService) {
+			return
+		} else if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "Daemon (saptune.service) did not become active within %d seconds.\n", waitSec)
+			return
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// Print the actions `daemon start` would take and the notes saptune.service would then apply, without changing anything.
+func PrintDaemonStartPlan() {
+	currentState := func(running bool) string {
+		if running {
+			return "currently running"
+		}
+		return "currently stopped"
+	}
+	fmt.Println("This is a dry run, nothing is changed. `saptune daemon start` would:")
+	fmt.Printf("\tdisable and stop %s (%s)\n", SapconfService, currentState(system.SystemctlIsRunning(SapconfService)))
+	if isLegacyTunedProfileActive() {
+		fmt.Printf("\tdisable and stop %s, which runs saptune by the profile \"%s\" (currently running)\n", TunedService, LegacyTunedProfileName)
+	}
+	fmt.Printf("\tenable and start %s (%s)\n", SaptuneService, currentState(system.SystemctlIsRunning(SaptuneService)))
+	noteIDs := tuneApp.GetSortedAllEnabledNotes()
+	if len(noteIDs) == 0 {
+		fmt.Println("Your system has not yet been tuned, saptune.service would not apply any note.")
+		return
+	}
+	if cliFlag("apply-now") {
+		fmt.Println("saptune.service would then apply the following solutions and notes, and the outcome of each note would be reported:")
+	} else {
+		fmt.Println("saptune.service would then apply the following solutions and notes:")
+	}
+	for _, sol := range tuneApp.TuneForSolutions {
+		fmt.Printf("\tsolution %s\n", sol)
+	}
+	for _, noteID := range noteIDs {
+		fmt.Printf("\tnote %s\n", noteID)
+	}
+}
+
+/*
+Report the outcome of the tuning that saptune.service carried out upon start, note by note. The daemon applies the
+notes before it counts as started, hence they are verified rather than applied again. Exit 1 if a note deviates or
+cannot be verified.
+*/
+func ReportDaemonTuning() {
+	fmt.Println("Outcome of the tuning applied by the daemon:")
+	unsatisfiedNotes, _, noteErrs := tuneApp.VerifyAll()
+	unsatisfied := make(map[string]bool)
+	for _, noteID := range unsatisfiedNotes {
+		unsatisfied[noteID] = true
+	}
+	for _, noteID := range tuneApp.GetSortedAllEnabledNotes() {
+		if err, failed := noteErrs[noteID]; failed {
+			fmt.Printf("\t%s\tcannot be verified - %v\n", noteID, err)
+		} else if unsatisfied[noteID] {
+			fmt.Printf("\t%s\tdeviates, run `saptune note verify %s` for details\n", noteID, noteID)
+		} else {
+			fmt.Printf("\t%s\tapplied\n", noteID)
+		}
+	}
+	if len(unsatisfiedNotes) > 0 || len(noteErrs) > 0 {
+		errorExit("The daemon did not apply all enabled notes as expected.")
+	}
+}
+
+/*
+Warn if the solution selector recorded when a solution was applied differs from the current one, e.g. because page
+cache limit became (un)available after a kernel upgrade. The enabled solutions then refer to a different set of notes.
+*/
+func warnSolutionSelectorMismatch() {
+	if len(tuneApp.TuneForSolutions) > 0 && tuneApp.SolutionSelector != "" && tuneApp.SolutionSelector != solutionSelector {
+		fmt.Fprintf(os.Stderr, "Warning: solutions were applied for %s, but the system now selects solutions for %s.\n"+
+			"The enabled solutions may refer to a different set of notes than at the time they were applied.\n",
+			tuneApp.SolutionSelector, solutionSelector)
+	}
+}
+
+/*
+Return the reminder to start the daemon, so that tuning is activated after a reboot.
+Return empty string if saptune.service is running.
+*/
+func daemonReminder(daemonRunning bool) string {
+	if daemonRunning {
+		return ""
+	}
+	return "\nRemember: if you wish to automatically activate the solution's tuning options after a reboot," +
+		"you must instruct saptune to start its daemon (saptune.service) by running:" +
+		"\n    saptune daemon start"
+}
+
+// Print the reminder to start the daemon if necessary. The daemon is only relevant to the live system.
+func printDaemonReminder() {
+	if !isLiveRoot() {
+		return
+	}
+	if reminder := daemonReminder(system.SystemctlIsRunning(SaptuneService)); reminder != "" {
+		fmt.Fprintln(infoOutput(), reminder)
+	}
+}
+
+// Print mismatching fields in the note comparison result.
+func PrintNoteFields(noteID string, comparisons map[string]note.NoteFieldComparison, printComparison bool) {
+	fmt.Printf("%s - %s -\n", noteID, tuningOptions[noteID].Name())
+	comparisons = filterParams(comparisons)
+	hasDiff := false
+	// Print in the stable order of parameter IDs, but show the friendly labels
+	paramIDs := make([]string, 0, len(comparisons))
+	for paramID := range comparisons {
+		paramIDs = append(paramIDs, paramID)
+	}
+	sort.Strings(paramIDs)
+	rejected, _ := tuneApp.State.GetRejected(noteID)
+	pins, _ := tuneApp.State.GetPins(noteID)
+	for _, paramID := range paramIDs {
+		comparison := comparisons[paramID]
+		if comparison.NotApplicable != "" {
+			fmt.Printf("\t%s : not applicable (%s)\n", comparison.Label(), comparison.NotApplicable)
+		} else if !comparison.MatchExpectation {
+			hasDiff = true
+			if printComparison {
+				remarks := make([]string, 0, 2)
+				if _, pinned := pins[note.GetParamName(comparison)]; pinned {
+					remarks = append(remarks, "pinned")
+				}
+				if comparison.Severity == note.SeverityRecommended {
+					remarks = append(remarks, note.SeverityRecommended)
+				}
+				if len(remarks) > 0 {
+					fmt.Printf("\t%s Expected: %s (%s)\n", comparison.Label(), comparison.ExpectedValueJS, strings.Join(remarks, ", "))
+				} else {
+					fmt.Printf("\t%s Expected: %s\n", comparison.Label(), comparison.ExpectedValueJS)
+				}
+				fmt.Printf("\t%s Actual  : %s\n", comparison.Label(), comparison.ActualValueJS)
+				if _, wasRejected := rejected[paramID]; wasRejected {
+					fmt.Printf("\t%s was not accepted by the system when the note was last applied\n", comparison.Label())
+				}
+			} else {
+				fmt.Printf("\t%s : %s\n", comparison.Label(), comparison.ExpectedValueJS)
+			}
+		}
+	}
+	if !hasDiff {
+		fmt.Printf("\t(no change)\n")
+	}
+}
+
+// Print the parameters that the system did not accept when the note was applied, with the values read back.
+func PrintRejectedParameters(noteID string, rejected map[string]note.NoteFieldComparison) {
+	fmt.Fprintf(os.Stderr, "Warning: the system did not accept the following parameters of note %s:\n", noteID)
+	paramIDs := make([]string, 0, len(rejected))
+	for paramID := range rejected {
+		paramIDs = append(paramIDs, paramID)
+	}
+	sort.Strings(paramIDs)
+	for _, paramID := range paramIDs {
+		comparison := rejected[paramID]
+		fmt.Fprintf(os.Stderr, "\t%s Written  : %s\n", comparison.Label(), comparison.ExpectedValueJS)
+		fmt.Fprintf(os.Stderr, "\t%s Read back: %s\n", comparison.Label(), comparison.ActualValueJS)
+	}
+}
+
+/*
+Print the parameters of the note that the environment did not permit to write, e.g. in a container that lacks some
+capabilities. Unlike rejected parameters they are left alone rather than considered a failure.
+*/
+func PrintEnvironmentLimitedParameters(noteID string, limited map[string]note.NoteFieldComparison) {
+	fmt.Fprintf(os.Stderr, "Notice: the environment does not permit writing the following parameters of note %s, they have been left alone:\n", noteID)
+	paramIDs := make([]string, 0, len(limited))
+	for paramID := range limited {
+		paramIDs = append(paramIDs, paramID)
+	}
+	sort.Strings(paramIDs)
+	for _, paramID := range paramIDs {
+		fmt.Fprintf(os.Stderr, "\t%s\n", limited[paramID].NotApplicable)
+	}
+}
+
+// Report the parameters of the readback that the system did not accept or the environment did not permit to write.
+func PrintReadbackProblems(noteID string, readback map[string]note.NoteFieldComparison) {
+	if rejected := app.GetRejected(readback); len(rejected) > 0 {
+		PrintRejectedParameters(noteID, rejected)
+	}
+	if limited := app.GetEnvironmentLimited(readback); len(limited) > 0 {
+		PrintEnvironmentLimitedParameters(noteID, limited)
+	}
+}
+
+// Return true only if --format asks for the results of verification and simulation in CSV or JSON.
+func isStructuredOutput() bool {
+	format := cliFlagValue("format")
+	return format == "csv" || format == "json"
+}
+
+// Return the destination of informational messages, they go to stderr if they would otherwise mix with structured output.
+func infoOutput() io.Writer {
+	if isStructuredOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// A note in the JSON output of `note list`.
+type noteListEntryJSON struct {
+	NoteID     string `json:"note_id"`
+	NoteName   string `json:"note_name"`
+	EnabledBy  string `json:"enabled_by,omitempty"`  // "solution" or "manual", empty if the note is not enabled
+	ReplacedBy string `json:"replaced_by,omitempty"` // the replacement of a deprecated note
+	Overridden bool   `json:"overridden,omitempty"`  // the override file of the note exists
+}
+
+// Print all notes as a JSON array sorted by note ID, only the enabled or the disabled ones if asked to.
+func PrintNoteListJSON(enabledOnly, disabledOnly bool) {
+	solutionNoteIDs := tuneApp.GetSortedSolutionEnabledNotes()
+	notes := make([]noteListEntryJSON, 0, len(tuningOptions))
+	for _, noteID := range tuningOptions.GetSortedIDs() {
+		if noteID == "Block" {
+			// internally used note for solution ASE, it is not listed
+			continue
+		}
+		entry := noteListEntryJSON{
+			NoteID:     noteID,
+			NoteName:   tuningOptions[noteID].Name(),
+			ReplacedBy: note.GetReplacement(tuningOptions[noteID]),
+			Overridden: tuneApp.IsNoteOverridden(noteID),
+		}
+		if i := sort.SearchStrings(solutionNoteIDs, noteID); i < len(solutionNoteIDs) && solutionNoteIDs[i] == noteID {
+			entry.EnabledBy = "solution"
+		} else if i := sort.SearchStrings(tuneApp.TuneForNotes, noteID); i < len(tuneApp.TuneForNotes) && tuneApp.TuneForNotes[i] == noteID {
+			entry.EnabledBy = "manual"
+		}
+		if (enabledOnly && entry.EnabledBy == "") || (disabledOnly && entry.EnabledBy != "") {
+			continue
+		}
+		notes = append(notes, entry)
+	}
+	content, err := json.MarshalIndent(notes, "", "  ")
+	if err != nil {
+		errorExit("Failed to serialise the list of notes: %v", err)
+	}
+	fmt.Println(string(content))
+}
+
+// Print the comparisons of the notes in the format given by --format, CSV or JSON.
+func PrintStructuredComparisons(comparisons map[string]map[string]note.NoteFieldComparison) {
+	if cliFlagValue("format") == "json" {
+		PrintComparisonsJSON(comparisons)
+	} else {
+		PrintComparisonsCSV(comparisons)
+	}
+}
+
+// The comparisons of a note in the JSON output of verification and simulation.
+type noteComparisonsJSON struct {
+	NoteID     string                     `json:"note_id"`
+	NoteName   string                     `json:"note_name"`
+	Conforming bool                       `json:"conforming"` // true only if all parameters match their expectation
+	Parameters []note.NoteFieldComparison `json:"parameters"`
+}
+
+/*
+Print the comparisons of the notes as a JSON array, one object per note sorted by note ID, each holding its parameters
+sorted by parameter ID. The parameter is identified by its stable ID, e.g. SysctlParams[vm.swappiness].
+*/
+func PrintComparisonsJSON(comparisons map[string]map[string]note.NoteFieldComparison) {
+	noteIDs := make([]string, 0, len(comparisons))
+	for noteID := range comparisons {
+		noteIDs = append(noteIDs, noteID)
+	}
+	sort.Strings(noteIDs)
+	notes := make([]noteComparisonsJSON, 0, len(noteIDs))
+	for _, noteID := range noteIDs {
+		noteJSON := noteComparisonsJSON{NoteID: noteID, Conforming: true, Parameters: make([]note.NoteFieldComparison, 0, len(comparisons[noteID]))}
+		if aNote, exists := tuningOptions[noteID]; exists {
+			noteJSON.NoteName = aNote.Name()
+		}
+		for _, comparison := range filterParams(comparisons[noteID]) {
+			noteJSON.Parameters = append(noteJSON.Parameters, comparison)
+			noteJSON.Conforming = noteJSON.Conforming && comparison.MatchExpectation
+		}
+		sort.Slice(noteJSON.Parameters, func(i, j int) bool {
+			return noteJSON.Parameters[i].ParamID < noteJSON.Parameters[j].ParamID
+		})
+		notes = append(notes, noteJSON)
+	}
+	content, err := json.MarshalIndent(notes, "", "  ")
+	if err != nil {
+		errorExit("Failed to serialise the comparisons: %v", err)
+	}
+	fmt.Println(string(content))
+}
+
+/*
+Print the comparisons of the notes in CSV, one row per parameter sorted by note ID and parameter ID, preceded by a
+header row. The parameter is identified by its stable ID, e.g. SysctlParams[vm.swappiness].
+*/
+func PrintComparisonsCSV(comparisons map[string]map[string]note.NoteFieldComparison) {
+	writer := csv.NewWriter(os.Stdout)
+	writer.Write([]string{"note_id", "note_name", "parameter", "expected", "actual", "matches", "severity"})
+	noteIDs := make([]string, 0, len(comparisons))
+	for noteID := range comparisons {
+		noteIDs = append(noteIDs, noteID)
+	}
+	sort.Strings(noteIDs)
+	for _, noteID := range noteIDs {
+		noteName := ""
+		if aNote, exists := tuningOptions[noteID]; exists {
+			noteName = aNote.Name()
+		}
+		noteComparisons := filterParams(comparisons[noteID])
+		paramIDs := make([]string, 0, len(noteComparisons))
+		for paramID := range noteComparisons {
+			paramIDs = append(paramIDs, paramID)
+		}
+		sort.Strings(paramIDs)
+		for _, paramID := range paramIDs {
+			comparison := noteComparisons[paramID]
+			writer.Write([]string{noteID, noteName, paramID, comparison.ExpectedValueJS, comparison.ActualValueJS, strconv.FormatBool(comparison.MatchExpectation), comparison.Severity})
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		errorExit("Failed to write CSV: %v", err)
+	}
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Print a gauge in the Prometheus text exposition format, the samples are given as label set VS value.
+func printGauge(out io.Writer, name, help string, samples map[string]float64) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	labelSets := make([]string, 0, len(samples))
+	for labels := range samples {
+		labelSets = append(labelSets, labels)
+	}
+	sort.Strings(labelSets)
+	for _, labels := range labelSets {
+		fmt.Fprintf(out, "%s%s %s\n", name, labels, strconv.FormatFloat(samples[labels], 'g', -1, 64))
+	}
+}
+
+/*
+Print the outcome of verifying the enabled notes as metrics for the textfile collector of node_exporter: the number of
+deviating parameters of each note, whether each note complies, whether each note failed to inspect the system, the
+time each note was last applied, and the overall compliance. The health of the daemon is only reported if it is known,
+i.e. not nil.
+*/
+func PrintMetrics(out io.Writer, comparisons map[string]map[string]note.NoteFieldComparison, noteErrs map[string]error, applyTimes map[string]time.Time, daemonHealthy *bool) {
+	noteLabels := func(noteID string) string {
+		noteName := ""
+		if aNote, exists := tuningOptions[noteID]; exists {
+			noteName = aNote.Name()
+		}
+		return fmt.Sprintf(`{note_id="%s",note_name="%s"}`, prometheusLabelEscaper.Replace(noteID), prometheusLabelEscaper.Replace(noteName))
+	}
+	deviating := make(map[string]float64)
+	compliant := make(map[string]float64)
+	failed := make(map[string]float64)
+	for noteID, noteComparisons := range comparisons {
+		count := 0
+		for _, comparison := range noteComparisons {
+			if !comparison.MatchExpectation {
+				count++
+			}
+		}
+		deviating[noteLabels(noteID)] = float64(count)
+		compliant[noteLabels(noteID)] = 0
+		if count == 0 {
+			compliant[noteLabels(noteID)] = 1
+		}
+		failed[noteLabels(noteID)] = 0
+	}
+	for noteID := range noteErrs {
+		failed[noteLabels(noteID)] = 1
+	}
+	lastApplied := make(map[string]float64)
+	for noteID, applyTime := range applyTimes {
+		lastApplied[noteLabels(noteID)] = float64(applyTime.Unix())
+	}
+	printGauge(out, "saptune_note_deviating_parameters", "Number of parameters of the enabled note that deviate from the recommendation.", deviating)
+	printGauge(out, "saptune_note_compliant", "1 if all parameters of the enabled note conform to the recommendation, 0 otherwise.", compliant)
+	printGauge(out, "saptune_note_verify_failed", "1 if the enabled note failed to inspect the system, 0 otherwise.", failed)
+	printGauge(out, "saptune_note_last_apply_timestamp_seconds", "Time the enabled note was last applied, in seconds since the epoch.", lastApplied)
+	printGauge(out, "saptune_compliance_ratio", "Ratio of the parameters of all enabled notes that conform to the recommendation.",
+		map[string]float64{"": app.GetComplianceScore(comparisons).Percentage / 100})
+	if daemonHealthy != nil {
+		health := 0.0
+		if *daemonHealthy {
+			health = 1
+		}
+		printGauge(out, "saptune_daemon_healthy", "1 if saptune.service is running, 0 otherwise.", map[string]float64{"": health})
+	}
+}
+
+// Print the definitions of all notes and solutions in JSON, except the notes given by --exclude-note (may repeat).
+func PrintCatalog() {
+	catalog := tuneApp.GetCatalog(cliFlagValues("exclude-note"))
+	content, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		errorExit("Failed to serialise the catalog: %v", err)
+	}
+	fmt.Println(string(content))
+}
+
+/*
+Print an overview of the tuning of the system: the state of the daemon and its profile, the enabled solutions and
+notes, the number of deviating parameters, and whether a reboot is pending. The system is not changed.
+*/
+func PrintStatus() {
+	status := tuneApp.GetStatus(getDaemonStatus())
+	if status.Daemon == nil {
+		fmt.Println("Daemon (saptune.service):\tnot inspected together with --root")
+	} else {
+		state := "stopped"
+		if status.Daemon.Running {
+			state = "running"
+		}
+		fmt.Printf("Daemon (saptune.service):\t%s\n", state)
+	}
+	if len(status.EnabledSolutions) > 0 {
+		fmt.Printf("Enabled solutions:\t%s\n", strings.Join(status.EnabledSolutions, " "))
+	} else {
+		fmt.Println("Enabled solutions:\tnone")
+	}
+	if len(status.EnabledNotes) > 0 {
+		fmt.Printf("Enabled notes:\t\t%s\n", strings.Join(status.EnabledNotes, " "))
+	} else {
+		fmt.Println("Enabled notes:\t\tnone")
+	}
+	fmt.Printf("Deviating parameters:\t%d of %d\n", status.DeviatingParameters, status.TotalParameters)
+	if status.PendingReboot > 0 {
+		fmt.Printf("Reboot pending:\t\tyes, for %d parameters\n", status.PendingReboot)
+	} else {
+		fmt.Println("Reboot pending:\t\tno")
+	}
+	if len(status.FailedNotes) > 0 {
+		fmt.Printf("Failed to verify:\t%s\n", strings.Join(status.FailedNotes, " "))
+	}
+	if status.DeviatingParameters > 0 || len(status.FailedNotes) > 0 {
+		fmt.Println("Run `saptune note verify` for details.")
+	}
+}
+
+// Return the state of the daemon, or nil with --root, as the daemon is only inspected on the running system.
+func getDaemonStatus() *app.DaemonStatus {
+	if !isLiveRoot() {
+		return nil
+	}
+	return &app.DaemonStatus{Running: system.SystemctlIsRunning(SaptuneService)}
+}
+
+// Verify all enabled notes and print the compliance report in JSON, or write it into the file given by --output-file.
+func PrintReport() {
+	report, err := tuneApp.GetReport(getDaemonStatus())
+	if err != nil {
+		errorExit("Failed to assemble the report: %v", err)
+	}
+	if outputFile := cliFlagValue("output-file"); outputFile != "" {
+		if err := report.Save(outputFile); err != nil {
+			errorExit("Failed to write the report into %s: %v", outputFile, err)
+		}
+		fmt.Printf("The report has been written into %s.\n", outputFile)
+		return
+	}
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		errorExit("Failed to serialise the report: %v", err)
+	}
+	fmt.Println(string(content))
+}
+
+// Verify all enabled notes and print the outcome as metrics for the textfile collector of node_exporter.
+func PrintAllMetrics() {
+	writeAllMetrics(os.Stdout)
+}
+
+// Verify all enabled notes and write the outcome as metrics, together with the apply times and the daemon health.
+func writeAllMetrics(out io.Writer) {
+	_, comparisons, noteErrs := tuneApp.VerifyAll()
+	applyTimes := make(map[string]time.Time)
+	for _, noteID := range tuneApp.GetSortedAllEnabledNotes() {
+		// A note that has not been applied yet has no apply time
+		if applyTime, err := tuneApp.State.GetApplyTime(noteID); err == nil {
+			applyTimes[noteID] = applyTime
+		}
+	}
+	var daemonHealthy *bool
+	if isLiveRoot() {
+		healthy := system.SystemctlIsRunning(SaptuneService)
+		daemonHealthy = &healthy
+	}
+	PrintMetrics(out, comparisons, noteErrs, applyTimes, daemonHealthy)
+}
+
+/*
+Serve the metrics of PrintAllMetrics over HTTP at /metrics, on the address given by --listen (DefaultExporterAddress by
+default), until saptune is terminated. The configuration and the notes are loaded afresh and the system is verified
+upon each scrape, one scrape at a time.
+*/
+func ServeMetrics(archSolutions map[string]solution.Solution) {
+	address := cliFlagValue("listen")
+	if address == "" {
+		address = DefaultExporterAddress
+	}
+	var scrapeMutex sync.Mutex
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		scrapeMutex.Lock()
+		defer scrapeMutex.Unlock()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		// Notes may have been enabled, customised, or updated by other saptune invocations since the last scrape
+		loadTuning(archSolutions)
+		configureTuning()
+		writeAllMetrics(w)
+	})
+	fmt.Printf("Serving the compliance metrics at http://%s/metrics\n", address)
+	if err := http.ListenAndServe(address, mux); err != nil {
+		errorExit("Failed to serve the metrics on %s: %v", address, err)
+	}
+}
+
+/*
+Print the changes that applying the notes would carry out, note by note. With --diff-only, notes without changes are
+omitted and only counted.
+*/
+func PrintSimulation(comparisons map[string]map[string]note.NoteFieldComparison) {
+	if isStructuredOutput() {
+		PrintStructuredComparisons(comparisons)
+		return
+	}
+	noteIDs := make([]string, 0, len(comparisons))
+	for noteID := range comparisons {
+		noteIDs = append(noteIDs, noteID)
+	}
+	sort.Strings(noteIDs)
+	omitted := 0
+	for _, noteID := range noteIDs {
+		hasDiff := false
+		for _, comparison := range comparisons[noteID] {
+			hasDiff = hasDiff || !comparison.MatchExpectation
+		}
+		if !hasDiff && cliFlag("diff-only") {
+			omitted++
+			continue
+		}
+		PrintNoteFields(noteID, comparisons[noteID], false)
+	}
+	if cliFlag("diff-only") {
+		fmt.Printf("%d notes fully conform and are omitted.\n", omitted)
+	}
+}
+
+/*
+Print the changes that applying each note known to saptune would carry out, no matter the note is enabled or not.
+Notes that fail to inspect the system are reported at the end.
+*/
+func SimulateAllNotes() {
+	noteIDs := make([]string, 0, len(tuningOptions))
+	for _, noteID := range tuningOptions.GetSortedIDs() {
+		if noteID != "Block" {
+			noteIDs = append(noteIDs, noteID)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Simulating all %d notes. Nothing is changed, but inspecting the system for every note may take a while.\n", len(noteIDs))
+	_, comparisons, noteErrs := tuneApp.VerifyNotes(noteIDs)
+	fmt.Fprintln(infoOutput(), "If you run `saptune note apply` on each note, the following changes will be applied to your system:")
+	PrintSimulation(comparisons)
+	if len(noteErrs) > 0 {
+		failedIDs := make([]string, 0, len(noteErrs))
+		for noteID := range noteErrs {
+			failedIDs = append(failedIDs, noteID)
+		}
+		sort.Strings(failedIDs)
+		for _, noteID := range failedIDs {
+			fmt.Fprintf(os.Stderr, "Failed to test the current system against note %s: %v\n", noteID, noteErrs[noteID])
+		}
+		os.Exit(ExitFailure)
+	}
+}
+
+// Print mismatching fields of all notes as a single list sorted by parameter name, each annotated with its note.
+func PrintDeviationsByParameter(comparisons map[string]map[string]note.NoteFieldComparison) {
+	type deviation struct {
+		noteID, name string
+		comparison   note.NoteFieldComparison
+	}
+	deviations := make([]deviation, 0, 0)
+	for noteID, noteComparisons := range comparisons {
+		for name, comparison := range filterParams(noteComparisons) {
+			if !comparison.MatchExpectation {
+				deviations = append(deviations, deviation{noteID: noteID, name: name, comparison: comparison})
+			}
+		}
+	}
+	sort.Slice(deviations, func(i, j int) bool {
+		if deviations[i].name != deviations[j].name {
+			return deviations[i].name < deviations[j].name
+		}
+		return deviations[i].noteID < deviations[j].noteID
+	})
+	for _, dev := range deviations {
+		fmt.Printf("%s (from %s - %s)\n", dev.name, dev.noteID, tuningOptions[dev.noteID].Name())
+		fmt.Printf("\tExpected: %s\n", dev.comparison.ExpectedValueJS)
+		fmt.Printf("\tActual  : %s\n", dev.comparison.ActualValueJS)
+	}
+}
+
+/*
+Print the findings of the external checkers configured for the solutions. A checker that fails is reported, but
+does not affect the outcome of the verification.
+*/
+func PrintExternalChecks(solNames []string) {
+	for _, solName := range solNames {
+		if _, configured := tuneApp.ExternalChecks[solName]; !configured {
+			continue
+		}
+		findings, err := tuneApp.RunExternalCheck(solName)
+		fmt.Printf("External check for solution %s:\n", solName)
+		for _, finding := range findings {
+			fmt.Printf("\t%s\n", finding)
+		}
+		if err != nil {
+			fmt.Printf("\t(%v)\n", err)
+		} else if len(findings) == 0 {
+			fmt.Printf("\t(no finding)\n")
+		}
+	}
+}
+
+// Print the percentage of conforming parameters. A system without enabled notes is reported as 100% compliant.
+func PrintComplianceScore(score app.ComplianceScore) {
+	fmt.Printf("Compliance score: %.1f%% (%d of %d parameters conform)\n", score.Percentage, score.ConformingParameters, score.TotalParameters)
+}
+
+/*
+Report the authoring mistakes in the definition of the note, or of all notes if the note ID is empty, e.g. a parameter
+defined twice in a tuning sheet. Exit 1 if there is any.
+*/
+func ValidateNotes(noteID string) {
+	noteIDs := tuningOptions.GetSortedIDs()
+	if noteID != "" {
+		noteIDs = []string{noteID}
+	}
+	invalid := 0
+	for _, id := range noteIDs {
+		aNote, err := tuneApp.GetNoteByID(id)
+		if err != nil {
+			errorExit("%v", err)
+		}
+		mistakes := note.ValidateNote(aNote)
+		for _, mistake := range mistakes {
+			fmt.Println(mistake)
+		}
+		if len(mistakes) > 0 {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		fmt.Printf("%d of %d notes have mistakes in their definition.\n", invalid, len(noteIDs))
+		os.Exit(ExitFailure)
+	}
+	fmt.Printf("The definitions of %d notes are valid.\n", len(noteIDs))
+}
+
+/*
+Print whether each enabled note is customised or pinned, and the parameters whose values differ from the note
+definition as a result.
+*/
+func PrintLocalModifications() {
+	fmt.Println("Local modifications of enabled notes (O denotes overridden notes, C denotes customised notes, P denotes notes with pinned parameters):")
+	modified := 0
+	for _, noteID := range tuneApp.GetSortedAllEnabledNotes() {
+		modification, err := tuneApp.GetLocalModification(noteID)
+		if err != nil {
+			errorExit("Failed to examine note %s: %v", noteID, err)
+		}
+		flags := ""
+		if modification.Overridden {
+			flags += "O"
+		}
+		if modification.Customised {
+			flags += "C"
+		}
+		if modification.Pinned {
+			flags += "P"
+		}
+		fmt.Printf("%-3s\t%s\t%s\n", flags, noteID, tuningOptions[noteID].Name())
+		for _, comparison := range modification.Differences {
+			fmt.Printf("\t\t%s: %s (default %s)\n", comparison.Label(), comparison.ActualValueJS, comparison.ExpectedValueJS)
+		}
+		if modification.IsModified() {
+			modified++
+		}
+	}
+	fmt.Printf("%d enabled notes are modified locally.\n", modified)
+}
+
+// Verify that all system parameters do not deviate from any of the enabled solutions/notes.
+func VerifyAllParameters() {
+	warnSolutionSelectorMismatch()
+	// Notes applied within the window given by --since are assumed to be still settling
+	skippedNotes := []string{}
+	if since := cliFlagValue("since"); since != "" {
+		window, err := time.ParseDuration(since)
+		if err != nil {
+			errorExit("The value of --since must be a duration such as 5m, \"%s\" is not.", since)
+		}
+		skippedNotes = tuneApp.GetRecentlyAppliedNotes(window)
+		for _, noteID := range skippedNotes {
+			fmt.Fprintf(infoOutput(), "%s - %s - recently applied, skipped.\n", noteID, tuningOptions[noteID].Name())
+		}
+	}
+	// Notes given by --exclude-note (may repeat) are neither inspected nor considered for the exit status
+	enabledNotes := tuneApp.GetSortedAllEnabledNotes()
+	for _, noteID := range cliFlagValues("exclude-note") {
+		if i := sort.SearchStrings(enabledNotes, noteID); !(i < len(enabledNotes) && enabledNotes[i] == noteID) {
+			fmt.Fprintf(os.Stderr, "Warning: note %s is not enabled, excluding it has no effect.\n", noteID)
+			continue
+		}
+		fmt.Fprintf(infoOutput(), "%s - %s - excluded.\n", noteID, tuningOptions[noteID].Name())
+		skippedNotes = append(skippedNotes, noteID)
+	}
+	unsatisfiedNotes, comparisons, noteErrs := tuneApp.VerifyAllExcept(skippedNotes)
+	unsatisfiedNotes = filterDeviatingNotes(unsatisfiedNotes, comparisons)
+	if cliFlag("fix") && len(unsatisfiedNotes) > 0 && FixDeviatingNotes(unsatisfiedNotes, comparisons) {
+		// Verify again to confirm the outcome of the fix
+		unsatisfiedNotes, comparisons, noteErrs = tuneApp.VerifyAllExcept(skippedNotes)
+		unsatisfiedNotes = filterDeviatingNotes(unsatisfiedNotes, comparisons)
+	}
+	if filePath := cliFlagValue("baseline-compare"); filePath != "" {
+		PrintBaselineChanges(filePath, comparisons, noteErrs)
+		return
+	}
+	if filePath := cliFlagValue("baseline-save"); filePath != "" {
+		if err := app.NewBaseline(comparisons).Save(filePath); err != nil {
+			errorExit("Failed to save baseline file %s: %v", filePath, err)
+		}
+		fmt.Fprintf(infoOutput(), "The verification result has been saved as baseline to %s.\n", filePath)
+	}
+	printPinnedParameters(infoOutput())
+	PrintVerifyResults(unsatisfiedNotes, comparisons, noteErrs, "all of the enabled notes")
+}
+
+/*
+Print only the parameters whose conformance changed since the baseline in the file was saved, and the notes that
+cannot be compared. Exit 1 if any parameter became deviating.
+*/
+func PrintBaselineChanges(filePath string, comparisons map[string]map[string]note.NoteFieldComparison, noteErrs map[string]error) {
+	baseline, err := app.ReadBaseline(filePath)
+	if err != nil {
+		errorExit("Failed to read baseline file %s: %v", filePath, err)
+	}
+	changes, missingNotes, newNotes := baseline.Compare(comparisons)
+	for _, noteID := range missingNotes {
+		if _, failed := noteErrs[noteID]; !failed {
+			fmt.Fprintf(infoOutput(), "%s - not verified this time, e.g. it is no longer enabled, skipped.\n", noteID)
+		}
+	}
+	for _, noteID := range newNotes {
+		fmt.Fprintf(infoOutput(), "%s - %s - not part of the baseline, skipped.\n", noteID, tuningOptions[noteID].Name())
+	}
+	erroredNotes := make([]string, 0, len(noteErrs))
+	for noteID := range noteErrs {
+		erroredNotes = append(erroredNotes, noteID)
+	}
+	sort.Strings(erroredNotes)
+	for _, noteID := range erroredNotes {
+		fmt.Fprintf(os.Stderr, "Failed to verify note %s: %v\n", noteID, noteErrs[noteID])
+	}
+	fmt.Printf("Changes of compliance since %s:\n", baseline.Time.Format(time.RFC3339))
+	if len(changes) == 0 {
+		fmt.Println("\tnone")
+	}
+	newlyDeviating := 0
+	for _, change := range changes {
+		status := "newly fixed"
+		if !change.IsFixed() {
+			status = "newly deviating"
+			newlyDeviating++
+		}
+		fmt.Printf("\t%s\t%s\t%s: %s -> %s (expected %s)\n", change.NoteID, change.Comparison.Label(), status,
+			change.Before.Actual, change.Comparison.ActualValueJS, change.Comparison.ExpectedValueJS)
+	}
+	if len(noteErrs) > 0 {
+		os.Exit(ExitVerifyFailed)
+	} else if newlyDeviating > 0 {
+		os.Exit(ExitFailure)
+	}
+}
+
+/*
+Print the deviations of the notes and re-apply the deviating notes once the user confirms, or immediately with --yes.
+Return true only if the notes were re-applied. Notes that fail to apply are reported, the others are still applied.
+*/
+func FixDeviatingNotes(unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison) bool {
+	fmt.Println("Deviating notes:")
+	for _, unsatisfiedNoteID := range unsatisfiedNotes {
+		PrintNoteFields(unsatisfiedNoteID, comparisons[unsatisfiedNoteID], true)
+	}
+	if !confirm(fmt.Sprintf("Re-apply the %d deviating notes listed above?", len(unsatisfiedNotes))) {
+		fmt.Println("The deviating notes have not been re-applied.")
+		return false
+	}
+	for _, noteID := range unsatisfiedNotes {
+		if err := tuneApp.TuneNote(noteID); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to re-apply note %s: %v\n", noteID, err)
+		} else {
+			fmt.Printf("Note %s has been re-applied.\n", noteID)
+		}
+	}
+	return true
+}
+
+// Ask the user a yes/no question on the terminal and return true only if the answer is yes. With --yes, do not ask.
+func confirm(question string) bool {
+	if cliFlag("yes") {
+		return true
+	}
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+/*
+Print the high-risk parameters that applying the note would change and ask the user to confirm. With --yes, do not ask.
+Without a terminal to ask on, refuse the changes.
+*/
+func confirmHighRisk(noteID string, changes []app.HighRiskChange) bool {
+	if cliFlag("yes") {
+		return true
+	}
+	fmt.Printf("Applying note %s changes the following high-risk parameters:\n", noteID)
+	for _, change := range changes {
+		fmt.Printf("\t%s: %s -> %s\n\t\t%s\n", change.Param, change.Current, change.Target, change.Risk)
+	}
+	if !isTerminal(os.Stdin) {
+		fmt.Fprintf(os.Stderr, "Cannot ask for confirmation without a terminal, use --yes to change the parameters nevertheless.\n")
+		return false
+	}
+	return confirm(fmt.Sprintf("Change the high-risk parameters of note %s?", noteID))
+}
+
+// Return true only if the file is a terminal rather than a pipe or a regular file.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// An entry of the interactive menu, either a solution or a note.
+type menuItem struct {
+	isSolution bool
+	id, name   string
+	enabled    bool // currently enabled
+	selected   bool // desired to be enabled
+	bySolution bool // note enabled by a solution, it cannot be toggled on its own
+}
+
+/*
+Let the operator toggle solutions and notes on a menu printed to the terminal, and then apply the newly selected ones
+and revert the deselected ones. Without a terminal, print help instead.
+*/
+func InteractiveMenu() {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		PrintHelpAndExit(ExitFailure)
+	}
+	items := make([]menuItem, 0, 0)
+	for _, solName := range solution.GetSortedSolutionNames(solutionSelector) {
+		i := sort.SearchStrings(tuneApp.TuneForSolutions, solName)
+		enabled := i < len(tuneApp.TuneForSolutions) && tuneApp.TuneForSolutions[i] == solName
+		items = append(items, menuItem{isSolution: true, id: solName, name: strings.Join(tuneApp.AllSolutions[solName], " "), enabled: enabled, selected: enabled})
+	}
+	for _, noteID := range tuningOptions.GetSortedIDs() {
+		if noteID == "Block" {
+			continue
+		}
+		i := sort.SearchStrings(tuneApp.TuneForNotes, noteID)
+		enabled := i < len(tuneApp.TuneForNotes) && tuneApp.TuneForNotes[i] == noteID
+		items = append(items, menuItem{id: noteID, name: tuningOptions[noteID].Name(), enabled: enabled, selected: enabled})
+	}
+	markSolutionNotes(items)
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Println("Solutions and notes ([x] selected, [*] enabled by a selected solution):")
+		for index, item := range items {
+			mark := " "
+			if item.selected {
+				mark = "x"
+			} else if item.bySolution {
+				mark = "*"
+			}
+			kind := "note"
+			if item.isSolution {
+				kind = "solution"
+			}
+			fmt.Printf("%4d [%s] %-8s %s\t%s\n", index+1, mark, kind, item.id, item.name)
+		}
+		fmt.Print("Enter numbers to toggle, 'a' to apply the selection, or 'q' to quit: ")
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Println()
+			return
+		}
+		switch strings.TrimSpace(strings.ToLower(line)) {
+		case "q":
+			return
+		case "a":
+			if applyMenuSelection(items, reader) {
+				return
+			}
+			continue
+		}
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' }) {
+			index, err := strconv.Atoi(field)
+			if err != nil || index < 1 || index > len(items) {
+				fmt.Printf("\"%s\" is not a number on the menu.\n", field)
+				continue
+			}
+			if item := &items[index-1]; item.bySolution && !item.selected {
+				fmt.Printf("Note %s is enabled by a solution, deselect the solution instead.\n", item.id)
+			} else {
+				item.selected = !item.selected
+			}
+			// Selecting or deselecting a solution changes the notes it enables
+			markSolutionNotes(items)
+		}
+	}
+}
+
+// Mark the notes of the menu that belong to a selected solution, leaving out the notes excluded from the solution.
+func markSolutionNotes(items []menuItem) {
+	solutionNoteIDs := make(map[string]struct{})
+	for _, item := range items {
+		if !item.isSolution || !item.selected {
+			continue
+		}
+		if sol, err := tuneApp.GetSolutionByName(item.id); err == nil {
+			for _, noteID := range sol {
+				solutionNoteIDs[noteID] = struct{}{}
+			}
+		}
+	}
+	for i := range items {
+		if !items[i].isSolution {
+			_, items[i].bySolution = solutionNoteIDs[items[i].id]
+		}
+	}
+}
+
+/*
+Print the changes of the menu selection, and once the operator confirms, apply the newly selected solutions and notes
+and revert the deselected ones. Return true only if the changes were carried out.
+*/
+func applyMenuSelection(items []menuItem, reader *bufio.Reader) bool {
+	changes := make([]menuItem, 0, 0)
+	for _, item := range items {
+		if item.selected != item.enabled {
+			changes = append(changes, item)
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Println("The selection is unchanged, nothing to apply.")
+		return true
+	}
+	for _, item := range changes {
+		action := "revert"
+		if item.selected {
+			action = "apply"
+		}
+		fmt.Printf("\t%s %s\n", action, item.id)
+	}
+	fmt.Print("Carry out the changes listed above? [y/N] ")
+	switch answer, _ := reader.ReadString('\n'); strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+	default:
+		return false
+	}
+	// The operator may have spent a while on the menu, the maintenance window is checked right before tuning
+	if !cliFlag("force") {
+		if err := tuneApp.CheckMaintenanceWindow(time.Now()); err != nil {
+			errorExit("%v\nUse --force to tune the system nevertheless.", err)
+		}
+	}
+	failed := false
+	for _, item := range changes {
+		var err error
+		switch {
+		case item.isSolution && item.selected:
+			_, err = tuneApp.TuneSolution(item.id)
+		case item.isSolution:
+			err = tuneApp.RevertSolution(item.id)
+		case item.selected:
+			err = tuneApp.TuneNote(item.id)
+		default:
+			err = tuneApp.RevertNote(item.id, true)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to change %s: %v\n", item.id, err)
+			failed = true
+		}
+	}
+	printDaemonReminder()
+	if failed {
+		errorExit("Failed to carry out one or more changes listed above.")
+	}
+	fmt.Println("The selection has been applied.")
+	return true
+}
+
+// Verify the system against the notes listed in the file, no matter they are enabled or not.
+func VerifyListedNotes(filePath string) {
+	noteIDs, err := app.ReadNoteListFile(filePath)
+	if err != nil {
+		errorExit("Failed to read note list file %s: %v", filePath, err)
+	} else if len(noteIDs) == 0 {
+		errorExit("The note list file %s does not list any note.", filePath)
+	}
+	unsatisfiedNotes, comparisons, noteErrs := tuneApp.VerifyNotes(noteIDs)
+	PrintVerifyResults(unsatisfiedNotes, comparisons, noteErrs, "the notes listed in "+filePath)
+}
+
+// Check system parameters against the specified note, no matter the note has been tuned for or not.
+func VerifySingleNote(noteID string) {
+	conforming, comparisons, err := tuneApp.VerifyNote(noteID)
+	if err != nil {
+		errorExit("Failed to test the current system against the specified note: %v", err)
+	}
+	conforming = conforming || len(filterDeviatingNotes([]string{noteID}, map[string]map[string]note.NoteFieldComparison{noteID: comparisons})) == 0
+	failing := !conforming && len(failingNotes([]string{noteID}, map[string]map[string]note.NoteFieldComparison{noteID: comparisons})) > 0
+	if isStructuredOutput() {
+		PrintStructuredComparisons(map[string]map[string]note.NoteFieldComparison{noteID: comparisons})
+		if failing {
+			os.Exit(ExitFailure)
+		}
+	} else if failing {
+		PrintNoteFields(noteID, comparisons, true)
+		errorExit("The parameters listed above have deviated from the specified note.\n")
+	} else if !conforming {
+		PrintNoteFields(noteID, comparisons, true)
+		fmt.Println("Only recommended parameters listed above have deviated from the specified note, they are tolerated.")
+	} else {
+		fmt.Printf("The system fully conforms to the specified note%s.\n", filterQualifier())
+	}
+}
+
+/*
+Return the deviating notes that fail the verification. With --tolerate-recommended, a note whose deviating parameters
+are all merely recommended does not fail. The notes are expected to be filtered by filterDeviatingNotes.
+*/
+func failingNotes(unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison) []string {
+	if !cliFlag("tolerate-recommended") {
+		return unsatisfiedNotes
+	}
+	failing := make([]string, 0, len(unsatisfiedNotes))
+	for _, noteID := range unsatisfiedNotes {
+		for _, comparison := range exitRelevantParams(comparisons[noteID]) {
+			if note.IsMandatoryViolation(comparison) {
+				failing = append(failing, noteID)
+				break
+			}
+		}
+	}
+	return failing
+}
+
+// Return the comparisons of the parameters whose label or ID matches --param-filter, all of them without the flag.
+func filterParams(comparisons map[string]note.NoteFieldComparison) map[string]note.NoteFieldComparison {
+	if paramFilter == nil {
+		return comparisons
+	}
+	filtered := make(map[string]note.NoteFieldComparison)
+	for paramID, comparison := range comparisons {
+		if paramFilter.MatchString(comparison.Label()) || paramFilter.MatchString(paramID) {
+			filtered[paramID] = comparison
+		}
+	}
+	return filtered
+}
+
+// Return the comparisons that decide the exit status: only those matching --param-filter with --filter-affects-exit.
+func exitRelevantParams(comparisons map[string]note.NoteFieldComparison) map[string]note.NoteFieldComparison {
+	if !cliFlag("filter-affects-exit") {
+		return comparisons
+	}
+	return filterParams(comparisons)
+}
+
+// Qualify a statement of conformance that only considers the parameters matching --param-filter.
+func filterQualifier() string {
+	if !cliFlag("filter-affects-exit") {
+		return ""
+	}
+	return fmt.Sprintf(" in the parameters matching %s", paramFilter)
+}
+
+/*
+Return the deviating notes that deviate in a parameter relevant to the exit status, so that with --filter-affects-exit
+a note deviating only in parameters that do not match --param-filter is considered conforming.
+*/
+func filterDeviatingNotes(unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison) []string {
+	if !cliFlag("filter-affects-exit") {
+		return unsatisfiedNotes
+	}
+	deviating := make([]string, 0, len(unsatisfiedNotes))
+	for _, noteID := range unsatisfiedNotes {
+		for _, comparison := range exitRelevantParams(comparisons[noteID]) {
+			if !comparison.MatchExpectation {
+				deviating = append(deviating, noteID)
+				break
+			}
+		}
+	}
+	return deviating
+}
+
+// Print the number of deviating mandatory and recommended parameters.
+func PrintSeveritySummary(comparisons map[string]map[string]note.NoteFieldComparison) {
+	mandatory, recommended := 0, 0
+	for _, noteComparisons := range comparisons {
+		for _, comparison := range noteComparisons {
+			if note.IsMandatoryViolation(comparison) {
+				mandatory++
+			} else if !comparison.MatchExpectation {
+				recommended++
+			}
+		}
+	}
+	fmt.Printf("Mandatory violations: %d, recommended deviations: %d\n", mandatory, recommended)
+}
+
+/*
+Verify the note, or all notes captured in the snapshot file if the note ID is empty, taking the current parameter
+values from the snapshot written by `saptune verify --export` on another host rather than from this system.
+*/
+func VerifySnapshot(filePath, noteID string) {
+	snapshot, err := app.ReadGoldenState(filePath)
+	if err != nil {
+		errorExit("Failed to read snapshot file %s: %v", filePath, err)
+	}
+	tuneApp.Inspector = app.SnapshotInspector{Snapshot: snapshot}
+	if noteID != "" {
+		VerifySingleNote(noteID)
+		return
+	}
+	noteIDs := snapshot.GetSortedNoteIDs()
+	if len(noteIDs) == 0 {
+		errorExit("The snapshot file %s does not capture any note.", filePath)
+	}
+	unsatisfiedNotes, comparisons, noteErrs := tuneApp.VerifyNotes(noteIDs)
+	PrintVerifyResults(unsatisfiedNotes, comparisons, noteErrs, "the notes captured in "+filePath)
+}
+
+/*
+Print the deviating notes, the notes that failed to inspect the system, and the compliance score. Exit with
+ExitVerifyFailed if any note failed, or 1 if any parameter deviates. The description names the verified notes.
+*/
+func PrintVerifyResults(unsatisfiedNotes []string, comparisons map[string]map[string]note.NoteFieldComparison, noteErrs map[string]error, description string) {
+	if isStructuredOutput() {
+		PrintStructuredComparisons(comparisons)
+		erroredNotes := make([]string, 0, len(noteErrs))
+		for noteID := range noteErrs {
+			erroredNotes = append(erroredNotes, noteID)
+		}
+		sort.Strings(erroredNotes)
+		for _, noteID := range erroredNotes {
+			fmt.Fprintf(os.Stderr, "Failed to verify note %s: %v\n", noteID, noteErrs[noteID])
+		}
+		if len(noteErrs) > 0 {
+			os.Exit(ExitVerifyFailed)
+		} else if len(failingNotes(unsatisfiedNotes, comparisons)) > 0 {
+			os.Exit(ExitFailure)
+		}
+		return
+	}
+	score := app.GetComplianceScore(comparisons)
+	if len(unsatisfiedNotes) == 0 && len(noteErrs) == 0 {
+		fmt.Printf("The running system is currently well-tuned according to %s%s.\n", description, filterQualifier())
+		PrintComplianceScore(score)
+		return
+	}
+	if len(unsatisfiedNotes) > 0 && cliFlagValue("group-by") == "category" {
+		PrintDeviationsByCategory(comparisons)
+	} else if len(unsatisfiedNotes) > 0 {
+		fmt.Println("Deviating notes:")
+		for _, unsatisfiedNoteID := range unsatisfiedNotes {
+			PrintNoteFields(unsatisfiedNoteID, comparisons[unsatisfiedNoteID], true)
+		}
+	}
+	if len(noteErrs) > 0 {
+		// The state of these notes is unknown, they are left out of the compliance score
+		fmt.Println("Notes that failed to inspect the current system:")
+		erroredNotes := make([]string, 0, len(noteErrs))
+		for noteID := range noteErrs {
+			erroredNotes = append(erroredNotes, noteID)
+		}
+		sort.Strings(erroredNotes)
+		for _, noteID := range erroredNotes {
+			if aNote, exists := tuningOptions[noteID]; exists {
+				fmt.Printf("%s - %s -\n\t%v\n", noteID, aNote.Name(), noteErrs[noteID])
+			} else {
+				fmt.Printf("%s -\n\t%v\n", noteID, noteErrs[noteID])
+			}
+		}
+	}
+	PrintComplianceScore(score)
+	PrintSeveritySummary(comparisons)
+	if len(noteErrs) > 0 {
+		fmt.Fprintln(os.Stderr, "Some of the notes could not be verified, please refer to the errors listed above.")
+		os.Exit(ExitVerifyFailed)
+	} else if len(failingNotes(unsatisfiedNotes, comparisons)) == 0 {
+		fmt.Println("Only recommended parameters listed above have deviated, they are tolerated.")
+		return
+	}
+	errorExit("The parameters listed above have deviated from SAP/SUSE recommendations.")
+}
+
+// Print the deviating parameters of all notes in sections by parameter category, uncategorised parameters last.
+func PrintDeviationsByCategory(comparisons map[string]map[string]note.NoteFieldComparison) {
+	filtered := make(map[string]map[string]note.NoteFieldComparison)
+	for noteID, noteComparisons := range comparisons {
+		filtered[noteID] = filterParams(noteComparisons)
+	}
+	groups := tuneApp.GroupByCategory(filtered)
+	categories := make([]string, 0, len(groups))
+	for category := range groups {
+		if category != note.Uncategorised {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	fmt.Println("Deviating parameters by category:")
+	for _, category := range append(categories, note.Uncategorised) {
+		hasDiff := false
+		for _, noteComparison := range groups[category] {
+			comparison := noteComparison.Comparison
+			if comparison.MatchExpectation {
+				continue
+			}
+			if !hasDiff {
+				fmt.Printf("%s -\n", category)
+				hasDiff = true
+			}
+			fmt.Printf("\t%s (note %s) Expected: %s\n", comparison.Label(), noteComparison.NoteID, comparison.ExpectedValueJS)
+			fmt.Printf("\t%s (note %s) Actual  : %s\n", comparison.Label(), noteComparison.NoteID, comparison.ActualValueJS)
+		}
+	}
+}
+
+// Revert all manually enabled notes and report which ones were reverted or skipped.
+func RevertManualNotes() {
+	revertedNotes, skippedNotes, err := tuneApp.RevertManualNotes()
+	if len(revertedNotes) > 0 {
+		fmt.Println("The following manually enabled notes have been reverted:")
+		for _, noteID := range revertedNotes {
+			fmt.Printf("\t%s\t%s\n", noteID, tuningOptions[noteID].Name())
+		}
+	} else {
+		fmt.Println("There are no manually enabled notes to revert.")
+	}
+	if len(skippedNotes) > 0 {
+		fmt.Println("The following notes are skipped, because an enabled solution still refers to them:")
+		for _, noteID := range skippedNotes {
+			fmt.Printf("\t%s\t%s\n", noteID, tuningOptions[noteID].Name())
+		}
+	}
+	if err != nil {
+		errorExit("%v", err)
+	}
+}
+
+/*
+Verify the specified note, or all enabled notes if note ID is empty, and tell parameters of enabled notes that only
+need a reboot to take effect apart from the genuinely deviating ones.
+Exit with ExitRebootPending if all deviations are pending a reboot, or 1 if any parameter genuinely deviates.
+*/
+func VerifyPendingReboot(noteID string) {
+	noteIDs := tuneApp.GetSortedAllEnabledNotes()
+	if noteID != "" {
+		noteIDs = []string{noteID}
+	}
+	enabledNotes := tuneApp.GetSortedAllEnabledNotes()
+	pendingReboot := make(map[string]map[string]note.NoteFieldComparison)
+	deviating := make(map[string]map[string]note.NoteFieldComparison)
+	for _, id := range noteIDs {
+		_, comparisons, err := tuneApp.VerifyNote(id)
+		if err != nil {
+			errorExit("Failed to test the current system against note %s: %v", id, err)
+		}
+		i := sort.SearchStrings(enabledNotes, id)
+		isEnabled := i < len(enabledNotes) && enabledNotes[i] == id
+		for name, comparison := range comparisons {
+			if comparison.MatchExpectation {
+				continue
+			}
+			// Only the parameters of an applied note are correct in its saved state and just waiting for a reboot
+			target := deviating
+			if isEnabled && tuneApp.State.IsSaved(id) && note.IsRebootRequired(tuningOptions[id], comparison) {
+				target = pendingReboot
+			}
+			if target[id] == nil {
+				target[id] = make(map[string]note.NoteFieldComparison)
+			}
+			target[id][name] = comparison
+		}
+	}
+	if len(pendingReboot) > 0 {
+		fmt.Println("The following parameters need a reboot to take effect:")
+		for _, id := range noteIDs {
+			if comparisons, found := pendingReboot[id]; found {
+				PrintNoteFields(id, comparisons, true)
+			}
+		}
+	}
+	if len(deviating) > 0 {
+		fmt.Println("The following parameters have deviated from SAP/SUSE recommendations:")
+		for _, id := range noteIDs {
+			if comparisons, found := deviating[id]; found {
+				PrintNoteFields(id, comparisons, true)
+			}
+		}
+		errorExit("The parameters listed above as deviated are genuinely wrong.")
+	}
+	if len(pendingReboot) > 0 {
+		fmt.Fprintln(os.Stderr, "The system will conform to the notes after a reboot.")
+		os.Exit(ExitRebootPending)
+	}
+	fmt.Println("The system fully conforms to the notes, no parameter is pending a reboot.")
+}
+
+/*
+Exit with an error if saptune does not recognise the note ID. If the ID is a solution name, suggest the equivalent
+solution action, otherwise suggest similar note IDs if there are any.
+*/
+func requireNoteID(actionName, noteID string) {
+	if _, err := tuneApp.GetNoteByID(noteID); err != nil {
+		if _, isComposite := compositeSolutions[noteID]; hasSolutionAction(actionName) && isComposite {
+			errorExit("%s is a solution rather than a note. Did you mean: saptune solution %s %s", noteID, actionName, noteID)
+		} else if _, solErr := tuneApp.GetSolutionByName(noteID); hasSolutionAction(actionName) && solErr == nil {
+			errorExit("%s is a solution rather than a note. Did you mean: saptune solution %s %s", noteID, actionName, noteID)
+		}
+		candidates := make([]string, 0, len(tuningOptions))
+		for _, id := range tuningOptions.GetSortedIDs() {
+			if id != "Block" {
+				candidates = append(candidates, id)
+			}
+		}
+		if similar := app.GetSimilar(noteID, candidates); len(similar) > 0 {
+			errorExit("%v\nDid you mean: %s", err, strings.Join(similar, ", "))
+		}
+		errorExit("%v", err)
+	}
+}
+
+/*
+Exit with an error if saptune does not recognise the solution name. If the name is a note ID, suggest the equivalent
+note action, otherwise suggest similar names if there are any.
+*/
+func requireSolutionName(actionName, solName string) {
+	if _, err := tuneApp.GetSolutionByName(solName); err != nil {
+		if _, isNote := tuningOptions[solName]; hasSolutionAction(actionName) && isNote && solName != "Block" {
+			errorExit("%s is a note rather than a solution. Did you mean: saptune note %s %s", solName, actionName, solName)
+		}
+		candidates := append(solution.GetSortedSolutionNames(solutionSelector), solution.GetSortedCompositeNames(compositeSolutions)...)
+		if similar := app.GetSimilar(solName, candidates); len(similar) > 0 {
+			errorExit("%v\nDid you mean: %s", err, strings.Join(similar, ", "))
+		}
+		errorExit("%v", err)
+	}
+}
+
+// If requested by --persist=sysctl, write the sysctl parameters of the applied note into a sysctl drop-in file.
+func persistNoteSysctl(noteID string) {
+	if cliFlagValue("persist") != "sysctl" {
+		return
+	}
+	persisted, notPersisted, err := tuneApp.PersistNoteSysctl(noteID)
+	if err != nil {
+		errorExit("Failed to write sysctl drop-in file for note %s: %v", noteID, err)
+	}
+	if len(notPersisted) > 0 {
+		fmt.Println("The following parameters are not sysctl parameters, they cannot be persisted in a sysctl drop-in file:")
+		for _, name := range notPersisted {
+			fmt.Printf("\t%s\n", name)
+		}
+	}
+	if len(persisted) == 0 {
+		fmt.Println("The note has no sysctl parameters, nothing is persisted.")
+		return
+	}
+	fmt.Printf("The sysctl parameters of the note are persisted in %s.\n", tuneApp.GetSysctlDropInPath(noteID))
+}
+
+// Return true only if the action is available both as a note action and as a solution action.
+func hasSolutionAction(actionName string) bool {
+	switch actionName {
+	case "apply", "verify", "simulate", "revert":
+		return true
+	}
+	return false
+}
+
+/*
+Write the customisation values given by --set=KEY=VALUE or --set KEY=VALUE (may repeat) and --from-json=PATH into the customisation file
+of the note, without launching an editor. Values given by --set take precedence over those from the JSON file.
+*/
+func CustomiseNonInteractive(noteID string) {
+	values := make(map[string]string)
+	if filePath := cliFlagValue("from-json"); filePath != "" {
+		content, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			errorExit("Failed to read file '%s' - %v", filePath, err)
+		}
+		if values, err = app.ParseCustomiseJSON(content); err != nil {
+			errorExit("Failed to parse JSON file '%s' - %v", filePath, err)
+		}
+	}
+	for _, keyValue := range cliFlagValues("set") {
+		fields := strings.SplitN(keyValue, "=", 2)
+		if len(fields) != 2 || fields[0] == "" {
+			errorExit("The value of --set must be KEY=VALUE, \"%s\" is not.", keyValue)
+		}
+		values[fields[0]] = fields[1]
+	}
+	if len(values) == 0 {
+		PrintHelpAndExit(ExitFailure)
+	}
+	if err := tuneApp.CustomiseNote(noteID, values); err != nil {
+		errorExit("Failed to customise note %s: %v", noteID, err)
+	}
+	fmt.Printf("%d values have been written to %s. They take effect when the note is applied again.\n", len(values), tuneApp.GetCustomiseFilePath(noteID))
+}
+
+/*
+Remove the customisation of the note and report the values that have been cleared. If the note is enabled, offer to
+re-apply it with its default values right away.
+*/
+func ResetCustomisation(noteID string) {
+	fileName := tuneApp.GetCustomiseFilePath(noteID)
+	cleared, err := tuneApp.ResetCustomisation(noteID)
+	if err != nil {
+		errorExit("Failed to reset the customisation of note %s: %v", noteID, err)
+	}
+	if len(cleared) == 0 {
+		fmt.Printf("Note %s is not customised.\n", noteID)
+		return
+	}
+	keys := make([]string, 0, len(cleared))
+	for key := range cleared {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Printf("The following values have been cleared by removing %s:\n", fileName)
+	for _, key := range keys {
+		fmt.Printf("\t%s=\"%s\"\n", key, cleared[key])
+	}
+	enabled := false
+	for _, id := range tuneApp.GetSortedAllEnabledNotes() {
+		if id == noteID {
+			enabled = true
+		}
+	}
+	if !enabled {
+		return
+	}
+	if !confirm(fmt.Sprintf("Note %s is enabled. Re-apply it with its default values now?", noteID)) {
+		fmt.Printf("The default values take effect when the note is applied again, e.g. by 'saptune note refresh %s'.\n", noteID)
+		return
+	}
+	changes, err := tuneApp.RefreshNote(noteID)
+	if err != nil {
+		errorExit("Failed to re-apply note %s: %v", noteID, err)
+	}
+	fmt.Println("Fields changed by re-applying the note:")
+	PrintNoteFields(noteID, changes, true)
+}
+
+// Print a notice if the note is deprecated, suggesting the note that replaces it.
+func warnDeprecatedNote(noteID string, aNote note.Note) {
+	if replacement := note.GetReplacement(aNote); replacement != "" {
+		fmt.Fprintf(os.Stderr, "Notice: note %s is deprecated and replaced by note %s. It is still applied for compatibility, please consider applying note %s instead.\n", noteID, replacement, replacement)
+	}
+}
+
+/*
+With --matching-version, report that the note does not suit the installed SAP product version and return true, the
+note is then not to be applied.
+*/
+func skipUnmatchedVersion(noteID string, aNote note.Note) bool {
+	if !cliFlag("matching-version") {
+		return false
+	}
+	if err := note.CheckProductVersion(aNote, tuneApp.SAPProductVersion); err != nil {
+		fmt.Printf("Note %s has not been applied: %v.\n", noteID, err)
+		return true
+	}
+	return false
+}
+
+/*
+Report that a software package required by the note is not installed and return true, the note is then not to be
+applied. With --ignore-packages or --force, the note is applied nevertheless.
+*/
+func skipMissingPackages(noteID string, aNote note.Note) bool {
+	if tuneApp.IgnorePackages {
+		return false
+	}
+	if err := note.CheckRequiredPackages(aNote); err != nil {
+		fmt.Printf("Note %s: %v, skipping. Use --ignore-packages or --force to apply it nevertheless.\n", noteID, err)
+		return true
+	}
+	return false
+}
+
+/*
+Apply the note and schedule its automatic revert once the TTL has elapsed. Nothing is applied if the revert cannot be
+scheduled.
+*/
+func ApplyNoteWithTTL(noteID, ttlValue string) {
+	ttl, err := time.ParseDuration(ttlValue)
+	if err != nil || ttl <= 0 {
+		errorExit("The value of --ttl must be a positive duration such as 2h, \"%s\" is not.", ttlValue)
+	}
+	expiry, err := tuneApp.TuneNoteWithTTL(noteID, ttl)
+	if err != nil {
+		errorExit("Failed to tune for note %s: %v", noteID, err)
+	}
+	fmt.Printf("The note has been applied successfully, it will be reverted automatically at %s.\n", expiry.Format(time.RFC3339))
+	persistNoteSysctl(noteID)
+	startDaemonAfterApply()
+}
+
+// Print the notes that will be reverted automatically once their TTL has elapsed, if there are any.
+func printPendingReverts() {
+	pending, err := tuneApp.GetPendingReverts()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read the pending automatic reverts: %v\n", err)
+		return
+	} else if len(pending) == 0 {
+		return
+	}
+	fmt.Println("The following notes will be reverted automatically:")
+	noteIDs := make([]string, 0, len(pending))
+	for noteID := range pending {
+		noteIDs = append(noteIDs, noteID)
+	}
+	sort.Strings(noteIDs)
+	for _, noteID := range noteIDs {
+		fmt.Printf("\t%s\tat %s\n", noteID, pending[noteID].Format(time.RFC3339))
+	}
+}
+
+// Re-apply the enabled note (or all enabled notes if note ID is "all") and report the fields that changed.
+func RefreshNotes(noteID string) {
+	noteIDs := []string{noteID}
+	if noteID == "all" {
+		noteIDs = tuneApp.GetSortedAllEnabledNotes()
+	}
+	failed := false
+	for _, id := range noteIDs {
+		changes, err := tuneApp.RefreshNote(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to refresh note %s: %v\n", id, err)
+			failed = true
+			continue
+		}
+		fmt.Println("Fields changed since the note was last applied:")
+		PrintNoteFields(id, changes, true)
+	}
+	if failed {
+		errorExit("Failed to refresh one or more notes.")
 	}
 }
 
-func DaemonAction(actionName string) {
+/*
+List the notes whose definition changed since it was released, compare the released and the current definition of a
+note, or release the current definition into use.
+*/
+func StagingAction(actionName, noteID string) {
+	if !tuneApp.NoteStaging {
+		fmt.Fprintf(os.Stderr, "Notice: %s is not enabled in %s, updated note definitions are not held back.\n", app.NoteStagingKey, app.SysconfigSaptuneDir)
+	}
 	switch actionName {
-	case "start":
-		fmt.Println("Starting daemon (tuned.service), this may take several seconds...")
-		system.SystemctlDisableStop(SapconfService) // do not error exit on failure
-		if err := system.WriteTunedAdmProfile("saptune"); err != nil {
-			errorExit("%v", err)
+	case "list":
+		allChanges, noteIDs := getStagedDefinitions()
+		if len(noteIDs) == 0 {
+			fmt.Println("No note definition is waiting to be released.")
+			return
 		}
-		if err := system.SystemctlEnableStart(TunedService); err != nil {
-			errorExit("%v", err)
+		fmt.Println("The definitions of the following notes are waiting to be released:")
+		for _, noteID := range noteIDs {
+			fmt.Printf("\t%s\t%d parameters changed\n", noteID, len(allChanges[noteID]))
 		}
-		// tuned then calls `sapconf daemon apply`
-		fmt.Println("Daemon (tuned.service) has been enabled and started.")
-		if len(tuneApp.TuneForSolutions) == 0 && len(tuneApp.TuneForNotes) == 0 {
-			fmt.Println("Your system has not yet been tuned. Please visit `saptune note` and `saptune solution` to start tuning.")
+		fmt.Println("Run `saptune staging diff NoteID` to compare them, and `saptune staging release NoteID` to take them into use.")
+	case "diff":
+		noteIDs := []string{noteID}
+		if noteID == "" {
+			_, noteIDs = getStagedDefinitions()
 		}
-	case "apply":
-		// This action name is only used by tuned script, hence it is not advertised to end user.
-		if err := tuneApp.TuneAll(); err != nil {
-			panic(err)
+		for _, id := range noteIDs {
+			changes, err := tuneApp.GetDefinitionChanges(id)
+			if err != nil {
+				errorExit("%v", err)
+			}
+			fmt.Printf("Note %s:\n", id)
+			if len(changes) == 0 {
+				fmt.Println("\tThe released definition is current.")
+			}
+			for _, change := range changes {
+				fmt.Printf("\t%s\t%s\treleased: %s\tstaged: %s\n", change.Param, change.Kind, change.Released, change.Staged)
+			}
 		}
-	case "status":
-		// Check daemon
-		if system.SystemctlIsRunning(TunedService) {
-			fmt.Println("Daemon (tuned.service) is running.")
-		} else {
-			fmt.Fprintln(os.Stderr, "Daemon (tuned.service) is stopped. If you wish to start the daemon, run `saptune daemon start`.")
-			os.Exit(ExitTunedStopped)
+	case "release":
+		if noteID == "" {
+			PrintHelpAndExit(ExitFailure)
 		}
-		// Check tuned profile
-		if system.GetTunedProfile() != TunedProfileName {
-			fmt.Fprintln(os.Stderr, "tuned.service profile is incorrect. If you wish to correct it, run `saptune daemon start`.")
-			os.Exit(ExitTunedWrongProfile)
+		noteIDs := []string{noteID}
+		if noteID == "all" {
+			_, noteIDs = getStagedDefinitions()
 		}
-		// Check for any enabled note/solution
-		if len(tuneApp.TuneForSolutions) > 0 || len(tuneApp.TuneForNotes) > 0 {
-			fmt.Println("The system has been tuned for the following solutions and notes:")
-			for _, sol := range tuneApp.TuneForSolutions {
-				fmt.Println("\t" + sol)
+		failed := false
+		for _, id := range noteIDs {
+			changes, err := tuneApp.ReleaseNote(id)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to release note %s: %v\n", id, err)
+				failed = true
+				continue
 			}
-			for _, noteID := range tuneApp.TuneForNotes {
-				fmt.Println("\t" + noteID)
+			fmt.Printf("The current definition of note %s has been released.\n", id)
+			if len(changes) > 0 {
+				fmt.Println("Fields changed by re-applying the note:")
+				PrintNoteFields(id, changes, true)
 			}
-		} else {
-			fmt.Fprintln(os.Stderr, "Your system has not yet been tuned. Please visit `saptune note` and `saptune solution` to start tuning.")
-			os.Exit(ExitNotTuned)
-		}
-	case "stop":
-		fmt.Println("Stopping daemon (tuned.service), this may take several seconds...")
-		if err := system.SystemctlDisableStop(TunedService); err != nil {
-			errorExit("%v", err)
 		}
-		// tuned then calls `sapconf daemon revert`
-		fmt.Println("Daemon (tuned.service) has been disabled and stopped.")
-		fmt.Println("All tuned parameters have been reverted to default.")
-	case "revert":
-		// This action name is only used by tuned script, hence it is not advertised to end user.
-		if err := tuneApp.RevertAll(false); err != nil {
-			panic(err)
+		if failed {
+			errorExit("Failed to release one or more notes.")
 		}
 	default:
-		PrintHelpAndExit(1)
-	}
-}
-
-// Print mismatching fields in the note comparison result.
-func PrintNoteFields(noteID string, comparisons map[string]note.NoteFieldComparison, printComparison bool) {
-	fmt.Printf("%s - %s -\n", noteID, tuningOptions[noteID].Name())
-	hasDiff := false
-	for name, comparison := range comparisons {
-		if !comparison.MatchExpectation {
-			hasDiff = true
-			if printComparison {
-				fmt.Printf("\t%s Expected: %s\n", name, comparison.ExpectedValueJS)
-				fmt.Printf("\t%s Actual  : %s\n", name, comparison.ActualValueJS)
-			} else {
-				fmt.Printf("\t%s : %s\n", name, comparison.ExpectedValueJS)
-			}
-		}
-	}
-	if !hasDiff {
-		fmt.Printf("\t(no change)\n")
+		PrintHelpAndExit(ExitFailure)
 	}
 }
 
-// Verify that all system parameters do not deviate from any of the enabled solutions/notes.
-func VerifyAllParameters() {
-	unsatisfiedNotes, comparisons, err := tuneApp.VerifyAll()
+// Return the changes of the note definitions waiting to be released, and the IDs of their notes sorted.
+func getStagedDefinitions() (map[string][]app.DefinitionChange, []string) {
+	allChanges, err := tuneApp.GetAllDefinitionChanges()
 	if err != nil {
-		errorExit("Failed to inspect the current system: %v", err)
+		errorExit("Failed to compare the note definitions: %v", err)
 	}
-	if len(unsatisfiedNotes) == 0 {
-		fmt.Println("The running system is currently well-tuned according to all of the enabled notes.")
-	} else {
-		for _, unsatisfiedNoteID := range unsatisfiedNotes {
-			PrintNoteFields(unsatisfiedNoteID, comparisons[unsatisfiedNoteID], true)
-		}
-		errorExit("The parameters listed above have deviated from SAP/SUSE recommendations.")
+	noteIDs := make([]string, 0, len(allChanges))
+	for noteID := range allChanges {
+		noteIDs = append(noteIDs, noteID)
 	}
+	sort.Strings(noteIDs)
+	return allChanges, noteIDs
 }
 
 func NoteAction(actionName, noteID string) {
+	switch actionName {
+	case "apply", "verify", "simulate", "customise", "revert", "refresh", "enable", "disable", "owner", "pin", "unpin", "validate":
+		// The pins of a note that is no longer defined may still be removed
+		if actionName == "unpin" && noteID != "" {
+			if pins, _ := tuneApp.State.GetPins(noteID); len(pins) > 0 {
+				break
+			}
+		}
+		if noteID != "" && !(actionName == "refresh" && noteID == "all") {
+			requireNoteID(actionName, noteID)
+		}
+	}
 	switch actionName {
 	case "apply":
+		if persist := cliFlagValue("persist"); persist != "" && persist != "sysctl" {
+			errorExit("The value of --persist must be \"sysctl\", \"%s\" is not supported.", persist)
+		} else if persist != "" && cliFlag("no-save") {
+			errorExit("--persist and --no-save cannot be used together.")
+		} else if cliFlag("if-changed") && (cliFlag("no-save") || cliFlag("reverse-on-verify-fail") || cliFlagValue("from-file") != "") {
+			errorExit("--if-changed cannot be used together with --no-save, --reverse-on-verify-fail, or --from-file.")
+		} else if cliFlagValue("ttl") != "" && (cliFlag("no-save") || cliFlag("if-changed") || cliFlag("reverse-on-verify-fail") || cliFlagValue("from-file") != "") {
+			errorExit("--ttl cannot be used together with --no-save, --if-changed, --reverse-on-verify-fail, or --from-file.")
+		} else if cliFlagValue("ttl") != "" && !isLiveRoot() {
+			errorExit("--ttl requires the live system, it is not available together with --root.")
+		}
+		checkThenStartDaemon()
+		if backupDir := cliFlagValue("backup-dir"); backupDir != "" {
+			tuneApp.BackupDir = backupDir
+		}
+		if filePath := cliFlagValue("from-file"); filePath != "" {
+			adHocNote, err := note.LoadINISettingsFile(filePath, tuningOptions)
+			if err != nil {
+				errorExit("Failed to load note definition from %s: %v", filePath, err)
+			}
+			noteID = adHocNote.ID
+			warnDeprecatedNote(noteID, adHocNote)
+			if skipUnmatchedVersion(noteID, adHocNote) || skipMissingPackages(noteID, adHocNote) {
+				return
+			}
+			if cliFlag("no-save") {
+				if _, exists := tuningOptions[noteID]; exists {
+					errorExit("Note ID \"%s\" is already defined by saptune, please choose a different ID.", noteID)
+				}
+				tuningOptions[noteID] = adHocNote
+				if err := tuneApp.TuneNoteEphemeral(noteID); err != nil {
+					errorExit("Failed to tune for note %s: %v", noteID, err)
+				}
+				fmt.Printf("The note has been applied successfully as note %s, it has not been saved.\n", noteID)
+				return
+			}
+			if err := tuneApp.TuneAdHocNote(noteID, filePath, adHocNote); err != nil {
+				errorExit("Failed to tune for note %s: %v", noteID, err)
+			}
+			fmt.Printf("The note has been applied successfully as note %s.\n", noteID)
+			persistNoteSysctl(noteID)
+			if cliFlag("then-start-daemon") {
+				startDaemonAfterApply()
+			}
+			return
+		}
 		if noteID == "" {
-			PrintHelpAndExit(1)
+			PrintHelpAndExit(ExitFailure)
 		}
-		if err := tuneApp.TuneNote(noteID); err != nil {
+		warnDeprecatedNote(noteID, tuningOptions[noteID])
+		if skipUnmatchedVersion(noteID, tuningOptions[noteID]) || skipMissingPackages(noteID, tuningOptions[noteID]) {
+			return
+		}
+		if cliFlag("no-save") {
+			if err := tuneApp.TuneNoteEphemeral(noteID); err != nil {
+				errorExit("Failed to tune for note %s: %v", noteID, err)
+			}
+			fmt.Println("The note has been applied successfully. It has not been saved, hence it can neither be reverted nor will it be applied upon boot.")
+			return
+		}
+		if ttl := cliFlagValue("ttl"); ttl != "" {
+			ApplyNoteWithTTL(noteID, ttl)
+			return
+		}
+		if cliFlag("reverse-on-verify-fail") {
+			deviations, err := tuneApp.TuneNoteVerified(noteID)
+			if len(deviations) > 0 {
+				PrintNoteFields(noteID, deviations, true)
+				if err != nil {
+					errorExit("The parameters listed above did not take effect, and reverting the note failed: %v", err)
+				}
+				errorExit("The parameters listed above did not take effect, hence the note has been reverted.")
+			} else if err != nil {
+				errorExit("Failed to tune for note %s: %v", noteID, err)
+			}
+		} else if cliFlag("if-changed") {
+			readback, skipped, err := tuneApp.TuneNoteIfChanged(noteID)
+			if err != nil {
+				errorExit("Failed to tune for note %s: %v", noteID, err)
+			}
+			PrintReadbackProblems(noteID, readback)
+			if len(skipped) > 0 {
+				fmt.Printf("%d parameters already had the desired value, they have not been written.\n", len(skipped))
+			}
+		} else if readback, err := tuneApp.TuneNoteReadback(noteID); err != nil {
 			errorExit("Failed to tune for note %s: %v", noteID, err)
+		} else {
+			PrintReadbackProblems(noteID, readback)
 		}
 		fmt.Println("The note has been applied successfully.")
-		if !system.SystemctlIsRunning(TunedService) || system.GetTunedProfile() != TunedProfileName {
-			fmt.Println("\nRemember: if you wish to automatically activate the solution's tuning options after a reboot," +
-				"you must instruct saptune to configure \"tuned\" daemon by running:" +
-				"\n    saptune daemon start")
-		}
+		persistNoteSysctl(noteID)
+		startDaemonAfterApply()
 	case "list":
-		fmt.Println("All notes (+ denotes manually enabled notes, * denotes notes enabled by solutions):")
+		if cliFlag("diff-from-defaults") {
+			PrintLocalModifications()
+			return
+		}
+		enabledOnly, disabledOnly := cliFlag("enabled-only"), cliFlag("disabled-only")
+		if enabledOnly && disabledOnly {
+			errorExit("--enabled-only and --disabled-only cannot be used together.")
+		} else if cliFlagValue("format") == "json" {
+			PrintNoteListJSON(enabledOnly, disabledOnly)
+			printDaemonReminder()
+			return
+		} else if enabledOnly {
+			fmt.Println("Enabled notes (+ denotes manually enabled notes, * denotes notes enabled by solutions):")
+		} else if disabledOnly {
+			fmt.Println("Notes that are not enabled:")
+		} else {
+			fmt.Println("All notes (+ denotes manually enabled notes, * denotes notes enabled by solutions):")
+		}
 		solutionNoteIDs := tuneApp.GetSortedSolutionEnabledNotes()
 		for _, noteID := range tuningOptions.GetSortedIDs() {
 			noteObj := tuningOptions[noteID]
 			format := "\t%s\t%s\n"
+			enabled := true
 			if i := sort.SearchStrings(solutionNoteIDs, noteID); i < len(solutionNoteIDs) && solutionNoteIDs[i] == noteID {
 				format = "*" + format
 			} else if i := sort.SearchStrings(tuneApp.TuneForNotes, noteID); i < len(tuneApp.TuneForNotes) && tuneApp.TuneForNotes[i] == noteID {
 				format = "+" + format
+			} else {
+				enabled = false
 			}
 			if noteID == "Block" {
 				// workaround: internal used note for solution ASE. Do not display
 				continue
 			}
-			fmt.Printf(format, noteID, noteObj.Name())
-		}
-		if !system.SystemctlIsRunning(TunedService) || system.GetTunedProfile() != TunedProfileName {
-			fmt.Println("\nRemember: if you wish to automatically activate the solution's tuning options after a reboot," +
-				"you must instruct saptune to configure \"tuned\" daemon by running:" +
-				"\n    saptune daemon start")
+			if (enabledOnly && !enabled) || (disabledOnly && enabled) {
+				continue
+			}
+			name := noteObj.Name()
+			if replacement := note.GetReplacement(noteObj); replacement != "" {
+				name += fmt.Sprintf(" (deprecated, replaced by %s)", replacement)
+			}
+			if tuneApp.IsNoteOverridden(noteID) {
+				name += fmt.Sprintf(" (overridden by %s)", tuneApp.GetOverrideFilePath(noteID))
+			}
+			fmt.Printf(format, noteID, name)
 		}
+		printDaemonReminder()
 	case "verify":
-		if noteID == "" {
+		if filePath := cliFlagValue("snapshot"); filePath != "" {
+			VerifySnapshot(filePath, noteID)
+		} else if cliFlag("pending-reboot") {
+			VerifyPendingReboot(noteID)
+		} else if filePath := cliFlagValue("list-file"); filePath != "" && noteID == "" {
+			VerifyListedNotes(filePath)
+		} else if noteID == "" {
 			VerifyAllParameters()
 		} else {
 			// Check system parameters against the specified note, no matter the note has been tuned for or not.
-			if conforming, comparisons, err := tuneApp.VerifyNote(noteID); err != nil {
-				errorExit("Failed to test the current system against the specified note: %v", err)
-			} else if !conforming {
-				PrintNoteFields(noteID, comparisons, true)
-				errorExit("The parameters listed above have deviated from the specified note.\n")
-			} else {
-				fmt.Println("The system fully conforms to the specified note.")
-			}
+			VerifySingleNote(noteID)
 		}
 	case "simulate":
+		if noteID == "" && cliFlag("all") {
+			SimulateAllNotes()
+			return
+		}
 		if noteID == "" {
-			PrintHelpAndExit(1)
+			PrintHelpAndExit(ExitFailure)
 		}
 		// Run verify and print out all fields of the note
 		if _, comparisons, err := tuneApp.VerifyNote(noteID); err != nil {
 			errorExit("Failed to test the current system against the specified note: %v", err)
+		} else if isStructuredOutput() {
+			PrintStructuredComparisons(map[string]map[string]note.NoteFieldComparison{noteID: comparisons})
 		} else {
 			fmt.Printf("If you run `saptune note apply %s`, the following changes will be applied to your system:\n", noteID)
 			PrintNoteFields(noteID, comparisons, false)
 		}
+	case "refresh":
+		if noteID == "" {
+			PrintHelpAndExit(ExitFailure)
+		}
+		RefreshNotes(noteID)
 	case "customise":
 		if noteID == "" {
-			PrintHelpAndExit(1)
+			PrintHelpAndExit(ExitFailure)
 		}
 		if _, err := tuneApp.GetNoteByID(noteID); err != nil {
 			errorExit("%v", err)
 		}
-		fileName := fmt.Sprintf("/etc/sysconfig/saptune-note-%s", noteID)
+		if cliFlag("reset") {
+			ResetCustomisation(noteID)
+			return
+		}
+		if cliFlag("set") || cliFlag("from-json") {
+			CustomiseNonInteractive(noteID)
+			return
+		}
+		fileName := tuneApp.GetCustomiseFilePath(noteID)
 		if _, err := os.Stat(fileName); os.IsNotExist(err) {
-			errorExit("Note %s does not require additional customisation input.", noteID)
+			// Any note may override the values of its parameters
+			header := strings.Join(note.CustomiseFileHeader(noteID), "\n") + "\n"
+			if err := os.MkdirAll(path.Dir(fileName), 0755); err != nil {
+				errorExit("Failed to create file '%s' - %v", fileName, err)
+			} else if err := ioutil.WriteFile(fileName, []byte(header), 0644); err != nil {
+				errorExit("Failed to create file '%s' - %v", fileName, err)
+			}
 		} else if err != nil {
 			errorExit("Failed to read file '%s' - %v", fileName, err)
 		}
@@ -280,41 +2794,166 @@ func NoteAction(actionName, noteID string) {
 			errorExit("Failed to start launch editor %s: %v", editor, err)
 		}
 	case "revert":
+		if noteID == "" && cliFlag("all-manual") {
+			RevertManualNotes()
+			return
+		}
 		if noteID == "" {
-			PrintHelpAndExit(1)
+			PrintHelpAndExit(ExitFailure)
 		}
-		if err := tuneApp.RevertNote(noteID, true); err != nil {
+		if cliFlag("verify") {
+			if cliFlagValue("from-backup") != "" {
+				errorExit("--verify and --from-backup cannot be used together.")
+			}
+			deviations, err := tuneApp.RevertNoteVerified(noteID)
+			if err != nil {
+				errorExit("Failed to revert note %s: %v", noteID, err)
+			} else if len(deviations) > 0 {
+				PrintNoteFields(noteID, deviations, true)
+				errorExit("The note has been reverted, but the parameters listed above did not return to the values saved before the note was applied.")
+			}
+			fmt.Println("Parameters tuned by the note have been successfully reverted, they have returned to the values saved before the note was applied.")
+		} else if backupPath := cliFlagValue("from-backup"); backupPath != "" {
+			if err := tuneApp.RevertNoteFromBackup(noteID, backupPath, true); err != nil {
+				errorExit("Failed to revert note %s: %v", noteID, err)
+			}
+			fmt.Printf("Parameters tuned by the note have been restored from backup file %s.\n", backupPath)
+		} else if err := tuneApp.RevertNote(noteID, true); err != nil {
 			errorExit("Failed to revert note %s: %v", noteID, err)
+		} else {
+			fmt.Println("Parameters tuned by the note have been successfully reverted.")
 		}
-		fmt.Println("Parameters tuned by the note have been successfully reverted.")
 		fmt.Println("Please note: the reverted note may still show up in list of enabled notes, if an enabled solution refers to it.")
+	case "fetch":
+		source, configured := app.GetRemoteSheetSource(rootPrefix, rootPrefix)
+		if !configured {
+			errorExit("No note source is configured, please set %s in /etc/sysconfig/saptune.", app.NoteSourceURLKey)
+		}
+		if err := source.Fetch(); err != nil {
+			errorExit("Failed to fetch tuning sheets, the sheets last fetched into %s remain in use: %v", source.CacheDir, err)
+		}
+		fmt.Printf("The tuning sheets have been fetched from %s into %s.\n", source.URL, source.CacheDir)
+	case "prune":
+		prunedNotes, err := tuneApp.PruneDanglingNotes()
+		for _, prunedNoteID := range prunedNotes {
+			fmt.Printf("Note %s is no longer defined, it has been removed.\n", prunedNoteID)
+		}
+		if err != nil {
+			errorExit("Failed to remove notes that are no longer defined: %v", err)
+		}
+		if len(prunedNotes) == 0 {
+			fmt.Println("All enabled and saved notes are defined, there is nothing to remove.")
+		}
+	case "owner":
+		if noteID == "" {
+			PrintHelpAndExit(ExitFailure)
+		}
+		PrintNoteSources(noteID)
+	case "validate":
+		ValidateNotes(noteID)
+	case "pin":
+		paramName, value := cliFlagValue("param"), cliFlagValue("value")
+		if noteID == "" || paramName == "" || !cliFlag("value") {
+			PrintHelpAndExit(ExitFailure)
+		}
+		if err := tuneApp.PinParameter(noteID, paramName, value); err != nil {
+			errorExit("Failed to pin parameter %s of note %s: %v", paramName, noteID, err)
+		}
+		fmt.Printf("Parameter %s of note %s has been pinned to \"%s\", regardless of the note definition.\n", paramName, noteID, value)
+		fmt.Printf("The pin takes effect when the note is applied again, e.g. by `saptune note refresh %s`.\n", noteID)
+	case "unpin":
+		paramName := cliFlagValue("param")
+		if noteID == "" || paramName == "" {
+			PrintHelpAndExit(ExitFailure)
+		}
+		if err := tuneApp.UnpinParameter(noteID, paramName); err != nil {
+			errorExit("Failed to unpin parameter %s of note %s: %v", paramName, noteID, err)
+		}
+		fmt.Printf("Parameter %s of note %s follows the note definition again.\n", paramName, noteID)
+		fmt.Printf("This takes effect when the note is applied again, e.g. by `saptune note refresh %s`.\n", noteID)
+	case "enable", "disable":
+		if noteID == "" {
+			PrintHelpAndExit(ExitFailure)
+		}
+		if err := tuneApp.StageNote(noteID, actionName == "enable"); err != nil {
+			errorExit("Failed to stage note %s: %v", noteID, err)
+		}
+		fmt.Printf("The note has been staged to be %sd. Run `saptune apply staged` to apply the staged notes.\n", actionName)
 	default:
-		PrintHelpAndExit(1)
+		PrintHelpAndExit(ExitFailure)
 	}
 }
 
 func SolutionAction(actionName, solName string) {
+	if composite, isComposite := compositeSolutions[solName]; isComposite {
+		CompositeSolutionAction(actionName, solName, composite)
+		return
+	}
+	switch actionName {
+	case "apply", "verify", "simulate", "revert":
+		if solName != "" {
+			requireSolutionName(actionName, solName)
+		}
+	}
 	switch actionName {
 	case "apply":
 		if solName == "" {
-			PrintHelpAndExit(1)
+			PrintHelpAndExit(ExitFailure)
+		}
+		checkThenStartDaemon()
+		// The exclusions given along only take effect once the solution has been tuned
+		previousExclusions := tuneApp.SolutionExclusions[solName]
+		if cliFlag("exclude") {
+			// An empty list clears the exclusions, otherwise the recorded exclusions remain in effect
+			excludedNotes := []string{}
+			if value := cliFlagValue("exclude"); value != "" {
+				excludedNotes = strings.Split(value, ",")
+			}
+			notInSolution, err := tuneApp.SetSolutionExclusions(solName, excludedNotes)
+			if err != nil {
+				errorExit("%v", err)
+			}
+			for _, noteID := range notInSolution {
+				fmt.Fprintf(os.Stderr, "Warning: note %s is not part of solution %s, hence it is not excluded.\n", noteID, solName)
+			}
+		}
+		if cliFlag("matching-version") {
+			filtered, err := tuneApp.SkipByProductVersion(solName)
+			if err != nil {
+				errorExit("%v", err)
+			}
+			filteredIDs := make([]string, 0, len(filtered))
+			for noteID := range filtered {
+				filteredIDs = append(filteredIDs, noteID)
+			}
+			sort.Strings(filteredIDs)
+			for _, noteID := range filteredIDs {
+				fmt.Printf("Note %s is left out of the solution: %s.\n", noteID, filtered[noteID])
+			}
 		}
+		tuneApp.ReadbackReporter = PrintReadbackProblems
 		removedAdditionalNotes, err := tuneApp.TuneSolution(solName)
 		if err != nil {
+			if _, restoreErr := tuneApp.SetSolutionExclusions(solName, previousExclusions); restoreErr == nil {
+				tuneApp.SaveConfig()
+			}
 			errorExit("Failed to tune for solution %s: %v", solName, err)
 		}
+		tuneApp.SolutionSelector = solutionSelector
+		if err := tuneApp.SaveConfig(); err != nil {
+			errorExit("Failed to save configuration: %v", err)
+		}
 		fmt.Println("All tuning options for the SAP solution have been applied successfully.")
+		if excludedNotes := tuneApp.SolutionExclusions[solName]; len(excludedNotes) > 0 {
+			fmt.Printf("The following notes are excluded from the SAP solution: %s\n", strings.Join(excludedNotes, ", "))
+		}
 		if len(removedAdditionalNotes) > 0 {
 			fmt.Println("The following previously-enabled notes are now tuned by the SAP solution:")
 			for _, noteNumber := range removedAdditionalNotes {
 				fmt.Printf("\t%s\t%s\n", noteNumber, tuningOptions[noteNumber].Name())
 			}
 		}
-		if !system.SystemctlIsRunning(TunedService) || system.GetTunedProfile() != TunedProfileName {
-			fmt.Println("\nRemember: if you wish to automatically activate the solution's tuning options after a reboot," +
-				"you must instruct saptune to configure \"tuned\" daemon by running:" +
-				"\n    saptune daemon start")
-		}
+		startDaemonAfterApply()
 	case "list":
 		fmt.Println("All solutions (* denotes enabled solution):")
 		for _, solName := range solution.GetSortedSolutionNames(solutionSelector) {
@@ -324,51 +2963,333 @@ func SolutionAction(actionName, solName string) {
 			}
 			fmt.Printf(format, solName)
 		}
-		if !system.SystemctlIsRunning(TunedService) || system.GetTunedProfile() != TunedProfileName {
-			fmt.Println("\nRemember: if you wish to automatically activate the solution's tuning options after a reboot," +
-				"you must instruct saptune to configure \"tuned\" daemon by running:" +
-				"\n    saptune daemon start")
+		if len(compositeSolutions) > 0 {
+			fmt.Println("All composite solutions (* denotes composite solution with all members enabled):")
+			for _, compName := range solution.GetSortedCompositeNames(compositeSolutions) {
+				format := "\t%s\t(%s)\n"
+				if isCompositeEnabled(compositeSolutions[compName]) {
+					format = "*" + format
+				}
+				fmt.Printf(format, compName, strings.Join(compositeSolutions[compName], " "))
+			}
 		}
+		printDaemonReminder()
 	case "verify":
 		if solName == "" {
 			VerifyAllParameters()
 		} else {
 			// Check system parameters against the specified solution, no matter the solution has been tuned for or not.
-			unsatisfiedNotes, comparisons, err := tuneApp.VerifySolution(solName)
+			warnSolutionSelectorMismatch()
+			verify := tuneApp.VerifySolution
+			if cliFlag("fail-fast") {
+				verify = tuneApp.VerifySolutionFailFast
+			}
+			unsatisfiedNotes, comparisons, err := verify(solName)
 			if err != nil {
 				errorExit("Failed to test the current system against the specified SAP solution: %v", err)
 			}
+			unsatisfiedNotes = filterDeviatingNotes(unsatisfiedNotes, comparisons)
+			if isStructuredOutput() {
+				PrintStructuredComparisons(comparisons)
+				if len(unsatisfiedNotes) > 0 {
+					os.Exit(ExitFailure)
+				}
+				return
+			}
 			if len(unsatisfiedNotes) == 0 {
-				fmt.Println("The system fully conforms to the tuning guidelines of the specified SAP solution.")
+				fmt.Printf("The system fully conforms to the tuning guidelines of the specified SAP solution%s.\n", filterQualifier())
+				PrintExternalChecks([]string{solName})
 			} else {
-				for _, unsatisfiedNoteID := range unsatisfiedNotes {
-					PrintNoteFields(unsatisfiedNoteID, comparisons[unsatisfiedNoteID], true)
+				if cliFlag("explain") {
+					PrintDeviationsByParameter(comparisons)
+				} else {
+					for _, unsatisfiedNoteID := range unsatisfiedNotes {
+						PrintNoteFields(unsatisfiedNoteID, comparisons[unsatisfiedNoteID], true)
+					}
+				}
+				if cliFlag("fail-fast") {
+					errorExit("The parameters listed above have deviated from the specified SAP solution recommendations, the remaining notes have not been verified.\n")
 				}
+				PrintExternalChecks([]string{solName})
 				errorExit("The parameters listed above have deviated from the specified SAP solution recommendations.\n")
 			}
 		}
 	case "simulate":
 		if solName == "" {
-			PrintHelpAndExit(1)
+			PrintHelpAndExit(ExitFailure)
 		}
 		// Run verify and print out all fields of the note
 		if _, comparisons, err := tuneApp.VerifySolution(solName); err != nil {
 			errorExit("Failed to test the current system against the specified note: %v", err)
 		} else {
-			fmt.Printf("If you run `saptune solution apply %s`, the following changes will be applied to your system:\n", solName)
-			for noteID, noteComparison := range comparisons {
-				PrintNoteFields(noteID, noteComparison, false)
-			}
+			fmt.Fprintf(infoOutput(), "If you run `saptune solution apply %s`, the following changes will be applied to your system:\n", solName)
+			PrintSimulation(comparisons)
 		}
 	case "revert":
 		if solName == "" {
-			PrintHelpAndExit(1)
+			PrintHelpAndExit(ExitFailure)
 		}
 		if err := tuneApp.RevertSolution(solName); err != nil {
 			errorExit("Failed to revert tuning for solution %s: %v", solName, err)
 		}
 		fmt.Println("Parameters tuned by the notes referred by the SAP solution have been successfully reverted.")
+	case "params":
+		if solName == "" {
+			PrintHelpAndExit(ExitFailure)
+		}
+		requireSolutionName(actionName, solName)
+		sol, _ := tuneApp.GetSolutionByName(solName)
+		PrintManagedParameters(solName, sol)
+	default:
+		PrintHelpAndExit(ExitFailure)
+	}
+}
+
+// Print the parameters managed by the notes of the solution, each with its value and the note that provides the value.
+func PrintManagedParameters(solName string, noteIDs []string) {
+	params, err := tuneApp.GetManagedParameters(noteIDs)
+	if err != nil {
+		errorExit("Failed to resolve the parameters of solution %s: %v", solName, err)
+	}
+	fmt.Printf("Parameters managed by solution %s (notes: %s):\n", solName, strings.Join(noteIDs, " "))
+	for _, param := range params {
+		fmt.Printf("\t%s = %s\t(%s)\n", param.Name, param.Value, param.NoteID)
+	}
+}
+
+// Print the sources of the note definition in order of precedence, each one overriding those printed before it.
+func PrintNoteSources(noteID string) {
+	sources, err := tuneApp.GetNoteSources(noteID)
+	if err != nil {
+		errorExit("Failed to resolve the sources of note %s: %v", noteID, err)
+	}
+	fmt.Printf("Note %s is defined by (in order of precedence, later ones override earlier ones):\n", noteID)
+	for i, source := range sources {
+		if source.Path == "" {
+			fmt.Printf("\t%d. %s\n", i+1, source.Origin)
+		} else {
+			fmt.Printf("\t%d. %s %s\n", i+1, source.Origin, source.Path)
+		}
+	}
+}
+
+// Print the inconsistencies between the records of saptune and the system, and exit 1 if there are any.
+func Diagnose() {
+	findings := tuneApp.Diagnose()
+	if len(findings) == 0 {
+		fmt.Println("The records of saptune are consistent with the system.")
+		return
+	}
+	fmt.Println("The records of saptune are inconsistent with the system:")
+	for _, finding := range findings {
+		fmt.Printf("\tNote %s %s.\n", finding.NoteID, finding.Problem)
+		if finding.Remedy != "" {
+			fmt.Printf("\t\tTo resolve it, run: %s\n", finding.Remedy)
+		}
+	}
+	os.Exit(ExitFailure)
+}
+
+// Print the parameters that saptune manages across all enabled notes, the value it enforces, and the owning note.
+func PrintAllManagedParameters() {
+	noteIDs := tuneApp.GetSortedAllEnabledNotes()
+	if len(noteIDs) == 0 {
+		fmt.Println("No note is enabled, saptune does not manage any parameter.")
+		return
+	}
+	params, err := tuneApp.GetAllManagedParameters()
+	if err != nil {
+		errorExit("Failed to resolve the managed parameters: %v", err)
+	}
+	fmt.Printf("Parameters managed by saptune (enabled notes: %s):\n", strings.Join(noteIDs, " "))
+	for _, param := range params {
+		fmt.Printf("\t%s = %s\t(%s)\n", param.Name, param.Value, param.NoteID)
+	}
+}
+
+// Return true only if all member solutions of the composite solution are enabled.
+func isCompositeEnabled(composite solution.Composite) bool {
+	for _, member := range composite {
+		if i := sort.SearchStrings(tuneApp.TuneForSolutions, member); !(i < len(tuneApp.TuneForSolutions) && tuneApp.TuneForSolutions[i] == member) {
+			return false
+		}
+	}
+	return true
+}
+
+// Carry out a solution action on each member solution of the composite solution.
+func CompositeSolutionAction(actionName, compName string, composite solution.Composite) {
+	switch actionName {
+	case "apply":
+		// Refuse to apply members that recommend different values for the same parameter
+		noteIDs := make([]string, 0, 0)
+		for _, member := range composite {
+			sol, _ := tuneApp.GetSolutionByName(member)
+			noteIDs = append(noteIDs, sol...)
+		}
+		conflicts, err := tuneApp.GetParameterConflicts(noteIDs)
+		if err != nil {
+			errorExit("Failed to test the current system against composite solution %s: %v", compName, err)
+		} else if len(conflicts) > 0 {
+			for _, conflict := range conflicts {
+				fmt.Printf("%s\n", conflict.Name)
+				for _, source := range conflict.Sources {
+					fmt.Printf("\t%s\t%s\n", source.NoteID, source.Comparison.ExpectedValueJS)
+				}
+			}
+			errorExit("The member solutions of composite solution %s recommend different values for the parameters listed above.", compName)
+		}
+		removedAdditionalNotes := make([]string, 0, 0)
+		for _, member := range composite {
+			removed, err := tuneApp.TuneSolution(member)
+			if err != nil {
+				errorExit("Failed to tune for solution %s of composite solution %s: %v", member, compName, err)
+			}
+			removedAdditionalNotes = append(removedAdditionalNotes, removed...)
+		}
+		tuneApp.SolutionSelector = solutionSelector
+		if err := tuneApp.SaveConfig(); err != nil {
+			errorExit("Failed to save configuration: %v", err)
+		}
+		fmt.Printf("All tuning options for the SAP solutions %s have been applied successfully.\n", strings.Join(composite, ", "))
+		if len(removedAdditionalNotes) > 0 {
+			fmt.Println("The following previously-enabled notes are now tuned by the SAP solutions:")
+			for _, noteNumber := range removedAdditionalNotes {
+				fmt.Printf("\t%s\t%s\n", noteNumber, tuningOptions[noteNumber].Name())
+			}
+		}
+		printDaemonReminder()
+	case "verify", "simulate":
+		warnSolutionSelectorMismatch()
+		unsatisfiedNotes := make([]string, 0, 0)
+		comparisons := make(map[string]map[string]note.NoteFieldComparison)
+		for _, member := range composite {
+			unsatisfiedSolNotes, solComparisons, err := tuneApp.VerifySolution(member)
+			if err != nil {
+				errorExit("Failed to test the current system against solution %s of composite solution %s: %v", member, compName, err)
+			}
+			for _, noteID := range unsatisfiedSolNotes {
+				if _, seen := comparisons[noteID]; !seen {
+					unsatisfiedNotes = append(unsatisfiedNotes, noteID)
+				}
+			}
+			for noteID, noteComparisons := range solComparisons {
+				comparisons[noteID] = noteComparisons
+			}
+		}
+		if actionName == "simulate" {
+			fmt.Printf("If you run `saptune solution apply %s`, the following changes will be applied to your system:\n", compName)
+			PrintSimulation(comparisons)
+		} else if len(unsatisfiedNotes) == 0 {
+			fmt.Println("The system fully conforms to the tuning guidelines of the specified composite solution.")
+			PrintExternalChecks(composite)
+		} else {
+			if cliFlag("explain") {
+				PrintDeviationsByParameter(comparisons)
+			} else {
+				for _, unsatisfiedNoteID := range unsatisfiedNotes {
+					PrintNoteFields(unsatisfiedNoteID, comparisons[unsatisfiedNoteID], true)
+				}
+			}
+			PrintExternalChecks(composite)
+			errorExit("The parameters listed above have deviated from the specified composite solution recommendations.\n")
+		}
+	case "revert":
+		for _, member := range composite {
+			if err := tuneApp.RevertSolution(member); err != nil {
+				errorExit("Failed to revert tuning for solution %s of composite solution %s: %v", member, compName, err)
+			}
+		}
+		fmt.Println("Parameters tuned by the notes referred by the SAP solutions have been successfully reverted.")
+	case "params":
+		noteIDs := make([]string, 0, 0)
+		for _, member := range composite {
+			sol, _ := tuneApp.GetSolutionByName(member)
+			noteIDs = append(noteIDs, sol...)
+		}
+		PrintManagedParameters(compName, noteIDs)
 	default:
-		PrintHelpAndExit(1)
+		PrintHelpAndExit(ExitFailure)
+	}
+}
+
+// The outcome of a single self-check.
+type selfCheckResult struct {
+	description string
+	critical    bool  // saptune cannot operate if the check fails
+	err         error // nil if the check passes
+}
+
+// Check the installation and environment of saptune, print the outcome of each check, and exit 1 if a critical check fails.
+func SelfCheck() {
+	results := make([]selfCheckResult, 0, 8)
+	// Architecture
+	selector := runtime.GOARCH
+	if system.IsPagecacheAvailable() {
+		selector += "_PC"
+	}
+	var err error
+	if _, exists := solution.AllSolutions[selector]; !exists {
+		err = fmt.Errorf("no solution is defined for %s", selector)
+	}
+	results = append(results, selfCheckResult{"The system architecture is supported", true, err})
+	// Daemon
+	_, err = os.Stat(path.Join(rootPrefix, SaptuneServiceUnit))
+	results = append(results, selfCheckResult{"The daemon " + SaptuneService + " is installed", false, err})
+	// Log file, its directory is created as saptune would do
+	os.MkdirAll(path.Join(rootPrefix, path.Dir(SaptuneLogFile)), 0755)
+	logFile, err := os.OpenFile(path.Join(rootPrefix, SaptuneLogFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err == nil {
+		logFile.Close()
+	}
+	results = append(results, selfCheckResult{"The log file is writable", true, err})
+	// Configuration
+	_, err = txtparser.ParseSysconfigFile(path.Join(rootPrefix, app.SysconfigSaptuneDir), false)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	results = append(results, selfCheckResult{"The configuration file is readable", true, err})
+	// Tuning sheets
+	sheetDir := path.Join(rootPrefix, ExtraTuningSheets)
+	opts := note.GetTuningOptions(rootPrefix, sheetDir)
+	_, sheets, err := system.ListDir(sheetDir)
+	results = append(results, selfCheckResult{"The tuning sheet directory " + sheetDir + " exists", false, err})
+	for _, fileName := range sheets {
+		_, err := txtparser.ParseINIFile(path.Join(sheetDir, fileName), false)
+		if idName := strings.SplitN(fileName, "-", 2); err == nil && len(idName) != 2 {
+			err = fmt.Errorf("the file name does not follow the convention ID-Description")
+		} else if _, loaded := opts[idName[0]]; err == nil && !loaded {
+			err = fmt.Errorf("the sheet is not loaded, e.g. because of a cyclic include, please refer to the log")
+		}
+		results = append(results, selfCheckResult{"The tuning sheet " + fileName + " is usable", false, err})
+	}
+	// Saved states
+	stateApp := app.InitialiseApp(rootPrefix, rootPrefix, opts, solution.AllSolutions[selector])
+	savedNotes, err := stateApp.State.List()
+	results = append(results, selfCheckResult{"The saved state directory is readable", true, err})
+	for _, noteID := range savedNotes {
+		var content map[string]interface{}
+		err := stateApp.State.Retrieve(noteID, &content)
+		if _, exists := opts[noteID]; err == nil && !exists {
+			if _, isAdHoc := stateApp.AdHocNotes[noteID]; !isAdHoc {
+				err = fmt.Errorf("the note is unknown, its state cannot be reverted")
+			}
+		}
+		results = append(results, selfCheckResult{"The saved state of note " + noteID + " is consistent", true, err})
+	}
+
+	criticalFailure := false
+	for _, result := range results {
+		if result.err == nil {
+			fmt.Printf("[PASS] %s\n", result.description)
+		} else if result.critical {
+			criticalFailure = true
+			fmt.Printf("[FAIL] %s: %v\n", result.description, result.err)
+		} else {
+			fmt.Printf("[WARN] %s: %v\n", result.description, result.err)
+		}
+	}
+	if criticalFailure {
+		errorExit("saptune cannot operate until the failed checks listed above are fixed.")
 	}
 }
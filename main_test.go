@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/HouzuoGuo/saptune/sap/note"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonReminder(t *testing.T) {
+	if reminder := daemonReminder(true); reminder != "" {
+		t.Fatal(reminder)
+	}
+	if reminder := daemonReminder(false); !strings.Contains(reminder, "saptune daemon start") {
+		t.Fatal(reminder)
+	}
+}
+
+func TestPrintComparisonsCSV(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	PrintComparisonsCSV(map[string]map[string]note.NoteFieldComparison{
+		"2": {"B": {ExpectedValueJS: `"a,b"`, ActualValueJS: "1", MatchExpectation: false, Severity: note.SeverityRecommended}},
+		"1": {"A": {ExpectedValueJS: "1", ActualValueJS: "1", MatchExpectation: true, Severity: note.SeverityMandatory}},
+	})
+	os.Stdout = stdout
+	writer.Close()
+	output, _ := ioutil.ReadAll(reader)
+	expected := "note_id,note_name,parameter,expected,actual,matches,severity\n1,,A,1,1,true,mandatory\n2,,B,\"\"\"a,b\"\"\",1,false,recommended\n"
+	if string(output) != expected {
+		t.Fatal(string(output))
+	}
+}
+
+func TestPrintComparisonsJSON(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	PrintComparisonsJSON(map[string]map[string]note.NoteFieldComparison{
+		"2": {
+			"B": {ParamID: "B", ExpectedValueJS: "2", ActualValueJS: "1", MatchExpectation: false, Severity: note.SeverityRecommended},
+			"A": {ParamID: "A", ExpectedValueJS: "1", ActualValueJS: "1", MatchExpectation: true},
+		},
+		"1": {"A": {ParamID: "A", ExpectedValueJS: "1", ActualValueJS: "1", MatchExpectation: true}},
+	})
+	os.Stdout = stdout
+	writer.Close()
+	output, _ := ioutil.ReadAll(reader)
+	var notes []noteComparisonsJSON
+	if err := json.Unmarshal(output, &notes); err != nil {
+		t.Fatal(err, string(output))
+	}
+	if len(notes) != 2 || notes[0].NoteID != "1" || !notes[0].Conforming || notes[1].Conforming {
+		t.Fatal(notes)
+	}
+	if len(notes[1].Parameters) != 2 || notes[1].Parameters[0].ParamID != "A" || notes[1].Parameters[1].Severity != note.SeverityRecommended {
+		t.Fatal(notes[1].Parameters)
+	}
+}
+
+func TestPrintMetrics(t *testing.T) {
+	var out bytes.Buffer
+	healthy := true
+	PrintMetrics(&out, map[string]map[string]note.NoteFieldComparison{
+		"2": {"A": {MatchExpectation: false}, "B": {MatchExpectation: true}},
+		"1": {"A": {MatchExpectation: true}, "B": {MatchExpectation: true}},
+	}, map[string]error{"3": fmt.Errorf("failing note")}, map[string]time.Time{"1": time.Unix(1600000000, 0)}, &healthy)
+	for _, expected := range []string{
+		"# TYPE saptune_note_deviating_parameters gauge\n",
+		"saptune_note_deviating_parameters{note_id=\"1\",note_name=\"\"} 0\nsaptune_note_deviating_parameters{note_id=\"2\",note_name=\"\"} 1\n",
+		"saptune_note_compliant{note_id=\"1\",note_name=\"\"} 1\nsaptune_note_compliant{note_id=\"2\",note_name=\"\"} 0\n",
+		"saptune_note_verify_failed{note_id=\"3\",note_name=\"\"} 1\n",
+		"saptune_note_last_apply_timestamp_seconds{note_id=\"1\",note_name=\"\"} 1.6e+09\n",
+		"saptune_compliance_ratio 0.75\n",
+		"saptune_daemon_healthy 1\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatal(out.String())
+		}
+	}
+	out.Reset()
+	PrintMetrics(&out, map[string]map[string]note.NoteFieldComparison{}, map[string]error{}, map[string]time.Time{}, nil)
+	if !strings.Contains(out.String(), "saptune_compliance_ratio 1\n") || strings.Contains(out.String(), "saptune_daemon_healthy") {
+		t.Fatal(out.String())
+	}
+	if escaped := prometheusLabelEscaper.Replace("a\"b\\c\n"); escaped != `a\"b\\c\n` {
+		t.Fatal(escaped)
+	}
+}
+
+func TestParamFilter(t *testing.T) {
+	comparisons := map[string]map[string]note.NoteFieldComparison{
+		"1": {
+			"SysctlParams[vm.swappiness]":      {ReflectFieldName: "SysctlParams", ReflectMapKey: "vm.swappiness", MatchExpectation: true},
+			"SysctlParams[kernel.shmmni]":      {ReflectFieldName: "SysctlParams", ReflectMapKey: "kernel.shmmni", MatchExpectation: false},
+			"SysctlParams[vm.dirty_bytes]":     {ReflectFieldName: "SysctlParams", ReflectMapKey: "vm.dirty_bytes", MatchExpectation: true},
+			"SysctlParams[net.core.somaxconn]": {ReflectFieldName: "SysctlParams", ReflectMapKey: "net.core.somaxconn", MatchExpectation: true},
+		},
+		"2": {"SysctlParams[vm.max_map_count]": {ReflectFieldName: "SysctlParams", ReflectMapKey: "vm.max_map_count", MatchExpectation: false}},
+	}
+	// Without the filter, everything is printed and decides the exit status
+	if filtered := filterParams(comparisons["1"]); len(filtered) != 4 {
+		t.Fatal(filtered)
+	}
+	paramFilter = regexp.MustCompile(`^vm\.`)
+	defer func() { paramFilter = nil }()
+	if filtered := filterParams(comparisons["1"]); len(filtered) != 2 {
+		t.Fatal(filtered)
+	}
+	if deviating := filterDeviatingNotes([]string{"1", "2"}, comparisons); !reflect.DeepEqual(deviating, []string{"1", "2"}) {
+		t.Fatal(deviating)
+	}
+	// Only the matching parameters decide the exit status, note 1 deviates in kernel.shmmni alone
+	args := os.Args
+	os.Args = append([]string{"saptune", "--filter-affects-exit"}, os.Args[1:]...)
+	defer func() { os.Args = args }()
+	if deviating := filterDeviatingNotes([]string{"1", "2"}, comparisons); !reflect.DeepEqual(deviating, []string{"2"}) {
+		t.Fatal(deviating)
+	}
+}
+
+func TestSeparateValueFlag(t *testing.T) {
+	args := os.Args
+	os.Args = []string{"saptune", "note", "customise", "--set", "vm.swappiness=10", "--set=vm.dirty_ratio=20", "1001"}
+	defer func() { os.Args = args }()
+	if values := cliFlagValues("set"); !reflect.DeepEqual(values, []string{"vm.swappiness=10", "vm.dirty_ratio=20"}) {
+		t.Fatal(values)
+	}
+	if noteID := cliArg(3); noteID != "1001" {
+		t.Fatal(noteID)
+	}
+}
+
+func TestExitCodesUnique(t *testing.T) {
+	seen := make(map[int]string)
+	for _, exitCode := range ExitCodes {
+		if name, exists := seen[exitCode.Code]; exists {
+			t.Fatal(exitCode.Code, name, exitCode.Name)
+		}
+		seen[exitCode.Code] = exitCode.Name
+	}
+}
@@ -1,27 +0,0 @@
-#!/bin/bash
-#
-# Copyright (c) 2016 SUSE LINUX GmbH, Nuernberg, Germany.
-#
-# This program is free software; you can redistribute it and/or
-# modify it under the terms of the GNU General Public License
-# as published by the Free Software Foundation; either version 2
-# of the License, or (at your option) any later version.
-#
-# This program is distributed in the hope that it will be useful,
-# but WITHOUT ANY WARRANTY; without even the implied warranty of
-# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
-# GNU General Public License for more details.
-#
-# Author:  Howard Guo <hguo@suse.com>
-
-. /usr/lib/tuned/functions
-
-start() {
-    saptune daemon apply
-}
-
-stop() {
-    saptune daemon revert
-}
-
-process $@
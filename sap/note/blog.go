@@ -6,6 +6,7 @@ import (
 	"github.com/HouzuoGuo/saptune/system"
 	"github.com/HouzuoGuo/saptune/txtparser"
 	"path"
+	"strconv"
 )
 
 /*
@@ -28,6 +29,10 @@ func (st SUSESysOptimisation) Name() string {
 	// Do not mention SLES 11 here
 	return "SLES 12 OS Tuning & Optimization Guide – Part 1"
 }
+func (st SUSESysOptimisation) RebootRequiredParams() []string {
+	// The kernel may not find enough contiguous memory for huge pages until the system is rebooted
+	return []string{"VMNumberHugePages"}
+}
 func (st SUSESysOptimisation) Initialise() (Note, error) {
 	newST := st
 	newST.VMNumberHugePages, _ = system.GetSysctlUint64(system.SysctlNumberHugepages)
@@ -92,6 +97,21 @@ func (st SUSESysOptimisation) Apply() error {
 	err := sap.PrintErrors(errs)
 	return err
 }
+func (st SUSESysOptimisation) SysctlValues() (sysctlValues map[string]string, otherParams []string) {
+	sysctlValues = make(map[string]string)
+	for name, value := range map[string]uint64{
+		system.SysctlNumberHugepages:      st.VMNumberHugePages,
+		system.SysctlSwappines:            st.VMSwappiness,
+		system.SysctlVFSCachePressure:     st.VMVfsCachePressure,
+		system.SysctlOvercommitMemory:     st.VMOvercommitMemory,
+		system.SysctlOvercommitRatio:      st.VMOvercommitRatio,
+		system.SysctlDirtyRatio:           st.VMDirtyRatio,
+		system.SysctlDirtyBackgroundRatio: st.VMDirtyBackgroundRatio,
+	} {
+		sysctlValues[name] = strconv.FormatUint(value, 10)
+	}
+	return sysctlValues, []string{"BlockDeviceSchedulers"}
+}
 
 /*
 SUSE-GUIDE-01 - SLES 11/12: Network, CPU Tuning and Optimization – Part 2
@@ -294,3 +314,45 @@ func (st SUSENetCPUOptimisation) Apply() error {
 	err := sap.PrintErrors(errs)
 	return err
 }
+
+// The TCP buffer sizes only set a field of their sysctl parameters, they are not persisted.
+func (st SUSENetCPUOptimisation) SysctlValues() (sysctlValues map[string]string, otherParams []string) {
+	sysctlValues = make(map[string]string)
+	for name, value := range map[string]uint64{
+		system.SysctlNetWriteMemMax:         st.NetCoreWmemMax,
+		system.SysctlNetReadMemMax:          st.NetCoreRmemMax,
+		system.SysctlNetMaxBacklog:          st.NetCoreNetdevMaxBacklog,
+		system.SysctlNetMaxconn:             st.NetCoreSoMaxConn,
+		system.SysctlTCPTimestamps:          st.NetIpv4TcpTimestamps,
+		system.SysctlTCPSack:                st.NetIpv4TcpSack,
+		system.SysctlTCPFack:                st.NetIpv4TcpFack,
+		system.SysctlTCPDsack:               st.NetIpv4TcpDsack,
+		system.SysctlTCPFragLowThreshold:    st.NetIpv4IpfragLowThres,
+		system.SysctlTCPFragHighThreshold:   st.NetIpv4IpfragHighThres,
+		system.SysctlTCPMaxSynBacklog:       st.NetIpv4TcpMaxSynBacklog,
+		system.SysctlTCPSynackRetries:       st.NetIpv4TcpSynackRetries,
+		system.SysctpTCPRetries2:            st.NetIpv4TcpRetries2,
+		system.SysctlTCPKeepaliveTime:       st.NetTcpKeepaliveTime,
+		system.SysctlTCPKeepaliveProbes:     st.NetTcpKeepaliveProbes,
+		system.SysctlTCPKeepaliveInterval:   st.NetTcpKeepaliveIntvl,
+		system.SysctlTCPTWRecycle:           st.NetTcpTwRecycle,
+		system.SysctlTCPTWReuse:             st.NetTcpTwReuse,
+		system.SysctlTCPFinTimeout:          st.NetTcpFinTimeout,
+		system.SysctlTCPMTUProbing:          st.NetTcpMtuProbing,
+		system.SysctlTCPSynCookies:          st.NetIpv4TcpSyncookies,
+		system.SysctlIPAcceptSourceRoute:    st.NetIpv4ConfAllAcceptSourceRoute,
+		system.SysctlIPAcceptRedirects:      st.NetIpv4ConfAllAcceptRedirects,
+		system.SysctlIPRPFilter:             st.NetIpv4ConfAllRPFilter,
+		system.SysctlIPIgnoreICMPBroadcasts: st.NetIpv4IcmpEchoIgnoreBroadcasts,
+		system.SysctlIPIgnoreICMPBogusError: st.NetIpv4IcmpIgnoreBogusErrorResponses,
+		system.SysctlIPLogMartians:          st.NetIpv4ConfAllLogMartians,
+		system.SysctlRandomizeVASpace:       st.KernelRandomizeVASpace,
+		system.SysctlKptrRestrict:           st.KernelKptrRestrict,
+		system.SysctlProtectHardlinks:       st.FSProtectedHardlinks,
+		system.SysctlProtectSymlinks:        st.FSProtectedSymlinks,
+		system.SysctlRunChildFirst:          st.KernelSchedChildRunsFirst,
+	} {
+		sysctlValues[name] = strconv.FormatUint(value, 10)
+	}
+	return sysctlValues, []string{"NetIpv4TcpRmem", "NetIpv4TcpWmem"}
+}
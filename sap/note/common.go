@@ -69,6 +69,22 @@ func (prepare PrepareForSAPEnvironments) Initialise() (Note, error) {
 	newPrepare.KernelSemMsl, newPrepare.KernelSemMns, newPrepare.KernelSemOpm, newPrepare.KernelSemMni = system.GetSemaphoreLimits()
 	return newPrepare, err
 }
+
+func (prepare PrepareForSAPEnvironments) ParamCategories() map[string]string {
+	return map[string]string{
+		"ShmFileSystemSizeMB":   "filesystem",
+		"LimitNofileSapsysSoft": "limits", "LimitNofileSapsysHard": "limits",
+		"LimitNofileSdbaSoft": "limits", "LimitNofileSdbaHard": "limits",
+		"LimitNofileDbaSoft": "limits", "LimitNofileDbaHard": "limits",
+		"KernelShmMax": "kernel", "KernelShmAll": "kernel", "KernelShmMni": "kernel",
+		"KernelSemMsl": "kernel", "KernelSemMns": "kernel", "KernelSemOpm": "kernel", "KernelSemMni": "kernel",
+		"VMMaxMapCount": "memory",
+	}
+}
+
+func (prepare PrepareForSAPEnvironments) RequiredMounts() map[string]string {
+	return map[string]string{"ShmFileSystemSizeMB": "/dev/shm"}
+}
 func (prepare PrepareForSAPEnvironments) Optimise() (Note, error) {
 	newPrepare := prepare
 
@@ -162,6 +178,20 @@ func (prepare PrepareForSAPEnvironments) Apply() error {
 	err = sap.PrintErrors(errs)
 	return nil
 }
+func (prepare PrepareForSAPEnvironments) SysctlValues() (sysctlValues map[string]string, otherParams []string) {
+	return map[string]string{
+		system.SysctlShmax:       fmt.Sprint(prepare.KernelShmMax),
+		system.SysctlShmall:      fmt.Sprint(prepare.KernelShmAll),
+		system.SysctlShmni:       fmt.Sprint(prepare.KernelShmMni),
+		system.SysctlMaxMapCount: fmt.Sprint(prepare.VMMaxMapCount),
+		system.SysctlSem:         fmt.Sprintf("%d %d %d %d", prepare.KernelSemMsl, prepare.KernelSemMns, prepare.KernelSemOpm, prepare.KernelSemMni),
+	}, []string{
+		"ShmFileSystemSizeMB",
+		"LimitNofileSapsysSoft", "LimitNofileSapsysHard",
+		"LimitNofileSdbaSoft", "LimitNofileSdbaHard",
+		"LimitNofileDbaSoft", "LimitNofileDbaHard",
+	}
+}
 
 // 1984787 - SUSE LINUX Enterprise Server 12: Installation notes
 type AfterInstallation struct {
@@ -0,0 +1,204 @@
+package note
+
+import (
+	"fmt"
+	"github.com/HouzuoGuo/saptune/system"
+	"github.com/HouzuoGuo/saptune/txtparser"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+CustomiseFileTemplate is the path to the customisation file of a note, the placeholder is the note ID.
+Besides the switches understood by the built-in notes, the file may override the optimised value of any parameter of
+the note, e.g. VMSwappiness="10" or vm.swappiness="10". A key enclosed in slashes is a regular expression that
+overrides all matching parameters, e.g. /^net\.ipv4\.conf\..*\.rp_filter$/="1".
+*/
+const CustomiseFileTemplate = "/etc/sysconfig/saptune-note-%s"
+
+/*
+OverrideFileTemplate is the path to the override file of a note, the placeholder is the note ID. It overrides the
+values of parameters like the customisation file does and in the same syntax, but it is meant to be maintained per
+host, e.g. by configuration management, while the customisation file is edited by `saptune note customise`. The
+customisation file takes precedence over the override file.
+*/
+const OverrideFileTemplate = "/etc/saptune/override/%s"
+
+/*
+NoteReferencePrefix introduces a customised value that refers to the value of a parameter of another note, followed
+by the note ID and the parameter name, e.g. "@note:1410736:vm.nr_hugepages".
+*/
+const NoteReferencePrefix = "@note:"
+
+// Return the note ID and the parameter name referenced by the customised value, ok is false if it is no reference.
+func ParseNoteReference(value string) (noteID, paramName string, ok bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, NoteReferencePrefix) {
+		return "", "", false
+	}
+	fields := strings.SplitN(strings.TrimPrefix(value, NoteReferencePrefix), ":", 2)
+	if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// Return the value of the parameter of the note as text, or an error if the note does not have the parameter.
+func GetParamValue(aNote Note, paramName string) (string, error) {
+	_, comparisons := CompareNoteFields(aNote, aNote)
+	for _, comparison := range comparisons {
+		if GetParamName(comparison) == paramName {
+			return fmt.Sprint(comparison.ExpectedValue), nil
+		}
+	}
+	return "", fmt.Errorf("the note does not have parameter %s", paramName)
+}
+
+// Return the comment lines written at the top of a new customisation file of the note.
+func CustomiseFileHeader(noteID string) []string {
+	return []string{
+		fmt.Sprintf("# Override the values of parameters of note %s, e.g. vm.swappiness=\"10\".", noteID),
+		"# A key enclosed in slashes is a regular expression matching parameter names, e.g. /^vm\\.dirty_/=\"10\".",
+		"# A parameter named explicitly takes its explicit value rather than the value of a matching regular expression.",
+		"# A value may refer to the value of a parameter of another note, e.g. vm.nr_hugepages=\"@note:1410736:vm.nr_hugepages\".",
+	}
+}
+
+// Return the name of the parameter under comparison, which is the map key if the structure field is a map.
+func GetParamName(comparison NoteFieldComparison) string {
+	if comparison.ReflectMapKey != "" {
+		return comparison.ReflectMapKey
+	}
+	return comparison.ReflectFieldName
+}
+
+// Return true only if the customisation key is a regular expression rather than a parameter name.
+func IsRegexOverride(key string) bool {
+	return len(key) > 2 && strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/")
+}
+
+/*
+Return the value overriding each parameter of the note, parameter name VS value. A parameter named explicitly takes
+the explicit value. Otherwise it takes the value of the first regular expression in the file that matches its name.
+Fields describing the note itself, such as its ID, are never overridden, naming one of them explicitly is an error.
+*/
+func GetOverrides(aNote Note, conf *txtparser.Sysconfig) (overrides map[string]string, err error) {
+	overrides = make(map[string]string)
+	type regexOverride struct {
+		regex *regexp.Regexp
+		value string
+	}
+	regexOverrides := make([]regexOverride, 0, 0)
+	for _, entry := range conf.AllValues {
+		if !IsRegexOverride(entry.Key) {
+			continue
+		}
+		regex, err := regexp.Compile(entry.Key[1 : len(entry.Key)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %s - %v", entry.Key, err)
+		}
+		regexOverrides = append(regexOverrides, regexOverride{regex: regex, value: entry.Value})
+	}
+	_, comparisons := CompareNoteFields(aNote, aNote)
+	for _, comparison := range comparisons {
+		name := GetParamName(comparison)
+		if !comparison.IsParameter() {
+			if _, explicit := conf.KeyValue[name]; explicit {
+				return nil, fmt.Errorf("%s is not a parameter, it cannot be customised", name)
+			}
+			continue
+		}
+		if entry, explicit := conf.KeyValue[name]; explicit {
+			overrides[name] = entry.Value
+			continue
+		}
+		for _, override := range regexOverrides {
+			if override.regex.MatchString(name) {
+				overrides[name] = override.value
+				break
+			}
+		}
+	}
+	return
+}
+
+/*
+Return a copy of the optimised note with parameter values replaced by the overrides, parameter name VS value.
+Overriding a parameter that is neither a string, a number, a boolean, nor an entry of a string map results in an error,
+so does overriding a field that describes the note itself rather than a parameter.
+*/
+func ApplyOverrides(optimised Note, overrides map[string]string) (Note, error) {
+	if len(overrides) == 0 {
+		return optimised, nil
+	}
+	refNote := reflect.New(reflect.TypeOf(optimised)).Elem()
+	refNote.Set(reflect.ValueOf(optimised))
+	for i := 0; i < refNote.NumField(); i++ {
+		fieldName := refNote.Type().Field(i).Name
+		field := refNote.Field(i)
+		if field.Kind() == reflect.Map && field.Type().Key().Kind() == reflect.String && field.Type().Elem().Kind() == reflect.String {
+			// Copy the map, it may be shared with other copies of the note
+			newMap := reflect.MakeMap(field.Type())
+			for _, key := range field.MapKeys() {
+				value := field.MapIndex(key)
+				if override, exists := overrides[key.String()]; exists {
+					value = reflect.ValueOf(override)
+				}
+				newMap.SetMapIndex(key, value)
+			}
+			field.Set(newMap)
+			continue
+		}
+		override, exists := overrides[fieldName]
+		if !exists {
+			continue
+		} else if !IsParameterField(fieldName) {
+			return nil, fmt.Errorf("%s is not a parameter, it cannot be customised", fieldName)
+		}
+		if err := setFieldValue(field, override); err != nil {
+			return nil, fmt.Errorf("parameter %s cannot be customised - %v", fieldName, err)
+		}
+	}
+	return refNote.Interface().(Note), nil
+}
+
+// Parse the text value and set it into the structure field.
+func setFieldValue(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	// Security limits may be unlimited, which is spelt as a word rather than a number
+	if field.Type() == reflect.TypeOf(system.SecurityLimitUnlimitedValue) {
+		for _, word := range system.SecurityLimitUnlimitedString {
+			if value == word {
+				field.SetInt(int64(system.SecurityLimitUnlimitedValue))
+				return nil
+			}
+		}
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(boolValue)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intValue, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(intValue)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uintValue, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(uintValue)
+	default:
+		return fmt.Errorf("values of type %s are not supported", field.Type())
+	}
+	return nil
+}
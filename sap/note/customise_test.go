@@ -0,0 +1,95 @@
+package note
+
+import (
+	"github.com/HouzuoGuo/saptune/sap/param"
+	"github.com/HouzuoGuo/saptune/txtparser"
+	"reflect"
+	"testing"
+)
+
+func TestGetOverrides(t *testing.T) {
+	vend := INISettings{SysctlParams: map[string]string{
+		"net.ipv4.conf.eth0.rp_filter": "0",
+		"net.ipv4.conf.eth1.rp_filter": "0",
+		"vm.swappiness":                "60",
+	}}
+	conf, _ := txtparser.ParseSysconfig(`/^net\.ipv4\.conf\..*\.rp_filter$/="1"
+/^vm\./="20"
+net.ipv4.conf.eth1.rp_filter="2"
+/^vm\.swap/="3"
+TUNE_SOMETHING="yes"
+`)
+	overrides, err := GetOverrides(vend, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Explicit overrides win over regular expressions, the first matching regular expression wins over later ones
+	expected := map[string]string{
+		"net.ipv4.conf.eth0.rp_filter": "1",
+		"net.ipv4.conf.eth1.rp_filter": "2",
+		"vm.swappiness":                "20",
+	}
+	if !reflect.DeepEqual(overrides, expected) {
+		t.Fatal(overrides)
+	}
+	conf, _ = txtparser.ParseSysconfig(`/[/="1"`)
+	if _, err := GetOverrides(vend, conf); err == nil {
+		t.Fatal("did not error")
+	}
+	// Regular expressions do not match the fields that describe the note, naming them explicitly is an error
+	conf, _ = txtparser.ParseSysconfig(`/./="1"`)
+	if overrides, err := GetOverrides(vend, conf); err != nil || len(overrides) != 3 {
+		t.Fatal(overrides, err)
+	}
+	conf, _ = txtparser.ParseSysconfig(`ID="1"`)
+	if _, err := GetOverrides(vend, conf); err == nil {
+		t.Fatal("did not error")
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	st := SUSESysOptimisation{
+		VMSwappiness:          60,
+		BlockDeviceSchedulers: param.BlockDeviceSchedulers{SchedulerChoice: map[string]string{"sda": "cfq"}},
+	}
+	overridden, err := ApplyOverrides(st, map[string]string{"VMSwappiness": "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newST := overridden.(SUSESysOptimisation); newST.VMSwappiness != 10 || st.VMSwappiness != 60 {
+		t.Fatal(newST, st)
+	}
+	// The location of the configuration is not a parameter
+	if _, err := ApplyOverrides(st, map[string]string{"SysconfigPrefix": "/abc"}); err == nil {
+		t.Fatal("did not error")
+	}
+	if _, err := ApplyOverrides(st, map[string]string{"VMSwappiness": "abc"}); err == nil {
+		t.Fatal("did not error")
+	}
+	if _, err := ApplyOverrides(st, map[string]string{"BlockDeviceSchedulers": "noop"}); err == nil {
+		t.Fatal("did not error")
+	}
+	// Map values are overridden in a copy of the map
+	vend := INISettings{SysctlParams: map[string]string{"vm.swappiness": "60"}}
+	overridden, err = ApplyOverrides(vend, map[string]string{"vm.swappiness": "10"})
+	if err != nil || overridden.(INISettings).SysctlParams["vm.swappiness"] != "10" || vend.SysctlParams["vm.swappiness"] != "60" {
+		t.Fatal(overridden, err)
+	}
+}
+
+func TestParseNoteReference(t *testing.T) {
+	if noteID, paramName, ok := ParseNoteReference(" @note:1410736:vm.nr_hugepages "); !ok || noteID != "1410736" || paramName != "vm.nr_hugepages" {
+		t.Fatal(noteID, paramName, ok)
+	}
+	for _, value := range []string{"10", "@note:1410736", "@note::vm.swappiness", "@note:1410736:"} {
+		if _, _, ok := ParseNoteReference(value); ok {
+			t.Fatal(value)
+		}
+	}
+	if value, err := GetParamValue(INISettings{SysctlParams: map[string]string{"vm.swappiness": "10"}}, "vm.swappiness"); err != nil || value != "10" {
+		t.Fatal(value, err)
+	}
+	if _, err := GetParamValue(INISettings{}, "vm.swappiness"); err == nil {
+		t.Fatal("did not error")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/HouzuoGuo/saptune/system"
 	"github.com/HouzuoGuo/saptune/txtparser"
 	"path"
+	"strconv"
 )
 
 /*
@@ -114,3 +115,9 @@ func (paging LinuxPagingImprovements) Apply() error {
 	err := sap.PrintErrors(errs)
 	return err
 }
+func (paging LinuxPagingImprovements) SysctlValues() (sysctlValues map[string]string, otherParams []string) {
+	return map[string]string{
+		system.SysctlPagecacheLimitMB:          strconv.FormatUint(paging.VMPagecacheLimitMB, 10),
+		system.SysctlPagecacheLimitIgnoreDirty: strconv.Itoa(paging.VMPagecacheLimitIgnoreDirty),
+	}, []string{}
+}
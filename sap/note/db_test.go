@@ -4,6 +4,7 @@ import (
 	"github.com/HouzuoGuo/saptune/system"
 	"os"
 	"path"
+	"reflect"
 	"testing"
 )
 
@@ -51,4 +52,8 @@ func TestLinuxPagingImprovements(t *testing.T) {
 	if o.VMPagecacheLimitMB != 0 || o.VMPagecacheLimitIgnoreDirty != 1 {
 		t.Fatal(o)
 	}
+	sysctlValues, otherParams := o.SysctlValues()
+	if !reflect.DeepEqual(sysctlValues, map[string]string{system.SysctlPagecacheLimitMB: "0", system.SysctlPagecacheLimitIgnoreDirty: "1"}) || len(otherParams) != 0 {
+		t.Fatal(sysctlValues, otherParams)
+	}
 }
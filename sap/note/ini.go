@@ -6,16 +6,40 @@ import (
 	"github.com/HouzuoGuo/saptune/sap/param"
 	"github.com/HouzuoGuo/saptune/system"
 	"github.com/HouzuoGuo/saptune/txtparser"
+	"io/ioutil"
 	"log"
+	"math"
+	"os"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 const (
+	INISectionMain      = "main" // section of directives about the tuning sheet itself rather than tunables
+	INIKeyInclude       = "include"
+	INIKeyID            = "id"
+	INIKeyReboot        = "reboot_required" // space-separated list of parameters that take effect after a reboot
+	INIKeyModules       = "modules"         // space-separated list of parameter:module pairs
+	INIKeyApplyAfter    = "apply_after"     // space-separated list of parameter:prerequisite pairs
+	INIKeyMounts        = "mounts"          // space-separated list of parameter:mount point pairs
+	INIKeyDeprecatedBy  = "deprecated_by"   // ID of the note that supersedes the sheet
+	INIKeyKernelMin     = "kernel_min"      // oldest kernel version the sheet supports
+	INIKeyKernelMax     = "kernel_max"      // newest kernel version the sheet supports
+	INIKeyProductVers   = "sap_versions"    // space-separated list of SAP product version patterns the sheet supports
+	INIKeyCategories    = "categories"      // space-separated list of parameter:category pairs
+	INIKeyUnits         = "units"           // space-separated list of parameter:unit pairs
+	INIKeyRecommended   = "recommended"     // space-separated list of parameters that are recommended rather than mandatory
+	INIKeyPackages      = "packages"        // space-separated list of software packages the sheet only takes effect with
+	INIKeyRiskPrefix    = "risk."           // followed by a parameter name, the value describes the risk of changing the parameter
 	INISectionSysctl    = "sysctl"
 	INISectionVM        = "vm"
 	INISectionBlock     = "block"
 	INISectionLimits    = "limits"
+	INISectionPlugin    = "plugin"  // parameters inspected and applied by vendor plugins, keyed by plugin name
+	INISectionBuiltin   = "builtin" // parameters of the included built-in note, keyed by their names as in customisation files
+	PluginDir           = "/etc/saptune/plugins/"
 	SysKernelTHPEnabled = "kernel/mm/transparent_hugepage/enabled"
 	SysKSMRun           = "kernel/mm/ksm/run"
 )
@@ -59,6 +83,44 @@ func CalculateOptimumValue(operator txtparser.Operator, currentValue string, exp
 	return strconv.FormatInt(iCurrentValue, 10), nil
 }
 
+var byteSuffixes = map[string]uint64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}
+
+/*
+Resolve a human-friendly value into the plain integer expected by the kernel.
+A percentage (e.g. 75%) is resolved to bytes of the main memory, i.e. MemTotal of /proc/meminfo without swap, a number
+with suffix K, M, G, or T (e.g. 2G) is resolved to bytes. Other values are returned unchanged. Return an error if the
+bytes exceed the range of an unsigned 64-bit integer.
+*/
+func ResolveValue(value string, mainMemBytes uint64) (string, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || strings.ContainsAny(value, " \t") {
+		return value, nil
+	}
+	number, suffix := value[:len(value)-1], strings.ToUpper(value[len(value)-1:])
+	if suffix == "%" {
+		percent, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return "", fmt.Errorf("percentage \"%s\" is not a number", value)
+		} else if percent < 0 || percent > 100 {
+			return "", fmt.Errorf("percentage \"%s\" is out of range 0%%-100%%", value)
+		}
+		return strconv.FormatUint(uint64(float64(mainMemBytes)*percent/100), 10), nil
+	}
+	multiplier, isByteSize := byteSuffixes[suffix]
+	if !isByteSize {
+		return value, nil
+	}
+	size, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		// Not a byte size after all, e.g. a word ending with the letter.
+		return value, nil
+	}
+	if size > math.MaxUint64/multiplier {
+		return "", fmt.Errorf("byte size \"%s\" is out of range", value)
+	}
+	return strconv.FormatUint(size*multiplier, 10), nil
+}
+
 // section handling
 
 // section [block]
@@ -238,27 +300,512 @@ func OptVmVal(parameter, act_value, cfg_value string) string {
 
 // Tuning options composed by a third party vendor.
 type INISettings struct {
-	ConfFilePath    string            // Full path to the 3rd party vendor's tuning configuration file
-	ID              string            // ID portion of the tuning configuration
-	DescriptiveName string            // Descriptive name portion of the tuning configuration
-	SysctlParams    map[string]string // Sysctl parameter values from the computer system
+	ConfFilePath      string            // Full path to the 3rd party vendor's tuning configuration file
+	IncludedFilePaths []string          // Full paths to the configuration files included by this one, base first
+	ID                string            // ID portion of the tuning configuration
+	DescriptiveName   string            // Descriptive name portion of the tuning configuration
+	SysctlParams      map[string]string // Sysctl parameter values from the computer system
+	PluginDir         string            // Directory of plugins referred to by section [plugin], PluginDir if empty
+	BaseID            string            // ID of the built-in note at the base of the include chain, empty if there is none
+	SysconfigPrefix   string            // Prepended to the paths of the files read by the built-in note of BaseID
 }
 
 func (vend INISettings) Name() string {
 	return vend.DescriptiveName
 }
 
+/*
+Return IDs of the tuning sheets that are included by the sheet of the ID, directly or indirectly, base first, together
+with the ID of the built-in note at the base of the chain, empty if the chain does not end in a built-in note.
+An included sheet or built-in note is named by the "include" key in section [main]. Cyclic and dangling includes are
+errors.
+*/
+func GetIncludeChain(id string, sheets map[string]INISettings) (chain []string, builtinID string, err error) {
+	chain = make([]string, 0, 0)
+	seen := map[string]struct{}{id: {}}
+	for current := id; ; {
+		ini, err := txtparser.ParseINIFile(sheets[current].ConfFilePath, false)
+		if err != nil {
+			return nil, "", err
+		}
+		base := ini.KeyValue[INISectionMain][INIKeyInclude].Value
+		if base == "" {
+			return chain, "", nil
+		}
+		if _, isBuiltin := getBuiltinNotes("")[base]; isBuiltin {
+			return chain, base, nil
+		}
+		if _, exists := sheets[base]; !exists {
+			return nil, "", fmt.Errorf("note %s includes note %s, which is neither built into saptune nor defined by a tuning sheet", current, base)
+		}
+		if _, found := seen[base]; found {
+			return nil, "", fmt.Errorf("note %s includes note %s, which makes the includes cyclic", current, base)
+		}
+		seen[base] = struct{}{}
+		chain = append([]string{base}, chain...)
+		current = base
+	}
+}
+
+// Return the value of a directive in section [main] of the configuration file, or empty string if it is not given.
+func (vend INISettings) getMainDirective(key string) string {
+	ini, err := txtparser.ParseINIFile(vend.ConfFilePath, false)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(ini.KeyValue[INISectionMain][key].Value)
+}
+
+// Return the paths to the files included by the configuration file followed by the file itself, base first.
+func (vend INISettings) getChainFilePaths() []string {
+	return append(append([]string{}, vend.IncludedFilePaths...), vend.ConfFilePath)
+}
+
+// Return the built-in note at the base of the include chain, and false if there is none.
+func (vend INISettings) getBaseNote() (Note, bool) {
+	if vend.BaseID == "" {
+		return nil, false
+	}
+	base, exists := getBuiltinNotes(vend.SysconfigPrefix)[vend.BaseID]
+	return base, exists
+}
+
+// Return the names of the parameters of the note, sorted in ascending order.
+func getSortedParamNames(aNote Note) []string {
+	_, comparisons := CompareNoteFields(aNote, aNote)
+	names := make([]string, 0, len(comparisons))
+	for _, comparison := range FilterParameters(comparisons) {
+		names = append(names, GetParamName(comparison))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Return the values of the parameters of the note, parameter name VS value, in the text form of customisation files.
+func getParamValues(aNote Note) map[string]string {
+	values := make(map[string]string)
+	_, comparisons := CompareNoteFields(aNote, aNote)
+	for _, comparison := range FilterParameters(comparisons) {
+		values[GetParamName(comparison)] = fmt.Sprint(comparison.ExpectedValue)
+	}
+	return values
+}
+
+/*
+Return the built-in note at the base of the include chain, holding the values of the parameters in section [builtin]
+of the sheet. The built-in note is initialised beforehand, so that the parameters kept in maps are known.
+*/
+func (vend INISettings) getBaseWithValues() (Note, error) {
+	base, _ := vend.getBaseNote()
+	initialised, err := base.Initialise()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, name := range getSortedParamNames(initialised) {
+		if value, exists := vend.SysctlParams[name]; exists {
+			values[name] = value
+		}
+	}
+	return ApplyOverrides(initialised, values)
+}
+
+/*
+Return the values of a per-parameter directive in section [main] of the configuration file and the files it includes,
+base first and separated by spaces. Hence the declarations accumulate along the include chain, and where a later file
+declares the same parameter again, its declaration overrides the earlier one.
+*/
+func (vend INISettings) getParamDirective(key string) string {
+	values := make([]string, 0, len(vend.IncludedFilePaths)+1)
+	for _, fileName := range vend.getChainFilePaths() {
+		ini, err := txtparser.ParseINIFile(fileName, false)
+		if err != nil {
+			continue
+		}
+		if value := strings.TrimSpace(ini.KeyValue[INISectionMain][key].Value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, " ")
+}
+
+func (vend INISettings) RebootRequiredParams() []string {
+	ret := strings.Fields(vend.getParamDirective(INIKeyReboot))
+	if base, hasBase := vend.getBaseNote(); hasBase {
+		if rebootNote, ok := base.(RebootRequired); ok {
+			ret = append(rebootNote.RebootRequiredParams(), ret...)
+		}
+	}
+	return ret
+}
+
+func (vend INISettings) RecommendedParams() []string {
+	return strings.Fields(vend.getParamDirective(INIKeyRecommended))
+}
+
+/*
+Return the parameters that are high-risk to change, each one is declared in section [main] by a key of the parameter
+name prefixed by "risk.", e.g. "risk.vm.nr_hugepages = reserving huge pages withdraws memory from applications".
+The declarations accumulate along the include chain like those of getParamDirective.
+*/
+func (vend INISettings) HighRiskParams() map[string]string {
+	ret := make(map[string]string)
+	for _, fileName := range vend.getChainFilePaths() {
+		ini, err := txtparser.ParseINIFile(fileName, false)
+		if err != nil {
+			continue
+		}
+		for key, entry := range ini.KeyValue[INISectionMain] {
+			if strings.HasPrefix(key, INIKeyRiskPrefix) && len(key) > len(INIKeyRiskPrefix) {
+				// The parser separates multiple values by tab, the risk is a sentence
+				ret[strings.TrimPrefix(key, INIKeyRiskPrefix)] = strings.Replace(strings.TrimSpace(entry.Value), "\t", " ", -1)
+			}
+		}
+	}
+	return ret
+}
+
+func (vend INISettings) RequiredModules() map[string]string {
+	ret := make(map[string]string)
+	for _, paramModule := range strings.Fields(vend.getParamDirective(INIKeyModules)) {
+		if fields := strings.SplitN(paramModule, ":", 2); len(fields) == 2 {
+			ret[fields[0]] = fields[1]
+		} else {
+			log.Printf("3rdPartyTuningOption %s: skip malformed module requirement \"%s\"", vend.ConfFilePath, paramModule)
+		}
+	}
+	return ret
+}
+
+func (vend INISettings) DeprecatedBy() string {
+	return vend.getMainDirective(INIKeyDeprecatedBy)
+}
+
+func (vend INISettings) KernelVersionRange() (min, max string) {
+	return vend.getMainDirective(INIKeyKernelMin), vend.getMainDirective(INIKeyKernelMax)
+}
+
+func (vend INISettings) ProductVersions() []string {
+	return strings.Fields(vend.getMainDirective(INIKeyProductVers))
+}
+
+func (vend INISettings) RequiredPackages() []string {
+	return strings.Fields(vend.getMainDirective(INIKeyPackages))
+}
+
+// Categories of sysctl parameters by the prefix of their names.
+var sysctlCategories = map[string]string{"vm": "memory", "net": "network", "kernel": "kernel", "fs": "filesystem"}
+
+/*
+Return the category of each parameter, parameter VS category. The category follows from the section and the prefix
+of sysctl names, e.g. "net." parameters are "network", unless the "categories" key in section [main] declares another
+one, e.g. "categories = kernel.numa_balancing:memory".
+*/
+func (vend INISettings) ParamCategories() map[string]string {
+	ret := make(map[string]string)
+	if base, hasBase := vend.getBaseNote(); hasBase {
+		if categorisedNote, ok := base.(Categorised); ok {
+			for name, category := range categorisedNote.ParamCategories() {
+				ret[name] = category
+			}
+		}
+	}
+	if ini, err := vend.parseINI(); err == nil {
+		for _, entry := range ini.AllValues {
+			switch entry.Section {
+			case INISectionSysctl:
+				if category, known := sysctlCategories[strings.SplitN(entry.Key, ".", 2)[0]]; known {
+					ret[entry.Key] = category
+				}
+			case INISectionVM:
+				ret[entry.Key] = "memory"
+			case INISectionBlock, INISectionLimits:
+				ret[entry.Key] = entry.Section
+			}
+		}
+	}
+	for _, paramCategory := range strings.Fields(vend.getParamDirective(INIKeyCategories)) {
+		if fields := strings.SplitN(paramCategory, ":", 2); len(fields) == 2 && fields[1] != "" {
+			ret[fields[0]] = fields[1]
+		} else {
+			log.Printf("3rdPartyTuningOption %s: skip malformed category \"%s\"", vend.ConfFilePath, paramCategory)
+		}
+	}
+	return ret
+}
+
+// Sysctl parameters whose values are quantities in a unit, they are compared by quantity unless declared otherwise.
+var sysctlUnits = map[string]string{
+	"kernel.shmmax":                   UnitBytes,
+	"vm.dirty_bytes":                  UnitBytes,
+	"vm.dirty_background_bytes":       UnitBytes,
+	"vm.min_free_kbytes":              UnitKilobytes,
+	"vm.admin_reserve_kbytes":         UnitKilobytes,
+	"vm.user_reserve_kbytes":          UnitKilobytes,
+	"net.core.rmem_max":               UnitBytes,
+	"net.core.wmem_max":               UnitBytes,
+	"net.core.rmem_default":           UnitBytes,
+	"net.core.wmem_default":           UnitBytes,
+	"net.ipv4.tcp_limit_output_bytes": UnitBytes,
+}
+
+/*
+Return the units of parameters whose values are quantities, so that e.g. "1024 kB" matches "1048576" bytes. Well-known
+sysctl parameters have a unit by default, the "units" key in section [main] declares the unit of other parameters,
+e.g. "units = vm.dirty_bytes:bytes vm.min_free_kbytes:kilobytes".
+*/
+func (vend INISettings) ParamUnits() map[string]string {
+	ret := make(map[string]string)
+	for paramName := range vend.SysctlParams {
+		if unit, known := sysctlUnits[paramName]; known {
+			ret[paramName] = unit
+		}
+	}
+	for _, paramUnit := range strings.Fields(vend.getParamDirective(INIKeyUnits)) {
+		if fields := strings.SplitN(paramUnit, ":", 2); len(fields) == 2 && fields[1] != "" {
+			ret[fields[0]] = fields[1]
+		} else {
+			log.Printf("3rdPartyTuningOption %s: skip malformed unit \"%s\"", vend.ConfFilePath, paramUnit)
+		}
+	}
+	return ret
+}
+
+func (vend INISettings) RequiredMounts() map[string]string {
+	ret := make(map[string]string)
+	if base, hasBase := vend.getBaseNote(); hasBase {
+		if mountNote, ok := base.(MountRequired); ok {
+			for name, mountPoint := range mountNote.RequiredMounts() {
+				ret[name] = mountPoint
+			}
+		}
+	}
+	for _, paramMount := range strings.Fields(vend.getParamDirective(INIKeyMounts)) {
+		if fields := strings.SplitN(paramMount, ":", 2); len(fields) == 2 {
+			ret[fields[0]] = fields[1]
+		} else {
+			log.Printf("3rdPartyTuningOption %s: skip malformed mount requirement \"%s\"", vend.ConfFilePath, paramMount)
+		}
+	}
+	return ret
+}
+
+/*
+Return the parameters that must be applied after other parameters of the sheet, parameter VS prerequisites.
+They are declared by the "apply_after" key in section [main], e.g. "apply_after = net.ipv4.tcp_ecn_fallback:net.ipv4.tcp_ecn".
+*/
+func (vend INISettings) ApplyAfter() map[string][]string {
+	ret := make(map[string][]string)
+	for _, paramPrereq := range strings.Fields(vend.getParamDirective(INIKeyApplyAfter)) {
+		if fields := strings.SplitN(paramPrereq, ":", 2); len(fields) == 2 && fields[0] != "" && fields[1] != "" {
+			ret[fields[0]] = append(ret[fields[0]], fields[1])
+		} else {
+			log.Printf("3rdPartyTuningOption %s: skip malformed apply order \"%s\"", vend.ConfFilePath, paramPrereq)
+		}
+	}
+	return ret
+}
+
+/*
+Return the entries ordered so that each parameter comes after its prerequisites. Otherwise the entries keep their
+order in the file. Prerequisites that are not defined by the sheet are ignored, cyclic prerequisites are an error.
+*/
+func OrderINIEntries(entries []txtparser.INIEntry, applyAfter map[string][]string) ([]txtparser.INIEntry, error) {
+	if len(applyAfter) == 0 {
+		return entries, nil
+	}
+	defined := make(map[string]struct{})
+	for _, entry := range entries {
+		defined[entry.Key] = struct{}{}
+	}
+	ordered := make([]txtparser.INIEntry, 0, len(entries))
+	placed := make(map[string]struct{})
+	remaining := entries
+	for len(remaining) > 0 {
+		next := -1
+		for i, entry := range remaining {
+			ready := true
+			for _, prereq := range applyAfter[entry.Key] {
+				_, isDefined := defined[prereq]
+				_, isPlaced := placed[prereq]
+				ready = ready && (!isDefined || isPlaced)
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			return nil, fmt.Errorf("the apply order of parameter %s is cyclic", remaining[0].Key)
+		}
+		ordered = append(ordered, remaining[next])
+		placed[remaining[next].Key] = struct{}{}
+		// Limit the capacity, so that removing the entry does not overwrite the entries of the caller
+		remaining = append(remaining[:next:next], remaining[next+1:]...)
+	}
+	return ordered, nil
+}
+
+// Parse the configuration file and return its entries in the order of application.
+func (vend INISettings) orderedEntries() ([]txtparser.INIEntry, error) {
+	ini, err := vend.parseINI()
+	if err != nil {
+		return nil, err
+	}
+	return OrderINIEntries(ini.AllValues, vend.ApplyAfter())
+}
+
+// Return the values of parameters from section [sysctl], and the names of parameters from other sections.
+func (vend INISettings) SysctlValues() (sysctlValues map[string]string, otherParams []string) {
+	sysctlValues = make(map[string]string)
+	otherParams = make([]string, 0, 0)
+	content, err := vend.parseINI()
+	if err != nil {
+		return
+	}
+	for _, param := range content.AllValues {
+		if param.Section == INISectionSysctl {
+			sysctlValues[param.Key] = vend.SysctlParams[param.Key]
+		} else if param.Section != INISectionBuiltin {
+			otherParams = append(otherParams, param.Key)
+		}
+	}
+	// The built-in note tells which of its parameters are sysctl parameters
+	if _, hasBase := vend.getBaseNote(); hasBase {
+		base, err := vend.getBaseWithValues()
+		if err != nil {
+			return
+		}
+		if persistable, ok := base.(SysctlPersistable); ok {
+			baseSysctl, baseOther := persistable.SysctlValues()
+			for name, value := range baseSysctl {
+				if _, exists := sysctlValues[name]; !exists {
+					sysctlValues[name] = value
+				}
+			}
+			otherParams = append(otherParams, baseOther...)
+		}
+	}
+	return
+}
+
+/*
+Return the path to the plugin executable of the name. The name must not lead out of the plugin directory, and the
+plugin must be a regular file rather than e.g. a symbolic link to an executable elsewhere.
+*/
+func (vend INISettings) getPluginPath(name string) (string, error) {
+	pluginDir := vend.PluginDir
+	if pluginDir == "" {
+		pluginDir = PluginDir
+	}
+	if name == "" || strings.Contains(name, "/") || strings.Contains(name, "..") {
+		return "", fmt.Errorf("%s: plugin name \"%s\" in section [%s] must not contain \"/\" or \"..\"", vend.ConfFilePath, name, INISectionPlugin)
+	}
+	pluginPath := path.Join(pluginDir, name)
+	info, err := os.Lstat(pluginPath)
+	if err != nil {
+		return "", err
+	} else if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("plugin %s is not a regular file", pluginPath)
+	}
+	return pluginPath, nil
+}
+
+// Parse the configuration file, on top of the included ones. Entries of the including file override included ones.
+func (vend INISettings) parseINI() (*txtparser.INIFile, error) {
+	merged := &txtparser.INIFile{
+		AllValues: make([]txtparser.INIEntry, 0, 64),
+		KeyValue:  make(map[string]map[string]txtparser.INIEntry),
+	}
+	for _, fileName := range vend.getChainFilePaths() {
+		ini, err := txtparser.ParseINIFile(fileName, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range ini.AllValues {
+			if entry.Section == INISectionMain {
+				continue
+			}
+			if _, exists := merged.KeyValue[entry.Section][entry.Key]; exists {
+				for i, existing := range merged.AllValues {
+					if existing.Section == entry.Section && existing.Key == entry.Key {
+						merged.AllValues[i] = entry
+					}
+				}
+			} else {
+				merged.AllValues = append(merged.AllValues, entry)
+			}
+			if merged.KeyValue[entry.Section] == nil {
+				merged.KeyValue[entry.Section] = make(map[string]txtparser.INIEntry)
+			}
+			merged.KeyValue[entry.Section][entry.Key] = entry
+		}
+	}
+	return merged, nil
+}
+
+/*
+Return the parameters defined more than once in the same section of the sheet, each one is reported with the file and
+line of its repeated definition. Included sheets are not examined, they are validated on their own.
+*/
+func (vend INISettings) Validate() []error {
+	ret := make([]error, 0, 0)
+	content, err := ioutil.ReadFile(vend.ConfFilePath)
+	if err != nil {
+		return append(ret, err)
+	}
+	if ini, err := txtparser.ParseINIFile(vend.ConfFilePath, false); err == nil && len(ini.KeyValue[INISectionBuiltin]) > 0 {
+		base, hasBase := vend.getBaseNote()
+		known := make(map[string]bool)
+		if hasBase {
+			for _, name := range getSortedParamNames(base) {
+				known[name] = true
+			}
+		}
+		for _, entry := range ini.AllValues {
+			if entry.Section != INISectionBuiltin {
+				continue
+			} else if !hasBase {
+				ret = append(ret, fmt.Errorf("%s: %s in section [%s] requires the sheet to include a built-in note", vend.ConfFilePath, entry.Key, INISectionBuiltin))
+			} else if !known[entry.Key] {
+				ret = append(ret, fmt.Errorf("%s: %s in section [%s] is not a parameter of note %s", vend.ConfFilePath, entry.Key, INISectionBuiltin, vend.BaseID))
+			}
+		}
+	}
+	for _, dup := range txtparser.FindINIDuplicates(string(content)) {
+		for i := 1; i < len(dup.Lines); i++ {
+			if dup.Values[i] != dup.Values[0] {
+				ret = append(ret, fmt.Errorf("%s:%d: %s in section [%s] is set to \"%s\", which contradicts \"%s\" on line %d",
+					vend.ConfFilePath, dup.Lines[i], dup.Key, dup.Section, dup.Values[i], dup.Values[0], dup.Lines[0]))
+			} else {
+				ret = append(ret, fmt.Errorf("%s:%d: %s in section [%s] is already set on line %d",
+					vend.ConfFilePath, dup.Lines[i], dup.Key, dup.Section, dup.Lines[0]))
+			}
+		}
+	}
+	return ret
+}
+
 func (vend INISettings) Initialise() (Note, error) {
 	// Parse the configuration file
-	ini, err := txtparser.ParseINIFile(vend.ConfFilePath, false)
+	entries, err := vend.orderedEntries()
 	if err != nil {
 		return vend, err
 	}
 
-	// Read current parameter values
+	// Read current parameter values, those of the built-in note at the base are inspected by the built-in note
 	vend.SysctlParams = make(map[string]string)
-	for _, param := range ini.AllValues {
+	if base, hasBase := vend.getBaseNote(); hasBase {
+		initialisedBase, err := base.Initialise()
+		if err != nil {
+			return vend, err
+		}
+		vend.SysctlParams = getParamValues(initialisedBase)
+	}
+	for _, param := range entries {
 		switch param.Section {
+		case INISectionBuiltin:
+			continue
 		case INISectionSysctl:
 			vend.SysctlParams[param.Key], _ = system.GetSysctlString(param.Key)
 		case INISectionVM:
@@ -267,8 +814,18 @@ func (vend INISettings) Initialise() (Note, error) {
 			vend.SysctlParams[param.Key], _ = GetBlockVal(param.Key)
 		case INISectionLimits:
 			vend.SysctlParams[param.Key], _ = GetLimitsVal(param.Key)
+		case INISectionPlugin:
+			pluginPath, err := vend.getPluginPath(param.Key)
+			if err != nil {
+				return vend, err
+			}
+			resp, err := system.RunPlugin(pluginPath, system.PluginRequest{Action: system.PluginActionVerify, Parameter: param.Key})
+			if err != nil {
+				return vend, err
+			}
+			vend.SysctlParams[param.Key] = resp.Value
 		default:
-			// saptune does not yet understand settings outside of [sysctl] section
+			// saptune does not understand settings of other sections
 			log.Printf("3rdPartyTuningOption %s: skip unknown section %s", vend.ConfFilePath, param.Section)
 			continue
 		}
@@ -276,47 +833,206 @@ func (vend INISettings) Initialise() (Note, error) {
 	return vend, nil
 }
 
+/*
+Return the sheet holding the parameters of its file together with their values as declared by the file, e.g. ">10",
+the system is not inspected. The parameters of the built-in note at the base take their default values unless the
+file declares them.
+*/
+func (vend INISettings) Declare() (Note, error) {
+	entries, err := vend.orderedEntries()
+	if err != nil {
+		return vend, err
+	}
+	vend.SysctlParams = make(map[string]string)
+	if base, hasBase := vend.getBaseNote(); hasBase {
+		vend.SysctlParams = getParamValues(base)
+	}
+	for _, param := range entries {
+		switch param.Section {
+		case INISectionSysctl, INISectionVM, INISectionBlock, INISectionLimits, INISectionPlugin, INISectionBuiltin:
+			vend.SysctlParams[param.Key] = joinDeclaredValue(param)
+		}
+	}
+	return vend, nil
+}
+
 func (vend INISettings) Optimise() (Note, error) {
 	// Parse the configuration file
-	ini, err := txtparser.ParseINIFile(vend.ConfFilePath, false)
+	entries, err := vend.orderedEntries()
 	if err != nil {
 		return vend, err
 	}
+	// The built-in note at the base calculates its parameters, unless the sheets declare their values
+	baseValues := make(map[string]string)
+	if _, hasBase := vend.getBaseNote(); hasBase {
+		base, err := vend.getBaseWithValues()
+		if err != nil {
+			return vend, err
+		}
+		optimisedBase, err := base.Optimise()
+		if err != nil {
+			return vend, err
+		}
+		baseValues = getParamValues(optimisedBase)
+	}
 
-	for _, param := range ini.AllValues {
+	for _, param := range entries {
 		// Compare current values against INI's definition
 		switch param.Section {
-		case INISectionSysctl:
-			optimisedValue, err := CalculateOptimumValue(param.Operator, vend.SysctlParams[param.Key], param.Value)
+		case INISectionSysctl, INISectionVM, INISectionBlock, INISectionLimits, INISectionPlugin, INISectionBuiltin:
+			optimisedValue, err := vend.optimiseEntry(param, vend.SysctlParams[param.Key])
 			if err != nil {
 				return vend, err
 			}
 			vend.SysctlParams[param.Key] = optimisedValue
-		case INISectionVM:
-			vend.SysctlParams[param.Key] = OptVmVal(param.Key, vend.SysctlParams[param.Key], param.Value)
-		case INISectionBlock:
-			vend.SysctlParams[param.Key] = OptBlkVal(param.Key, vend.SysctlParams[param.Key], param.Value)
-		case INISectionLimits:
-			vend.SysctlParams[param.Key] = OptLimitsVal(vend.SysctlParams[param.Key], param.Value)
+			delete(baseValues, param.Key)
 		default:
-			// saptune does not yet understand settings outside of [sysctl] section
+			// saptune does not understand settings of other sections
 			log.Printf("3rdPartyTuningOption %s: skip unknown section %s", vend.ConfFilePath, param.Section)
 			continue
 		}
 	}
+	for name, value := range baseValues {
+		vend.SysctlParams[name] = value
+	}
 	return vend, nil
 }
 
+// Return the optimised value of the parameter of the entry, given its current value.
+func (vend INISettings) optimiseEntry(param txtparser.INIEntry, currentValue string) (string, error) {
+	switch param.Section {
+	case INISectionSysctl:
+		expectedValue, err := ResolveValue(param.Value, system.ParseMeminfo()[system.MemMainTotalKey]*1024)
+		if err != nil {
+			return "", fmt.Errorf("note %s, parameter %s: %v", vend.ID, param.Key, err)
+		}
+		optimisedValue, err := CalculateOptimumValue(param.Operator, currentValue, expectedValue)
+		if err != nil {
+			return "", fmt.Errorf("note %s, parameter %s: %v", vend.ID, param.Key, err)
+		}
+		return optimisedValue, nil
+	case INISectionVM:
+		return OptVmVal(param.Key, currentValue, param.Value), nil
+	case INISectionBlock:
+		return OptBlkVal(param.Key, currentValue, param.Value), nil
+	case INISectionLimits:
+		return OptLimitsVal(currentValue, param.Value), nil
+	case INISectionPlugin, INISectionBuiltin:
+		optimisedValue, err := CalculateOptimumValue(param.Operator, currentValue, param.Value)
+		if err != nil {
+			return "", fmt.Errorf("note %s, parameter %s: %v", vend.ID, param.Key, err)
+		}
+		return optimisedValue, nil
+	}
+	return "", fmt.Errorf("note %s, parameter %s: section %s is not understood", vend.ID, param.Key, param.Section)
+}
+
+/*
+Return the optimised value of the parameter as if the file declared it by the value given, e.g. ">10" or "75%", for
+its current value. The parameter must be declared by the file, as its section decides how it is optimised.
+*/
+func (vend INISettings) OptimiseParam(name, declared, currentValue string) (string, error) {
+	entries, err := vend.orderedEntries()
+	if err != nil {
+		return "", err
+	}
+	for _, param := range entries {
+		if param.Key == name {
+			param.Operator, param.Value = splitDeclaredValue(declared)
+			return vend.optimiseEntry(param, currentValue)
+		}
+	}
+	return "", fmt.Errorf("note %s does not declare parameter %s", vend.ID, name)
+}
+
+// Return the value of the entry as declared by the file, the operator precedes the value unless it is "=".
+func joinDeclaredValue(param txtparser.INIEntry) string {
+	if param.Operator == txtparser.OperatorEqual {
+		return param.Value
+	}
+	return string(param.Operator) + param.Value
+}
+
+// Split a value as declared by the file into its operator and value, e.g. ">10" into ">" and "10".
+func splitDeclaredValue(declared string) (txtparser.Operator, string) {
+	for _, operator := range []txtparser.Operator{txtparser.OperatorLessThan, txtparser.OperatorMoreThan} {
+		if strings.HasPrefix(declared, string(operator)) {
+			return operator, strings.TrimPrefix(declared, string(operator))
+		}
+	}
+	return txtparser.OperatorEqual, declared
+}
+
 func (vend INISettings) Apply() error {
+	return vend.applyEntries(nil, system.PluginActionApply)
+}
+
+// Restore the saved state like Apply, but ask the plugins to revert their parameters.
+func (vend INISettings) Revert() error {
+	return vend.applyEntries(nil, system.PluginActionRevert)
+}
+
+// Apply only the parameters of the given names, in their declared order.
+func (vend INISettings) ApplyOnly(params []string) error {
+	only := make(map[string]struct{})
+	for _, param := range params {
+		only[param] = struct{}{}
+	}
+	return vend.applyEntries(only, system.PluginActionApply)
+}
+
+/*
+Apply the parameters of the built-in note at the base whose names are in the set, or all of them if the set is nil.
+The built-in note reverts them if the plugin action is to revert and the built-in note is Revertible.
+*/
+func (vend INISettings) applyBase(only map[string]struct{}, pluginAction string) error {
+	if _, hasBase := vend.getBaseNote(); !hasBase {
+		return nil
+	}
+	base, err := vend.getBaseWithValues()
+	if err != nil {
+		return err
+	}
+	params := make([]string, 0, 0)
+	for _, name := range getSortedParamNames(base) {
+		if _, included := only[name]; only == nil || included {
+			params = append(params, name)
+		}
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	if revertible, ok := base.(Revertible); ok && pluginAction == system.PluginActionRevert {
+		return revertible.Revert()
+	} else if partial, ok := base.(PartiallyApplicable); ok && only != nil {
+		return partial.ApplyOnly(params)
+	}
+	return base.Apply()
+}
+
+/*
+Apply the parameters of the configuration file whose names are in the set, or all of them if the set is nil. Plugins
+are run with the plugin action.
+*/
+func (vend INISettings) applyEntries(only map[string]struct{}, pluginAction string) error {
 	errs := make([]error, 0, 0)
 	// Parse the configuration file
-	ini, err := txtparser.ParseINIFile(vend.ConfFilePath, false)
+	entries, err := vend.orderedEntries()
 	if err != nil {
 		return err
 	}
-	//for key, value := range vend.SysctlParams {
-	for _, param := range ini.AllValues {
+	// The built-in note at the base applies its parameters first
+	if err := vend.applyBase(only, pluginAction); err != nil {
+		errs = append(errs, err)
+	}
+	// Apply parameters in their declared order, prerequisites first
+	for _, param := range entries {
+		if _, included := only[param.Key]; only != nil && !included {
+			continue
+		}
 		switch param.Section {
+		case INISectionBuiltin:
+			continue
 		case INISectionSysctl:
 			// Apply sysctl parameters
 			errs = append(errs, system.SetSysctlString(param.Key, vend.SysctlParams[param.Key]))
@@ -326,12 +1042,28 @@ func (vend INISettings) Apply() error {
 			errs = append(errs, SetBlkVal(param.Key, vend.SysctlParams[param.Key]))
 		case INISectionLimits:
 			errs = append(errs, SetLimitsVal(param.Key, vend.SysctlParams[param.Key]))
+		case INISectionPlugin:
+			pluginPath, err := vend.getPluginPath(param.Key)
+			if err == nil {
+				_, err = system.RunPlugin(pluginPath, system.PluginRequest{Action: pluginAction, Parameter: param.Key, Value: vend.SysctlParams[param.Key]})
+			}
+			errs = append(errs, err)
 		default:
-			// saptune does not yet understand settings outside of [sysctl] section
+			// saptune does not understand settings of other sections
 			log.Printf("3rdPartyTuningOption %s: skip unknown section %s", vend.ConfFilePath, param.Section)
 			continue
 		}
 	}
-	err = sap.PrintErrors(errs)
+	// Parameters the environment does not permit to write are reported apart from genuine failures
+	limited := make([]system.CapabilityError, 0, 0)
+	for i, err := range errs {
+		if capErr, isCapErr := err.(system.CapabilityError); isCapErr {
+			limited = append(limited, capErr)
+			errs[i] = nil
+		}
+	}
+	if err = sap.PrintErrors(errs); err == nil && len(limited) > 0 {
+		return EnvironmentLimitedError{Limited: limited}
+	}
 	return err
 }
@@ -1,10 +1,14 @@
 package note
 
 import (
+	"github.com/HouzuoGuo/saptune/system"
 	"github.com/HouzuoGuo/saptune/txtparser"
+	"io/ioutil"
 	"os"
 	"path"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -70,3 +74,542 @@ func TestVendorSettings(t *testing.T) {
 		t.Fatal(i, err)
 	}
 }
+
+func TestIncludedVendorSettings(t *testing.T) {
+	tmpDir := "/tmp/saptunetest-include"
+	os.RemoveAll(tmpDir)
+	defer os.RemoveAll(tmpDir)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for fileName, content := range map[string]string{
+		"BASE-base.conf":       "[main]\nreboot_required = vm.swappiness\n[sysctl]\nvm.swappiness = 10\nvm.dirty_ratio = 20\n",
+		"DERIVED-derived.conf": "[main]\ninclude = BASE\nreboot_required = vm.dirty_ratio\n[sysctl]\nvm.dirty_ratio = 30\n",
+		"LOOPA-loop_a.conf":    "[main]\ninclude = LOOPB\n",
+		"LOOPB-loop_b.conf":    "[main]\ninclude = LOOPA\n",
+		"DANGLING-dangling":    "[main]\ninclude = 1234567\n",
+	} {
+		if err := ioutil.WriteFile(path.Join(tmpDir, fileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := GetTuningOptions("", tmpDir)
+	for _, id := range []string{"LOOPA", "LOOPB", "DANGLING"} {
+		if _, exists := opts[id]; exists {
+			t.Fatal(id)
+		}
+	}
+	derived, exists := opts["DERIVED"]
+	if !exists {
+		t.Fatal(opts)
+	}
+	ini, err := derived.(INISettings).parseINI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ini.AllValues) != 2 || ini.KeyValue["sysctl"]["vm.swappiness"].Value != "10" || ini.KeyValue["sysctl"]["vm.dirty_ratio"].Value != "30" {
+		t.Fatal(ini)
+	}
+	// Per-parameter directives accumulate along the include chain
+	if reboot := derived.(INISettings).RebootRequiredParams(); !reflect.DeepEqual(reboot, []string{"vm.swappiness", "vm.dirty_ratio"}) {
+		t.Fatal(reboot)
+	}
+}
+
+func TestIncludedBuiltinNote(t *testing.T) {
+	tmpDir := "/tmp/saptunetest-include-builtin"
+	os.RemoveAll(tmpDir)
+	defer os.RemoveAll(tmpDir)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for fileName, content := range map[string]string{
+		"EXTENDED-extended.conf": "[main]\ninclude = 1275776\n[builtin]\nKernelSemMni = 9000\n[sysctl]\nvm.swappiness = 10\n",
+		"DERIVED-derived.conf":   "[main]\ninclude = EXTENDED\n[sysctl]\nvm.dirty_ratio = 20\n",
+		"MISTAKE-mistake.conf":   "[main]\ninclude = 1275776\n[builtin]\nNoSuchField = 1\n",
+	} {
+		if err := ioutil.WriteFile(path.Join(tmpDir, fileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := GetTuningOptions(OSPackageInGOPATH, tmpDir)
+	extended, exists := opts["EXTENDED"].(INISettings)
+	if !exists || extended.BaseID != "1275776" || opts["DERIVED"].(INISettings).BaseID != "1275776" {
+		t.Fatal(opts)
+	}
+	if mistakes := opts["MISTAKE"].(INISettings).Validate(); len(mistakes) != 1 || !strings.Contains(mistakes[0].Error(), "NoSuchField") {
+		t.Fatal(mistakes)
+	}
+	// The definition holds the parameters of the built-in note, the sheet overrides one of them
+	declared, err := GetDeclaredParameters(extended)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if declared["SysctlParams[KernelSemMni]"].ExpectedValue != "9000" || declared["SysctlParams[KernelSemMsl]"].ExpectedValue != "0" || declared["SysctlParams[vm.swappiness]"].ExpectedValue != "10" {
+		t.Fatal(declared)
+	}
+	if categories := extended.ParamCategories(); categories["KernelSemMni"] != "kernel" {
+		t.Fatal(categories)
+	}
+	if mounts := extended.RequiredMounts(); mounts["ShmFileSystemSizeMB"] != "/dev/shm" {
+		t.Fatal(mounts)
+	}
+	initialised, err := extended.Initialise()
+	if err != nil {
+		t.Skip(err)
+	}
+	optimised, err := initialised.Optimise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := optimised.(INISettings).SysctlParams
+	if params["KernelSemMni"] != "9000" || params["vm.swappiness"] != "10" {
+		t.Fatal(params)
+	}
+	// The built-in note calculates the parameters that the sheet does not override
+	if semMsl, _ := strconv.ParseUint(params["KernelSemMsl"], 10, 64); semMsl < 1250 {
+		t.Fatal(params)
+	}
+	base, err := optimised.(INISettings).getBaseWithValues()
+	if err != nil || base.(PrepareForSAPEnvironments).KernelSemMni != 9000 {
+		t.Fatal(base, err)
+	}
+	if sysctlValues, _ := optimised.(INISettings).SysctlValues(); sysctlValues["vm.swappiness"] != "10" || !strings.HasSuffix(sysctlValues["kernel.sem"], " 9000") {
+		t.Fatal(sysctlValues)
+	}
+}
+
+func TestMissingModuleWarnings(t *testing.T) {
+	iniPath := "/tmp/saptunetest-modules.conf"
+	defer os.Remove(iniPath)
+	content := "[main]\nmodules = vm.swappiness:not_loaded does.not.exist:not_loaded\n[sysctl]\nvm.swappiness = 10\n"
+	if err := ioutil.WriteFile(iniPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	warnings := GetMissingModuleWarnings(INISettings{ConfFilePath: iniPath})
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "does.not.exist") {
+		t.Fatal(warnings)
+	}
+	if warnings := GetMissingModuleWarnings(HANARecommendedOSSettings{}); len(warnings) != 0 {
+		t.Fatal(warnings)
+	}
+}
+
+func TestDeprecatedBy(t *testing.T) {
+	iniPath := "/tmp/saptunetest-deprecated.conf"
+	defer os.Remove(iniPath)
+	if err := ioutil.WriteFile(iniPath, []byte("[main]\ndeprecated_by = 4711\n[sysctl]\nvm.swappiness = 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if replacement := GetReplacement(INISettings{ConfFilePath: iniPath}); replacement != "4711" {
+		t.Fatal(replacement)
+	}
+	if replacement := GetReplacement(HANARecommendedOSSettings{}); replacement != "" {
+		t.Fatal(replacement)
+	}
+}
+
+func TestKernelVersionRange(t *testing.T) {
+	defer func() { kernelVersion = system.GetKernelVersion }()
+	kernelVersion = func() (string, error) { return "4.12.14-122.37-default", nil }
+	iniPath := "/tmp/saptunetest-kernel.conf"
+	defer os.Remove(iniPath)
+	if err := ioutil.WriteFile(iniPath, []byte("[main]\nkernel_min = 5.3\n[sysctl]\nvm.swappiness = 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sheet := INISettings{ConfFilePath: iniPath, SysctlParams: map[string]string{"vm.swappiness": "60"}}
+	if err := CheckKernelVersion(sheet); err == nil {
+		t.Fatal("did not error")
+	}
+	// The parameters of a note on an unsupported kernel do not deviate, the reason is given instead
+	_, comparisons := CompareNoteFields(sheet, INISettings{ConfFilePath: iniPath, SysctlParams: map[string]string{"vm.swappiness": "10"}})
+	if !MarkNotApplicable(sheet, comparisons) || !strings.Contains(comparisons["SysctlParams[vm.swappiness]"].NotApplicable, "older than 5.3") {
+		t.Fatal(comparisons)
+	}
+	if err := ioutil.WriteFile(iniPath, []byte("[main]\nkernel_min = 4.12\nkernel_max = 4.12\n[sysctl]\nvm.swappiness = 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckKernelVersion(sheet); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckKernelVersion(HANARecommendedOSSettings{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequiredPackages(t *testing.T) {
+	defer func() { isPackageInstalled = system.IsPackageInstalled }()
+	isPackageInstalled = func(name string) bool { return name == "tuned" }
+	iniPath := "/tmp/saptunetest-packages.conf"
+	defer os.Remove(iniPath)
+	if err := ioutil.WriteFile(iniPath, []byte("[main]\npackages = tuned sapdb\n[sysctl]\nvm.swappiness = 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sheet := INISettings{ConfFilePath: iniPath, SysctlParams: map[string]string{"vm.swappiness": "60"}}
+	if err := CheckRequiredPackages(sheet); err == nil || err.Error() != "package sapdb not installed" {
+		t.Fatal(err)
+	}
+	isPackageInstalled = func(name string) bool { return true }
+	if err := CheckRequiredPackages(sheet); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckRequiredPackages(HANARecommendedOSSettings{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProductVersions(t *testing.T) {
+	iniPath := "/tmp/saptunetest-versions.conf"
+	defer os.Remove(iniPath)
+	if err := ioutil.WriteFile(iniPath, []byte("[main]\nsap_versions = 2.0* 1.00.122\n[sysctl]\nvm.swappiness = 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sheet := INISettings{ConfFilePath: iniPath}
+	if versions := sheet.ProductVersions(); !reflect.DeepEqual(versions, []string{"2.0*", "1.00.122"}) {
+		t.Fatal(versions)
+	}
+	for _, installed := range []string{"2.00.059", "1.00.122"} {
+		if err := CheckProductVersion(sheet, installed); err != nil {
+			t.Fatal(installed, err)
+		}
+	}
+	for _, installed := range []string{"1.00.121", ""} {
+		if err := CheckProductVersion(sheet, installed); err == nil {
+			t.Fatal(installed)
+		}
+	}
+	// Notes without version constraints suit all versions
+	if err := CheckProductVersion(HANARecommendedOSSettings{}, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParamCategories(t *testing.T) {
+	iniPath := "/tmp/saptunetest-categories.conf"
+	defer os.Remove(iniPath)
+	content := "[main]\ncategories = kernel.numa_balancing:memory\n[sysctl]\nvm.swappiness = 10\nnet.core.somaxconn = 4096\nkernel.numa_balancing = 0\nsunrpc.tcp_slot_table_entries = 128\n[limits]\nsapsys_nofile = 65536\n"
+	if err := ioutil.WriteFile(iniPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sheet := INISettings{ConfFilePath: iniPath, SysctlParams: map[string]string{"vm.swappiness": "10", "net.core.somaxconn": "4096", "kernel.numa_balancing": "0", "sunrpc.tcp_slot_table_entries": "128"}}
+	_, comparisons := CompareNoteFields(sheet, sheet)
+	categories := GetParamCategories(sheet)
+	for paramID, expected := range map[string]string{
+		"SysctlParams[vm.swappiness]":                 "memory",
+		"SysctlParams[net.core.somaxconn]":            "network",
+		"SysctlParams[kernel.numa_balancing]":         "memory",
+		"SysctlParams[sunrpc.tcp_slot_table_entries]": Uncategorised,
+		"ConfFilePath": Uncategorised,
+	} {
+		if category := GetParamCategory(categories, comparisons[paramID]); category != expected {
+			t.Fatal(paramID, category)
+		}
+	}
+	if categories["sapsys_nofile"] != "limits" {
+		t.Fatal(categories)
+	}
+}
+
+func TestGetDeclaredParameters(t *testing.T) {
+	iniPath := "/tmp/saptunetest-declared.conf"
+	defer os.Remove(iniPath)
+	content := "[main]\nreboot_required = vm.swappiness\n[sysctl]\nvm.swappiness = 10\nvm.dirty_ratio > 5\n[limits]\nsapsys_nofile = 65536\n"
+	if err := ioutil.WriteFile(iniPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	declared, err := GetDeclaredParameters(INISettings{ConfFilePath: iniPath, ID: "declared"})
+	if err != nil || len(declared) != 3 || declared["SysctlParams[vm.swappiness]"].ExpectedValueJS != "10" || declared["SysctlParams[sapsys_nofile]"].ExpectedValueJS != "65536" {
+		t.Fatal(declared, err)
+	}
+	if declared["SysctlParams[vm.dirty_ratio]"].ExpectedValue != ">5" {
+		t.Fatal(declared)
+	}
+	// A declaration other than the file's is optimised the way the file's section optimises it
+	ini := INISettings{ConfFilePath: iniPath, ID: "declared"}
+	if value, err := ini.OptimiseParam("vm.dirty_ratio", ">20", "10"); err != nil || value != "21" {
+		t.Fatal(value, err)
+	}
+	if value, err := ini.OptimiseParam("vm.dirty_ratio", ">20", "30"); err != nil || value != "30" {
+		t.Fatal(value, err)
+	}
+	if value, err := ini.OptimiseParam("vm.swappiness", "20", "10"); err != nil || value != "20" {
+		t.Fatal(value, err)
+	}
+	if _, err := ini.OptimiseParam("vm.dirty_bytes", "20", "10"); err == nil {
+		t.Fatal("did not error")
+	}
+	if _, err := GetDeclaredParameters(INISettings{ConfFilePath: "/tmp/saptunetest-does-not-exist.conf"}); err == nil {
+		t.Fatal("did not error")
+	}
+}
+
+func TestParamUnits(t *testing.T) {
+	iniPath := "/tmp/saptunetest-units.conf"
+	defer os.Remove(iniPath)
+	content := "[main]\nunits = vm.overcommit_kbytes:kilobytes\n[sysctl]\nvm.dirty_bytes = 1G\nvm.overcommit_kbytes = 4G\nvm.swappiness = 10\n"
+	if err := ioutil.WriteFile(iniPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected := INISettings{ConfFilePath: iniPath, SysctlParams: map[string]string{"vm.dirty_bytes": "1G", "vm.overcommit_kbytes": "4G", "vm.swappiness": "10"}}
+	actual := INISettings{ConfFilePath: iniPath, SysctlParams: map[string]string{"vm.dirty_bytes": "1073741824", "vm.overcommit_kbytes": "4194304", "vm.swappiness": "10 kB"}}
+	allMatch, comparisons := CompareNoteFields(actual, expected)
+	if allMatch {
+		t.Fatal(comparisons)
+	}
+	if comparison := comparisons["SysctlParams[vm.dirty_bytes]"]; !comparison.MatchExpectation || comparison.ExpectedValueJS != "1073741824" || comparison.ExpectedValue != "1G" {
+		t.Fatal(comparison)
+	}
+	if comparison := comparisons["SysctlParams[vm.overcommit_kbytes]"]; !comparison.MatchExpectation || comparison.ActualValueJS != "4194304" {
+		t.Fatal(comparison)
+	}
+	// Parameters without unit are compared by text
+	if comparison := comparisons["SysctlParams[vm.swappiness]"]; comparison.MatchExpectation || comparison.ActualValueJS != "10 kB" {
+		t.Fatal(comparison)
+	}
+}
+
+func TestHighRiskParams(t *testing.T) {
+	iniPath := "/tmp/saptunetest-risk.conf"
+	defer os.Remove(iniPath)
+	content := "[main]\nrisk.vm.nr_hugepages = reserving huge pages withdraws memory\nrisk. = nothing\n[sysctl]\nvm.nr_hugepages = 128\nvm.swappiness = 10\n"
+	if err := ioutil.WriteFile(iniPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ini := INISettings{ConfFilePath: iniPath}
+	if risks := ini.HighRiskParams(); !reflect.DeepEqual(risks, map[string]string{"vm.nr_hugepages": "reserving huge pages withdraws memory"}) {
+		t.Fatal(risks)
+	}
+	if risk := GetParamRisk(ini, NoteFieldComparison{ReflectFieldName: "SysctlParams", ReflectMapKey: "vm.swappiness"}); risk != "" {
+		t.Fatal(risk)
+	}
+	if risk := GetParamRisk(ini, NoteFieldComparison{ReflectFieldName: "SysctlParams", ReflectMapKey: "vm.nr_hugepages"}); risk == "" {
+		t.Fatal("risk is missing")
+	}
+	// Risks declared by an included sheet carry over, the including sheet may override them
+	basePath := "/tmp/saptunetest-risk-base.conf"
+	defer os.Remove(basePath)
+	if err := ioutil.WriteFile(basePath, []byte("[main]\nrisk.vm.nr_hugepages = base risk\nrisk.vm.swappiness = swapping\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ini.IncludedFilePaths = []string{basePath}
+	if risks := ini.HighRiskParams(); !reflect.DeepEqual(risks, map[string]string{"vm.nr_hugepages": "reserving huge pages withdraws memory", "vm.swappiness": "swapping"}) {
+		t.Fatal(risks)
+	}
+}
+
+func TestLoadINISettingsFile(t *testing.T) {
+	tmpDir := "/tmp/saptunetest-adhoc"
+	os.RemoveAll(tmpDir)
+	defer os.RemoveAll(tmpDir)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for fileName, content := range map[string]string{
+		"mynote.conf":        "[sysctl]\nvm.swappiness = 10\n",
+		"MINE-declared.conf": "[main]\nid = DECLARED\n[sysctl]\nvm.swappiness = 10\n",
+		"empty.conf":         "[main]\n",
+	} {
+		if err := ioutil.WriteFile(path.Join(tmpDir, fileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sheet, err := LoadINISettingsFile(path.Join(tmpDir, "mynote.conf"), TuningOptions{}); err != nil || sheet.ID != "mynote" {
+		t.Fatal(sheet, err)
+	}
+	if sheet, err := LoadINISettingsFile(path.Join(tmpDir, "MINE-declared.conf"), TuningOptions{}); err != nil || sheet.ID != "DECLARED" || sheet.Name() != "declared" {
+		t.Fatal(sheet, err)
+	}
+	if _, err := LoadINISettingsFile(path.Join(tmpDir, "empty.conf"), TuningOptions{}); err == nil {
+		t.Fatal("did not error")
+	}
+	if _, err := LoadINISettingsFile(path.Join(tmpDir, "does-not-exist.conf"), TuningOptions{}); err == nil {
+		t.Fatal("did not error")
+	}
+}
+
+func TestRecommendedParams(t *testing.T) {
+	iniPath := "/tmp/saptunetest-recommended.conf"
+	defer os.Remove(iniPath)
+	content := "[main]\nrecommended = vm.swappiness\n[sysctl]\nvm.swappiness = 10\nvm.dirty_ratio = 10\n"
+	if err := ioutil.WriteFile(iniPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected := INISettings{ConfFilePath: iniPath, SysctlParams: map[string]string{"vm.swappiness": "10", "vm.dirty_ratio": "10"}}
+	actual := INISettings{ConfFilePath: iniPath, SysctlParams: map[string]string{"vm.swappiness": "60", "vm.dirty_ratio": "20"}}
+	_, comparisons := CompareNoteFields(actual, expected)
+	MarkSeverity(expected, comparisons)
+	if comparison := comparisons["SysctlParams[vm.swappiness]"]; comparison.Severity != SeverityRecommended || IsMandatoryViolation(comparison) {
+		t.Fatal(comparison)
+	}
+	if comparison := comparisons["SysctlParams[vm.dirty_ratio]"]; comparison.Severity != SeverityMandatory || !IsMandatoryViolation(comparison) {
+		t.Fatal(comparison)
+	}
+	// Parameters of notes that do not grade them are all mandatory
+	MarkSeverity(SUSESysOptimisation{}, comparisons)
+	if comparison := comparisons["SysctlParams[vm.swappiness]"]; comparison.Severity != SeverityMandatory || !IsMandatoryViolation(comparison) {
+		t.Fatal(comparison)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	iniPath := "/tmp/saptunetest-validate.conf"
+	defer os.Remove(iniPath)
+	if err := ioutil.WriteFile(iniPath, []byte("[sysctl]\nvm.swappiness = 10\nvm.dirty_ratio = 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sheet := INISettings{ConfFilePath: iniPath}
+	if mistakes := ValidateNote(sheet); len(mistakes) != 0 {
+		t.Fatal(mistakes)
+	}
+	if err := ioutil.WriteFile(iniPath, []byte("[sysctl]\nvm.swappiness = 10\nvm.dirty_ratio = 10\n\nvm.swappiness = 60\nvm.dirty_ratio = 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mistakes := ValidateNote(sheet)
+	if len(mistakes) != 2 {
+		t.Fatal(mistakes)
+	}
+	if msg := mistakes[0].Error(); msg != iniPath+`:5: vm.swappiness in section [sysctl] is set to "60", which contradicts "10" on line 2` {
+		t.Fatal(msg)
+	}
+	if msg := mistakes[1].Error(); msg != iniPath+`:6: vm.dirty_ratio in section [sysctl] is already set on line 3` {
+		t.Fatal(msg)
+	}
+	// A sheet with mistakes is not loaded from an arbitrary file
+	if _, err := LoadINISettingsFile(iniPath, TuningOptions{}); err == nil {
+		t.Fatal("did not error")
+	}
+	// Notes implemented in Go have nothing to validate
+	if mistakes := ValidateNote(SUSESysOptimisation{}); len(mistakes) != 0 {
+		t.Fatal(mistakes)
+	}
+}
+
+func TestResolveValue(t *testing.T) {
+	for value, expected := range map[string]string{
+		"10":          "10",
+		"75%":         "750",
+		"0%":          "0",
+		"2K":          "2048",
+		"2m":          "2097152",
+		"1G":          "1073741824",
+		"noop":        "noop",
+		"250\t32000":  "250\t32000",
+		"ABCM":        "ABCM",
+		"18446744073": "18446744073",
+	} {
+		if resolved, err := ResolveValue(value, 1000); err != nil || resolved != expected {
+			t.Fatal(value, resolved, err)
+		}
+	}
+	for _, value := range []string{"101%", "-1%", "a%", "16777216T", "18446744073709551615K"} {
+		if resolved, err := ResolveValue(value, 1000); err == nil {
+			t.Fatal(value, resolved)
+		}
+	}
+}
+
+func TestPluginSettings(t *testing.T) {
+	tmpDir := "/tmp/saptunetest-plugin"
+	os.RemoveAll(tmpDir)
+	defer os.RemoveAll(tmpDir)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The plugin keeps its parameter value in a file
+	valueFile := path.Join(tmpDir, "value")
+	script := `#!/bin/sh
+read request
+case "$request" in
+	*'"action":"verify"'*) echo "{\"value\":\"$(cat ` + valueFile + `)\"}" ;;
+	*'"action":"apply"'*) echo "$request" | sed 's/.*"value":"\([^"]*\)".*/\1/' > ` + valueFile + ` ;;
+	*'"action":"revert"'*) echo "$request" | sed 's/.*"value":"\([^"]*\)".*/\1/' > ` + valueFile + `.reverted ;;
+esac
+`
+	if err := ioutil.WriteFile(path.Join(tmpDir, "queue_depth"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(valueFile, []byte("32"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(tmpDir, "sheet.conf"), []byte("[plugin]\nqueue_depth = 64\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vend := INISettings{ConfFilePath: path.Join(tmpDir, "sheet.conf"), ID: "plugin", PluginDir: tmpDir}
+	initialised, err := vend.Initialise()
+	if err != nil || initialised.(INISettings).SysctlParams["queue_depth"] != "32" {
+		t.Fatal(initialised, err)
+	}
+	optimised, err := initialised.Optimise()
+	if err != nil || optimised.(INISettings).SysctlParams["queue_depth"] != "64" {
+		t.Fatal(optimised, err)
+	}
+	if err := optimised.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(valueFile); err != nil || strings.TrimSpace(string(content)) != "64" {
+		t.Fatal(string(content), err)
+	}
+	// Restoring the saved state tells the plugin to revert rather than apply
+	saved := vend
+	saved.SysctlParams = map[string]string{"queue_depth": "32"}
+	if err := RestoreSaved(saved); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(valueFile + ".reverted"); err != nil || strings.TrimSpace(string(content)) != "32" {
+		t.Fatal(string(content), err)
+	}
+	// A missing plugin fails the inspection
+	if err := ioutil.WriteFile(path.Join(tmpDir, "sheet.conf"), []byte("[plugin]\nmissing = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vend.Initialise(); err == nil {
+		t.Fatal("did not error")
+	}
+	// A plugin must be a regular file in the plugin directory
+	if err := os.Symlink(path.Join(tmpDir, "queue_depth"), path.Join(tmpDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../saptunetest-plugin/queue_depth", "sub/queue_depth", "..", "link", ""} {
+		if pluginPath, err := vend.getPluginPath(name); err == nil {
+			t.Fatal(name, pluginPath)
+		}
+	}
+	if err := ioutil.WriteFile(path.Join(tmpDir, "sheet.conf"), []byte("[plugin]\nlink = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vend.Initialise(); err == nil {
+		t.Fatal("did not error")
+	}
+}
+
+func TestOrderINIEntries(t *testing.T) {
+	entries := []txtparser.INIEntry{{Key: "c"}, {Key: "b"}, {Key: "a"}}
+	keys := func(entries []txtparser.INIEntry) (ret []string) {
+		for _, entry := range entries {
+			ret = append(ret, entry.Key)
+		}
+		return
+	}
+	if ordered, err := OrderINIEntries(entries, map[string][]string{}); err != nil || strings.Join(keys(ordered), " ") != "c b a" {
+		t.Fatal(keys(ordered), err)
+	}
+	if ordered, err := OrderINIEntries(entries, map[string][]string{"c": {"a", "undefined"}, "b": {"c"}}); err != nil || strings.Join(keys(ordered), " ") != "a c b" {
+		t.Fatal(keys(ordered), err)
+	}
+	if strings.Join(keys(entries), " ") != "c b a" {
+		t.Fatal(keys(entries))
+	}
+	if _, err := OrderINIEntries(entries, map[string][]string{"c": {"b"}, "b": {"c"}}); err == nil {
+		t.Fatal("did not error")
+	}
+	// The order is declared in section [main]
+	iniPath := "/tmp/saptunetest-order.conf"
+	defer os.Remove(iniPath)
+	content := "[main]\napply_after = vm.dirty_ratio:vm.swappiness\n[sysctl]\nvm.dirty_ratio = 20\nvm.swappiness = 10\n"
+	if err := ioutil.WriteFile(iniPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ordered, err := (INISettings{ConfFilePath: iniPath}).orderedEntries(); err != nil || strings.Join(keys(ordered), " ") != "vm.swappiness vm.dirty_ratio" {
+		t.Fatal(keys(ordered), err)
+	}
+}
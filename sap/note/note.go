@@ -12,7 +12,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/HouzuoGuo/saptune/system"
+	"github.com/HouzuoGuo/saptune/txtparser"
+	"io"
 	"log"
+	"math"
 	"path"
 	"reflect"
 	"sort"
@@ -31,52 +34,695 @@ type Note interface {
 	Name() string              // The original note name.
 }
 
+/*
+A note that implements RebootRequired names its parameters that only take full effect after a reboot, such as huge
+page reservations that cannot be satisfied by a running system.
+*/
+type RebootRequired interface {
+	RebootRequiredParams() []string // Structure field names, or map keys if the structure field is a map.
+}
+
+// Return true only if the note declares the compared parameter to take effect only after a reboot.
+func IsRebootRequired(aNote Note, comparison NoteFieldComparison) bool {
+	rebootNote, ok := aNote.(RebootRequired)
+	if !ok {
+		return false
+	}
+	for _, name := range rebootNote.RebootRequiredParams() {
+		if (comparison.ReflectMapKey == "" && name == comparison.ReflectFieldName) || (comparison.ReflectMapKey != "" && name == comparison.ReflectMapKey) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+A note that implements PartiallyApplicable can apply a subset of its parameters, e.g. to leave alone the parameters
+that already have the desired value. Call on an optimised note.
+*/
+type PartiallyApplicable interface {
+	ApplyOnly(params []string) error // Structure field names, or map keys if the structure field is a map.
+}
+
+/*
+Apply the parameters of the optimised note that deviate according to the comparisons, and return the comparison names
+of the parameters that already had the desired value and were not written. A note that cannot apply a subset of its
+parameters is applied in full, nothing is skipped then.
+*/
+func ApplyDeviating(optimised Note, comparisons map[string]NoteFieldComparison) (skipped []string, err error) {
+	skipped = make([]string, 0, 0)
+	partialNote, ok := optimised.(PartiallyApplicable)
+	if !ok {
+		return skipped, optimised.Apply()
+	}
+	params := make([]string, 0, len(comparisons))
+	for name, comparison := range comparisons {
+		if comparison.MatchExpectation {
+			skipped = append(skipped, name)
+		} else {
+			params = append(params, GetParamName(comparison))
+		}
+	}
+	sort.Strings(skipped)
+	return skipped, partialNote.ApplyOnly(params)
+}
+
+/*
+A note that implements Revertible restores the state saved before it was applied differently from applying it, e.g.
+so that a plugin may tell a revert apart. Call on the saved state of the note.
+*/
+type Revertible interface {
+	Revert() error
+}
+
+// Restore the saved state of a note, by reverting it if the note is Revertible, or by applying it otherwise.
+func RestoreSaved(saved Note) error {
+	if revertible, ok := saved.(Revertible); ok {
+		return revertible.Revert()
+	}
+	return saved.Apply()
+}
+
+/*
+A note that implements ModuleRequired names the kernel modules that provide some of its sysctl parameters.
+Such a parameter does not exist, and applying it silently does nothing, until the module is loaded.
+*/
+type ModuleRequired interface {
+	RequiredModules() map[string]string // Sysctl parameter name VS kernel module name
+}
+
+/*
+Return a warning for each sysctl parameter of the note that is absent from the running kernel, because the kernel
+module providing the parameter is not loaded.
+*/
+func GetMissingModuleWarnings(aNote Note) (warnings []string) {
+	warnings = make([]string, 0, 0)
+	moduleNote, ok := aNote.(ModuleRequired)
+	if !ok {
+		return
+	}
+	modules := moduleNote.RequiredModules()
+	params := make([]string, 0, len(modules))
+	for param := range modules {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		if !system.IsSysctlAvailable(param) && !system.IsKernelModuleLoaded(modules[param]) {
+			warnings = append(warnings, fmt.Sprintf("parameter %s requires kernel module %s which isn't loaded", param, modules[param]))
+		}
+	}
+	return
+}
+
+/*
+A note that implements MountRequired names its parameters that only apply to the file system mounted at a specific
+mount point. Such a parameter cannot be verified while the file system is not mounted, e.g. because it is mounted late
+in boot.
+*/
+type MountRequired interface {
+	RequiredMounts() map[string]string // Structure field name, or map key if the structure field is a map, VS mount point
+}
+
+// NotMounted is the reason why a parameter of a MountRequired note is not applicable.
+const NotMounted = "filesystem not mounted"
+
+// Return true only if a file system is mounted at the mount point, it is a variable so that tests may replace it.
+var isMounted = system.IsMounted
+
+/*
+Parameters that the kernel command line may set at boot time, parameter name (structure field name or map key) VS
+kernel command line parameter.
+*/
+var cmdlineParams = map[string]string{
+	"vm.nr_hugepages":             "hugepages",
+	"VMNumberHugePages":           "hugepages",
+	"INI_THP":                     "transparent_hugepage",
+	"KernelMMTransparentHugepage": "transparent_hugepage",
+	"kernel.numa_balancing":       "numa_balancing",
+	"KernelNumaBalancing":         "numa_balancing",
+}
+
+// Return the parameters of the kernel command line, it is a variable so that tests may replace it.
+var kernelCmdline = system.GetKernelCmdline
+
+// Severities of parameters, SAP requires mandatory parameters to be set for support and merely recommends the others.
+const (
+	SeverityMandatory   = "mandatory"
+	SeverityRecommended = "recommended"
+)
+
+/*
+A note that implements Graded tells the parameters that are merely recommended apart from the mandatory ones. All
+parameters of other notes are mandatory.
+*/
+type Graded interface {
+	RecommendedParams() []string // Structure field names, or map keys if the structure field is a map.
+}
+
+// Set the severity of the compared parameters as declared by the note.
+func MarkSeverity(aNote Note, comparisons map[string]NoteFieldComparison) {
+	recommended := make(map[string]struct{})
+	if gradedNote, ok := aNote.(Graded); ok {
+		for _, name := range gradedNote.RecommendedParams() {
+			recommended[name] = struct{}{}
+		}
+	}
+	for paramID, comparison := range comparisons {
+		comparison.Severity = SeverityMandatory
+		if _, isRecommended := recommended[GetParamName(comparison)]; isRecommended {
+			comparison.Severity = SeverityRecommended
+		}
+		comparisons[paramID] = comparison
+	}
+}
+
+// Return true only if the parameter deviates and is not merely recommended.
+func IsMandatoryViolation(comparison NoteFieldComparison) bool {
+	return !comparison.MatchExpectation && comparison.Severity != SeverityRecommended
+}
+
+/*
+Mark the comparisons of parameters whose file system is not mounted as not applicable, they then no longer deviate.
+So are deviating parameters that the kernel command line sets to another value, they cannot be changed at runtime. A
+parameter that deviates although the kernel command line sets the expected value still deviates.
+All comparisons are not applicable if the running kernel is not supported by the note.
+Return true only if all comparisons match or are not applicable.
+*/
+func MarkNotApplicable(aNote Note, comparisons map[string]NoteFieldComparison) (allMatch bool) {
+	allMatch = true
+	mountNote, ok := aNote.(MountRequired)
+	var mounts map[string]string
+	if ok {
+		mounts = mountNote.RequiredMounts()
+	}
+	kernelErr := CheckKernelVersion(aNote)
+	cmdline, err := kernelCmdline()
+	if err != nil {
+		log.Printf("MarkNotApplicable: failed to read the kernel command line - %v", err)
+	}
+	for paramID, comparison := range comparisons {
+		cmdlineParam := cmdlineParams[GetParamName(comparison)]
+		cmdlineValue, governed := cmdline[cmdlineParam]
+		if kernelErr != nil {
+			comparison.NotApplicable = kernelErr.Error()
+			comparison.MatchExpectation = true
+			comparisons[paramID] = comparison
+		} else if mountPoint, scoped := mounts[GetParamName(comparison)]; scoped && !isMounted(mountPoint) {
+			comparison.NotApplicable = NotMounted
+			comparison.MatchExpectation = true
+			comparisons[paramID] = comparison
+		} else if governed && !comparison.MatchExpectation && cmdlineValue != fmt.Sprint(comparison.ExpectedValue) {
+			comparison.NotApplicable = fmt.Sprintf("governed by the kernel cmdline %s=%s and cannot be changed at runtime", cmdlineParam, cmdlineValue)
+			comparison.MatchExpectation = true
+			comparisons[paramID] = comparison
+		}
+		if !comparison.MatchExpectation {
+			allMatch = false
+		}
+	}
+	return
+}
+
+/*
+A note that implements KernelRequired only suits a range of kernel versions, e.g. because its recommendations are
+harmful on older kernels. A version matches all kernels that it is a prefix of, e.g. "5.3" matches 5.3.18.
+*/
+type KernelRequired interface {
+	KernelVersionRange() (min, max string) // Lowest and highest supported kernel version, empty if unbounded.
+}
+
+var kernelVersion = system.GetKernelVersion
+
+// Return an error that explains why the running kernel is outside of the kernel versions supported by the note.
+func CheckKernelVersion(aNote Note) error {
+	kernelNote, ok := aNote.(KernelRequired)
+	if !ok {
+		return nil
+	}
+	min, max := kernelNote.KernelVersionRange()
+	if min == "" && max == "" {
+		return nil
+	}
+	running, err := kernelVersion()
+	if err != nil {
+		return err
+	}
+	if min != "" && system.CompareKernelVersions(running, min) < 0 {
+		return fmt.Errorf("kernel %s is older than %s, the oldest kernel supported by the note", running, min)
+	} else if max != "" && system.CompareKernelVersions(running, max) > 0 {
+		return fmt.Errorf("kernel %s is newer than %s, the newest kernel supported by the note", running, max)
+	}
+	return nil
+}
+
+/*
+A note that implements PackageRequired only takes effect if some software packages are installed, e.g. because it
+tunes parameters for a specific database.
+*/
+type PackageRequired interface {
+	RequiredPackages() []string // Names of the packages, empty if the note takes effect regardless.
+}
+
+// Return true only if the package is installed, it is a variable so that tests may replace it.
+var isPackageInstalled = system.IsPackageInstalled
+
+// Return an error that names the packages required by the note that are not installed.
+func CheckRequiredPackages(aNote Note) error {
+	packageNote, ok := aNote.(PackageRequired)
+	if !ok {
+		return nil
+	}
+	missing := make([]string, 0, 0)
+	for _, name := range packageNote.RequiredPackages() {
+		if !isPackageInstalled(name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("package %s not installed", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Mark all comparisons as not applicable for the reason, they then no longer deviate.
+func MarkAllNotApplicable(comparisons map[string]NoteFieldComparison, reason string) {
+	for paramID, comparison := range comparisons {
+		comparison.NotApplicable = reason
+		comparison.MatchExpectation = true
+		comparisons[paramID] = comparison
+	}
+}
+
+/*
+EnvironmentLimitedError is returned by Apply of a note whose other parameters have been applied, but some parameters
+could not be written because the environment lacks the capabilities to do so, e.g. in a restricted container.
+*/
+type EnvironmentLimitedError struct {
+	Limited []system.CapabilityError
+}
+
+func (limitedErr EnvironmentLimitedError) Error() string {
+	msgs := make([]string, 0, len(limitedErr.Limited))
+	for _, capErr := range limitedErr.Limited {
+		msgs = append(msgs, capErr.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+/*
+A note that implements ProductVersionRequired only suits some versions of the installed SAP product. The versions are
+shell patterns, e.g. "2.0*" matches 2.00.059.
+*/
+type ProductVersionRequired interface {
+	ProductVersions() []string // Patterns of the supported product versions, empty if the note suits all versions.
+}
+
+/*
+Return an error that explains why the installed SAP product version is not among the versions supported by the note.
+Notes without version constraints suit all versions, even an unknown one.
+*/
+func CheckProductVersion(aNote Note, installed string) error {
+	versionNote, ok := aNote.(ProductVersionRequired)
+	if !ok {
+		return nil
+	}
+	patterns := versionNote.ProductVersions()
+	if len(patterns) == 0 {
+		return nil
+	}
+	if installed == "" {
+		return fmt.Errorf("the note is limited to product versions %s, but the installed version is unknown", strings.Join(patterns, " "))
+	}
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, installed); err == nil && matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("product version %s does not match %s, the versions supported by the note", installed, strings.Join(patterns, " "))
+}
+
+/*
+A note that implements Categorised sorts its parameters into categories such as "memory" or "network", so that
+parameters of all notes can be reviewed by category.
+*/
+type Categorised interface {
+	ParamCategories() map[string]string // Structure field name, or map key if the structure field is a map, VS category
+}
+
+// Uncategorised is the category of parameters whose note does not declare one.
+const Uncategorised = "uncategorized"
+
+/*
+Return the categories declared by the note, parameter VS category, or nil if the note does not declare any. Inspect
+them once for all parameters of the note, as an INI note parses its file to find them.
+*/
+func GetParamCategories(aNote Note) map[string]string {
+	if categorisedNote, ok := aNote.(Categorised); ok {
+		return categorisedNote.ParamCategories()
+	}
+	return nil
+}
+
+// Return the category of the compared parameter among the categories of its note, or Uncategorised if there is none.
+func GetParamCategory(categories map[string]string, comparison NoteFieldComparison) string {
+	if category := categories[GetParamName(comparison)]; category != "" {
+		return category
+	}
+	return Uncategorised
+}
+
+// A note that implements Deprecated has been superseded by another note, it may still be applied for compatibility.
+type Deprecated interface {
+	DeprecatedBy() string // ID of the note that replaces it, empty if the note is not deprecated.
+}
+
+// Return the ID of the note that replaces the deprecated note, or empty string if the note is not deprecated.
+func GetReplacement(aNote Note) string {
+	if deprecatedNote, ok := aNote.(Deprecated); ok {
+		return deprecatedNote.DeprecatedBy()
+	}
+	return ""
+}
+
+/*
+A note that implements Declarative tells the parameters it defines without inspecting the system, e.g. a tuning sheet
+whose parameters are map keys that are otherwise only known after the note is initialised.
+*/
+type Declarative interface {
+	Declare() (Note, error) // The note holding the parameters of its definition together with their defined values.
+}
+
+/*
+Return the comparisons of the parameters defined by the note, comparison name VS comparison, without inspecting the
+system. Fields that describe the note are left out. The values are those of the definition where the note tells them.
+*/
+func GetDeclaredParameters(aNote Note) (map[string]NoteFieldComparison, error) {
+	if declarativeNote, ok := aNote.(Declarative); ok {
+		declared, err := declarativeNote.Declare()
+		if err != nil {
+			return nil, err
+		}
+		aNote = declared
+	}
+	_, comparisons := CompareNoteFields(aNote, aNote)
+	return FilterParameters(comparisons), nil
+}
+
+/*
+A note that implements Redefinable can optimise its parameters by values declared otherwise than by its current
+definition, e.g. by a definition recorded before the note was updated.
+*/
+type Redefinable interface {
+	OptimiseParam(name, declared, currentValue string) (string, error) // Values in the text form of the definition.
+}
+
+/*
+A note that implements HighRisk names parameters that may destabilise a running system when they are changed, so that
+changing them may be confirmed beforehand.
+*/
+type HighRisk interface {
+	HighRiskParams() map[string]string // Structure field name, or map key if the structure field is a map, VS the risk
+}
+
+// Return the risk of changing the compared parameter declared by the note, or empty string if it is not high-risk.
+func GetParamRisk(aNote Note, comparison NoteFieldComparison) string {
+	if riskyNote, ok := aNote.(HighRisk); ok {
+		return riskyNote.HighRiskParams()[GetParamName(comparison)]
+	}
+	return ""
+}
+
+// A note that implements Validatable detects authoring mistakes in its own definition, e.g. in a tuning sheet.
+type Validatable interface {
+	Validate() []error // Empty if the definition is free of mistakes.
+}
+
+// Return the authoring mistakes in the definition of the note, empty if there are none or the note cannot tell.
+func ValidateNote(aNote Note) []error {
+	if validatableNote, ok := aNote.(Validatable); ok {
+		return validatableNote.Validate()
+	}
+	return []error{}
+}
+
+/*
+A note that implements UnitBearing declares the unit of parameters whose values may be written in different forms,
+e.g. "1048576" and "1024 kB", so that such values are compared by quantity rather than by text.
+*/
+type UnitBearing interface {
+	ParamUnits() map[string]string // Structure field name, or map key if the structure field is a map, VS unit
+}
+
+// Units that UnitBearing notes may declare, values of parameters in these units are normalised to plain integers.
+const (
+	UnitBytes     = "bytes"
+	UnitKilobytes = "kilobytes"
+)
+
+var canonicalUnits = map[string]uint64{UnitBytes: 1, UnitKilobytes: 1 << 10}
+
+var unitSuffixes = map[string]uint64{
+	"B": 1,
+	"K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+	"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20,
+	"G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30,
+	"T": 1 << 40, "TB": 1 << 40, "TIB": 1 << 40,
+}
+
+/*
+Normalise the value of a parameter in the unit to a plain integer in that unit, e.g. "1024 kB" in bytes is "1048576".
+A number without suffix is already in the unit. Digit group separators (e.g. "1,048,576") are disregarded. The value is
+returned unchanged if it is not a quantity, or if it is not a whole number in the unit.
+*/
+func NormaliseUnitValue(value, unit string) string {
+	divisor, known := canonicalUnits[unit]
+	trimmed := strings.TrimSpace(value)
+	if !known || trimmed == "" {
+		return value
+	}
+	numberEnd := strings.IndexFunc(trimmed, func(r rune) bool {
+		return (r < '0' || r > '9') && r != ',' && r != '_' && r != '.'
+	})
+	if numberEnd == -1 {
+		numberEnd = len(trimmed)
+	}
+	number := strings.NewReplacer(",", "", "_", "").Replace(trimmed[:numberEnd])
+	multiplier := divisor
+	if suffix := strings.ToUpper(strings.TrimSpace(trimmed[numberEnd:])); suffix != "" {
+		if multiplier, known = unitSuffixes[suffix]; !known {
+			return value
+		}
+	}
+	var quantity uint64
+	if strings.Contains(number, ".") {
+		fraction, err := strconv.ParseFloat(number, 64)
+		if err != nil || fraction*float64(multiplier) != math.Trunc(fraction*float64(multiplier)) {
+			return value
+		}
+		quantity = uint64(fraction * float64(multiplier))
+	} else {
+		integer, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return value
+		}
+		quantity = integer * multiplier
+	}
+	if quantity%divisor != 0 {
+		return value
+	}
+	return strconv.FormatUint(quantity/divisor, 10)
+}
+
+// Normalise the actual and expected value of the parameter if the note declares its unit and both values are text.
+func normaliseParamValues(units map[string]string, paramName string, actualValue, expectedValue interface{}) (interface{}, interface{}) {
+	unit, hasUnit := units[paramName]
+	actualStr, actualIsStr := actualValue.(string)
+	expectedStr, expectedIsStr := expectedValue.(string)
+	if !hasUnit || !actualIsStr || !expectedIsStr {
+		return actualValue, expectedValue
+	}
+	return NormaliseUnitValue(actualStr, unit), NormaliseUnitValue(expectedStr, unit)
+}
+
+/*
+A note that implements SysctlPersistable tells its sysctl parameters apart from other parameters, so that the sysctl
+parameters may be persisted in a sysctl drop-in file independent of tuned.
+*/
+type SysctlPersistable interface {
+	SysctlValues() (sysctlValues map[string]string, otherParams []string) // Call on an optimised note.
+}
+
 type TuningOptions map[string]Note // Collection of tuning options from SAP notes and 3rd party vendors.
 
-// Return all built-in tunable SAP notes together with those defined by 3rd party vendors.
-func GetTuningOptions(thirdPartyTuningDir string) TuningOptions {
+// LoadingTrace receives a trace of how the tuning sheets are discovered, parsed, and resolved, nil for no trace.
+var LoadingTrace io.Writer
+
+// Write a line into the loading trace, if there is one.
+func traceLoading(format string, args ...interface{}) {
+	if LoadingTrace != nil {
+		fmt.Fprintf(LoadingTrace, "trace-loading: "+format+"\n", args...)
+	}
+}
+
+// Return the notes built into saptune, the sysconfig prefix is prepended to the paths of the files they read.
+func getBuiltinNotes(sysconfigPrefix string) TuningOptions {
 	ret := TuningOptions{
 		"2205917":       HANARecommendedOSSettings{},
-		"1275776":       PrepareForSAPEnvironments{},
+		"1275776":       PrepareForSAPEnvironments{SysconfigPrefix: sysconfigPrefix},
 		"1984787":       AfterInstallation{},
 		"2161991":       VmwareGuestIOElevator{},
-		"SUSE-GUIDE-01": SUSESysOptimisation{},
-		"SUSE-GUIDE-02": SUSENetCPUOptimisation{},
+		"SUSE-GUIDE-01": SUSESysOptimisation{SysconfigPrefix: sysconfigPrefix},
+		"SUSE-GUIDE-02": SUSENetCPUOptimisation{SysconfigPrefix: sysconfigPrefix},
 	}
 	if system.IsPagecacheAvailable() {
-		ret["1557506"] = LinuxPagingImprovements{}
+		ret["1557506"] = LinuxPagingImprovements{SysconfigPrefix: sysconfigPrefix}
 	}
+	return ret
+}
+
+/*
+Return all built-in tunable SAP notes together with those defined by 3rd party vendors.
+The sysconfig prefix is prepended to the path of customisation files read by built-in notes, it is normally empty.
+*/
+func GetTuningOptions(sysconfigPrefix, thirdPartyTuningDir string) TuningOptions {
+	return GetTuningOptionsFrom(sysconfigPrefix, thirdPartyTuningDir)
+}
+
+/*
+Return all built-in tunable SAP notes together with those defined by 3rd party vendors in the directories. A tuning
+sheet overrides the sheet of the same ID in the directories before it, e.g. local sheets override fetched ones.
+*/
+func GetTuningOptionsFrom(sysconfigPrefix string, thirdPartyTuningDirs ...string) TuningOptions {
+	ret := getBuiltinNotes(sysconfigPrefix)
+	traceLoading("%d notes are built into saptune: %s", len(ret), strings.Join(ret.GetSortedIDs(), " "))
 
 	// Collect those defined by 3rd party
-	_, files, err := system.ListDir(thirdPartyTuningDir)
-	if err != nil {
-		// Not a fatal error
-		log.Printf("GetTuningOptions: failed to read 3rd party tuning definitions - %v", err)
-	}
-	for _, fileName := range files {
-		// By convention, the portion before dash makes up the ID.
-		idName := strings.SplitN(fileName, "-", 2)
-		if len(idName) != 2 {
-			log.Printf("GetTuningOptions: skip bad file name \"%s\"", fileName)
-			continue
+	sheets := make(map[string]INISettings)
+	for _, thirdPartyTuningDir := range thirdPartyTuningDirs {
+		traceLoading("scanning directory %s", thirdPartyTuningDir)
+		_, files, err := system.ListDir(thirdPartyTuningDir)
+		if err != nil {
+			// Not a fatal error
+			log.Printf("GetTuningOptions: failed to read 3rd party tuning definitions - %v", err)
+		}
+		for _, fileName := range files {
+			filePath := path.Join(thirdPartyTuningDir, fileName)
+			// By convention, the portion before dash makes up the ID.
+			idName := strings.SplitN(fileName, "-", 2)
+			if len(idName) != 2 {
+				log.Printf("GetTuningOptions: skip bad file name \"%s\"", fileName)
+				traceLoading("%s: skipped, the file name does not follow the convention ID-name.conf", filePath)
+				continue
+			}
+			id := idName[0]
+			// Just for the cosmetics, remove suffix .conf from description
+			name := strings.TrimSuffix(idName[1], ".conf")
+			// Do not allow vendor to override built-in
+			if _, exists := ret[id]; exists {
+				log.Printf("GetTuningOptions: vendor's \"%s\" will not override built-in tuning implementation", fileName)
+				traceLoading("%s: skipped, note %s is built into saptune and cannot be overridden", filePath, id)
+				continue
+			}
+			if LoadingTrace != nil {
+				if ini, err := txtparser.ParseINIFile(filePath, false); err != nil {
+					traceLoading("%s: failed to parse - %v", filePath, err)
+				} else {
+					traceLoading("%s: parsed %d settings in %d sections", filePath, len(ini.AllValues), len(ini.KeyValue))
+				}
+				if previous, exists := sheets[id]; exists {
+					traceLoading("%s: overrides %s as the definition of note %s", filePath, previous.ConfFilePath, id)
+				}
+				traceLoading("%s: contributes note %s (%s)", filePath, id, name)
+			}
+			sheets[id] = INISettings{
+				ConfFilePath:    filePath,
+				ID:              id,
+				DescriptiveName: name,
+				PluginDir:       path.Join(sysconfigPrefix, PluginDir),
+				SysconfigPrefix: sysconfigPrefix,
+			}
 		}
-		id := idName[0]
-		// Just for the cosmetics, remove suffix .conf from description
-		name := strings.TrimSuffix(idName[1], ".conf")
-		// Do not allow vendor to override built-in
-		if _, exists := ret[id]; exists {
-			log.Printf("GetTuningOptions: vendor's \"%s\" will not override built-in tuning implementation", fileName)
+	}
+	// Resolve the sheets included by other sheets
+	for id, sheet := range sheets {
+		chain, builtinID, err := GetIncludeChain(id, sheets)
+		if err != nil {
+			log.Printf("GetTuningOptions: skip vendor's \"%s\" - %v", sheet.ConfFilePath, err)
+			traceLoading("note %s: skipped, its includes cannot be resolved - %v", id, err)
 			continue
 		}
-		ret[id] = INISettings{
-			ConfFilePath:    path.Join(thirdPartyTuningDir, fileName),
-			ID:              id,
-			DescriptiveName: name,
+		sheet.BaseID = builtinID
+		sheet.IncludedFilePaths = make([]string, 0, len(chain))
+		for _, includedID := range chain {
+			sheet.IncludedFilePaths = append(sheet.IncludedFilePaths, sheets[includedID].ConfFilePath)
+		}
+		for _, mistake := range sheet.Validate() {
+			log.Printf("GetTuningOptions: %v", mistake)
 		}
+		if builtinID != "" {
+			traceLoading("note %s: extends built-in note %s", id, builtinID)
+		}
+		if len(chain) > 0 {
+			traceLoading("note %s: includes %s, base first", id, strings.Join(sheet.IncludedFilePaths, " "))
+		}
+		ret[id] = sheet
 	}
+	traceLoading("%d notes are loaded in total", len(ret))
 	return ret
 }
 
+/*
+Load a tuning sheet from an arbitrary file, outside of the 3rd party tuning directory, and validate it.
+The note ID is declared by key "id" in section [main], or otherwise follows the file name convention of tuning sheets.
+A sheet may include other sheets among the existing tuning options.
+*/
+func LoadINISettingsFile(filePath string, opts TuningOptions) (INISettings, error) {
+	ini, err := txtparser.ParseINIFile(filePath, false)
+	if err != nil {
+		return INISettings{}, err
+	}
+	fileName := path.Base(filePath)
+	id := strings.TrimSuffix(fileName, ".conf")
+	name := id
+	if idName := strings.SplitN(fileName, "-", 2); len(idName) == 2 {
+		id = idName[0]
+		name = strings.TrimSuffix(idName[1], ".conf")
+	}
+	if declaredID := strings.TrimSpace(ini.KeyValue[INISectionMain][INIKeyID].Value); declaredID != "" {
+		id = declaredID
+	}
+	sheet := INISettings{ConfFilePath: filePath, ID: id, DescriptiveName: name}
+	// Resolve includes against the existing tuning sheets
+	sheets := map[string]INISettings{id: sheet}
+	for otherID, opt := range opts {
+		if otherSheet, ok := opt.(INISettings); ok && otherID != id {
+			sheets[otherID] = otherSheet
+		}
+	}
+	chain, builtinID, err := GetIncludeChain(id, sheets)
+	if err != nil {
+		return INISettings{}, err
+	}
+	sheet.BaseID = builtinID
+	sheet.IncludedFilePaths = make([]string, 0, len(chain))
+	for _, includedID := range chain {
+		sheet.IncludedFilePaths = append(sheet.IncludedFilePaths, sheets[includedID].ConfFilePath)
+	}
+	merged, err := sheet.parseINI()
+	if err != nil {
+		return INISettings{}, err
+	}
+	if len(merged.AllValues) == 0 {
+		return INISettings{}, fmt.Errorf("the file %s does not define any tunable parameter", filePath)
+	}
+	if mistakes := sheet.Validate(); len(mistakes) > 0 {
+		return INISettings{}, mistakes[0]
+	}
+	traceLoading("%s: contributes note %s (%s) from outside of the tuning sheet directories", filePath, id, name)
+	return sheet, nil
+}
+
 // Return all tuning option IDs, sorted in ascending order.
 func (opts *TuningOptions) GetSortedIDs() (ret []string) {
 	ret = make([]string, 0, len(*opts))
@@ -87,13 +733,60 @@ func (opts *TuningOptions) GetSortedIDs() (ret []string) {
 	return
 }
 
-// Record the actual value versus expected value for a note field. The field name has to be the actual name in Go struct.
+/*
+Record the actual value versus expected value for a note field. The field name has to be the actual name in Go struct.
+ParamID identifies the parameter in structured output and does not change when the display label is reworded.
+*/
 type NoteFieldComparison struct {
-	ReflectFieldName               string // Structure field name
-	ReflectMapKey                  string // If structure field is a map, this is the map key
-	ActualValue, ExpectedValue     interface{}
-	ActualValueJS, ExpectedValueJS string
-	MatchExpectation               bool
+	ParamID                    string      `json:"id"`                // Structure field name, followed by the map key in brackets if the field is a map
+	ReflectFieldName           string      `json:"field"`             // Structure field name
+	ReflectMapKey              string      `json:"map_key,omitempty"` // If structure field is a map, this is the map key
+	ActualValue, ExpectedValue interface{} `json:"-"`
+	ActualValueJS              string      `json:"actual"`
+	ExpectedValueJS            string      `json:"expected"`
+	MatchExpectation           bool        `json:"match"`
+	NotApplicable              string      `json:"not_applicable,omitempty"` // Reason why the parameter cannot be verified on this system, empty if it can
+	Severity                   string      `json:"severity,omitempty"`       // SeverityMandatory or SeverityRecommended, empty if the comparison is not graded
+}
+
+// Return the label of the parameter for display to the user, e.g. "vm.swappiness" rather than its ParamID.
+func (comparison NoteFieldComparison) Label() string {
+	if label := GetParamName(comparison); label != "" {
+		return label
+	}
+	return comparison.ParamID
+}
+
+// Fields of notes that describe the note itself, such as where it is defined, rather than tune the system.
+var metadataFields = map[string]bool{
+	"ID":                true,
+	"ConfFilePath":      true,
+	"IncludedFilePaths": true,
+	"BaseID":            true,
+	"DescriptiveName":   true,
+	"PluginDir":         true,
+	"SysconfigPrefix":   true,
+}
+
+// Return true only if the note field tunes the system, rather than describing the note itself.
+func IsParameterField(fieldName string) bool {
+	return !metadataFields[fieldName]
+}
+
+// Return true only if the comparison is about a tunable parameter, rather than a field describing the note itself.
+func (comparison NoteFieldComparison) IsParameter() bool {
+	return IsParameterField(comparison.ReflectFieldName)
+}
+
+// Return the comparisons of tunable parameters, leaving out the fields that describe the note itself.
+func FilterParameters(comparisons map[string]NoteFieldComparison) map[string]NoteFieldComparison {
+	params := make(map[string]NoteFieldComparison)
+	for paramID, comparison := range comparisons {
+		if comparison.IsParameter() {
+			params[paramID] = comparison
+		}
+	}
+	return params
 }
 
 // Compare JSON representation of two values and see if they match.
@@ -125,6 +818,10 @@ func CompareNoteFields(actualNote, expectedNote Note) (allMatch bool, comparison
 	// Compare all fields
 	refActualNote := reflect.ValueOf(actualNote)
 	refExpectedNote := reflect.ValueOf(expectedNote)
+	var units map[string]string
+	if unitNote, ok := actualNote.(UnitBearing); ok {
+		units = unitNote.ParamUnits()
+	}
 	for i := 0; i < refActualNote.NumField(); i++ {
 		var fieldComparison NoteFieldComparison
 		// Retrieve actualField value from actual and expected note
@@ -137,8 +834,9 @@ func CompareNoteFields(actualNote, expectedNote Note) (allMatch bool, comparison
 			for _, key := range actualField.MapKeys() {
 				actualValue := actualField.MapIndex(key).Interface()
 				expectedValue := expectedMap.MapIndex(key).Interface()
-				actualValueJS, expectedValueJS, match := CompareJSValue(actualValue, expectedValue)
+				actualValueJS, expectedValueJS, match := CompareJSValue(normaliseParamValues(units, key.String(), actualValue, expectedValue))
 				fieldComparison = NoteFieldComparison{
+					ParamID:          fmt.Sprintf("%s[%s]", fieldName, key.String()),
 					ReflectFieldName: fieldName,
 					ReflectMapKey:    key.String(),
 					ActualValue:      actualValue,
@@ -147,7 +845,7 @@ func CompareNoteFields(actualNote, expectedNote Note) (allMatch bool, comparison
 					ExpectedValueJS:  expectedValueJS,
 					MatchExpectation: match,
 				}
-				comparisons[fmt.Sprintf("%s[%s]", fieldName, key.String())] = fieldComparison
+				comparisons[fieldComparison.ParamID] = fieldComparison
 				if !fieldComparison.MatchExpectation {
 					allMatch = false
 				}
@@ -156,8 +854,9 @@ func CompareNoteFields(actualNote, expectedNote Note) (allMatch bool, comparison
 			// Compare ordinary field value
 			actualValue := refActualNote.Field(i).Interface()
 			expectedValue := refExpectedNote.Field(i).Interface()
-			actualValueJS, expectedValueJS, match := CompareJSValue(actualValue, expectedValue)
+			actualValueJS, expectedValueJS, match := CompareJSValue(normaliseParamValues(units, fieldName, actualValue, expectedValue))
 			fieldComparison = NoteFieldComparison{
+				ParamID:          fieldName,
 				ReflectFieldName: fieldName,
 				ActualValue:      actualValue,
 				ExpectedValue:    expectedValue,
@@ -165,7 +864,7 @@ func CompareNoteFields(actualNote, expectedNote Note) (allMatch bool, comparison
 				ExpectedValueJS:  expectedValueJS,
 				MatchExpectation: match,
 			}
-			comparisons[fieldName] = fieldComparison
+			comparisons[fieldComparison.ParamID] = fieldComparison
 			if !fieldComparison.MatchExpectation {
 				allMatch = false
 			}
@@ -1,10 +1,15 @@
 package note
 
 import (
+	"bytes"
 	"encoding/json"
 	"github.com/HouzuoGuo/saptune/sap/param"
+	"github.com/HouzuoGuo/saptune/system"
+	"io/ioutil"
 	"os"
 	"path"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -117,12 +122,72 @@ func TestNoteSerialisation(t *testing.T) {
 }
 
 func TestGetTuningOptions(t *testing.T) {
-	allOpts := GetTuningOptions("")
+	allOpts := GetTuningOptions("", "")
 	if sorted := allOpts.GetSortedIDs(); len(allOpts) != len(sorted) {
 		t.Fatal(sorted, allOpts)
 	}
 }
 
+func TestLoadingTrace(t *testing.T) {
+	tmpDir := "/tmp/saptunetest-trace"
+	os.RemoveAll(tmpDir)
+	defer os.RemoveAll(tmpDir)
+	fetchedDir, localDir := path.Join(tmpDir, "fetched"), path.Join(tmpDir, "local")
+	os.MkdirAll(fetchedDir, 0755)
+	os.MkdirAll(localDir, 0755)
+	files := map[string]string{
+		path.Join(fetchedDir, "BASE-base.conf"):     "[sysctl]\nvm.swappiness = 10\n",
+		path.Join(fetchedDir, "SHEET-fetched.conf"): "[sysctl]\nvm.swappiness = 20\n",
+		path.Join(localDir, "SHEET-local.conf"):     "[main]\ninclude = BASE\n[sysctl]\nvm.swappiness = 30\n",
+		path.Join(localDir, "1275776-clash.conf"):   "[sysctl]\nvm.swappiness = 40\n",
+		path.Join(localDir, "badname.conf"):         "[sysctl]\nvm.swappiness = 50\n",
+	}
+	for filePath, content := range files {
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var trace bytes.Buffer
+	LoadingTrace = &trace
+	defer func() { LoadingTrace = nil }()
+	GetTuningOptionsFrom("", fetchedDir, localDir)
+	for _, expected := range []string{
+		"scanning directory " + localDir,
+		path.Join(localDir, "badname.conf") + ": skipped, the file name does not follow",
+		path.Join(localDir, "1275776-clash.conf") + ": skipped, note 1275776 is built into saptune",
+		path.Join(localDir, "SHEET-local.conf") + ": parsed 2 settings in 2 sections",
+		path.Join(localDir, "SHEET-local.conf") + ": overrides " + path.Join(fetchedDir, "SHEET-fetched.conf") + " as the definition of note SHEET",
+		path.Join(localDir, "SHEET-local.conf") + ": contributes note SHEET (local)",
+		"note SHEET: includes " + path.Join(fetchedDir, "BASE-base.conf") + ", base first",
+	} {
+		if !strings.Contains(trace.String(), expected) {
+			t.Fatal(expected, trace.String())
+		}
+	}
+	// Nothing is traced without a trace
+	LoadingTrace = nil
+	trace.Reset()
+	GetTuningOptionsFrom("", fetchedDir, localDir)
+	if trace.Len() != 0 {
+		t.Fatal(trace.String())
+	}
+}
+
+func TestFilterParameters(t *testing.T) {
+	comparisons := map[string]NoteFieldComparison{
+		"ConfFilePath":                {ParamID: "ConfFilePath", ReflectFieldName: "ConfFilePath"},
+		"SysctlParams[vm.swappiness]": {ParamID: "SysctlParams[vm.swappiness]", ReflectFieldName: "SysctlParams", ReflectMapKey: "vm.swappiness"},
+		"VMSwappiness":                {ParamID: "VMSwappiness", ReflectFieldName: "VMSwappiness"},
+	}
+	params := FilterParameters(comparisons)
+	if len(params) != 2 || params["ConfFilePath"].ParamID != "" || !params["VMSwappiness"].IsParameter() {
+		t.Fatal(params)
+	}
+	if IsParameterField("SysconfigPrefix") || !IsParameterField("SysctlParams") {
+		t.Fatal("metadata field")
+	}
+}
+
 func TestCompareNoteFields(t *testing.T) {
 	// SUSESysOptimisation has a good mix of data types among its fields, hence it is chosen for this test.
 	systune := SUSESysOptimisation{
@@ -189,3 +254,167 @@ func TestCompareNoteFields(t *testing.T) {
 
 	}
 }
+
+func TestIsRebootRequired(t *testing.T) {
+	st := SUSESysOptimisation{}
+	if !IsRebootRequired(st, NoteFieldComparison{ReflectFieldName: "VMNumberHugePages"}) {
+		t.Fatal("hugepages")
+	}
+	if IsRebootRequired(st, NoteFieldComparison{ReflectFieldName: "VMSwappiness"}) {
+		t.Fatal("swappiness")
+	}
+	if IsRebootRequired(HANARecommendedOSSettings{}, NoteFieldComparison{ReflectFieldName: "KernelMMKsm"}) {
+		t.Fatal("ksm")
+	}
+}
+
+func TestNormaliseUnitValue(t *testing.T) {
+	for _, testCase := range []struct{ value, unit, expected string }{
+		{"1048576", UnitBytes, "1048576"},
+		{"1024 kB", UnitBytes, "1048576"},
+		{"1M", UnitBytes, "1048576"},
+		{"1,048,576", UnitBytes, "1048576"},
+		{" 1.5 GiB ", UnitBytes, "1610612736"},
+		{"2G", UnitKilobytes, "2097152"},
+		{"67584", UnitKilobytes, "67584"},
+		{"1000 B", UnitKilobytes, "1000 B"},
+		{"4096 16384 4194304", UnitBytes, "4096 16384 4194304"},
+		{"never", UnitBytes, "never"},
+		{"1024 kB", "", "1024 kB"},
+		{"1024 kB", "pages", "1024 kB"},
+	} {
+		if normalised := NormaliseUnitValue(testCase.value, testCase.unit); normalised != testCase.expected {
+			t.Fatal(testCase, normalised)
+		}
+	}
+}
+
+func TestComparisonParamID(t *testing.T) {
+	vend := INISettings{ID: "abc", SysctlParams: map[string]string{"vm.swappiness": "10"}}
+	_, comparisons := CompareNoteFields(vend, vend)
+	for paramID, comparison := range comparisons {
+		if comparison.ParamID != paramID {
+			t.Fatal(paramID, comparison)
+		}
+	}
+	if comparison := comparisons["SysctlParams[vm.swappiness]"]; comparison.Label() != "vm.swappiness" {
+		t.Fatal(comparison)
+	}
+	if comparison := comparisons["ID"]; comparison.Label() != "ID" {
+		t.Fatal(comparison)
+	}
+	if label := (NoteFieldComparison{ParamID: "Unknown"}).Label(); label != "Unknown" {
+		t.Fatal(label)
+	}
+	// Structured output carries the stable identifier
+	encoded, err := json.Marshal(comparisons["SysctlParams[vm.swappiness]"])
+	if err != nil || string(encoded) != `{"id":"SysctlParams[vm.swappiness]","field":"SysctlParams","map_key":"vm.swappiness","actual":"10","expected":"10","match":true}` {
+		t.Fatal(string(encoded), err)
+	}
+}
+
+func TestMarkNotApplicable(t *testing.T) {
+	defer func() { isMounted = system.IsMounted }()
+	actual := PrepareForSAPEnvironments{ShmFileSystemSizeMB: 1024}
+	expected := PrepareForSAPEnvironments{ShmFileSystemSizeMB: 2048}
+	// A mounted file system is verified as usual
+	isMounted = func(string) bool { return true }
+	_, comparisons := CompareNoteFields(actual, expected)
+	if MarkNotApplicable(actual, comparisons) || comparisons["ShmFileSystemSizeMB"].NotApplicable != "" {
+		t.Fatal(comparisons["ShmFileSystemSizeMB"])
+	}
+	// The parameter of a file system that is not mounted does not deviate
+	isMounted = func(mountPoint string) bool { return mountPoint != "/dev/shm" }
+	_, comparisons = CompareNoteFields(actual, expected)
+	if !MarkNotApplicable(actual, comparisons) || comparisons["ShmFileSystemSizeMB"].NotApplicable != NotMounted {
+		t.Fatal(comparisons["ShmFileSystemSizeMB"])
+	}
+	// Other parameters still deviate
+	expected.KernelShmMni = 1
+	_, comparisons = CompareNoteFields(actual, expected)
+	if MarkNotApplicable(actual, comparisons) {
+		t.Fatal(comparisons)
+	}
+}
+
+func TestMarkGovernedByCmdline(t *testing.T) {
+	defer func() { kernelCmdline = system.GetKernelCmdline }()
+	kernelCmdline = func() (map[string]string, error) {
+		return system.ParseKernelCmdline("root=/dev/sda1 hugepages=1024 quiet"), nil
+	}
+	actual := SUSESysOptimisation{VMNumberHugePages: 1024, VMSwappiness: 60}
+	expected := SUSESysOptimisation{VMNumberHugePages: 2048, VMSwappiness: 60}
+	_, comparisons := CompareNoteFields(actual, expected)
+	if !MarkNotApplicable(actual, comparisons) {
+		t.Fatal(comparisons)
+	}
+	if reason := comparisons["VMNumberHugePages"].NotApplicable; reason != "governed by the kernel cmdline hugepages=1024 and cannot be changed at runtime" {
+		t.Fatal(reason)
+	}
+	// A parameter that the kernel command line sets is verified as usual while it conforms
+	_, comparisons = CompareNoteFields(actual, actual)
+	if !MarkNotApplicable(actual, comparisons) || comparisons["VMNumberHugePages"].NotApplicable != "" {
+		t.Fatal(comparisons)
+	}
+	// A kernel command line setting the expected value does not excuse a deviation
+	kernelCmdline = func() (map[string]string, error) { return system.ParseKernelCmdline("hugepages=2048"), nil }
+	_, comparisons = CompareNoteFields(actual, expected)
+	if MarkNotApplicable(actual, comparisons) || comparisons["VMNumberHugePages"].NotApplicable != "" {
+		t.Fatal(comparisons)
+	}
+	// Without the kernel command line setting, the parameter deviates
+	kernelCmdline = func() (map[string]string, error) { return system.ParseKernelCmdline("quiet"), nil }
+	_, comparisons = CompareNoteFields(actual, expected)
+	if MarkNotApplicable(actual, comparisons) || comparisons["VMNumberHugePages"].NotApplicable != "" {
+		t.Fatal(comparisons)
+	}
+}
+
+// The parameters applied by the test notes below, "all" if a note was applied in full.
+var appliedTestParams []string
+
+// A note that can apply a subset of its parameters.
+type partialNote struct {
+	Params map[string]string
+}
+
+func (n partialNote) Initialise() (Note, error) { return n, nil }
+func (n partialNote) Optimise() (Note, error)   { return n, nil }
+func (n partialNote) Name() string              { return "partial" }
+func (n partialNote) Apply() error {
+	appliedTestParams = append(appliedTestParams, "all")
+	return nil
+}
+func (n partialNote) ApplyOnly(params []string) error {
+	appliedTestParams = append(appliedTestParams, params...)
+	return nil
+}
+
+// A note that can only be applied in full.
+type fullNote struct {
+	Param int
+}
+
+func (n fullNote) Initialise() (Note, error) { return n, nil }
+func (n fullNote) Optimise() (Note, error)   { return n, nil }
+func (n fullNote) Name() string              { return "full" }
+func (n fullNote) Apply() error {
+	appliedTestParams = append(appliedTestParams, "all")
+	return nil
+}
+
+func TestApplyDeviating(t *testing.T) {
+	appliedTestParams = nil
+	optimised := partialNote{Params: map[string]string{"a": "1", "b": "2"}}
+	_, comparisons := CompareNoteFields(partialNote{Params: map[string]string{"a": "1", "b": "1"}}, optimised)
+	skipped, err := ApplyDeviating(optimised, comparisons)
+	if err != nil || !reflect.DeepEqual(skipped, []string{"Params[a]"}) || !reflect.DeepEqual(appliedTestParams, []string{"b"}) {
+		t.Fatal(skipped, appliedTestParams, err)
+	}
+	// A note that cannot apply a subset of its parameters is applied in full
+	appliedTestParams = nil
+	_, comparisons = CompareNoteFields(fullNote{Param: 1}, fullNote{Param: 2})
+	if skipped, err := ApplyDeviating(fullNote{Param: 2}, comparisons); err != nil || len(skipped) != 0 || !reflect.DeepEqual(appliedTestParams, []string{"all"}) {
+		t.Fatal(skipped, appliedTestParams, err)
+	}
+}
@@ -6,6 +6,10 @@ A system can be tuned for more than one solutions at a time.
 package solution
 
 import (
+	"fmt"
+	"github.com/HouzuoGuo/saptune/txtparser"
+	"log"
+	"os"
 	"sort"
 )
 
@@ -18,6 +22,8 @@ const (
 
 type Solution []string // Solution is identified by set of note numbers.
 
+type Composite []string // Composite solution is identified by set of solution names.
+
 var AllSolutions = map[string]map[string]Solution{
 	ArchX86: {
 		"BOBJ":             {"1275776", "1984787", "SAP_BOBJ"},
@@ -62,3 +68,54 @@ func GetSortedSolutionNames(archName string) (ret []string) {
 	sort.Strings(ret)
 	return
 }
+
+/*
+Read composite solutions from a sysconfig-style file, each line defines a composite name and its member solutions,
+e.g. OURSTACK="HANA NETWEAVER". A composite must not hide a solution of the same name, and all of its members must
+be existing solutions, otherwise the composite is skipped. A missing file defines no composite.
+*/
+func GetCompositeSolutions(fileName string, solutions map[string]Solution) (ret map[string]Composite) {
+	ret = make(map[string]Composite)
+	conf, err := txtparser.ParseSysconfigFile(fileName, false)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		log.Printf("GetCompositeSolutions: failed to read composite solution definitions - %v", err)
+		return
+	}
+	for _, entry := range conf.AllValues {
+		members := conf.GetStringArray(entry.Key, []string{})
+		if err := validateComposite(entry.Key, members, solutions); err != nil {
+			log.Printf("GetCompositeSolutions: skip composite solution \"%s\" - %v", entry.Key, err)
+			continue
+		}
+		ret[entry.Key] = Composite(members)
+	}
+	return
+}
+
+// Return an error if the composite solution hides a solution or refers to a missing solution.
+func validateComposite(name string, members []string, solutions map[string]Solution) error {
+	if _, exists := solutions[name]; exists {
+		return fmt.Errorf("the name is already used by a solution")
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("no member solution is specified")
+	}
+	for _, member := range members {
+		if _, exists := solutions[member]; !exists {
+			return fmt.Errorf("member solution \"%s\" does not exist", member)
+		}
+	}
+	return nil
+}
+
+// Return all composite solution names, sorted alphabetically.
+func GetSortedCompositeNames(composites map[string]Composite) (ret []string) {
+	ret = make([]string, 0, len(composites))
+	for name := range composites {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return
+}
@@ -1,6 +1,10 @@
 package solution
 
 import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
 	"runtime"
 	"testing"
 )
@@ -10,3 +14,27 @@ func TestGetSortedSolutionIDs(t *testing.T) {
 		t.Fatal(GetSortedSolutionNames(runtime.GOARCH))
 	}
 }
+
+func TestGetCompositeSolutions(t *testing.T) {
+	confFile := path.Join(os.TempDir(), "saptune-test-composite")
+	defer os.Remove(confFile)
+	if composites := GetCompositeSolutions(confFile, AllSolutions[ArchX86]); len(composites) != 0 {
+		t.Fatal(composites)
+	}
+	content := `# composite solutions
+STACK="HANA NETWEAVER"
+HANA="HANA MAXDB"
+BROKEN="HANA DOES-NOT-EXIST"
+EMPTY=""
+`
+	if err := ioutil.WriteFile(confFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	composites := GetCompositeSolutions(confFile, AllSolutions[ArchX86])
+	if !reflect.DeepEqual(composites, map[string]Composite{"STACK": {"HANA", "NETWEAVER"}}) {
+		t.Fatal(composites)
+	}
+	if names := GetSortedCompositeNames(composites); !reflect.DeepEqual(names, []string{"STACK"}) {
+		t.Fatal(names)
+	}
+}
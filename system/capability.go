@@ -0,0 +1,39 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+/*
+CapabilityError reports a parameter that could not be written even by root, because the environment withholds the
+capability to do so, e.g. a container runtime that drops capabilities or mounts /proc/sys read-only.
+*/
+type CapabilityError struct {
+	Param      string // name of the parameter, e.g. "net.core.somaxconn"
+	Capability string // the capability that is presumably missing, e.g. "CAP_NET_ADMIN"
+	Err        error  // the failure to write the parameter
+}
+
+func (capErr CapabilityError) Error() string {
+	return fmt.Sprintf("parameter %s requires capability %s unavailable in this environment", capErr.Param, capErr.Capability)
+}
+
+func (capErr CapabilityError) Unwrap() error {
+	return capErr.Err
+}
+
+// Return true only if the error tells that writing was denied by permission rather than by the value written.
+func IsPermissionDenied(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EROFS)
+}
+
+// Return the capability that writing the sysctl parameter requires, e.g. CAP_NET_ADMIN for "net.core.somaxconn".
+func RequiredSysctlCapability(parameter string) string {
+	if strings.HasPrefix(parameter, "net.") {
+		return "CAP_NET_ADMIN"
+	}
+	return "CAP_SYS_ADMIN"
+}
@@ -0,0 +1,26 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestCapabilityError(t *testing.T) {
+	denied := &os.PathError{Op: "open", Path: "/proc/sys/net/core/somaxconn", Err: syscall.EROFS}
+	if !IsPermissionDenied(denied) || IsPermissionDenied(&os.PathError{Op: "write", Err: syscall.EINVAL}) || IsPermissionDenied(nil) {
+		t.Fatal("wrong classification")
+	}
+	var err error = CapabilityError{Param: "net.core.somaxconn", Capability: RequiredSysctlCapability("net.core.somaxconn"), Err: denied}
+	if err.Error() != "parameter net.core.somaxconn requires capability CAP_NET_ADMIN unavailable in this environment" {
+		t.Fatal(err)
+	}
+	var capErr CapabilityError
+	if !errors.As(err, &capErr) || !errors.Is(err, syscall.EROFS) {
+		t.Fatal(err)
+	}
+	if capability := RequiredSysctlCapability("vm.swappiness"); capability != "CAP_SYS_ADMIN" {
+		t.Fatal(capability)
+	}
+}
@@ -6,25 +6,71 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
-// Cal systemctl enable and then systemctl start on thing. Panic on error.
+var (
+	// SystemctlRetries is the number of times a transient systemctl failure is retried, e.g. while systemd is still settling after boot.
+	SystemctlRetries = 2
+	// SystemctlRetryInterval is the pause before the first retry, it doubles with every further retry.
+	SystemctlRetryInterval = 1 * time.Second
+
+	systemctlCommand = "systemctl"
+)
+
+/*
+Output of systemctl that indicates a failure which will not go away by retrying. A failed job, e.g. "Job for
+saptune.service failed because the control process exited with error code", has run the unit already, retrying would
+run it again.
+*/
+var definitiveSystemctlFailures = []string{"not found", "does not exist", "not loaded", "masked", "access denied", "unknown operation", "invalid argument", "job for"}
+
+// Return true only if the systemctl failure may go away by retrying, i.e. systemctl ran but did not report a definitive error.
+func isTransientSystemctlFailure(err error, out []byte) bool {
+	if _, ranSystemctl := err.(*exec.ExitError); !ranSystemctl {
+		return false
+	}
+	lowerOut := strings.ToLower(string(out))
+	for _, failure := range definitiveSystemctlFailures {
+		if strings.Contains(lowerOut, failure) {
+			return false
+		}
+	}
+	return true
+}
+
+// Call systemctl with the arguments, and retry with backoff as long as it fails transiently. Return the output of the last call.
+func callSystemctl(args ...string) (out []byte, err error) {
+	interval := SystemctlRetryInterval
+	for attempt := 0; ; attempt++ {
+		if out, err = exec.Command(systemctlCommand, args...).CombinedOutput(); err == nil {
+			return
+		}
+		if attempt >= SystemctlRetries || !isTransientSystemctlFailure(err, out) {
+			return
+		}
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+// Cal systemctl enable and then systemctl start on thing. Transient failures are retried.
 func SystemctlEnableStart(thing string) error {
-	if out, err := exec.Command("systemctl", "enable", thing).CombinedOutput(); err != nil {
+	if out, err := callSystemctl("enable", thing); err != nil {
 		return fmt.Errorf("Failed to call systemctl enable on %s - %v %s", thing, err, string(out))
 	}
-	if out, err := exec.Command("systemctl", "start", thing).CombinedOutput(); err != nil {
+	if out, err := callSystemctl("start", thing); err != nil {
 		return fmt.Errorf("Failed to call systemctl start on %s - %v %s", thing, err, string(out))
 	}
 	return nil
 }
 
-// Cal systemctl disable and then systemctl stop on thing. Panic on error.
+// Cal systemctl disable and then systemctl stop on thing. Transient failures are retried.
 func SystemctlDisableStop(thing string) error {
-	if out, err := exec.Command("systemctl", "disable", thing).CombinedOutput(); err != nil {
+	if out, err := callSystemctl("disable", thing); err != nil {
 		return fmt.Errorf("Failed to call systemctl disable on %s - %v %s", thing, err, string(out))
 	}
-	if out, err := exec.Command("systemctl", "stop", thing).CombinedOutput(); err != nil {
+	if out, err := callSystemctl("stop", thing); err != nil {
 		return fmt.Errorf("Failed to call systemctl stop on %s - %v %s", thing, err, string(out))
 	}
 	return nil
@@ -38,23 +84,6 @@ func SystemctlIsRunning(thing string) bool {
 	return false
 }
 
-// Call tuned-adm to switch to the specified profile. Panic on error.
-func TunedAdmProfile(profileName string) error {
-	if out, err := exec.Command("tuned-adm", "profile", profileName).CombinedOutput(); err != nil {
-		return fmt.Errorf("Failed to call tuned-adm to active profile %s - %v %s", profileName, err, string(out))
-	}
-	return nil
-}
-
-// Write new profile to tuned
-func WriteTunedAdmProfile(profileName string) error {
-	err := ioutil.WriteFile("/etc/tuned/active_profile", []byte(profileName), 0644)
-	if err != nil {
-		return fmt.Errorf("Failed to write tuned profile '%s' to '%s': %v", profileName, "/etc/tuned/active_profile", err)
-        }
-        return nil
-}
-
 // Return the currently active tuned profile. Return empty string if it cannot be determined.
 func GetTunedProfile() string {
 	content, err := ioutil.ReadFile("/etc/tuned/active_profile")
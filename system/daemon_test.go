@@ -0,0 +1,78 @@
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Let a shell script stand in for systemctl, and restore the defaults afterwards.
+func fakeSystemctl(t *testing.T, script string) (dir string, restore func()) {
+	dir, err := ioutil.TempDir("", "saptune-systemctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "systemctl"), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldCommand, oldInterval := systemctlCommand, SystemctlRetryInterval
+	systemctlCommand = path.Join(dir, "systemctl")
+	SystemctlRetryInterval = time.Millisecond
+	return dir, func() {
+		systemctlCommand, SystemctlRetryInterval = oldCommand, oldInterval
+		os.RemoveAll(dir)
+	}
+}
+
+func TestIsTransientSystemctlFailure(t *testing.T) {
+	exitErr := exec.Command("false").Run()
+	if !isTransientSystemctlFailure(exitErr, []byte("Failed to connect to bus: Connection refused")) {
+		t.Fatal("bus failure is not transient")
+	}
+	if isTransientSystemctlFailure(exitErr, []byte("Failed to enable unit: Unit file tuned.service does not exist.")) {
+		t.Fatal("missing unit is transient")
+	}
+	// The failed job has run the unit, e.g. saptune.service has applied the notes, it must not run again
+	if isTransientSystemctlFailure(exitErr, []byte("Job for saptune.service failed because the control process exited with error code.")) {
+		t.Fatal("failed job is transient")
+	}
+	if isTransientSystemctlFailure(exec.Command("/does/not/exist").Run(), nil) {
+		t.Fatal("missing systemctl is transient")
+	}
+}
+
+func TestSystemctlRetry(t *testing.T) {
+	// Fail twice, then succeed
+	dir, restore := fakeSystemctl(t, `echo x >> "$(dirname "$0")/calls"; [ $(wc -l < "$(dirname "$0")/calls") -gt 2 ]`)
+	defer restore()
+	if err := SystemctlEnableStart("tuned"); err != nil {
+		t.Fatal(err)
+	}
+	if calls, _ := ioutil.ReadFile(path.Join(dir, "calls")); strings.Count(string(calls), "x") != 4 {
+		t.Fatal(string(calls))
+	}
+	// Definitive failures are not retried
+	_, restore = fakeSystemctl(t, `echo x >> "$(dirname "$0")/calls"; echo "Unit tuned.service not found."; exit 5`)
+	defer restore()
+	if err := SystemctlEnableStart("tuned"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatal(err)
+	}
+	if calls, _ := ioutil.ReadFile(path.Join(path.Dir(systemctlCommand), "calls")); strings.Count(string(calls), "x") != 1 {
+		t.Fatal(string(calls))
+	}
+	// Give up after the configured number of retries
+	SystemctlRetries = 1
+	defer func() { SystemctlRetries = 2 }()
+	_, restore = fakeSystemctl(t, `echo x >> "$(dirname "$0")/calls"; exit 1`)
+	defer restore()
+	if err := SystemctlDisableStop("tuned"); err == nil {
+		t.Fatal("did not error")
+	}
+	if calls, _ := ioutil.ReadFile(path.Join(path.Dir(systemctlCommand), "calls")); strings.Count(string(calls), "x") != 2 {
+		t.Fatal(string(calls))
+	}
+}
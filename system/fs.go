@@ -108,6 +108,16 @@ func ParseMtabMounts() MountPoints {
 	return ParseMounts(string(mounts))
 }
 
+// Return true only if a file system is mounted at the mount point. If /proc/mounts cannot be read, assume it is mounted.
+func IsMounted(mountPoint string) bool {
+	mounts, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return true
+	}
+	_, found := ParseMounts(string(mounts)).GetByMountPoint(mountPoint)
+	return found
+}
+
 // Invoke mount command to resize /dev/shm to the specified value.
 func RemountSHM(newSizeMB uint64) error {
 	cmd := exec.Command("mount", "-o", fmt.Sprintf("remount,size=%dM", newSizeMB), "/dev/shm")
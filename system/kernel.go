@@ -0,0 +1,92 @@
+package system
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ProcCmdline holds the command line that the running kernel was booted with.
+const ProcCmdline = "/proc/cmdline"
+
+var kernelVersionSeparator = regexp.MustCompile("[^0-9]+")
+
+// Return the release of the running kernel, e.g. "5.14.21-150400.24.46-default".
+func GetKernelVersion() (string, error) {
+	return GetSysctlString("kernel.osrelease")
+}
+
+// Split a kernel version into its numeric components, e.g. "4.12.14-122.37-default" into 4 12 14 122 37.
+func splitKernelVersion(version string) []int {
+	components := make([]int, 0, 8)
+	for _, field := range kernelVersionSeparator.Split(version, -1) {
+		if number, err := strconv.Atoi(field); err == nil {
+			components = append(components, number)
+		}
+	}
+	return components
+}
+
+/*
+Compare the kernel version against the reference version, only as far as the reference version goes. Return -1 if
+the version is older, 1 if it is newer, and 0 if it belongs to the reference version, e.g. "5.3.18-57-default"
+belongs to "5.3".
+*/
+func CompareKernelVersions(version, reference string) int {
+	components := splitKernelVersion(version)
+	for i, refComponent := range splitKernelVersion(reference) {
+		component := 0
+		if i < len(components) {
+			component = components[i]
+		}
+		if component < refComponent {
+			return -1
+		} else if component > refComponent {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Return the parameters of the command line that the running kernel was booted with, see ParseKernelCmdline.
+func GetKernelCmdline() (map[string]string, error) {
+	content, err := ioutil.ReadFile(ProcCmdline)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKernelCmdline(string(content)), nil
+}
+
+/*
+Parse a kernel command line into parameter name VS value, e.g. "hugepages=1024 quiet" into hugepages VS 1024 and quiet
+VS empty value. Quotes around a value are removed. Of a parameter given more than once, the last value is kept.
+*/
+func ParseKernelCmdline(cmdline string) map[string]string {
+	ret := make(map[string]string)
+	// Spaces within quotes do not separate parameters, e.g. dyndbg="file a.c +p"
+	fields := make([]string, 0, 16)
+	var field strings.Builder
+	inQuotes := false
+	for _, r := range cmdline + " " {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if inQuotes || !unicode.IsSpace(r) {
+			field.WriteRune(r)
+		} else if field.Len() > 0 {
+			fields = append(fields, field.String())
+			field.Reset()
+		}
+	}
+	for _, field := range fields {
+		nameValue := strings.SplitN(field, "=", 2)
+		if len(nameValue) == 2 {
+			ret[nameValue[0]] = strings.Trim(nameValue[1], `"`)
+		} else {
+			ret[nameValue[0]] = ""
+		}
+	}
+	return ret
+}
@@ -0,0 +1,43 @@
+package system
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareKernelVersions(t *testing.T) {
+	for _, tc := range []struct {
+		version, reference string
+		expected           int
+	}{
+		{"5.3.18-57-default", "5.3", 0},
+		{"5.3.18-57-default", "5.3.18", 0},
+		{"5.3.18-57-default", "5.3.19", -1},
+		{"5.3.18-57-default", "5.4", -1},
+		{"5.14.21-150400.24.46-default", "5.3", 1},
+		{"4.12.14-122.37-default", "4.12.14-122.40", -1},
+		{"5", "5.0.1", -1},
+	} {
+		if result := CompareKernelVersions(tc.version, tc.reference); result != tc.expected {
+			t.Fatal(tc, result)
+		}
+	}
+	if version, err := GetKernelVersion(); err != nil || CompareKernelVersions(version, "2.6") < 0 {
+		t.Fatal(version, err)
+	}
+}
+
+func TestParseKernelCmdline(t *testing.T) {
+	cmdline := "BOOT_IMAGE=/boot/vmlinuz-5.14.21 root=UUID=abc hugepages=1024 quiet dyndbg=\"file a.c +p\" numa_balancing=disable hugepages=2048\n"
+	expected := map[string]string{
+		"BOOT_IMAGE":     "/boot/vmlinuz-5.14.21",
+		"root":           "UUID=abc",
+		"hugepages":      "2048",
+		"quiet":          "",
+		"dyndbg":         "file a.c +p",
+		"numa_balancing": "disable",
+	}
+	if params := ParseKernelCmdline(cmdline); !reflect.DeepEqual(params, expected) {
+		t.Fatal(params)
+	}
+}
@@ -0,0 +1,33 @@
+// Inspect loaded kernel modules.
+package system
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// Return names of all kernel modules listed in the text of /proc/modules.
+func ParseProcModules(txt string) (modules []string) {
+	modules = make([]string, 0, 0)
+	for _, line := range strings.Split(txt, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			modules = append(modules, fields[0])
+		}
+	}
+	return
+}
+
+// Return true only if the kernel module is loaded. Dashes and underscores in module name are interchangeable.
+func IsKernelModuleLoaded(name string) bool {
+	content, err := ioutil.ReadFile("/proc/modules")
+	if err != nil {
+		return false
+	}
+	name = strings.Replace(name, "-", "_", -1)
+	for _, module := range ParseProcModules(string(content)) {
+		if module == name {
+			return true
+		}
+	}
+	return false
+}
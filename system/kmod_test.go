@@ -0,0 +1,18 @@
+package system
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProcModules(t *testing.T) {
+	txt := `br_netfilter 24576 0 - Live 0x0000000000000000
+bridge 155648 1 br_netfilter, Live 0x0000000000000000
+`
+	if modules := ParseProcModules(txt); !reflect.DeepEqual(modules, []string{"br_netfilter", "bridge"}) {
+		t.Fatal(modules)
+	}
+	if IsKernelModuleLoaded("this-module-does-not-exist") {
+		t.Fatal("module found")
+	}
+}
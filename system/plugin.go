@@ -0,0 +1,65 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+/*
+A plugin is an executable supplied by a vendor to inspect and apply a parameter that saptune cannot handle by itself.
+saptune runs the plugin once per action, writes a PluginRequest as JSON into its standard input, and reads a
+PluginResponse as JSON from its standard output. A plugin reports failure by exiting with a non-zero status, the
+standard error output then describes the failure.
+*/
+const (
+	PluginActionVerify = "verify" // report the current value of the parameter
+	PluginActionApply  = "apply"  // set the parameter to the value
+	PluginActionRevert = "revert" // restore the value the parameter had before it was applied
+)
+
+// PluginTimeout is the time a plugin may take for an action, it is killed afterwards.
+var PluginTimeout = 30 * time.Second
+
+// The request written into the standard input of a plugin.
+type PluginRequest struct {
+	Action    string `json:"action"`
+	Parameter string `json:"parameter"`
+	Value     string `json:"value"` // the value to apply or restore, empty for verify
+}
+
+// The response read from the standard output of a plugin.
+type PluginResponse struct {
+	Value string `json:"value"` // the current value of the parameter, only for verify
+}
+
+// Run the plugin executable for the request and return its response.
+func RunPlugin(pluginPath string, req PluginRequest) (resp PluginResponse, err error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), PluginTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, pluginPath)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); ctx.Err() == context.DeadlineExceeded {
+		return resp, fmt.Errorf("plugin %s did not %s parameter %s within %v and has been killed", pluginPath, req.Action, req.Parameter, PluginTimeout)
+	} else if err != nil {
+		return resp, fmt.Errorf("plugin %s failed to %s parameter %s - %v %s", pluginPath, req.Action, req.Parameter, err, strings.TrimSpace(stderr.String()))
+	}
+	if req.Action != PluginActionVerify && len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return
+	}
+	if err = json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return resp, fmt.Errorf("plugin %s responded to %s of parameter %s with malformed output - %v", pluginPath, req.Action, req.Parameter, err)
+	}
+	return
+}
@@ -0,0 +1,54 @@
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPlugin(t *testing.T) {
+	pluginDir := path.Join(os.TempDir(), "saptune-test-plugin")
+	defer os.RemoveAll(pluginDir)
+	os.MkdirAll(pluginDir, 0755)
+	pluginPath := path.Join(pluginDir, "sample")
+	script := `#!/bin/sh
+read request
+case "$request" in
+	*'"action":"verify"'*) echo '{"value":"current"}' ;;
+	*'"value":"bad"'*) echo "bad value" >&2; exit 1 ;;
+esac
+`
+	if err := ioutil.WriteFile(pluginPath, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := RunPlugin(pluginPath, PluginRequest{Action: PluginActionVerify, Parameter: "p"}); err != nil || resp.Value != "current" {
+		t.Fatal(resp, err)
+	}
+	if _, err := RunPlugin(pluginPath, PluginRequest{Action: PluginActionApply, Parameter: "p", Value: "good"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunPlugin(pluginPath, PluginRequest{Action: PluginActionApply, Parameter: "p", Value: "bad"}); err == nil {
+		t.Fatal("did not error")
+	}
+	if _, err := RunPlugin(path.Join(pluginDir, "does-not-exist"), PluginRequest{Action: PluginActionVerify}); err == nil {
+		t.Fatal("did not error")
+	}
+	// A plugin that hangs is killed
+	if err := ioutil.WriteFile(pluginPath, []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(timeout time.Duration) {
+		PluginTimeout = timeout
+	}(PluginTimeout)
+	PluginTimeout = 100 * time.Millisecond
+	start := time.Now()
+	if _, err := RunPlugin(pluginPath, PluginRequest{Action: PluginActionVerify, Parameter: "p"}); err == nil || !strings.Contains(err.Error(), "killed") {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatal(elapsed)
+	}
+}
@@ -0,0 +1,16 @@
+// Inspect installed software packages.
+package system
+
+import (
+	"os/exec"
+)
+
+var rpmCommand = "rpm"
+
+/*
+Return true only if the software package is installed according to the RPM package database. The package is
+considered missing if the database cannot be queried.
+*/
+func IsPackageInstalled(name string) bool {
+	return exec.Command(rpmCommand, "--query", "--quiet", name).Run() == nil
+}
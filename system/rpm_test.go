@@ -0,0 +1,36 @@
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestIsPackageInstalled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "saptune-rpm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The fake package database only knows package "tuned"
+	if err := ioutil.WriteFile(path.Join(dir, "rpm"), []byte("#!/bin/sh\n[ \"$3\" = tuned ]\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldCommand := rpmCommand
+	defer func() {
+		rpmCommand = oldCommand
+	}()
+	rpmCommand = path.Join(dir, "rpm")
+	if !IsPackageInstalled("tuned") {
+		t.Fatal("tuned is not installed")
+	}
+	if IsPackageInstalled("sapdb") {
+		t.Fatal("sapdb is installed")
+	}
+	// Without package database, no package is installed
+	rpmCommand = path.Join(dir, "does-not-exist")
+	if IsPackageInstalled("tuned") {
+		t.Fatal("tuned is installed")
+	}
+}
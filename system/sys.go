@@ -45,7 +45,9 @@ func GetSysInt(parameter string) (int, error) {
 
 // Write a string /sys/ value.
 func SetSysString(parameter, value string) error {
-	if err := ioutil.WriteFile(path.Join("/sys", strings.Replace(parameter, ".", "/", -1)), []byte(value), 0644); err != nil {
+	if err := ioutil.WriteFile(path.Join("/sys", strings.Replace(parameter, ".", "/", -1)), []byte(value), 0644); IsPermissionDenied(err) {
+		return CapabilityError{Param: parameter, Capability: "CAP_SYS_ADMIN", Err: err}
+	} else if err != nil {
 		return fmt.Errorf("failed to set sys key '%s' to string '%s': %v", parameter, value, err)
 	}
 	return nil
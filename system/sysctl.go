@@ -2,15 +2,20 @@
 package system
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// SysctlDropInDir is read by systemd-sysctl upon boot.
+const SysctlDropInDir = "/etc/sysctl.d"
+
 const (
 	SysctlPagecacheLimitMB          = "vm.pagecache_limit_mb"
 	SysctlPagecacheLimitIgnoreDirty = "vm.pagecache_limit_ignore_dirty"
@@ -72,6 +77,12 @@ func GetSysctlString(parameter string) (string, error) {
 	return strings.TrimSpace(string(val)), nil
 }
 
+// Return true only if the sysctl key exists on the running kernel.
+func IsSysctlAvailable(parameter string) bool {
+	_, err := GetSysctlString(parameter)
+	return err == nil
+}
+
 // Read an integer sysctl key.
 func GetSysctlInt(parameter string) (int, error) {
 	value, err := GetSysctlString(parameter)
@@ -111,6 +122,8 @@ func SetSysctlString(parameter, value string) error {
 	err := ioutil.WriteFile(path.Join("/proc/sys", strings.Replace(parameter, ".", "/", -1)), []byte(value), 0644)
 	if os.IsNotExist(err) {
 		log.Printf("sysctl key '%s' is not supported by os, skipping.", parameter)
+	} else if IsPermissionDenied(err) {
+		return CapabilityError{Param: parameter, Capability: RequiredSysctlCapability(parameter), Err: err}
 	} else if err != nil {
 		return fmt.Errorf("Failed to write sysctl key '%s': %v", parameter, err)
 	}
@@ -152,3 +165,21 @@ func IsPagecacheAvailable() bool {
         }
         return false
 }
+
+// Write sysctl parameter values into a drop-in file in sysctl.conf syntax, sorted by parameter name.
+func WriteSysctlDropIn(fileName, comment string, values map[string]string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var content bytes.Buffer
+	content.WriteString("# " + comment + "\n")
+	for _, key := range keys {
+		content.WriteString(fmt.Sprintf("%s = %s\n", key, values[key]))
+	}
+	if err := os.MkdirAll(path.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, content.Bytes(), 0644)
+}
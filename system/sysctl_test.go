@@ -1,6 +1,11 @@
 package system
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
 
 func TestReadSysctl(t *testing.T) {
 	if value, err := GetSysctlInt("vm.max_map_count"); err != nil {
@@ -23,3 +28,14 @@ func TestReadSysctl(t *testing.T) {
 		t.Fatal(value)
 	}
 }
+
+func TestWriteSysctlDropIn(t *testing.T) {
+	fileName := path.Join(os.TempDir(), "saptune-test-sysctl", "99-test.conf")
+	defer os.RemoveAll(path.Dir(fileName))
+	if err := WriteSysctlDropIn(fileName, "test", map[string]string{"vm.swappiness": "10", "kernel.sem": "250\t32000"}); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(fileName); err != nil || string(content) != "# test\nkernel.sem = 250\t32000\nvm.swappiness = 10\n" {
+		t.Fatal(string(content), err)
+	}
+}
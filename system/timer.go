@@ -0,0 +1,43 @@
+// Schedule commands to run later in transient systemd timer units.
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+var (
+	systemdRunCommand = "systemd-run"
+	systemdRuntimeDir = "/run/systemd/system" // present only if the system is booted with systemd
+)
+
+// Return true only if the system is booted with systemd, hence commands can be scheduled.
+func IsSystemdBooted() bool {
+	info, err := os.Stat(systemdRuntimeDir)
+	return err == nil && info.IsDir()
+}
+
+/*
+Run the command once after the delay in a transient service unit of the name, triggered by a transient timer unit of
+the same name. The units vanish once the command has run, or when the system reboots before.
+*/
+func ScheduleCommand(unitName string, delay time.Duration, command ...string) error {
+	if !IsSystemdBooted() {
+		return fmt.Errorf("the system is not booted with systemd, hence commands cannot be scheduled")
+	}
+	args := append([]string{"--unit=" + unitName, fmt.Sprintf("--on-active=%ds", int64(delay/time.Second)), "--timer-property=AccuracySec=1s"}, command...)
+	if out, err := exec.Command(systemdRunCommand, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("Failed to call systemd-run to schedule %s - %v %s", unitName, err, string(out))
+	}
+	return nil
+}
+
+// Cancel the command scheduled in the transient units of the name, unless it has already run.
+func CancelScheduledCommand(unitName string) error {
+	if out, err := callSystemctl("stop", unitName+".timer"); err != nil {
+		return fmt.Errorf("Failed to call systemctl stop on %s.timer - %v %s", unitName, err, string(out))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "saptune-systemd-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The fake systemd-run records its arguments
+	argsFile := path.Join(dir, "args")
+	if err := ioutil.WriteFile(path.Join(dir, "systemd-run"), []byte("#!/bin/sh\necho \"$@\" > "+argsFile+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldCommand, oldRuntimeDir := systemdRunCommand, systemdRuntimeDir
+	defer func() {
+		systemdRunCommand, systemdRuntimeDir = oldCommand, oldRuntimeDir
+	}()
+	systemdRunCommand = path.Join(dir, "systemd-run")
+	// Without systemd nothing is scheduled
+	systemdRuntimeDir = path.Join(dir, "does-not-exist")
+	if IsSystemdBooted() {
+		t.Fatal("systemd is booted")
+	}
+	if err := ScheduleCommand("saptune-test", time.Hour, "/usr/sbin/saptune", "note", "revert", "1001"); err == nil {
+		t.Fatal("did not error")
+	}
+	systemdRuntimeDir = dir
+	if err := ScheduleCommand("saptune-test", 2*time.Hour, "/usr/sbin/saptune", "note", "revert", "1001"); err != nil {
+		t.Fatal(err)
+	}
+	if args, err := ioutil.ReadFile(argsFile); err != nil || strings.TrimSpace(string(args)) != "--unit=saptune-test --on-active=7200s --timer-property=AccuracySec=1s /usr/sbin/saptune note revert 1001" {
+		t.Fatal(string(args), err)
+	}
+}
@@ -59,7 +59,71 @@ func ParseINI(input string) *INIFile {
 	currentSection := ""
 	currentEntriesArray := make([]INIEntry, 0, 8)
 	currentEntriesMap := make(map[string]INIEntry)
-	for _, line := range strings.Split(input, "\n") {
+	scanINI(input, func(section string) {
+		// Save previous section
+		if currentSection != "" {
+			ret.KeyValue[currentSection] = currentEntriesMap
+			ret.AllValues = append(ret.AllValues, currentEntriesArray...)
+		}
+		// Start a new section
+		currentSection = section
+		currentEntriesArray = make([]INIEntry, 0, 8)
+		currentEntriesMap = make(map[string]INIEntry)
+	}, func(_ int, entry INIEntry) {
+		currentEntriesArray = append(currentEntriesArray, entry)
+		currentEntriesMap[entry.Key] = entry
+	})
+	// Save last section
+	if currentSection != "" {
+		ret.KeyValue[currentSection] = currentEntriesMap
+		ret.AllValues = append(ret.AllValues, currentEntriesArray...)
+	}
+	return ret
+}
+
+// A key defined more than once in the same section of an INI file, the last definition takes effect.
+type INIDuplicate struct {
+	Section string
+	Key     string
+	Lines   []int    // line numbers of the definitions, counting from 1
+	Values  []string // values of the definitions, in the order of the lines
+}
+
+// Return the keys defined more than once in the same section, in the order of their first definition.
+func FindINIDuplicates(input string) []INIDuplicate {
+	ret := make([]INIDuplicate, 0, 0)
+	seen := make(map[string]map[string]int) // section VS key VS index of the first definition in defs
+	defs := make([]INIDuplicate, 0, 64)
+	currentSection := ""
+	scanINI(input, func(section string) {
+		currentSection = section
+	}, func(lineNum int, entry INIEntry) {
+		if seen[currentSection] == nil {
+			seen[currentSection] = make(map[string]int)
+		}
+		if i, exists := seen[currentSection][entry.Key]; exists {
+			defs[i].Lines = append(defs[i].Lines, lineNum)
+			defs[i].Values = append(defs[i].Values, entry.Value)
+			return
+		}
+		seen[currentSection][entry.Key] = len(defs)
+		defs = append(defs, INIDuplicate{Section: currentSection, Key: entry.Key, Lines: []int{lineNum}, Values: []string{entry.Value}})
+	})
+	for _, def := range defs {
+		if len(def.Lines) > 1 {
+			ret = append(ret, def)
+		}
+	}
+	return ret
+}
+
+/*
+Go through the lines of INI text, call onSection with the name of each section header and onEntry with the line number
+(counting from 1) and the content of each key-value pair. Comments, empty, and irregular lines are skipped.
+*/
+func scanINI(input string, onSection func(section string), onEntry func(lineNum int, entry INIEntry)) {
+	currentSection := ""
+	for i, line := range strings.Split(input, "\n") {
 		line = strings.TrimSpace(line)
 		if len(line) == 0 {
 			continue
@@ -73,15 +137,8 @@ func ParseINI(input string) *INIFile {
 		}
 
 		if line[0] == '[' {
-			// Save previous section
-			if currentSection != "" {
-				ret.KeyValue[currentSection] = currentEntriesMap
-				ret.AllValues = append(ret.AllValues, currentEntriesArray...)
-			}
-			// Start a new section
 			currentSection = line[1 : len(line)-1]
-			currentEntriesArray = make([]INIEntry, 0, 8)
-			currentEntriesMap = make(map[string]INIEntry)
+			onSection(currentSection)
 			continue
 		}
 		// Break apart a line into key, operator, value.
@@ -92,19 +149,11 @@ func ParseINI(input string) *INIFile {
 		}
 		// handle tunables with more than one value
 		value := strings.Replace(kov[3], " ", "\t", -1)
-		entry := INIEntry{
+		onEntry(i+1, INIEntry{
 			Section:  currentSection,
 			Key:      kov[1],
 			Operator: Operator(kov[2]),
 			Value:    value,
-		}
-		currentEntriesArray = append(currentEntriesArray, entry)
-		currentEntriesMap[entry.Key] = entry
+		})
 	}
-	// Save last section
-	if currentSection != "" {
-		ret.KeyValue[currentSection] = currentEntriesMap
-		ret.AllValues = append(ret.AllValues, currentEntriesArray...)
-	}
-	return ret
 }
@@ -104,3 +104,28 @@ func TestParseINI(t *testing.T) {
 		t.Fatalf("\n%+v\n%+v\n", *actualINI, expectedINI)
 	}
 }
+
+func TestFindINIDuplicates(t *testing.T) {
+	if dups := FindINIDuplicates(iniExample); len(dups) != 0 {
+		t.Fatal(dups)
+	}
+	input := `[a]
+x = 1
+y = 2
+# x = 3
+x = 3
+[b]
+x = 4
+y = 2
+[a]
+y = 2
+`
+	dups := FindINIDuplicates(input)
+	expected := []INIDuplicate{
+		{Section: "a", Key: "x", Lines: []int{2, 5}, Values: []string{"1", "3"}},
+		{Section: "a", Key: "y", Lines: []int{3, 10}, Values: []string{"2", "2"}},
+	}
+	if !reflect.DeepEqual(dups, expected) {
+		t.Fatalf("%+v", dups)
+	}
+}